@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"social-network/pkg/db"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a logged-in device as shown to its owner; the token itself is never exposed
+type Session struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+	Current   bool   `json:"current"` // the session making the request
+}
+
+// ListSessions returns the user's unexpired sessions, newest first, marking the one holding currentToken
+func ListSessions(userID, currentToken string) ([]Session, error) {
+	rows, err := db.DB.Query(`
+        SELECT id, created_at, expires_at, user_agent, ip_address, token = ?
+        FROM sessions
+        WHERE user_id = ? AND datetime(expires_at) > datetime('now')
+        ORDER BY created_at DESC
+    `, currentToken, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.CreatedAt, &s.ExpiresAt, &s.UserAgent, &s.IPAddress, &s.Current); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession deletes one of the user's sessions; requests using its token fail from then on
+func RevokeSession(userID, sessionID string) error {
+	result, err := db.DB.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
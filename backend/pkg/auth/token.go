@@ -62,7 +62,8 @@ func InvalidateToken(tokenString string) error {
 	return nil
 }
 
-func GenerateToken(userID string) (string, error) {
+// GenerateToken creates a session for the user, recording the client it was created from
+func GenerateToken(userID, userAgent, ipAddress string) (string, error) {
 	// Generate a new session token
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
@@ -76,8 +77,8 @@ func GenerateToken(userID string) (string, error) {
 	sessionID := uuid.New().String()
 
 	// Strore in database
-	_, err := db.DB.Exec("INSERT INTO sessions (id, user_id, token, expires_at, created_at) VALUES (?, ?, ?, ?, ?)", 
-        sessionID, userID, token, expiresAt, time.Now())
+	_, err := db.DB.Exec("INSERT INTO sessions (id, user_id, token, expires_at, created_at, user_agent, ip_address) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		sessionID, userID, token, expiresAt, time.Now(), userAgent, ipAddress)
 
 	return token, err
 }
\ No newline at end of file
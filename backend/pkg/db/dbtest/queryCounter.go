@@ -0,0 +1,66 @@
+// Package dbtest holds helpers shared by the tests of packages that use the database.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// QueryCounter counts the statements run on its databases whose query passes the filter,
+// e.g. to check that a list is loaded without one query per item
+type QueryCounter struct {
+	filter func(query string) bool
+	count  int64
+}
+
+func NewQueryCounter(filter func(query string) bool) *QueryCounter {
+	return &QueryCounter{filter: filter}
+}
+
+// Open opens the SQLite database at dsn with its statements counted by c
+func (c *QueryCounter) Open(dsn string) *sql.DB {
+	return sql.OpenDB(countingConnector{dsn: dsn, counter: c})
+}
+
+func (c *QueryCounter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+func (c *QueryCounter) Reset() {
+	atomic.StoreInt64(&c.count, 0)
+}
+
+type countingConnector struct {
+	dsn     string
+	counter *QueryCounter
+}
+
+func (c countingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{Conn: conn, counter: c.counter}, nil
+}
+
+func (countingConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// countingConn hides the driver's Queryer interfaces so database/sql prepares
+// every statement, which lets Prepare count them
+type countingConn struct {
+	driver.Conn
+	counter *QueryCounter
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	if c.counter.filter(query) {
+		atomic.AddInt64(&c.counter.count, 1)
+	}
+	return c.Conn.Prepare(query)
+}
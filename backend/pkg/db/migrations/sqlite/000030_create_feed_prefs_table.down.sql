@@ -0,0 +1 @@
+DROP TABLE IF EXISTS feed_prefs;
\ No newline at end of file
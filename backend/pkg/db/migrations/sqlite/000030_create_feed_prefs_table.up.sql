@@ -0,0 +1,7 @@
+-- Per-user home feed preferences
+CREATE TABLE IF NOT EXISTS feed_prefs (
+    user_id          TEXT    PRIMARY KEY,
+    hide_group_posts INTEGER NOT NULL DEFAULT 0,
+    updated_at       TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS post_mutes;
\ No newline at end of file
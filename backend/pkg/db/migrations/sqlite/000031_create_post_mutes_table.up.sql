@@ -0,0 +1,10 @@
+-- Muting a user's posts hides them from the muter's feed without touching the follow relationship
+CREATE TABLE IF NOT EXISTS post_mutes (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    muter_id   TEXT    NOT NULL,
+    muted_id   TEXT    NOT NULL,
+    created_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(muter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(muted_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(muter_id, muted_id)
+);
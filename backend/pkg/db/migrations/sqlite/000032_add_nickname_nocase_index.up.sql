@@ -0,0 +1,10 @@
+-- Nicknames are unique regardless of case ("Alice" and "alice" are the same account)
+-- Existing case variants keep the oldest account's nickname; the others get "_<rowid>" appended,
+-- which no valid nickname contains, and can pick a new one from their profile
+UPDATE users SET nickname = nickname || '_' || rowid
+WHERE EXISTS (
+    SELECT 1 FROM users older
+    WHERE older.nickname = users.nickname COLLATE NOCASE
+        AND (older.created_at < users.created_at OR (older.created_at = users.created_at AND older.rowid < users.rowid))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_nickname_nocase ON users(nickname COLLATE NOCASE);
@@ -0,0 +1 @@
+-- Data repair only: the original privacy values are not kept, nothing to revert
\ No newline at end of file
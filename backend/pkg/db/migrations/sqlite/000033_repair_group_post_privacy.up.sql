@@ -0,0 +1,6 @@
+-- Posts with privacy='group' but no group never match the group membership join; keep them with the author's followers
+UPDATE posts SET privacy = 'followers' WHERE privacy = 'group' AND group_id IS NULL;
+
+-- Posts tied to a group are restricted to that group; custom audiences do not apply to group posts
+DELETE FROM post_allowed_followers WHERE post_id IN (SELECT id FROM posts WHERE privacy != 'group' AND group_id IS NOT NULL);
+UPDATE posts SET privacy = 'group' WHERE privacy != 'group' AND group_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Deleted posts are kept for a while so their author can restore them
+ALTER TABLE posts ADD COLUMN deleted_at TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at);
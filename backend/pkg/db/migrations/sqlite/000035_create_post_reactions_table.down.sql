@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_reactions_post_id;
+DROP TABLE IF EXISTS post_reactions;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_hashtags_tag;
+DROP TABLE IF EXISTS post_hashtags;
\ No newline at end of file
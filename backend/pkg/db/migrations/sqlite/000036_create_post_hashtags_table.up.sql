@@ -0,0 +1,9 @@
+-- Hashtags parsed from post content, stored lowercase without the leading #
+CREATE TABLE IF NOT EXISTS post_hashtags (
+    post_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (post_id, tag),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_tag ON post_hashtags(tag);
\ No newline at end of file
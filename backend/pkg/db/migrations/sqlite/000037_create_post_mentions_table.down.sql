@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_mentions_user_id;
+DROP TABLE IF EXISTS post_mentions;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- Users mentioned with @nickname in a post
+CREATE TABLE IF NOT EXISTS post_mentions (
+    post_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_mentions_user_id ON post_mentions(user_id);
\ No newline at end of file
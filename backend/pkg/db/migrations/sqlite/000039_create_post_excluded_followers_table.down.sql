@@ -0,0 +1 @@
+DROP TABLE IF EXISTS post_excluded_followers;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Followers hidden from a followers or custom post, takes precedence over post_allowed_followers
+CREATE TABLE IF NOT EXISTS post_excluded_followers (
+    post_id INTEGER NOT NULL,
+    follower_id TEXT NOT NULL,
+    PRIMARY KEY (post_id, follower_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
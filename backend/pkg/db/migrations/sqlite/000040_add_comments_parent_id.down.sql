@@ -0,0 +1,3 @@
+-- Remove threaded replies from comments table
+DROP INDEX IF EXISTS idx_comments_parent_id;
+ALTER TABLE comments DROP COLUMN parent_id;
\ No newline at end of file
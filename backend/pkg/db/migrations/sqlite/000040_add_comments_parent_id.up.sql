@@ -0,0 +1,5 @@
+-- Threaded replies: a reply points at the comment it answers.
+-- Deleting a comment cascades to its replies and their replies in turn.
+ALTER TABLE comments ADD COLUMN parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Remove edit tracking from messages table
+ALTER TABLE messages DROP COLUMN edited_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Track when a chat message was last edited, NULL for messages never edited
+ALTER TABLE messages ADD COLUMN edited_at TEXT;
\ No newline at end of file
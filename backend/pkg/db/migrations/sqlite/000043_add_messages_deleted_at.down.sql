@@ -0,0 +1,2 @@
+-- Remove soft delete from messages table
+ALTER TABLE messages DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Soft delete chat messages, deleted messages are shown as a placeholder
+ALTER TABLE messages ADD COLUMN deleted_at TEXT;
\ No newline at end of file
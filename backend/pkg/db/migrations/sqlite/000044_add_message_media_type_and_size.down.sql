@@ -0,0 +1,4 @@
+-- Remove attachment metadata from message_media table
+DROP INDEX IF EXISTS idx_message_media_message_id;
+ALTER TABLE message_media DROP COLUMN size;
+ALTER TABLE message_media DROP COLUMN media_type;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Structured attachment metadata for chat messages
+ALTER TABLE message_media ADD COLUMN media_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE message_media ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_message_media_message_id ON message_media(message_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Timestamps stay in UTC RFC3339: they describe the same instants and are parsed fine,
+-- the original formatting can't be restored.
+SELECT 1;
\ No newline at end of file
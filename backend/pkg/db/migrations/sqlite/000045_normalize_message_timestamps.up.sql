@@ -0,0 +1,14 @@
+-- Normalize message timestamps to UTC RFC3339 (e.g. 2025-09-19T16:37:47Z).
+-- Naive rows (2025-09-19 16:37:47) were written by CURRENT_TIMESTAMP and are already UTC,
+-- rows with an offset (2025-09-19T19:37:47+03:00) are converted to UTC.
+UPDATE messages
+SET created_at = strftime('%Y-%m-%dT%H:%M:%SZ', created_at)
+WHERE strftime('%Y-%m-%dT%H:%M:%SZ', created_at) IS NOT NULL;
+
+UPDATE messages
+SET edited_at = strftime('%Y-%m-%dT%H:%M:%SZ', edited_at)
+WHERE strftime('%Y-%m-%dT%H:%M:%SZ', edited_at) IS NOT NULL;
+
+UPDATE messages
+SET deleted_at = strftime('%Y-%m-%dT%H:%M:%SZ', deleted_at)
+WHERE strftime('%Y-%m-%dT%H:%M:%SZ', deleted_at) IS NOT NULL;
\ No newline at end of file
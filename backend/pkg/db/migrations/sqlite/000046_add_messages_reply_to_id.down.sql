@@ -0,0 +1,2 @@
+-- Remove replies from messages table
+ALTER TABLE messages DROP COLUMN reply_to_id;
\ No newline at end of file
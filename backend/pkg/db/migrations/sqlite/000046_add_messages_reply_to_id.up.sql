@@ -0,0 +1,2 @@
+-- Let a chat message reply to (quote) an earlier message of the same chat
+ALTER TABLE messages ADD COLUMN reply_to_id INTEGER REFERENCES messages(id) ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Drop blocked_users table
+DROP INDEX IF EXISTS idx_blocked_users_blocked_id;
+DROP TABLE IF EXISTS blocked_users;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Remove latest-message index
+DROP INDEX IF EXISTS idx_messages_chat_id_created_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Speeds up finding the latest message of each chat for the paged chat list
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id_created_at ON messages(chat_id, created_at);
\ No newline at end of file
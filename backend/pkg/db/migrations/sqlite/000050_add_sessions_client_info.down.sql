@@ -0,0 +1,3 @@
+-- Remove client info from sessions table
+ALTER TABLE sessions DROP COLUMN ip_address;
+ALTER TABLE sessions DROP COLUMN user_agent;
\ No newline at end of file
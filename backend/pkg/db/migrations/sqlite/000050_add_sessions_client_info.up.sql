@@ -0,0 +1,3 @@
+-- Remember which device and address each session was created from
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Remove group avatar and cover images
+ALTER TABLE groups DROP COLUMN cover_path;
+ALTER TABLE groups DROP COLUMN avatar_path;
\ No newline at end of file
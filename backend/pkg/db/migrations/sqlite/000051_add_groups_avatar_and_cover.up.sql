@@ -0,0 +1,3 @@
+-- Group avatar and cover images, empty means the default image
+ALTER TABLE groups ADD COLUMN avatar_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE groups ADD COLUMN cover_path TEXT NOT NULL DEFAULT '';
\ No newline at end of file
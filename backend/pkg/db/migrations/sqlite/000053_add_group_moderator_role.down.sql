@@ -0,0 +1,23 @@
+ALTER TABLE posts DROP COLUMN deleted_by;
+
+-- Remove 'moderator' from allowed group membership roles (moderators become members)
+
+UPDATE group_memberships SET role = 'member' WHERE role = 'moderator';
+
+CREATE TABLE group_memberships_old (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_id   INTEGER NOT NULL,
+    user_id    TEXT    NOT NULL,
+    role       TEXT    NOT NULL CHECK(role IN ('member','admin')),
+    joined_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id)  REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(group_id, user_id)
+);
+
+INSERT INTO group_memberships_old (id, group_id, user_id, role, joined_at)
+SELECT id, group_id, user_id, role, joined_at
+FROM group_memberships;
+
+DROP TABLE group_memberships;
+ALTER TABLE group_memberships_old RENAME TO group_memberships;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- Add 'moderator' to allowed group membership roles
+
+CREATE TABLE group_memberships_new (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_id   INTEGER NOT NULL,
+    user_id    TEXT    NOT NULL,
+    role       TEXT    NOT NULL CHECK(role IN ('member','moderator','admin')),
+    joined_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id)  REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(group_id, user_id)
+);
+
+INSERT INTO group_memberships_new (id, group_id, user_id, role, joined_at)
+SELECT id, group_id, user_id, role, joined_at
+FROM group_memberships;
+
+DROP TABLE group_memberships;
+ALTER TABLE group_memberships_new RENAME TO group_memberships;
+
+-- Who removed a post, so a post taken down by a group moderator can't be restored by its author
+ALTER TABLE posts ADD COLUMN deleted_by TEXT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_group_requests_pending;
\ No newline at end of file
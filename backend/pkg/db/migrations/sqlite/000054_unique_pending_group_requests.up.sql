@@ -0,0 +1,12 @@
+-- Only one pending join request per user and group; duplicates beyond the first are dropped
+DELETE FROM group_requests
+WHERE status = 'pending'
+  AND id NOT IN (
+    SELECT MIN(id) FROM group_requests
+    WHERE status = 'pending'
+    GROUP BY group_id, requester_id
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_requests_pending
+    ON group_requests(group_id, requester_id)
+    WHERE status = 'pending';
\ No newline at end of file
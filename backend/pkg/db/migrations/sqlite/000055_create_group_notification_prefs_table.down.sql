@@ -0,0 +1 @@
+DROP TABLE IF EXISTS group_notification_prefs;
\ No newline at end of file
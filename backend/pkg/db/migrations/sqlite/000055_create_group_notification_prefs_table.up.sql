@@ -0,0 +1,10 @@
+-- Per-group notification settings; a muted group sends no join request, invitation or event notifications to the user
+CREATE TABLE IF NOT EXISTS group_notification_prefs (
+    group_id   INTEGER NOT NULL,
+    user_id    TEXT    NOT NULL,
+    muted      INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id),
+    FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id)  REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Remove pinned group posts
+ALTER TABLE groups DROP COLUMN pinned_post_id;
\ No newline at end of file
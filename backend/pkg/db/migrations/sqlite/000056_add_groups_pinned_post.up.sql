@@ -0,0 +1,2 @@
+-- One announcement post per group shown above the rest of the group feed
+ALTER TABLE groups ADD COLUMN pinned_post_id INTEGER NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS notification_prefs;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- Notification types a user opted out of; types without a row are enabled
+CREATE TABLE IF NOT EXISTS notification_prefs (
+    user_id    TEXT    NOT NULL,
+    type       TEXT    NOT NULL,
+    enabled    INTEGER NOT NULL DEFAULT 1,
+    updated_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, type),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
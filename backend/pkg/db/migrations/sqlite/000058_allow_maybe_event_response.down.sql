@@ -0,0 +1,21 @@
+-- Remove 'maybe' from allowed event responses (maybe responses are dropped)
+
+DELETE FROM event_responses WHERE response = 'maybe';
+
+CREATE TABLE event_responses_old (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id     INTEGER NOT NULL,
+    user_id      TEXT    NOT NULL,
+    response     TEXT    NOT NULL CHECK(response IN ('going','not_going')),
+    responded_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id)  REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(event_id, user_id)
+);
+
+INSERT INTO event_responses_old (id, event_id, user_id, response, responded_at)
+SELECT id, event_id, user_id, response, responded_at
+FROM event_responses;
+
+DROP TABLE event_responses;
+ALTER TABLE event_responses_old RENAME TO event_responses;
\ No newline at end of file
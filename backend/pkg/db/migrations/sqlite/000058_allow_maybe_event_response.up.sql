@@ -0,0 +1,19 @@
+-- Add 'maybe' to allowed event responses
+
+CREATE TABLE event_responses_new (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id     INTEGER NOT NULL,
+    user_id      TEXT    NOT NULL,
+    response     TEXT    NOT NULL CHECK(response IN ('going','not_going','maybe')),
+    responded_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id)  REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(event_id, user_id)
+);
+
+INSERT INTO event_responses_new (id, event_id, user_id, response, responded_at)
+SELECT id, event_id, user_id, response, responded_at
+FROM event_responses;
+
+DROP TABLE event_responses;
+ALTER TABLE event_responses_new RENAME TO event_responses;
\ No newline at end of file
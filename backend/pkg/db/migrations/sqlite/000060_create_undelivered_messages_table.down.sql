@@ -0,0 +1 @@
+DROP TABLE IF EXISTS undelivered_messages;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- WebSocket messages (notifications, chat messages, invitations) sent while the recipient
+-- had no connection; delivered and removed when the recipient connects again
+CREATE TABLE IF NOT EXISTS undelivered_messages (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    TEXT    NOT NULL,
+    payload    TEXT    NOT NULL,
+    created_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_undelivered_messages_user ON undelivered_messages(user_id, id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Remove last-seen tracking from users table
+ALTER TABLE users DROP COLUMN last_seen;
\ No newline at end of file
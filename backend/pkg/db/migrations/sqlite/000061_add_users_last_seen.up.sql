@@ -0,0 +1,2 @@
+-- When the user last connected or disconnected, RFC3339 in UTC; NULL for users never online
+ALTER TABLE users ADD COLUMN last_seen TEXT;
\ No newline at end of file
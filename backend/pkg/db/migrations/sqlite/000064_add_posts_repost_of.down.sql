@@ -0,0 +1,4 @@
+-- Remove reposts
+DROP INDEX IF EXISTS idx_posts_author_repost_of;
+DELETE FROM posts WHERE repost_of IS NOT NULL;
+ALTER TABLE posts DROP COLUMN repost_of;
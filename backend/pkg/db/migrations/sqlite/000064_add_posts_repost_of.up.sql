@@ -0,0 +1,4 @@
+-- A repost is a post without content of its own that reshares another user's public post;
+-- a user can have one live repost of a post
+ALTER TABLE posts ADD COLUMN repost_of INTEGER NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_author_repost_of ON posts(author_id, repost_of) WHERE repost_of IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,9 @@
+-- Users who opened a post, once per user; the author's own views are not recorded
+CREATE TABLE IF NOT EXISTS post_views (
+    post_id   INTEGER NOT NULL,
+    user_id   TEXT    NOT NULL,
+    viewed_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_views_post_user ON post_views(post_id, user_id);
@@ -0,0 +1,8 @@
+-- Recent failed logins per identifier ('identifier:<email or nickname>') and per client IP
+-- ('ip:<address>'); a key is locked out until locked_until after too many failures
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_key     TEXT    PRIMARY KEY,
+    failures        INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TEXT    NOT NULL,
+    locked_until    TEXT
+);
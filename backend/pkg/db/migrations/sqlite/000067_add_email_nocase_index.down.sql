@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_users_email_nocase;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- Emails are unique regardless of case ("User@Example.com" and "user@example.com" are the same account).
+-- Existing case variants keep the oldest account's email; the others get ".duplicate-<rowid>" appended,
+-- which keeps them unique, and can still log in with their nickname and change it from their profile
+UPDATE users SET email = LOWER(TRIM(email)) || '.duplicate-' || rowid
+WHERE EXISTS (
+    SELECT 1 FROM users older
+    WHERE LOWER(TRIM(older.email)) = LOWER(TRIM(users.email))
+        AND (older.created_at < users.created_at OR (older.created_at = users.created_at AND older.rowid < users.rowid))
+);
+UPDATE users SET email = LOWER(TRIM(email));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_nocase ON users(email COLLATE NOCASE);
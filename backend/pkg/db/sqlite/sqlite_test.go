@@ -1,10 +1,13 @@
 package sqlite_test
 
 import (
+	"database/sql"
 	"fmt"
 	"path/filepath"
 	"social-network/pkg/db/sqlite"
 	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestRunMigrations(t *testing.T) {
@@ -21,3 +24,71 @@ func TestRunMigrations(t *testing.T) {
 		t.Fatalf("Failed to run migrations: %v", err)
 	}
 }
+
+// migrateWithUsers migrates a new database to version, adds the users and runs the
+// remaining migrations
+func migrateWithUsers(t *testing.T, version uint, users [][2]string) *sql.DB {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	migrationDir := "../migrations/sqlite"
+	if err := sqlite.RunMigrations(dbPath, migrationDir); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	if err := sqlite.RollbackToVersion(dbPath, migrationDir, version); err != nil {
+		t.Fatalf("Failed to roll back to version %d: %v", version, err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for i, u := range users {
+		_, err := db.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, created_at)
+			VALUES (?, ?, 'x', 'First', 'Last', '2000-01-01', ?, ?)`, fmt.Sprint("u", i), u[0], u[1], fmt.Sprintf("2020-01-0%d 00:00:00", i+1))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	if err := sqlite.RunMigrations(dbPath, migrationDir); err != nil {
+		t.Fatalf("Failed to run the remaining migrations: %v", err)
+	}
+	return db
+}
+
+func TestNicknameIndexMigrationRenamesCaseVariants(t *testing.T) {
+	db := migrateWithUsers(t, 31, [][2]string{{"a@example.com", "alice"}, {"b@example.com", "Alice"}})
+
+	var nickname string
+	if err := db.QueryRow("SELECT nickname FROM users WHERE id = 'u1'").Scan(&nickname); err != nil {
+		t.Fatalf("Failed to get nickname: %v", err)
+	}
+	if nickname == "Alice" {
+		t.Errorf("expected the newer case variant to be renamed")
+	}
+}
+
+func TestEmailIndexMigrationRenamesCaseVariants(t *testing.T) {
+	db := migrateWithUsers(t, 66, [][2]string{{"User@Example.com", "alice"}, {"user@example.com", "bob"}, {"Other@Example.com", "carol"}})
+
+	want := map[string]string{"u0": "user@example.com", "u2": "other@example.com"}
+	for id, email := range want {
+		var got string
+		if err := db.QueryRow("SELECT email FROM users WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatalf("Failed to get email: %v", err)
+		}
+		if got != email {
+			t.Errorf("expected %s to have email %q, got %q", id, email, got)
+		}
+	}
+
+	var renamed string
+	if err := db.QueryRow("SELECT email FROM users WHERE id = 'u1'").Scan(&renamed); err != nil {
+		t.Fatalf("Failed to get email: %v", err)
+	}
+	if renamed == "user@example.com" {
+		t.Errorf("expected the newer case variant to be renamed")
+	}
+}
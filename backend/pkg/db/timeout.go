@@ -0,0 +1,16 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// QueryTimeout bounds the database work done for one request, so a query stuck behind
+// a write lock fails instead of holding on to the handler goroutine
+const QueryTimeout = 5 * time.Second
+
+// WithQueryTimeout returns a context for database calls that is cancelled after QueryTimeout
+// or when parent is, e.g. when the client of an HTTP request goes away
+func WithQueryTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, QueryTimeout)
+}
@@ -2,52 +2,109 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"social-network/pkg/db"
 	"social-network/pkg/models/comment"
+	"social-network/pkg/models/post"
+	"social-network/pkg/sockets/websocket"
 	"social-network/pkg/utils"
 )
 
-// handler for creating a new comment
-func CommentHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+// notifyPostComment tells the post's author about a new comment, unless they wrote it themselves.
+// Group posts are handled the same way: only the post author is notified.
+func notifyPostComment(hub *websocket.Hub, c comment.Comment) {
+	postID, err := strconv.ParseInt(c.PostID, 10, 64)
+	if err != nil {
 		return
 	}
 
-	// Get the user ID from the context (set by auth middleware)
-	userID := r.Context().Value("userID").(string)
-	if userID == "" {
-		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+	authorID, err := post.NewPostService(db.DB).GetPostAuthor(postID)
+	if err != nil {
+		log.Printf("Error getting author of post %s: %v", c.PostID, err)
 		return
 	}
-
-	var newComment comment.Comment
-	if err := json.NewDecoder(r.Body).Decode(&newComment); err != nil {
-		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+	if authorID == c.AuthorID {
 		return
 	}
 
-	// Set the author ID from the authenticated user
-	newComment.AuthorID = userID
+	websocket.SendPostCommentNotification(hub, c.AuthorID, authorID, c.PostID)
+}
 
-	// validate the comment
-	if err := comment.ValidateComment(newComment); err != nil {
-		utils.WriteErrorJSON(w, "Invalid comment: "+err.Error(), http.StatusBadRequest)
-		return
+// writeCommentOwnershipError maps a missing comment to 404 and a non-author to 403,
+// reporting whether it wrote a response
+func writeCommentOwnershipError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, comment.ErrCommentNotFound):
+		utils.WriteErrorJSON(w, "Comment not found", http.StatusNotFound)
+	case errors.Is(err, comment.ErrNotCommentOwner):
+		utils.WriteErrorJSON(w, "Unauthorized: you are not the author of this comment", http.StatusForbidden)
+	default:
+		return false
 	}
+	return true
+}
 
-	createdComment, err := comment.CreateComment(db.DB, newComment)
-	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to create comment: "+err.Error(), http.StatusInternalServerError)
-		return
+// handler for creating a new comment; the post author is notified of the new comment
+func CommentHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Get the user ID from the context (set by auth middleware)
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var newComment comment.Comment
+		if err := json.NewDecoder(r.Body).Decode(&newComment); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Set the author ID from the authenticated user
+		newComment.AuthorID = userID
+		newComment.Content = strings.TrimSpace(newComment.Content)
+
+		// validate the comment
+		if err := comment.ValidateComment(newComment); err != nil {
+			utils.WriteErrorJSON(w, "Invalid comment: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		newComment.Content = utils.SanitizeText(newComment.Content)
+
+		createdComment, err := comment.CreateComment(db.DB, newComment)
+		if err != nil {
+			if errors.Is(err, comment.ErrParentNotFound) {
+				utils.WriteErrorJSON(w, "Invalid comment: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, post.ErrPostNotFound) {
+				utils.WriteErrorJSON(w, "Post not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, post.ErrPostDeleted) {
+				utils.WriteErrorJSON(w, "This post has been deleted", http.StatusGone)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to create comment: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		go notifyPostComment(hub, createdComment)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdComment)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdComment)
 }
 
 // handler for updating an existing comment
@@ -72,16 +129,21 @@ func UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Ensure the user can only update their own comments
 	updatedComment.AuthorID = userID
+	updatedComment.Content = strings.TrimSpace(updatedComment.Content)
 
 	// validate the updated comment
 	if err := comment.ValidateComment(updatedComment); err != nil {
 		utils.WriteErrorJSON(w, "Invalid comment: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	updatedComment.Content = utils.SanitizeText(updatedComment.Content)
 
 	// Update the comment in the database
 	updated, err := comment.UpdateComment(db.DB, updatedComment)
 	if err != nil {
+		if writeCommentOwnershipError(w, err) {
+			return
+		}
 		utils.WriteErrorJSON(w, "Failed to update comment: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -117,6 +179,9 @@ func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
 	// Delete the comment from the database
 	err := comment.DeleteComment(db.DB, deletedComment)
 	if err != nil {
+		if writeCommentOwnershipError(w, err) {
+			return
+		}
 		utils.WriteErrorJSON(w, "Failed to delete comment: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -149,6 +214,9 @@ func GetCommentsByPostIDHandler(w http.ResponseWriter, r *http.Request) {
 		utils.WriteErrorJSON(w, "Invalid offset value", http.StatusBadRequest)
 		return
 	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
 
 	limitStr := r.URL.Query().Get("limit")
 	if limitStr == "" {
@@ -173,13 +241,7 @@ func GetCommentsByPostIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(comments) == 0 {
-		utils.WriteErrorJSON(w, "No comments found for the given post ID", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+	utils.WritePaginatedJSON(w, comments, len(comments) >= limit, utils.TotalUnknown, offset, limit)
 }
 
 // handler for liking/unliking a comment
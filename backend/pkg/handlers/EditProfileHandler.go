@@ -2,12 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"social-network/pkg/models/follow"
 	"social-network/pkg/models/user"
 	"social-network/pkg/utils"
 	"strings"
-	"social-network/pkg/models/follow"
 )
 
 type EditProfileResponse struct {
@@ -29,21 +29,38 @@ func EditProfileHandler(w http.ResponseWriter, r *http.Request, fs follow.Follow
 		return
 	}
 
-	var req user.EditProfileRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req, err := user.ParseEditProfileRequest(fields)
+	if err != nil {
 		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Validate the request
-	if err := validateEditProfileRequest(&req); err != nil {
-		utils.WriteErrorJSON(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+	if err := validateEditProfileRequest(userID, req); err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, validationErrorsStatus(validationErrs))
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to validate profile: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	sanitizeEditProfileRequest(req)
 
 	// Update the user profile
-	err := user.UpdateUserProfile(userID, &req, &fs)
+	err = user.UpdateUserProfile(userID, req, &fs)
 	if err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, validationErrorsStatus(validationErrs))
+			return
+		}
 		utils.WriteErrorJSON(w, "Failed to update profile: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -59,59 +76,196 @@ func EditProfileHandler(w http.ResponseWriter, r *http.Request, fs follow.Follow
 	json.NewEncoder(w).Encode(response)
 }
 
-// validateEditProfileRequest validates the profile edit request
-func validateEditProfileRequest(req *user.EditProfileRequest) error {
+// PatchProfileHandler updates only the profile fields present in the request body
+func PatchProfileHandler(w http.ResponseWriter, r *http.Request, fs follow.FollowService) {
+	if r.Method != http.MethodPatch {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get the user ID from the context (set by auth middleware)
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req, err := user.ParsePatchProfileRequest(fields)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Sending the current nickname or email back is not a change, so skip the uniqueness checks for them
+	if req.Nickname != nil || req.Email != nil {
+		current, err := user.GetUserByID(userID, userID)
+		if err == nil {
+			if req.Nickname != nil && strings.EqualFold(current.Nickname, *req.Nickname) {
+				req.Nickname = nil
+			}
+			if req.Email != nil && current.Email == user.NormalizeEmail(*req.Email) {
+				req.Email = nil
+			}
+		}
+	}
+
+	// Validate only the provided fields
+	if err := validateEditProfileRequest(userID, req); err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, validationErrorsStatus(validationErrs))
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to validate profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sanitizeEditProfileRequest(req)
+
+	if err := user.UpdateUserProfile(userID, req, &fs); err != nil {
+		if err.Error() == "no fields provided to update" {
+			utils.WriteErrorJSON(w, "No changes to apply", http.StatusBadRequest)
+			return
+		}
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, validationErrorsStatus(validationErrs))
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to update profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := user.GetUserByID(userID, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Profile updated but failed to load it: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, updated, http.StatusOK)
+}
+
+// ProfileVisibilityHandler switches the user's profile between public and private
+func ProfileVisibilityHandler(w http.ResponseWriter, r *http.Request, fs follow.FollowService) {
+	if r.Method != http.MethodPut {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		IsPublic *bool `json:"is_public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.IsPublic == nil {
+		utils.WriteErrorJSON(w, "is_public is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := user.SetProfileVisibility(userID, *req.IsPublic, &fs); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to update profile visibility: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]bool{"is_public": *req.IsPublic}, http.StatusOK)
+}
+
+// sanitizeEditProfileRequest escapes the free text profile fields once they are validated
+func sanitizeEditProfileRequest(req *user.EditProfileRequest) {
+	if req.AboutMe != nil {
+		aboutMe := utils.SanitizeText(*req.AboutMe)
+		req.AboutMe = &aboutMe
+	}
+}
+
+// validateEditProfileRequest validates the profile edit request of userID; every failing field
+// is reported in the returned utils.ValidationErrors. The email is normalized before it is checked.
+func validateEditProfileRequest(userID string, req *user.EditProfileRequest) error {
+	var errs utils.ValidationErrors
+
+	// check runs a user validator and records its failure under field
+	check := func(field string, valid bool, err error) error {
+		if valid {
+			return nil
+		}
+		return user.AddValidationError(&errs, field, err)
+	}
+
 	if req.FirstName != nil {
-		if valid, err := user.ValidateName(*req.FirstName, "dummy"); !valid {
+		valid, err := user.ValidateFirstName(*req.FirstName)
+		if err := check("first_name", valid, err); err != nil {
 			return err
 		}
 	}
 	if req.LastName != nil {
-		if valid, err := user.ValidateName("dummy", *req.LastName); !valid {
+		valid, err := user.ValidateLastName(*req.LastName)
+		if err := check("last_name", valid, err); err != nil {
 			return err
 		}
 	}
 	if req.Nickname != nil {
-		if valid, err := user.ValidateNickname(*req.Nickname); !valid {
+		valid, err := user.ValidateNickname(*req.Nickname)
+		if err := check("nickname", valid, err); err != nil {
 			return err
 		}
 	}
 	if req.Email != nil {
-		if valid, err := user.ValidateEmail(*req.Email); !valid {
+		email := user.NormalizeEmail(*req.Email)
+		req.Email = &email
+		valid, err := user.ValidateEmail(email)
+		if err := check("email", valid, err); err != nil {
 			return err
 		}
+		if valid {
+			taken, err := user.EmailTakenByOther(email, userID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				errs.Add("email", utils.CodeTaken, user.ErrEmailAlreadyExists.Error())
+			}
+		}
 	}
 	if req.AboutMe != nil {
-		if valid, err := user.ValidateAboutMe(*req.AboutMe); !valid {
+		valid, err := user.ValidateAboutMe(*req.AboutMe)
+		if err := check("about_me", valid, err); err != nil {
 			return err
 		}
 	}
 	if req.DOB != nil {
-		if valid, err := user.ValidateDOB(*req.DOB); !valid {
-			return err
-		}
-	}
-
-	// Password change validation
-	if req.NewPassword != nil || req.ConfirmNewPassword != nil || req.OldPassword != nil {
-		if req.OldPassword == nil || req.NewPassword == nil || req.ConfirmNewPassword == nil {
-			return fmt.Errorf("all password fields (old_password, new_password, confirm_new_password) are required to change password")
-		}
-		if *req.NewPassword != *req.ConfirmNewPassword {
-			return fmt.Errorf("new password and confirm new password do not match")
-		}
-		if valid, err := user.ValidatePassword(*req.NewPassword); !valid {
+		valid, err := user.ValidateDOB(*req.DOB)
+		if err := check("dob", valid, err); err != nil {
 			return err
 		}
 	}
 
-	if req.AvatarPath != nil {
+	if req.RemoveAvatar && req.AvatarPath != nil {
+		errs.Add("avatar_path", utils.CodeInvalid, "cannot set and remove the avatar at the same time")
+	} else if req.AvatarPath != nil {
 		// Basic validation for avatar path
 		if *req.AvatarPath != "" && !strings.HasPrefix(*req.AvatarPath, "/uploads/") {
-			return fmt.Errorf("invalid avatar path format")
+			errs.Add("avatar_path", utils.CodeInvalid, "invalid avatar path format")
 		}
 	}
 
 	// is_public is a bool, no validation needed unless you want to restrict values
-	return nil
+	return errs.Err()
 }
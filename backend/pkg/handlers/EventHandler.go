@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"social-network/pkg/db"
 	"social-network/pkg/models/event"
@@ -46,9 +48,94 @@ func CreateEventHandler(hub *websocket.Hub) http.HandlerFunc {
 	}
 }
 
-// Handler for Creating/Updating Event Responses
+// Handler for editing an event's title, description and time; the event creator and group admins can
+func EditEventHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var changes event.Event
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if changes.ID == "" {
+			utils.WriteErrorJSON(w, "Missing event id", http.StatusBadRequest)
+			return
+		}
+		if err := changes.ValidateEventUpdate(); err != nil {
+			utils.WriteErrorJSON(w, "Invalid event: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := event.EditEvent(db.DB, changes.ID, userID, changes, hub)
+		if err != nil {
+			writeEventManageError(w, err, "Failed to edit event: ")
+			return
+		}
+
+		utils.WriteSuccessJSON(w, updated, http.StatusOK)
+	}
+}
+
+// Handler for deleting an event; its attendees are told it was cancelled
+func DeleteEventHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			EventID string `json:"event_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.EventID == "" {
+			utils.WriteErrorJSON(w, "Missing event_id", http.StatusBadRequest)
+			return
+		}
+
+		if err := event.DeleteEvent(db.DB, req.EventID, userID, hub); err != nil {
+			writeEventManageError(w, err, "Failed to delete event: ")
+			return
+		}
+
+		utils.WriteSuccessJSON(w, "Event deleted successfully", http.StatusOK)
+	}
+}
+
+// writeEventManageError maps the errors of EditEvent and DeleteEvent to responses
+func writeEventManageError(w http.ResponseWriter, err error, prefix string) {
+	switch {
+	case errors.Is(err, event.ErrEventNotFound):
+		utils.WriteErrorJSON(w, "Event not found", http.StatusNotFound)
+	case errors.Is(err, event.ErrNotEventManager):
+		utils.WriteErrorJSON(w, "Only the event creator or a group admin can change this event", http.StatusForbidden)
+	default:
+		utils.WriteErrorJSON(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Handler for Creating/Updating Event Responses; a user has at most one response per event
 func CreateEventResponseHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -69,23 +156,146 @@ func CreateEventResponseHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Validate the event response (but skip duplicate check since we'll handle updates)
 	if err := newEventResponse.ValidateEventResponse(db.DB); err != nil {
-		utils.WriteErrorJSON(w, "Invalid event response: "+err.Error(), http.StatusBadRequest)
+		switch {
+		case errors.Is(err, event.ErrEventNotFound):
+			utils.WriteErrorJSON(w, "Event not found", http.StatusNotFound)
+		case errors.Is(err, event.ErrNotGroupMember):
+			utils.WriteErrorJSON(w, "You must be a member of the group to respond to its events", http.StatusForbidden)
+		default:
+			utils.WriteErrorJSON(w, "Invalid event response: "+err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
-	// Use REPLACE to update existing response or create new one
-	query := `
-        REPLACE INTO event_responses (event_id, user_id, response) 
-        VALUES (?, ?, ?)
-    `
-
-	_, err := db.DB.Exec(query, newEventResponse.EventID, newEventResponse.UserID, newEventResponse.Response)
+	saved, err := event.UpdateEventResponse(db.DB, newEventResponse)
 	if err != nil {
 		utils.WriteErrorJSON(w, "Failed to record event response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	utils.WriteSuccessJSON(w, "Event response recorded successfully", http.StatusCreated)
+	utils.WriteSuccessJSON(w, saved, http.StatusOK)
+}
+
+// Handler for listing who responded to an event: /api/event/attendees?eventId=123
+func GetEventAttendeesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	eventID := r.URL.Query().Get("eventId")
+	if eventID == "" {
+		utils.WriteErrorJSON(w, "Missing eventId query parameter", http.StatusBadRequest)
+		return
+	}
+
+	attendees, err := event.GetEventAttendees(db.DB, eventID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, event.ErrEventNotFound):
+			utils.WriteErrorJSON(w, "Event not found", http.StatusNotFound)
+		case errors.Is(err, event.ErrNotGroupMember):
+			utils.WriteErrorJSON(w, "You must be a member of the group to see its event attendees", http.StatusForbidden)
+		default:
+			utils.WriteErrorJSON(w, "Failed to fetch attendees: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.WriteSuccessJSON(w, attendees, http.StatusOK)
+}
+
+// Range of the upcoming events calendar: a month by default, at most a year
+const (
+	defaultUpcomingRange = 30 * 24 * time.Hour
+	maxUpcomingRange     = 366 * 24 * time.Hour
+)
+
+// Handler for the events of all the user's groups: /api/events/me?from=...&to=...&tz=...
+// from and to are RFC3339 times or YYYY-MM-DD dates; dates are read in the tz time zone
+// (an IANA name, UTC by default) and to includes the whole day. from defaults to now and
+// to to a month after from.
+func GetMyUpcomingEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			utils.WriteErrorJSON(w, "Invalid tz parameter: must be an IANA time zone name", http.StatusBadRequest)
+			return
+		}
+	}
+
+	from := time.Now()
+	if s := r.URL.Query().Get("from"); s != "" {
+		t, err := parseEventRangeBound(s, loc, false)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Invalid from parameter: must be RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	to := from.Add(defaultUpcomingRange)
+	if s := r.URL.Query().Get("to"); s != "" {
+		t, err := parseEventRangeBound(s, loc, true)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Invalid to parameter: must be RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	if !to.After(from) {
+		utils.WriteErrorJSON(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxUpcomingRange {
+		utils.WriteErrorJSON(w, "Date range can't be longer than a year", http.StatusBadRequest)
+		return
+	}
+
+	events, err := event.GetUserUpcomingEvents(db.DB, userID, from, to)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to fetch events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]interface{}{
+		"events": events,
+		"from":   from.UTC().Format(time.RFC3339),
+		"to":     to.UTC().Format(time.RFC3339),
+	}, http.StatusOK)
+}
+
+// parseEventRangeBound parses an RFC3339 time, or a YYYY-MM-DD date in loc; as an end
+// bound a date stands for the end of that day, i.e. the start of the next one
+func parseEventRangeBound(s string, loc *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
 }
 
 // Handler for Getting Events for a Group
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"social-network/pkg/db"
 	"social-network/pkg/sockets/websocket"
 	"social-network/pkg/utils"
+	"strconv"
+	"strings"
 )
 
 func CreatePrivateChatHandler(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +42,10 @@ func CreatePrivateChatHandler(w http.ResponseWriter, r *http.Request) {
 	chatService := websocket.NewChatService(db.DB)
 	chatRoom, err := chatService.GetOrCreatePrivateChat(userID, req.UserID)
 	if err != nil {
+		if errors.Is(err, websocket.ErrUserBlocked) {
+			utils.WriteErrorJSON(w, "You cannot message this user", http.StatusForbidden)
+			return
+		}
 		utils.WriteErrorJSON(w, "Failed to create/fetch private chat: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -46,3 +53,190 @@ func CreatePrivateChatHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(chatRoom)
 }
+
+// LeaveChatHandler removes the user from an ad-hoc chat.
+// Chats that belong to a group cannot be left this way: users stay in the group chat
+// for as long as they are group members and should mute it instead.
+func LeaveChatHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			ChatID string `json:"chat_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ChatID == "" {
+			utils.WriteErrorJSON(w, "chat_id is required", http.StatusBadRequest)
+			return
+		}
+
+		chatService := websocket.NewChatService(db.DB)
+		if err := chatService.LeaveChat(userID, req.ChatID); err != nil {
+			switch {
+			case errors.Is(err, websocket.ErrChatNotFound):
+				utils.WriteErrorJSON(w, "Chat not found", http.StatusNotFound)
+			case errors.Is(err, websocket.ErrNotChatParticipant):
+				utils.WriteErrorJSON(w, "You are not a participant of this chat", http.StatusForbidden)
+			case errors.Is(err, websocket.ErrGroupChatLeave):
+				utils.WriteErrorJSON(w, "This chat belongs to a group: mute it to stop notifications, or leave the group to leave the chat", http.StatusConflict)
+			default:
+				utils.WriteErrorJSON(w, "Failed to leave chat: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Refresh the chat list on the user's open connections
+		ctx, cancel := db.WithQueryTimeout(r.Context())
+		defer cancel()
+		if chats, err := chatService.GetUserChats(ctx, userID, websocket.DefaultChatListLimit, 0); err == nil {
+			hub.SendChatListToUser(userID, chats)
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message": "Left chat successfully",
+			"chat_id": req.ChatID,
+		}, http.StatusOK)
+	}
+}
+
+// GetChatStatsHandler returns message statistics for a chat the user participates in
+func GetChatStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.URL.Query().Get("chat_id")
+	if chatID == "" {
+		utils.WriteErrorJSON(w, "chat_id is required", http.StatusBadRequest)
+		return
+	}
+
+	chatService := websocket.NewChatService(db.DB)
+	stats, err := chatService.GetChatStats(userID, chatID)
+	if err != nil {
+		if errors.Is(err, websocket.ErrNotChatParticipant) {
+			utils.WriteErrorJSON(w, "You are not a participant of this chat", http.StatusForbidden)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to get chat stats: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, stats, http.StatusOK)
+}
+
+// GetMessageReadReceiptsHandler lists who has read a message in a chat the user participates in
+func GetMessageReadReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	messageID := r.URL.Query().Get("message_id")
+	if messageID == "" {
+		utils.WriteErrorJSON(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	chatService := websocket.NewChatService(db.DB)
+	receipts, err := chatService.GetMessageReadReceipts(userID, messageID)
+	if err != nil {
+		if errors.Is(err, websocket.ErrMessageNotFound) {
+			utils.WriteErrorJSON(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, websocket.ErrNotChatParticipant) {
+			utils.WriteErrorJSON(w, "You are not a participant of this chat", http.StatusForbidden)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to get read receipts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, receipts, http.StatusOK)
+}
+
+// SearchChatMessagesHandler searches the message history of a chat the user participates in
+func SearchChatMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.URL.Query().Get("chat_id")
+	if chatID == "" {
+		utils.WriteErrorJSON(w, "chat_id is required", http.StatusBadRequest)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.WriteErrorJSON(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+
+	// Parse limit parameter (default to 20, max 50)
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+	if utils.RejectDeepOffset(w, offset, "narrow the search to find further results") {
+		return
+	}
+
+	chatService := websocket.NewChatService(db.DB)
+	messages, err := chatService.SearchChatMessages(chatID, userID, query, limit, offset)
+	if err != nil {
+		if errors.Is(err, websocket.ErrNotChatParticipant) {
+			utils.WriteErrorJSON(w, "You are not a participant of this chat", http.StatusForbidden)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to search messages: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WritePaginatedJSON(w, messages, len(messages) >= limit, utils.TotalUnknown, offset, limit)
+}
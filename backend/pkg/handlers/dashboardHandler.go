@@ -32,10 +32,18 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 	userData.PasswordHash = ""
 	userData.ID = ""
 
+	// badge counters for the app shell
+	counts, err := user.GetDashboardCounts(userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get dashboard counts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"user":   userData,
+		"counts": counts,
 		"status": http.StatusOK,
 	})
 }
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"social-network/pkg/db"
 	"social-network/pkg/db/sqlite"
+	"social-network/pkg/models/post"
 	"social-network/pkg/models/user"
 	"social-network/pkg/sockets/websocket"
 	"social-network/pkg/utils"
@@ -66,6 +67,119 @@ func GetUserByIDHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(userData)
 }
 
+// GetUserByNicknameHandler resolves a nickname to a user profile (used by profile URLs and @mentions)
+func GetUserByNicknameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get authenticated user ID from context
+	authenticatedUserID, ok := r.Context().Value("userID").(string)
+	if !ok || authenticatedUserID == "" {
+		utils.WriteErrorJSON(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	nickname := strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("nickname")), "@")
+	if nickname == "" {
+		utils.WriteErrorJSON(w, "Nickname is required", http.StatusBadRequest)
+		return
+	}
+
+	found, err := user.GetUserByNickname(nickname)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Load the profile the same way GetUserByIDHandler does so counts and follow state match
+	userData, err := user.GetUserByID(found.ID, authenticatedUserID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(userData)
+}
+
+// DevOrphanedChatsHandler lists (GET) or repairs (POST) chat threads that reference
+// deleted users or groups (development only)
+func DevOrphanedChatsHandler(w http.ResponseWriter, r *http.Request) {
+	chatService := websocket.NewChatService(db.DB)
+
+	switch r.Method {
+	case http.MethodGet:
+		orphans, err := chatService.FindOrphanedChats()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to find orphaned chats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"orphaned_chats": orphans,
+			"count":          len(orphans),
+		}, http.StatusOK)
+
+	case http.MethodPost:
+		repaired, err := chatService.RepairOrphanedChats()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to repair orphaned chats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Repaired %d orphaned chat thread(s)", len(repaired))
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"repaired_chats": repaired,
+			"count":          len(repaired),
+		}, http.StatusOK)
+
+	default:
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DevInconsistentPostsHandler lists (GET) or repairs (POST) posts whose privacy does not match their group_id
+func DevInconsistentPostsHandler(w http.ResponseWriter, r *http.Request) {
+	postService := post.NewPostService(db.DB)
+
+	switch r.Method {
+	case http.MethodGet:
+		posts, err := postService.FindInconsistentPosts()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to find inconsistent posts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"inconsistent_posts": posts,
+			"count":              len(posts),
+		}, http.StatusOK)
+
+	case http.MethodPost:
+		repaired, err := postService.RepairInconsistentPosts()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to repair inconsistent posts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Repaired %d inconsistent post(s)", len(repaired))
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"repaired_posts": repaired,
+			"count":          len(repaired),
+		}, http.StatusOK)
+
+	default:
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // Helper function to validate UUID format
 func isValidUUID(uuid string) bool {
 	// UUID v4 regex pattern
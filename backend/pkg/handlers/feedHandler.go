@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"social-network/pkg/models/post"
+	"social-network/pkg/utils"
+)
+
+// FeedPrefsHandler returns (GET) or updates (PUT) the user's home feed preferences
+func (h *PostHandler) FeedPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := h.PostService.GetFeedPrefs(userID)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to get feed preferences: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteSuccessJSON(w, prefs, http.StatusOK)
+
+	case http.MethodPut:
+		var req post.UpdateFeedPrefsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.PostService.UpdateFeedPrefs(userID, &req); err != nil {
+			if err.Error() == "no preferences provided" {
+				utils.WriteErrorJSON(w, "No preferences provided", http.StatusBadRequest)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to update feed preferences: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		prefs, err := h.PostService.GetFeedPrefs(userID)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to get feed preferences: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteSuccessJSON(w, prefs, http.StatusOK)
+
+	default:
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// MuteUserPostsHandler hides a user's posts from the requester's home feed without unfollowing them
+func (h *PostHandler) MuteUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" {
+		utils.WriteErrorJSON(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PostService.MuteUserPosts(userID, req.UserID); err != nil {
+		switch err.Error() {
+		case "you cannot mute yourself":
+			utils.WriteErrorJSON(w, "You cannot mute yourself", http.StatusBadRequest)
+		case "user not found":
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+		default:
+			utils.WriteErrorJSON(w, "Failed to mute user: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "User muted successfully", http.StatusOK)
+}
+
+// UnmuteUserPostsHandler shows a muted user's posts in the requester's home feed again
+func (h *PostHandler) UnmuteUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" {
+		utils.WriteErrorJSON(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PostService.UnmuteUserPosts(userID, req.UserID); err != nil {
+		if err.Error() == "user is not muted" {
+			utils.WriteErrorJSON(w, "User is not muted", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to unmute user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "User unmuted successfully", http.StatusOK)
+}
@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"social-network/pkg/db"
 	"social-network/pkg/models/follow"
 	"social-network/pkg/utils"
+	"strconv"
 )
 
 type FollowHandler struct {
@@ -48,6 +51,10 @@ func (h *FollowHandler) SendFollowRequestHandler(w http.ResponseWriter, r *http.
 			utils.WriteErrorJSON(w, "Follow request already exists", http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, follow.ErrBlocked) {
+			utils.WriteErrorJSON(w, "You cannot follow this user", http.StatusForbidden)
+			return
+		}
 		utils.WriteErrorJSON(w, "Failed to send follow request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -116,6 +123,45 @@ func (h *FollowHandler) RejectFollowRequestHandler(w http.ResponseWriter, r *htt
 	json.NewEncoder(w).Encode(map[string]string{"message": "Follow request rejected"})
 }
 
+// CancelFollowRequestHandler withdraws a follow request the user sent that is still pending
+func (h *FollowHandler) CancelFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized access: UserID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		FolloweeID string `json:"followee_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.FolloweeID == "" {
+		utils.WriteErrorJSON(w, "followee_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.FollowService.CancelFollowRequest(userID, req.FolloweeID); err != nil {
+		if errors.Is(err, follow.ErrNoPendingRequest) {
+			utils.WriteErrorJSON(w, "No pending follow request to cancel", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to cancel follow request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "Follow request cancelled", http.StatusOK)
+}
+
 func (h *FollowHandler) GetPendingRequestsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -128,9 +174,11 @@ func (h *FollowHandler) GetPendingRequestsHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	requests, err := h.FollowService.GetPendingRequests(userID)
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	requests, err := h.FollowService.GetOutgoingFollowRequests(ctx, userID)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to get pending requests: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to get pending requests: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
@@ -138,6 +186,30 @@ func (h *FollowHandler) GetPendingRequestsHandler(w http.ResponseWriter, r *http
 	json.NewEncoder(w).Encode(requests)
 }
 
+// GetIncomingRequestsHandler lists the follow requests waiting for the user's approval
+func (h *FollowHandler) GetIncomingRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized access: UserID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	requests, err := h.FollowService.GetIncomingFollowRequests(ctx, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get incoming requests: "+err.Error(), utils.QueryErrorStatus(err))
+		return
+	}
+
+	utils.WriteSuccessJSON(w, requests, http.StatusOK)
+}
+
 func (h *FollowHandler) GetUserFollowersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -184,9 +256,11 @@ func (h *FollowHandler) GetUserFollowersHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// check privacy related settings between the two users
-	canView, err := h.FollowService.CanViewUserData(userID, reqBody.UserID)
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	canView, err := h.FollowService.CanViewUserData(ctx, userID, reqBody.UserID)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to check privacy settings: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to check privacy settings: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
@@ -196,20 +270,14 @@ func (h *FollowHandler) GetUserFollowersHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Get followers from DB
-	followers, err := h.FollowService.GetUserFollowers(userID, reqBody.UserID, offset, limit)
+	followers, err := h.FollowService.GetUserFollowers(ctx, userID, reqBody.UserID, offset, limit)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to get followers: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to get followers: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
 	// Return followers as JSON
-	response := map[string]interface{}{
-		"success":   true,
-		"followers": followers,
-		"hasMore":   len(followers) >= limit,
-	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	utils.WritePaginatedJSON(w, followers, len(followers) >= limit, utils.TotalUnknown, offset, limit)
 }
 
 func (h *FollowHandler) GetUserFollowingHandler(w http.ResponseWriter, r *http.Request) {
@@ -258,9 +326,11 @@ func (h *FollowHandler) GetUserFollowingHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// check privacy related settings between the two users
-	canView, err := h.FollowService.CanViewUserData(userID, reqBody.UserID)
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	canView, err := h.FollowService.CanViewUserData(ctx, userID, reqBody.UserID)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to check privacy settings: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to check privacy settings: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
@@ -270,20 +340,14 @@ func (h *FollowHandler) GetUserFollowingHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Get following from DB
-	following, err := h.FollowService.GetUserFollowing(userID, reqBody.UserID, offset, limit)
+	following, err := h.FollowService.GetUserFollowing(ctx, userID, reqBody.UserID, offset, limit)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to get following: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to get following: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
 	// Return following as JSON
-	response := map[string]interface{}{
-		"success":   true,
-		"following": following,
-		"hasMore":   len(following) >= limit,
-	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	utils.WritePaginatedJSON(w, following, len(following) >= limit, utils.TotalUnknown, offset, limit)
 }
 
 func (h *FollowHandler) UnfollowHandler(w http.ResponseWriter, r *http.Request) {
@@ -328,3 +392,163 @@ func (h *FollowHandler) UnfollowHandler(w http.ResponseWriter, r *http.Request)
 
 	utils.WriteSuccessJSON(w, "Successfully unfollowed user", http.StatusOK)
 }
+
+// MaxFollowStatusBatch caps how many users one status-batch request can ask about
+const MaxFollowStatusBatch = 100
+
+// FollowStatusBatchHandler reports which of the given users the requester follows
+func (h *FollowHandler) FollowStatusBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized access: UserID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UserIDs []string `json:"user_ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.UserIDs) > MaxFollowStatusBatch {
+		utils.WriteErrorJSON(w, "Too many user IDs: at most "+strconv.Itoa(MaxFollowStatusBatch)+" per request", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	statuses, err := h.FollowService.GetFollowStatuses(ctx, userID, req.UserIDs)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get follow statuses: "+err.Error(), utils.QueryErrorStatus(err))
+		return
+	}
+
+	utils.WriteSuccessJSON(w, statuses, http.StatusOK)
+}
+
+// RemoveFollowerHandler removes someone who follows the user
+func (h *FollowHandler) RemoveFollowerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized access: UserID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		FollowerID string `json:"follower_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.FollowerID == "" {
+		utils.WriteErrorJSON(w, "follower_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.FollowService.RemoveFollower(userID, req.FollowerID); err != nil {
+		switch {
+		case errors.Is(err, follow.ErrCannotRemoveSelf):
+			utils.WriteErrorJSON(w, "You cannot remove yourself as a follower", http.StatusBadRequest)
+		case errors.Is(err, follow.ErrNotAFollower):
+			utils.WriteErrorJSON(w, "This user does not follow you", http.StatusNotFound)
+		default:
+			utils.WriteErrorJSON(w, "Failed to remove follower: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "Follower removed", http.StatusOK)
+}
+
+// BlockUserHandler blocks a user: no direct messages or follow requests between the two from now on
+func (h *FollowHandler) BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized access: UserID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" {
+		utils.WriteErrorJSON(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.FollowService.BlockUser(userID, req.UserID); err != nil {
+		switch {
+		case errors.Is(err, follow.ErrCannotBlockSelf):
+			utils.WriteErrorJSON(w, "You cannot block yourself", http.StatusBadRequest)
+		case errors.Is(err, follow.ErrUserNotFound):
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+		default:
+			utils.WriteErrorJSON(w, "Failed to block user: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "User blocked successfully", http.StatusOK)
+}
+
+// UnblockUserHandler lifts a block the user placed
+func (h *FollowHandler) UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized access: UserID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" {
+		utils.WriteErrorJSON(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.FollowService.UnblockUser(userID, req.UserID); err != nil {
+		if errors.Is(err, follow.ErrNotBlocked) {
+			utils.WriteErrorJSON(w, "User is not blocked", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to unblock user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "User unblocked successfully", http.StatusOK)
+}
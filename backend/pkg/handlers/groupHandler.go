@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -18,26 +19,7 @@ import (
 
 // Helper function to add user to group chat within a transaction
 func addUserToGroupChatTx(tx *sql.Tx, userID, groupID string) error {
-	// Get the group's chat thread ID
-	var chatID int64
-	err := tx.QueryRow(`
-        SELECT id FROM chat_threads 
-        WHERE is_group = 1 AND group_id = ?
-    `, groupID).Scan(&chatID)
-	if err != nil {
-		return fmt.Errorf("failed to find group chat thread: %w", err)
-	}
-
-	// Add user as participant
-	_, err = tx.Exec(`
-        INSERT OR IGNORE INTO chat_participants (chat_id, user_id)
-        VALUES (?, ?)
-    `, chatID, userID)
-	if err != nil {
-		return fmt.Errorf("failed to add user to group chat: %w", err)
-	}
-
-	return nil
+	return group.AddUserToGroupChatTx(tx, userID, groupID)
 }
 
 // Helper function to remove user from group chat within a transaction
@@ -64,6 +46,17 @@ func removeUserFromGroupChatTx(tx *sql.Tx, userID, groupID string) error {
 	return nil
 }
 
+// notifyGroupRequestResolved lets the group admins know a pending join request no longer needs a response
+func notifyGroupRequestResolved(hub *websocket.Hub, requesterID, requesterName, groupID, groupName string) {
+	adminIDs, err := group.GetGroupAdminIDs(db.DB, groupID)
+	if err != nil {
+		log.Printf("Failed to get admins for group %s: %v", groupID, err)
+		return
+	}
+
+	go websocket.SendGroupRequestResolvedNotification(hub, requesterID, requesterName, adminIDs, groupID, groupName)
+}
+
 // Handler for creating groups
 func GroupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -84,11 +77,20 @@ func GroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newGroup.CreatorID = userID // CreatorID = authenticated userID
+	newGroup.Title = strings.TrimSpace(newGroup.Title)
+	newGroup.Description = strings.TrimSpace(newGroup.Description)
 
 	if err := newGroup.ValidateGroupCreation(); err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, http.StatusBadRequest)
+			return
+		}
 		utils.WriteErrorJSON(w, "Invalid group: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	newGroup.Title = utils.SanitizeText(newGroup.Title)
+	newGroup.Description = utils.SanitizeText(newGroup.Description)
 
 	createGroup, err := group.CreateGroup(db.DB, newGroup)
 	if err != nil {
@@ -105,6 +107,7 @@ func GroupHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	websocket.NewChatService(db.DB).InvalidateUserChats(userID)
 
 	// response including chat thread information
 	response := map[string]interface{}{
@@ -202,12 +205,20 @@ func GroupRequestHandler(hub *websocket.Hub) http.HandlerFunc {
 		groupReq.Status = "pending"
 
 		if err := groupReq.ValidateGroupRequest(db.DB); err != nil {
+			if errors.Is(err, group.ErrGroupRequestPending) {
+				utils.WriteErrorJSON(w, "You already have a pending request to join this group", http.StatusConflict)
+				return
+			}
 			utils.WriteErrorJSON(w, "Invalid group request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		groupRequest, err := group.CreateGroupRequest(db.DB, groupReq)
 		if err != nil {
+			if errors.Is(err, group.ErrGroupRequestPending) {
+				utils.WriteErrorJSON(w, "You already have a pending request to join this group", http.StatusConflict)
+				return
+			}
 			utils.WriteErrorJSON(w, "Failed to create group request: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -228,10 +239,10 @@ func GroupRequestHandler(hub *websocket.Hub) http.HandlerFunc {
 	}
 }
 
-// Handler for accepting group invitations
-func AcceptGroupInvitationHandler(hub *websocket.Hub) http.HandlerFunc {
+// CancelGroupRequestHandler lets the requester withdraw a pending join request before an admin responds
+func CancelGroupRequestHandler(hub *websocket.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
+		if r.Method != http.MethodPost {
 			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
@@ -242,95 +253,100 @@ func AcceptGroupInvitationHandler(hub *websocket.Hub) http.HandlerFunc {
 			return
 		}
 
-		var groupInv group.GroupInvitation
-		if err := json.NewDecoder(r.Body).Decode(&groupInv); err != nil {
+		var req struct {
+			GroupID string `json:"group_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		if req.GroupID == "" {
+			utils.WriteErrorJSON(w, "group_id is required", http.StatusBadRequest)
+			return
+		}
 
-		// Begin transaction for atomic operations
-		tx, err := db.DB.Begin()
-		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to begin transaction: "+err.Error(), http.StatusInternalServerError)
+		if err := group.CancelGroupRequest(db.DB, req.GroupID, userID); err != nil {
+			if errors.Is(err, group.ErrNoPendingGroupRequest) {
+				utils.WriteErrorJSON(w, "No pending request to join this group", http.StatusNotFound)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to cancel group request: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer tx.Rollback()
 
-		// Find invitation by group_id and invitee_id
-		var invitationID, inviterID, groupName string
-		err = tx.QueryRow(`
-            SELECT gi.id, gi.inviter_id, g.title 
-            FROM group_invitations gi 
-            JOIN groups g ON gi.group_id = g.id 
-            WHERE gi.group_id = ? AND gi.invitee_id = ? AND gi.status = 'pending'
-        `, groupInv.GroupID, groupInv.InviteeID).Scan(&invitationID, &inviterID, &groupName)
+		adminIDs, err := group.GetGroupAdminIDs(db.DB, req.GroupID)
 		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to find invitation: "+err.Error(), http.StatusNotFound)
-			return
+			log.Printf("Failed to get admins for group %s: %v", req.GroupID, err)
 		}
+		go websocket.SendGroupRequestCancelledNotification(hub, userID, adminIDs, req.GroupID)
 
-		groupInv.ID = invitationID
+		utils.WriteSuccessJSON(w, "Group request cancelled successfully", http.StatusOK)
+	}
+}
 
-		// Accept invitation and add to group
-		_, err = tx.Exec(`
-            UPDATE group_invitations 
-            SET status = 'accepted', responded_at = datetime('now') 
-            WHERE id = ?
-        `, groupInv.ID)
-		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to accept invitation: "+err.Error(), http.StatusInternalServerError)
+// Handler for accepting group invitations.
+// The invitee is always the authenticated user; only the group_id is read from the body.
+func AcceptGroupInvitationHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Add user to group_memberships
-		// Check if user is already a member (defensive check)
-		var exists int
-		err = tx.QueryRow(`
-    SELECT COUNT(*) FROM group_memberships WHERE group_id = ? AND user_id = ?
-`, groupInv.GroupID, userID).Scan(&exists)
-		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to check group membership: "+err.Error(), http.StatusInternalServerError)
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
 			return
 		}
 
-		if exists == 0 {
-			_, err = tx.Exec(`
-        INSERT INTO group_memberships (group_id, user_id, role, joined_at)
-        VALUES (?, ?, 'member', datetime('now'))
-    `, groupInv.GroupID, userID)
-			if err != nil {
-				utils.WriteErrorJSON(w, "Failed to add user to group: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
+		var req struct {
+			GroupID string `json:"group_id"`
 		}
-
-		// Add user to group chat
-		if err := addUserToGroupChatTx(tx, userID, groupInv.GroupID); err != nil {
-			utils.WriteErrorJSON(w, "Failed to add user to group chat: "+err.Error(), http.StatusInternalServerError)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			utils.WriteErrorJSON(w, "Failed to commit transaction: "+err.Error(), http.StatusInternalServerError)
+		if req.GroupID == "" {
+			utils.WriteErrorJSON(w, "group_id is required", http.StatusBadRequest)
 			return
 		}
 
-		// Get invitee name for notification
-		var inviteeName string
-		err = db.DB.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", userID).Scan(&inviteeName)
+		// Accept the invitation, join the group and its chat, and resolve pending requests atomically
+		resp, err := group.AcceptPendingGroupInvitation(db.DB, req.GroupID, userID)
 		if err != nil {
-			inviteeName = "Unknown User"
+			if errors.Is(err, group.ErrInvitationNotFound) {
+				utils.WriteErrorJSON(w, "Failed to find invitation: no pending invitation for this group", http.StatusNotFound)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to accept invitation: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
+		websocket.NewChatService(db.DB).InvalidateGroupChat(resp.GroupID)
+
 		// Send WebSocket notification after successful DB update
-		go hub.NotifyInvitationResponse(inviterID, userID, groupInv.GroupID, groupName, inviteeName, "accepted")
+		go hub.NotifyInvitationResponse(resp.InviterID, userID, resp.GroupID, resp.GroupName, resp.InviteeName, "accepted")
+
+		if resp.ResolvedRequests > 0 {
+			notifyGroupRequestResolved(hub, userID, resp.InviteeName, resp.GroupID, resp.GroupName)
+		}
 
-		utils.WriteSuccessJSON(w, "Group invitation accepted successfully", http.StatusOK)
+		chatID, err := group.GetGroupChatID(db.DB, resp.GroupID)
+		if err != nil {
+			log.Printf("Warning: Failed to get chat for group %s: %v", resp.GroupID, err)
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":    "Group invitation accepted successfully",
+			"group_id":   resp.GroupID,
+			"group_name": resp.GroupName,
+			"chat_id":    chatID,
+		}, http.StatusOK)
 	}
 }
 
-// Handler for declining group invitations
+// Handler for declining group invitations.
+// The invitee is always the authenticated user; only the group_id is read from the body.
 func DeclineGroupInvitationHandler(hub *websocket.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPut {
@@ -344,40 +360,29 @@ func DeclineGroupInvitationHandler(hub *websocket.Hub) http.HandlerFunc {
 			return
 		}
 
-		var groupInv group.GroupInvitation
-		if err := json.NewDecoder(r.Body).Decode(&groupInv); err != nil {
+		var req struct {
+			GroupID string `json:"group_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Find invitation by group_id and invitee_id (userID)
-		var invitationID, inviterID, groupName string
-		err := db.DB.QueryRow(`
-            SELECT gi.id, gi.inviter_id, g.title
-            FROM group_invitations gi
-            JOIN groups g ON gi.group_id = g.id
-            WHERE gi.group_id = ? AND gi.invitee_id = ? AND gi.status = 'pending'
-        `, groupInv.GroupID, groupInv.InviteeID).Scan(&invitationID, &inviterID, &groupName)
-		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to find invitation: "+err.Error(), http.StatusNotFound)
+		if req.GroupID == "" {
+			utils.WriteErrorJSON(w, "group_id is required", http.StatusBadRequest)
 			return
 		}
 
-		groupInv.ID = invitationID
-
-		if err := group.DeclineGroupInvitation(db.DB, groupInv); err != nil {
+		resp, err := group.DeclinePendingGroupInvitation(db.DB, req.GroupID, userID)
+		if err != nil {
+			if errors.Is(err, group.ErrInvitationNotFound) {
+				utils.WriteErrorJSON(w, "Failed to find invitation: no pending invitation for this group", http.StatusNotFound)
+				return
+			}
 			utils.WriteErrorJSON(w, "Failed to decline group invitation: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Get invitee name for notification
-		var inviteeName string
-		err = db.DB.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", userID).Scan(&inviteeName)
-		if err != nil {
-			inviteeName = "Unknown User"
-		}
-
-		go hub.NotifyInvitationResponse(inviterID, userID, groupInv.GroupID, groupName, inviteeName, "declined")
+		go hub.NotifyInvitationResponse(resp.InviterID, userID, resp.GroupID, resp.GroupName, resp.InviteeName, "declined")
 
 		utils.WriteSuccessJSON(w, "Group invitation declined successfully", http.StatusOK)
 	}
@@ -508,16 +513,35 @@ func AcceptGroupRequestHandler(hub *websocket.Hub) http.HandlerFunc {
 			return
 		}
 
+		// Resolve any invitations the requester still has pending for this group
+		if _, err := group.ResolvePendingGroupInvitationsTx(tx, requestBody.GroupID, requestBody.RequesterID); err != nil {
+			utils.WriteErrorJSON(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// Commit transaction
 		if err = tx.Commit(); err != nil {
 			utils.WriteErrorJSON(w, "Failed to commit transaction: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		websocket.NewChatService(db.DB).InvalidateGroupChat(requestBody.GroupID)
 
 		// Send success notification
 		go websocket.SendGroupRequestResponseNotification(hub, requestBody.RequesterID, requestBody.GroupID, groupName, true, userID)
 
-		utils.WriteSuccessJSON(w, "Group request accepted successfully", http.StatusOK)
+		chatID, err := group.GetGroupChatID(db.DB, requestBody.GroupID)
+		if err != nil {
+			log.Printf("Warning: Failed to get chat for group %s: %v", requestBody.GroupID, err)
+		}
+
+		resp := map[string]interface{}{
+			"message":    "Group request accepted successfully",
+			"group_id":   requestBody.GroupID,
+			"group_name": groupName,
+			"chat_id":    chatID,
+		}
+
+		utils.WriteSuccessJSON(w, resp, http.StatusOK)
 	}
 }
 
@@ -680,6 +704,9 @@ func (h *PostHandler) GetGroupPosts(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
 
 	// Parse limit parameter (default to 20, max 100)
 	limitStr := r.URL.Query().Get("limit")
@@ -695,21 +722,29 @@ func (h *PostHandler) GetGroupPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	commentsPerPost, err := parseInlineCommentsParam(r)
+	if err != nil {
+		utils.WriteErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get group posts from the DB
-	posts, err := h.PostService.GetGroupPosts(userID, groupID, offset, limit)
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	posts, err := h.PostService.GetGroupPosts(ctx, userID, groupID, offset, limit, commentsPerPost)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to retrieve group posts: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to retrieve group posts: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
-	// Return success response
-	response := map[string]interface{}{
-		"success": true,
-		"posts":   posts,
-		"hasMore": len(posts) >= limit,
+	total, err := h.PostService.CountGroupPosts(groupID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to count group posts: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+
+	// Return success response
+	utils.WritePaginatedJSON(w, posts, offset+len(posts) < total, total, offset, limit)
 }
 
 // Handler to get all groups the user is a member of
@@ -731,10 +766,111 @@ func GetUserGroupsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"groups": groups,
-	})
+	// All of the user's groups are returned in one page
+	utils.WritePaginatedJSON(w, groups, false, len(groups), 0, len(groups))
+}
+
+// Handler to get groups suggested from the memberships of the people the user follows
+func GetSuggestedGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse limit parameter (default to 10, max 50)
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			utils.WriteErrorJSON(w, "Invalid limit parameter: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	groups, err := group.GetSuggestedGroups(db.DB, userID, limit)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get suggested groups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Suggestions are a single ranked page
+	utils.WritePaginatedJSON(w, groups, false, len(groups), 0, limit)
+}
+
+// DiscoverGroupsHandler lets the user browse public groups they haven't joined or requested to join.
+// sort=popular (default) orders by member count, sort=recent by creation date.
+func DiscoverGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = group.DiscoverSortPopular
+	}
+	if sort != group.DiscoverSortPopular && sort != group.DiscoverSortRecent {
+		utils.WriteErrorJSON(w, "Invalid sort parameter: must be popular or recent", http.StatusBadRequest)
+		return
+	}
+
+	// Parse offset parameter (default to 0)
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			utils.WriteErrorJSON(w, "Invalid offset parameter: must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
+
+	// Parse limit parameter (default to 20, max 50)
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			utils.WriteErrorJSON(w, "Invalid limit parameter: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	groups, err := group.GetDiscoverableGroups(db.DB, userID, sort, limit, offset)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get groups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	total, err := group.CountDiscoverableGroups(db.DB, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to count groups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WritePaginatedJSON(w, groups, offset+len(groups) < total, total, offset, limit)
 }
 
 // Handler to get info for a specific group, including membership and role
@@ -761,6 +897,7 @@ func GetGroupByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Default membership info
 	isMember := false
+	isMuted := false
 	role := ""
 
 	// If user is authenticated, check membership and role
@@ -774,12 +911,18 @@ func GetGroupByIDHandler(w http.ResponseWriter, r *http.Request) {
 			isMember = true
 			role = dbRole.String
 		}
+
+		isMuted, err = group.IsGroupMuted(db.DB, groupID, userID)
+		if err != nil {
+			log.Printf("Failed to get group notification prefs for user %s: %v", userID, err)
+		}
 	}
 
 	// Build response
 	resp := map[string]interface{}{
 		"group":    groupInfo,
 		"isMember": isMember,
+		"isMuted":  isMuted,
 		"role":     role,
 	}
 
@@ -867,7 +1010,7 @@ func GetPendingGroupRequestsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetGroupMembersHandler retrieves all members of a group
+// GetGroupMembersHandler lists a page of the group's members, optionally filtered by role or a name search (q)
 func GetGroupMembersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -897,42 +1040,53 @@ func GetGroupMembersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all group members
-	rows, err := db.DB.Query(`
-        SELECT gm.user_id, gm.role, u.nickname, u.first_name, u.last_name, u.avatar_path, gm.joined_at
-        FROM group_memberships gm
-        JOIN users u ON gm.user_id = u.id
-        WHERE gm.group_id = ?
-        ORDER BY gm.joined_at ASC
-    `, groupID)
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			utils.WriteErrorJSON(w, "Invalid offset parameter: must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
+
+	limit := group.DefaultMembersLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			utils.WriteErrorJSON(w, "Invalid limit parameter: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit > group.MaxMembersLimit {
+			limit = group.MaxMembersLimit
+		}
+	}
+
+	// Optional filters: role=admin (or moderator, member) to list only that role, q to search by name
+	filter := group.MemberFilter{
+		Role:  r.URL.Query().Get("role"),
+		Query: strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+	if filter.Role != "" && !group.IsMemberRole(filter.Role) {
+		utils.WriteErrorJSON(w, "Invalid role parameter: must be admin, moderator or member", http.StatusBadRequest)
+		return
+	}
+
+	members, err := group.GetGroupMembers(db.DB, groupID, filter, limit, offset)
 	if err != nil {
 		utils.WriteErrorJSON(w, "Failed to get group members: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var members []map[string]interface{}
-	for rows.Next() {
-		var memberID, memberRole, nickname, firstName, lastName, avatarPath, joinedAt string
-		if err := rows.Scan(&memberID, &memberRole, &nickname, &firstName, &lastName, &avatarPath, &joinedAt); err != nil {
-			utils.WriteErrorJSON(w, "Failed to scan member: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		members = append(members, map[string]interface{}{
-			"id":         memberID,
-			"role":       memberRole,
-			"nickname":   nickname,
-			"first_name": firstName,
-			"last_name":  lastName,
-			"avatar":     avatarPath,
-			"joined_at":  joinedAt,
-		})
+	total, err := group.CountGroupMembers(db.DB, groupID, filter)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to count group members: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"members": members,
-	})
+	utils.WritePaginatedJSON(w, members, offset+len(members) < total, total, offset, limit)
 }
 
 // GrantAdminHandler grants admin role to a group member
@@ -957,27 +1111,27 @@ func GrantAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get group creator ID
-	var creatorID string
-	err := db.DB.QueryRow("SELECT creator_id FROM groups WHERE id = ?", req.GroupID).Scan(&creatorID)
+	// Check if user is admin or creator; moderators cannot grant admin
+	role, err := group.GetMemberRole(db.DB, req.GroupID, userID)
 	if err != nil {
 		utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	// Check if user is admin or creator
-	var role sql.NullString
-	err = db.DB.QueryRow(
-		"SELECT role FROM group_memberships WHERE group_id = ? AND user_id = ?",
-		req.GroupID, userID,
-	).Scan(&role)
-	isAdmin := err == nil && role.Valid && role.String == "admin"
-
-	if !isAdmin && userID != creatorID {
+	if !group.CanAdminister(role) {
 		utils.WriteErrorJSON(w, "Unauthorized: Only group admins or creator can grant admin role", http.StatusForbidden)
 		return
 	}
 
+	targetRole, err := group.GetMemberRole(db.DB, req.GroupID, req.MemberID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !group.IsMemberRole(targetRole) {
+		utils.WriteErrorJSON(w, "Target user is not a member of this group", http.StatusBadRequest)
+		return
+	}
+
 	// Update member role to admin
 	_, err = db.DB.Exec(
 		"UPDATE group_memberships SET role = 'admin' WHERE group_id = ? AND user_id = ?",
@@ -1040,11 +1194,84 @@ func RevokeAdminHandler(w http.ResponseWriter, r *http.Request) {
 	utils.WriteSuccessJSON(w, "Admin role revoked successfully", http.StatusOK)
 }
 
-// GrantCreatorHandler transfers creator ownership to another member
-func GrantCreatorHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// GrantModeratorHandler promotes a regular member to moderator. Only admins or the creator can do this,
+// and admins cannot be demoted to moderator through it.
+func GrantModeratorHandler(w http.ResponseWriter, r *http.Request) {
+	setModeratorRole(w, r, group.RoleMember, group.RoleModerator)
+}
+
+// RevokeModeratorHandler demotes a moderator back to a regular member. Only admins or the creator can do this.
+func RevokeModeratorHandler(w http.ResponseWriter, r *http.Request) {
+	setModeratorRole(w, r, group.RoleModerator, group.RoleMember)
+}
+
+// setModeratorRole changes the target's role from one role to another on behalf of an admin or the creator
+func setModeratorRole(w http.ResponseWriter, r *http.Request, fromRole, toRole string) {
+	if r.Method != http.MethodPut {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		GroupID  string `json:"group_id"`
+		MemberID string `json:"member_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	role, err := group.GetMemberRole(db.DB, req.GroupID, userID)
+	if err == sql.ErrNoRows {
+		utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !group.CanAdminister(role) {
+		utils.WriteErrorJSON(w, "Unauthorized: Only group admins or creator can manage moderators", http.StatusForbidden)
+		return
+	}
+
+	targetRole, err := group.GetMemberRole(db.DB, req.GroupID, req.MemberID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if targetRole != fromRole {
+		utils.WriteErrorJSON(w, fmt.Sprintf("Target user must be a %s of this group", fromRole), http.StatusBadRequest)
+		return
+	}
+
+	_, err = db.DB.Exec(
+		"UPDATE group_memberships SET role = ? WHERE group_id = ? AND user_id = ?",
+		toRole, req.GroupID, req.MemberID,
+	)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to update member role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if toRole == group.RoleModerator {
+		utils.WriteSuccessJSON(w, "Moderator role granted successfully", http.StatusOK)
+		return
+	}
+	utils.WriteSuccessJSON(w, "Moderator role revoked successfully", http.StatusOK)
+}
+
+// GrantCreatorHandler transfers creator ownership to another member
+func GrantCreatorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
 	userID := r.Context().Value("userID").(string)
@@ -1147,57 +1374,50 @@ func KickMemberHandler(hub *websocket.Hub) http.HandlerFunc {
 			return
 		}
 
-		// Begin transaction
-		tx, err := db.DB.Begin()
+		// Get both roles; the creator is reported as group.RoleCreator
+		userRole, err := group.GetMemberRole(db.DB, req.GroupID, userID)
+		if err == sql.ErrNoRows {
+			utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to begin transaction: "+err.Error(), http.StatusInternalServerError)
+			utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer tx.Rollback()
-
-		// Get group creator ID
-		var creatorID string
-		err = tx.QueryRow("SELECT creator_id FROM groups WHERE id = ?", req.GroupID).Scan(&creatorID)
+		targetRole, err := group.GetMemberRole(db.DB, req.GroupID, req.MemberID)
 		if err != nil {
 			utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Get target member's role
-		var targetRole sql.NullString
-		err = tx.QueryRow(
-			"SELECT role FROM group_memberships WHERE group_id = ? AND user_id = ?",
-			req.GroupID, req.MemberID,
-		).Scan(&targetRole)
-		if err != nil || !targetRole.Valid {
+		// Cannot kick the creator
+		if targetRole == group.RoleCreator {
+			utils.WriteErrorJSON(w, "Cannot kick the group creator", http.StatusBadRequest)
+			return
+		}
+		if targetRole == "" {
 			utils.WriteErrorJSON(w, "Target user is not a member of this group", http.StatusBadRequest)
 			return
 		}
 
-		// Check permissions
-		var userRole sql.NullString
-		err = tx.QueryRow(
-			"SELECT role FROM group_memberships WHERE group_id = ? AND user_id = ?",
-			req.GroupID, userID,
-		).Scan(&userRole)
-		isAdmin := err == nil && userRole.Valid && userRole.String == "admin"
-		isCreator := userID == creatorID
-
-		// Only creator can kick admins, admins can kick members
-		if targetRole.String == "admin" && !isCreator {
-			utils.WriteErrorJSON(w, "Unauthorized: Only the creator can kick admins", http.StatusForbidden)
+		// Moderators and above can kick, but only users ranked below them:
+		// the creator kicks admins, admins kick moderators, moderators kick members
+		if !group.CanModerate(userRole) {
+			utils.WriteErrorJSON(w, "Unauthorized: Only moderators, admins or creator can kick members", http.StatusForbidden)
 			return
 		}
-		if !isAdmin && !isCreator {
-			utils.WriteErrorJSON(w, "Unauthorized: Only admins or creator can kick members", http.StatusForbidden)
+		if !group.Outranks(userRole, targetRole) {
+			utils.WriteErrorJSON(w, "Unauthorized: You can only kick members ranked below you", http.StatusForbidden)
 			return
 		}
 
-		// Cannot kick the creator
-		if req.MemberID == creatorID {
-			utils.WriteErrorJSON(w, "Cannot kick the group creator", http.StatusBadRequest)
+		// Begin transaction
+		tx, err := db.DB.Begin()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to begin transaction: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		defer tx.Rollback()
 
 		// Remove member from group
 		_, err = tx.Exec(
@@ -1238,6 +1458,9 @@ func KickMemberHandler(hub *websocket.Hub) http.HandlerFunc {
 			utils.WriteErrorJSON(w, "Failed to commit transaction: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		chatService := websocket.NewChatService(db.DB)
+		chatService.InvalidateGroupChat(req.GroupID)
+		chatService.InvalidateUserChats(req.MemberID)
 
 		go websocket.SendGroupKickNotification(hub, req.MemberID, req.GroupID, userID)
 
@@ -1246,7 +1469,7 @@ func KickMemberHandler(hub *websocket.Hub) http.HandlerFunc {
 
 }
 
-// EditGroupHandler allows admins to edit group title, description, and privacy setting
+// EditGroupHandler allows admins to edit group title, description, privacy setting and images
 func EditGroupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1260,25 +1483,45 @@ func EditGroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		GroupID     string `json:"group_id"`
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		IsPublic    bool   `json:"is_public"`
+		GroupID     string  `json:"group_id"`
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		IsPublic    bool    `json:"is_public"`
+		AvatarPath  *string `json:"avatar_path"` // omitted keeps the current image, "" resets to the default
+		CoverPath   *string `json:"cover_path"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Description = strings.TrimSpace(req.Description)
 
 	// Validate required fields
 	if req.GroupID == "" {
 		utils.WriteErrorJSON(w, "Group ID is required", http.StatusBadRequest)
 		return
 	}
-	if req.Title == "" {
-		utils.WriteErrorJSON(w, "Group title is required", http.StatusBadRequest)
+
+	// An edited group must pass the same checks as a new one; omitted images are kept as they are
+	edited := group.Group{Title: req.Title, Description: req.Description}
+	if req.AvatarPath != nil {
+		edited.AvatarPath = *req.AvatarPath
+	}
+	if req.CoverPath != nil {
+		edited.CoverPath = *req.CoverPath
+	}
+	if err := edited.ValidateGroupCreation(); err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, http.StatusBadRequest)
+			return
+		}
+		utils.WriteErrorJSON(w, "Invalid group: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Title = utils.SanitizeText(req.Title)
+	req.Description = utils.SanitizeText(req.Description)
 
 	// Get group creator ID
 	var creatorID string
@@ -1304,9 +1547,10 @@ func EditGroupHandler(w http.ResponseWriter, r *http.Request) {
 	// Update group settings (removed updated_at since column doesn't exist)
 	_, err = db.DB.Exec(`
         UPDATE groups 
-        SET title = ?, description = ?, is_public = ?
+        SET title = ?, description = ?, is_public = ?,
+            avatar_path = COALESCE(?, avatar_path), cover_path = COALESCE(?, cover_path)
         WHERE id = ?
-    `, req.Title, req.Description, req.IsPublic, req.GroupID)
+    `, req.Title, req.Description, req.IsPublic, req.AvatarPath, req.CoverPath, req.GroupID)
 	if err != nil {
 		utils.WriteErrorJSON(w, "Failed to update group settings: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -1316,97 +1560,230 @@ func EditGroupHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Handler for Joining a Public Group
-func JoinPublicGroupHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+func JoinPublicGroupHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	userID := r.Context().Value("userID").(string)
-	if userID == "" {
-		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
-		return
-	}
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+			return
+		}
 
-	var requestBody struct {
-		GroupID string `json:"group_id"`
-	}
+		var requestBody struct {
+			GroupID string `json:"group_id"`
+		}
 
-	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
-		return
-	}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	if requestBody.GroupID == "" {
-		utils.WriteErrorJSON(w, "Missing group_id", http.StatusBadRequest)
-		return
-	}
+		if requestBody.GroupID == "" {
+			utils.WriteErrorJSON(w, "Missing group_id", http.StatusBadRequest)
+			return
+		}
 
-	// Check if group exists and is public
-	var isPublic bool
-	var groupTitle string
-	query := `SELECT is_public, title FROM groups WHERE id = ?`
-	err := db.DB.QueryRow(query, requestBody.GroupID).Scan(&isPublic, &groupTitle)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+		// Check if group exists and is public
+		var isPublic bool
+		var groupTitle string
+		query := `SELECT is_public, title FROM groups WHERE id = ?`
+		err := db.DB.QueryRow(query, requestBody.GroupID).Scan(&isPublic, &groupTitle)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to check group: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		utils.WriteErrorJSON(w, "Failed to check group: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	if !isPublic {
-		utils.WriteErrorJSON(w, "Can only join public groups directly", http.StatusForbidden)
-		return
-	}
+		if !isPublic {
+			utils.WriteErrorJSON(w, "Can only join public groups directly", http.StatusForbidden)
+			return
+		}
 
-	// Check if user is already a member (defensive check)
-	// Check if user is already a member (defensive check for both membership and creator)
-	var existingMemberCount int
-	memberQuery := `
-    SELECT COUNT(*) FROM (
-        SELECT user_id FROM group_memberships WHERE group_id = ? AND user_id = ?
-        UNION
-        SELECT creator_id FROM groups WHERE id = ? AND creator_id = ?
-    )
-`
-	err = db.DB.QueryRow(memberQuery, requestBody.GroupID, userID, requestBody.GroupID, userID).Scan(&existingMemberCount)
-	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to check membership: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+		// Check if user is already a member (defensive check)
+		// Check if user is already a member (defensive check for both membership and creator)
+		var existingMemberCount int
+		memberQuery := `
+	    SELECT COUNT(*) FROM (
+	        SELECT user_id FROM group_memberships WHERE group_id = ? AND user_id = ?
+	        UNION
+	        SELECT creator_id FROM groups WHERE id = ? AND creator_id = ?
+	    )
+	`
+		err = db.DB.QueryRow(memberQuery, requestBody.GroupID, userID, requestBody.GroupID, userID).Scan(&existingMemberCount)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to check membership: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	if existingMemberCount > 0 {
-		utils.WriteErrorJSON(w, "You are already a member of this group", http.StatusConflict)
-		return
-	}
+		if existingMemberCount > 0 {
+			utils.WriteErrorJSON(w, "You are already a member of this group", http.StatusConflict)
+			return
+		}
 
-	// Add user as member using group_memberships table
-	insertQuery := `
-    INSERT INTO group_memberships (group_id, user_id, role, joined_at)
-    VALUES (?, ?, 'member', datetime('now'))
-`
-	_, err = db.DB.Exec(insertQuery, requestBody.GroupID, userID)
-	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to join group: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+		tx, err := db.DB.Begin()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to begin transaction: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
 
-	// Add user to group chat
-	chatService := websocket.NewChatService(db.DB)
-	if err := chatService.AddUserToGroupChat(userID, requestBody.GroupID); err != nil {
-		log.Printf("Warning: Failed to add user to group chat: %v", err)
-		// Don't fail the request, just log the warning
-	}
+		// Add user as member using group_memberships table
+		insertQuery := `
+	    INSERT INTO group_memberships (group_id, user_id, role, joined_at)
+	    VALUES (?, ?, 'member', datetime('now'))
+	`
+		_, err = tx.Exec(insertQuery, requestBody.GroupID, userID)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to join group: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	resp := map[string]interface{}{
-		"message":    "Successfully joined group",
-		"group_id":   requestBody.GroupID,
-		"group_name": groupTitle,
+		// Joining directly settles any pending request or invitation for this group
+		resolvedRequests, err := group.ResolvePendingGroupRequestsTx(tx, requestBody.GroupID, userID)
+		if err != nil {
+			utils.WriteErrorJSON(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := group.ResolvePendingGroupInvitationsTx(tx, requestBody.GroupID, userID); err != nil {
+			utils.WriteErrorJSON(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			utils.WriteErrorJSON(w, "Failed to commit transaction: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Add user to group chat
+		chatService := websocket.NewChatService(db.DB)
+		if err := chatService.AddUserToGroupChat(userID, requestBody.GroupID); err != nil {
+			log.Printf("Warning: Failed to add user to group chat: %v", err)
+			// Don't fail the request, just log the warning
+		}
+
+		if resolvedRequests > 0 {
+			var nickname string
+			if err := db.DB.QueryRow("SELECT nickname FROM users WHERE id = ?", userID).Scan(&nickname); err != nil {
+				nickname = "Unknown User"
+			}
+			notifyGroupRequestResolved(hub, userID, nickname, requestBody.GroupID, groupTitle)
+		}
+
+		chatID, err := group.GetGroupChatID(db.DB, requestBody.GroupID)
+		if err != nil {
+			log.Printf("Warning: Failed to get chat for group %s: %v", requestBody.GroupID, err)
+		}
+
+		resp := map[string]interface{}{
+			"message":    "Successfully joined group",
+			"group_id":   requestBody.GroupID,
+			"group_name": groupTitle,
+			"chat_id":    chatID,
+		}
+
+		utils.WriteSuccessJSON(w, resp, http.StatusOK)
 	}
+}
+
+// DeleteGroupHandler lets the creator delete a group that still has members.
+// The group is hard-deleted rather than archived: its chat thread and messages, events,
+// posts, memberships, requests and invitations all go with it, the same as when the last
+// member leaves. Former members get a "group_deleted" notification.
+func DeleteGroupHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(string)
+		if userID == "" {
+			utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var requestBody struct {
+			GroupID string `json:"group_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if requestBody.GroupID == "" {
+			utils.WriteErrorJSON(w, "Missing group_id", http.StatusBadRequest)
+			return
+		}
+
+		var creatorID, groupTitle string
+		err := db.DB.QueryRow("SELECT creator_id, title FROM groups WHERE id = ?", requestBody.GroupID).Scan(&creatorID, &groupTitle)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if creatorID != userID {
+			utils.WriteErrorJSON(w, "Unauthorized: Only the group creator can delete the group", http.StatusForbidden)
+			return
+		}
+
+		// Collect the members before their memberships are deleted so they can be notified
+		rows, err := db.DB.Query("SELECT user_id FROM group_memberships WHERE group_id = ?", requestBody.GroupID)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to get group members: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var memberIDs []string
+		for rows.Next() {
+			var memberID string
+			if err := rows.Scan(&memberID); err != nil {
+				rows.Close()
+				utils.WriteErrorJSON(w, "Failed to scan member: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			memberIDs = append(memberIDs, memberID)
+		}
+		rows.Close()
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			utils.WriteErrorJSON(w, "Failed to begin transaction: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if err := group.DeleteGroupTx(tx, requestBody.GroupID); err != nil {
+			utils.WriteErrorJSON(w, "Failed to delete group: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			utils.WriteErrorJSON(w, "Failed to commit deletion: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		websocket.NewChatService(db.DB).InvalidateUserChats(append(memberIDs, userID)...)
+
+		websocket.SendGroupDeletedNotification(hub, memberIDs, requestBody.GroupID, groupTitle, userID)
 
-	utils.WriteSuccessJSON(w, resp, http.StatusOK)
+		resp := map[string]interface{}{
+			"message":       "Group deleted successfully",
+			"group_id":      requestBody.GroupID,
+			"group_name":    groupTitle,
+			"group_deleted": true,
+		}
+		utils.WriteSuccessJSON(w, resp, http.StatusOK)
+	}
 }
 
 // Handler for Leaving a Group
@@ -1466,7 +1843,7 @@ func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check if user is a member (creator or member in group_memberships)
 	isCreator := creatorID == userID
-	isMember := memberRole.Valid && (memberRole.String == "member" || memberRole.String == "admin")
+	isMember := memberRole.Valid && group.IsMemberRole(memberRole.String)
 
 	if !isMember && !isCreator {
 		utils.WriteErrorJSON(w, "You are not a member of this group", http.StatusForbidden)
@@ -1487,7 +1864,7 @@ func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
 		// If creator is the only member (memberCount = 1 and it's just the creator)
 		if memberCount == 1 {
 			// Creator is the only member - delete the entire group
-			if err := deleteGroupCompletely(tx, requestBody.GroupID); err != nil {
+			if err := group.DeleteGroupTx(tx, requestBody.GroupID); err != nil {
 				utils.WriteErrorJSON(w, "Failed to delete group: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -1496,6 +1873,7 @@ func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
 				utils.WriteErrorJSON(w, "Failed to commit deletion: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
+			websocket.NewChatService(db.DB).InvalidateUserChats(userID)
 
 			resp := map[string]interface{}{
 				"message":       "Group deleted successfully (you were the only member)",
@@ -1562,6 +1940,9 @@ func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
 			utils.WriteErrorJSON(w, "Failed to commit leave operation: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		chatService := websocket.NewChatService(db.DB)
+		chatService.InvalidateGroupChat(requestBody.GroupID)
+		chatService.InvalidateUserChats(userID)
 
 		resp := map[string]interface{}{
 			"message":    "Successfully left group",
@@ -1573,85 +1954,116 @@ func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Helper function to delete group and all related data
-func deleteGroupCompletely(tx *sql.Tx, groupID string) error {
-	// Delete chat participants first
-	_, err := tx.Exec(`
-        DELETE FROM chat_participants 
-        WHERE chat_id IN (
-            SELECT id FROM chat_threads 
-            WHERE is_group = 1 AND group_id = ?
-        )
-    `, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete chat participants: %v", err)
+// MuteGroupHandler stops join request, invitation and event notifications from a group for the user
+func MuteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	setGroupMuted(w, r, true)
+}
+
+// UnmuteGroupHandler turns a muted group's notifications back on
+func UnmuteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	setGroupMuted(w, r, false)
+}
+
+// setGroupMuted saves the user's mute setting for the group in the request body
+func setGroupMuted(w http.ResponseWriter, r *http.Request, muted bool) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Delete chat messages
-	_, err = tx.Exec(`
-        DELETE FROM messages 
-        WHERE chat_id IN (
-            SELECT id FROM chat_threads 
-            WHERE is_group = 1 AND group_id = ?
-        )
-    `, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete chat messages: %v", err)
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
 	}
 
-	// Delete chat threads
-	_, err = tx.Exec(`
-        DELETE FROM chat_threads 
-        WHERE is_group = 1 AND group_id = ?
-    `, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete chat threads: %v", err)
+	var req struct {
+		GroupID string `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.GroupID == "" {
+		utils.WriteErrorJSON(w, "group_id is required", http.StatusBadRequest)
+		return
 	}
 
-	// Delete event responses first
-	_, err = tx.Exec(`
-        DELETE FROM event_responses 
-        WHERE event_id IN (SELECT id FROM events WHERE group_id = ?)
-    `, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete event responses: %v", err)
+	if err := group.SetGroupMuted(db.DB, req.GroupID, userID, muted); err != nil {
+		if err == sql.ErrNoRows {
+			utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to update group notifications: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// Delete events
-	_, err = tx.Exec(`DELETE FROM events WHERE group_id = ?`, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete events: %v", err)
+	utils.WriteSuccessJSON(w, map[string]interface{}{"group_id": req.GroupID, "muted": muted}, http.StatusOK)
+}
+
+// PinPostHandler pins (POST) a group post as the group's announcement, replacing the previous one,
+// or unpins (DELETE) it. Only admins or the creator can do this.
+func PinPostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Delete group memberships
-	_, err = tx.Exec(`DELETE FROM group_memberships WHERE group_id = ?`, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete group members: %v", err)
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
 	}
 
-	// Delete group requests
-	_, err = tx.Exec(`DELETE FROM group_requests WHERE group_id = ?`, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete group requests: %v", err)
+	var req struct {
+		GroupID string `json:"group_id"`
+		PostID  int64  `json:"post_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.GroupID == "" {
+		utils.WriteErrorJSON(w, "group_id is required", http.StatusBadRequest)
+		return
 	}
 
-	// Delete group invitations
-	_, err = tx.Exec(`DELETE FROM group_invitations WHERE group_id = ?`, groupID)
+	role, err := group.GetMemberRole(db.DB, req.GroupID, userID)
+	if err == sql.ErrNoRows {
+		utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		return fmt.Errorf("failed to delete group invitations: %v", err)
+		utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !group.CanAdminister(role) {
+		utils.WriteErrorJSON(w, "Unauthorized: Only group admins or creator can pin posts", http.StatusForbidden)
+		return
 	}
 
-	// Delete posts in the group
-	_, err = tx.Exec(`DELETE FROM posts WHERE group_id = ?`, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete group posts: %v", err)
+	if r.Method == http.MethodDelete {
+		if err := group.UnpinPost(db.DB, req.GroupID); err != nil {
+			utils.WriteErrorJSON(w, "Failed to unpin post: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteSuccessJSON(w, "Post unpinned successfully", http.StatusOK)
+		return
 	}
 
-	// Finally delete the group itself
-	_, err = tx.Exec(`DELETE FROM groups WHERE id = ?`, groupID)
-	if err != nil {
-		return fmt.Errorf("failed to delete group: %v", err)
+	if req.PostID <= 0 {
+		utils.WriteErrorJSON(w, "post_id is required", http.StatusBadRequest)
+		return
 	}
 
-	return nil
+	if err := group.PinPost(db.DB, req.GroupID, req.PostID); err != nil {
+		if errors.Is(err, group.ErrPostNotInGroup) {
+			utils.WriteErrorJSON(w, "Post not found in this group", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to pin post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "Post pinned successfully", http.StatusOK)
 }
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"math"
 	"net/http"
 	"social-network/pkg/auth"
 	"social-network/pkg/models/user"
 	"social-network/pkg/utils"
+	"strconv"
+	"strings"
 )
 
 // LoginHandler handles user login
@@ -22,7 +26,16 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.UserAgent = r.UserAgent()
+	req.IPAddress = clientIP(r)
+
 	userData, token, err := user.Login(req)
+	var locked *user.LoginLockedError
+	if errors.As(err, &locked) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		utils.WriteErrorJSON(w, locked.Error(), http.StatusTooManyRequests)
+		return
+	}
 	if err != nil {
 		// default error status
 		status := http.StatusBadRequest
@@ -83,3 +96,179 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	utils.WriteSuccessJSON(w, map[string]string{"message": "Logged out successfully"}, http.StatusOK)
 }
+
+// ChangePasswordHandler changes the authenticated user's password and signs out their other sessions
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.OldPassword == "" || req.NewPassword == "" {
+		utils.WriteErrorJSON(w, "old_password and new_password are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := user.ChangePassword(userID, req.OldPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, user.ErrWrongCurrentPassword):
+			utils.WriteErrorJSON(w, "Current password is incorrect", http.StatusUnauthorized)
+		case errors.Is(err, user.ErrUserNotFound):
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, user.ErrSamePassword),
+			errors.Is(err, user.ErrPasswordTooShort),
+			errors.Is(err, user.ErrPasswordTooLong),
+			errors.Is(err, user.ErrPasswordNoUpper),
+			errors.Is(err, user.ErrPasswordNoLower),
+			errors.Is(err, user.ErrPasswordNoDigit):
+			utils.WriteErrorJSON(w, err.Error(), http.StatusBadRequest)
+		default:
+			utils.WriteErrorJSON(w, "Failed to change password: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Keep the session that made the change and sign out the rest
+	if err := user.InvalidateOtherSessions(userID, requestToken(r)); err != nil {
+		log.Printf("Error invalidating other sessions: %v", err)
+	}
+
+	utils.WriteSuccessJSON(w, map[string]string{"message": "Password changed successfully"}, http.StatusOK)
+}
+
+// DeleteAccountHandler permanently deletes the authenticated user's account after they re-enter their password
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Password == "" {
+		utils.WriteErrorJSON(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := user.CheckPassword(userID, req.Password); err != nil {
+		switch {
+		case errors.Is(err, user.ErrWrongCurrentPassword):
+			utils.WriteErrorJSON(w, "Password is incorrect", http.StatusUnauthorized)
+		case errors.Is(err, user.ErrUserNotFound):
+			utils.WriteErrorJSON(w, "User not found", http.StatusNotFound)
+		default:
+			utils.WriteErrorJSON(w, "Failed to verify password: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := user.DeleteAccount(userID); err != nil {
+		log.Printf("Error deleting account %s: %v", userID, err)
+		utils.WriteErrorJSON(w, "Failed to delete account: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]string{"message": "Account deleted successfully"}, http.StatusOK)
+}
+
+// SessionsHandler lists the devices the user is logged in on
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := auth.ListSessions(userID, requestToken(r))
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, sessions, http.StatusOK)
+}
+
+// RevokeSessionHandler logs out one of the user's sessions, e.g. a lost device
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.SessionID == "" {
+		utils.WriteErrorJSON(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.RevokeSession(userID, req.SessionID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			utils.WriteErrorJSON(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to revoke session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]string{"message": "Session revoked"}, http.StatusOK)
+}
+
+// requestToken returns the session token the request was authenticated with,
+// looking in the same places as AuthMiddleware
+func requestToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"social-network/pkg/db"
+	"social-network/pkg/db/sqlite"
+	"social-network/pkg/handlers"
+	"social-network/pkg/models/user"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupLoginDB points db.DB at an empty, migrated database
+func setupLoginDB(t *testing.T) {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../db/migrations/sqlite"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	conn, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+}
+
+// failLogin posts a login for an unknown account and returns the status code
+func failLogin(remoteAddr, forwardedFor, identifier string) int {
+	body := fmt.Sprintf(`{"identifier": %q, "password": "Wrong1234"}`, identifier)
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	handlers.LoginHandler(rec, req)
+	return rec.Code
+}
+
+func TestLoginIPLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	setupLoginDB(t)
+
+	// A new X-Forwarded-For and account each time must not dodge the IP limit
+	for i := 0; i < user.MaxIPLoginFailures; i++ {
+		code := failLogin("192.0.2.7:5000", fmt.Sprintf("203.0.113.%d", i), fmt.Sprintf("nobody%d", i))
+		if code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, code)
+		}
+	}
+	if code := failLogin("192.0.2.7:5000", "203.0.113.250", "another"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the client IP to be locked out, got %d", code)
+	}
+
+	// Nor can a client lock out an address by claiming it
+	if code := failLogin("192.0.2.8:5000", "192.0.2.7", "another"); code != http.StatusUnauthorized {
+		t.Errorf("expected a forged X-Forwarded-For to be ignored, got %d", code)
+	}
+}
+
+func TestLoginIPLockoutBehindTrustedProxy(t *testing.T) {
+	setupLoginDB(t)
+	if err := handlers.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+	t.Cleanup(func() { handlers.SetTrustedProxies(nil) })
+
+	// The proxy appends the real client, entries the client sent come before it
+	for i := 0; i < user.MaxIPLoginFailures; i++ {
+		forwarded := fmt.Sprintf("203.0.113.%d, 198.51.100.7, 10.0.0.2", i)
+		if code := failLogin("10.0.0.1:5000", forwarded, fmt.Sprintf("nobody%d", i)); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, code)
+		}
+	}
+	if code := failLogin("10.0.0.1:5000", "198.51.100.7", "another"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the client behind the proxy to be locked out, got %d", code)
+	}
+	if code := failLogin("10.0.0.1:5000", "198.51.100.8", "another"); code != http.StatusUnauthorized {
+		t.Errorf("expected other clients behind the proxy to log in, got %d", code)
+	}
+}
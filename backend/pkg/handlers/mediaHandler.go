@@ -95,4 +95,23 @@ func (h *MediaHandler) UploadMediaHandler(w http.ResponseWriter, r *http.Request
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
+
+// ServeMediaFiles serves the uploaded media in MediaUploadDir. Avatar thumbnails are
+// linked without checking they exist (see utils.AvatarThumb), so a missing thumbnail
+// is answered with the full-size file of the same name.
+func ServeMediaFiles() http.Handler {
+	files := http.FileServer(http.Dir(MediaUploadDir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, isThumb := strings.CutPrefix(r.URL.Path, "thumbs/")
+		if isThumb && name != "" && !strings.Contains(name, "/") {
+			if _, err := os.Stat(filepath.Join(MediaUploadDir, "thumbs", name)); err != nil {
+				fallback := *r.URL
+				fallback.Path, fallback.RawPath = name, ""
+				r = r.Clone(r.Context())
+				r.URL = &fallback
+			}
+		}
+		files.ServeHTTP(w, r)
+	})
+}
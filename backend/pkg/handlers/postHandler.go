@@ -2,19 +2,56 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"social-network/pkg/db"
 	"social-network/pkg/models/post"
+	"social-network/pkg/sockets/websocket"
 	"social-network/pkg/utils"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type PostHandler struct {
 	PostService *post.PostService
+	Hub         *websocket.Hub
 }
 
-func NewPostHandler(postService *post.PostService) *PostHandler {
-	return &PostHandler{PostService: postService}
+func NewPostHandler(postService *post.PostService, hub *websocket.Hub) *PostHandler {
+	return &PostHandler{PostService: postService, Hub: hub}
+}
+
+// notifyMentions sends a post_mention notification to every mentioned user allowed to see the post
+func (h *PostHandler) notifyMentions(postID int64, authorID string) {
+	recipients, err := h.PostService.GetMentionRecipients(postID)
+	if err != nil {
+		log.Printf("Error getting mention recipients for post %d: %v", postID, err)
+		return
+	}
+	if len(recipients) > 0 {
+		websocket.SendPostMentionNotification(h.Hub, authorID, recipients, strconv.FormatInt(postID, 10))
+	}
+}
+
+// parseInlineCommentsParam reads the optional "comments" query parameter: how many
+// of the latest comments to inline under each post (default 0 = none)
+func parseInlineCommentsParam(r *http.Request) (int, error) {
+	commentsStr := r.URL.Query().Get("comments")
+	if commentsStr == "" {
+		return 0, nil
+	}
+
+	comments, err := strconv.Atoi(commentsStr)
+	if err != nil || comments < 0 {
+		return 0, errors.New("Invalid comments parameter: must be a non-negative integer")
+	}
+	if comments > post.MaxInlineComments {
+		comments = post.MaxInlineComments
+	}
+
+	return comments, nil
 }
 
 // Handlers creation of a new post
@@ -37,9 +74,15 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Content = strings.TrimSpace(req.Content)
 
 	// Validate the request
 	if _, err := post.ValidateCreatePostRequest(&req); err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, http.StatusBadRequest)
+			return
+		}
 		response := post.CreatePostResponse{
 			Success: false,
 			Error:   "Validation error: " + err.Error(),
@@ -48,6 +91,7 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	req.Content = utils.SanitizeText(req.Content)
 
 	// Create post in database
 	postID, err := h.PostService.CreatePost(&req, userID)
@@ -61,6 +105,8 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	go h.notifyMentions(postID, userID)
+
 	authorData, err := h.PostService.GetAuthorData(userID)
 	if err != nil {
 		response := post.CreatePostResponse{
@@ -109,6 +155,9 @@ func (h *PostHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if utils.RejectDeepOffset(w, offset, "pass pagination.nextCursor as the cursor parameter to load older posts") {
+		return
+	}
 
 	// Parse limit parameter (default to 20, max 100)
 	limitStr := r.URL.Query().Get("limit")
@@ -125,26 +174,74 @@ func (h *PostHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Get posts from the DB with pagination
-	posts, err := h.PostService.GetPosts(userID, offset, limit)
+	commentsPerPost, err := parseInlineCommentsParam(r)
+	if err != nil {
+		utils.WriteErrorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Parse since parameter: the newest post ID the client already has
+	var sinceID int64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		sinceID, err = strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil || sinceID <= 0 {
+			utils.WriteErrorJSON(w, "Invalid since parameter: must be a positive post ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get posts from the DB, one extra to know whether another page exists.
+	// A cursor (from a previous response) pages by keyset, otherwise the offset is used.
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	var posts []post.Post
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		if offset != 0 || sinceID != 0 {
+			utils.WriteErrorJSON(w, "The cursor parameter cannot be combined with offset or since", http.StatusBadRequest)
+			return
+		}
+		beforeCreatedAt, beforeID, err := post.DecodeFeedCursor(cursor)
+		if err != nil {
+			utils.WriteErrorJSON(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		posts, err = h.PostService.GetPostsAfter(ctx, userID, beforeCreatedAt, beforeID, limit+1, commentsPerPost)
+	} else {
+		posts, err = h.PostService.GetPosts(ctx, userID, sinceID, offset, limit+1, commentsPerPost)
+	}
 	if err != nil {
 		response := post.GetPostsResponse{
 			Success: false,
 			Error:   "Failed to retrieve posts: " + err.Error(),
 		}
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(utils.QueryErrorStatus(err))
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	// Return success response with posts including author details
-	response := map[string]interface{}{
-		"success": true,
-		"posts":   posts,
-		"hasMore": len(posts) >= limit,
+	hasMore := len(posts) > limit
+	if hasMore {
+		posts = posts[:limit]
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+
+	total, err := h.PostService.CountVisiblePosts(ctx, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to count posts: "+err.Error(), utils.QueryErrorStatus(err))
+		return
+	}
+	if cursor == "" && sinceID == 0 {
+		// plain offset paging over the whole feed, the total is authoritative
+		hasMore = offset+len(posts) < total
+	}
+
+	nextCursor := ""
+	if hasMore && len(posts) > 0 {
+		nextCursor = post.EncodeFeedCursor(posts[len(posts)-1])
+	}
+
+	// Return success response with posts including author details
+	utils.WriteCursorPaginatedJSON(w, posts, hasMore, total, offset, limit, nextCursor)
 }
 
 func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
@@ -168,13 +265,19 @@ func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get post from the database
-	postObj, err := h.PostService.GetPostByID(postIDstr, userID)
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	postObj, err := h.PostService.GetPostByID(ctx, postIDstr, userID)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
+		if err.Error() == "sql: no rows in result set" || errors.Is(err, post.ErrPostNotFound) {
 			utils.WriteErrorJSON(w, "Post not found", http.StatusNotFound)
 			return
 		}
-		utils.WriteErrorJSON(w, "Failed to retrieve post: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, post.ErrPostDeleted) {
+			utils.WriteErrorJSON(w, "This post has been deleted", http.StatusGone)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to retrieve post: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
@@ -234,6 +337,9 @@ func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	if offset < 0 {
 		offset = 0
 	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
 
 	limit := reqBody.Limit
 	if limit <= 0 {
@@ -244,20 +350,22 @@ func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user posts from DB
-	posts, err := h.PostService.GetUserPosts(userID, reqBody.UserID, offset, limit)
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	posts, err := h.PostService.GetUserPosts(ctx, userID, reqBody.UserID, offset, limit)
 	if err != nil {
-		utils.WriteErrorJSON(w, "Failed to retrieve user posts: "+err.Error(), http.StatusInternalServerError)
+		utils.WriteErrorJSON(w, "Failed to retrieve user posts: "+err.Error(), utils.QueryErrorStatus(err))
 		return
 	}
 
-	// Return success response with user posts
-	response := map[string]interface{}{
-		"success": true,
-		"posts":   posts,
-		"hasMore": len(posts) >= limit,
+	total, err := h.PostService.CountUserPosts(userID, reqBody.UserID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to count user posts: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+
+	// Return success response with user posts
+	utils.WritePaginatedJSON(w, posts, offset+len(posts) < total, total, offset, limit)
 }
 
 func (h *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
@@ -292,9 +400,15 @@ func (h *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
 		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Content = strings.TrimSpace(req.Content)
 
 	// Validate the request
 	if _, err := post.ValidateEditPostRequest(&req); err != nil {
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, http.StatusBadRequest)
+			return
+		}
 		response := post.EditPostResponse{
 			Success: false,
 			Error:   "validation error: " + err.Error(),
@@ -303,12 +417,13 @@ func (h *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	req.Content = utils.SanitizeText(req.Content)
 
 	// Edit post in database
-	err = h.PostService.EditPost(postID, &req, userID)
+	modified, err := h.PostService.EditPost(postID, &req, userID)
 	if err != nil {
 		status := http.StatusInternalServerError
-		if err.Error() == "unauthorized: you are not the author of this post" {
+		if err.Error() == "unauthorized: you are not the author of this post" || errors.Is(err, post.ErrRepostNotEdited) {
 			status = http.StatusForbidden
 		}
 		response := post.EditPostResponse{
@@ -321,7 +436,8 @@ func (h *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := post.EditPostResponse{
-		Success: true,
+		Success:  true,
+		Modified: modified,
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -360,6 +476,9 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		if err.Error() == "unauthorized: you are not the author of this post" {
 			status = http.StatusForbidden
 		}
+		if errors.Is(err, post.ErrPostDeleted) {
+			status = http.StatusConflict
+		}
 
 		response := post.DeletePostResponse{
 			Success: false,
@@ -378,6 +497,101 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// RestorePost undoes the deletion of one of the user's posts within post.RestoreWindow
+func (h *PostHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get the user ID from the context
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Get post ID from URL parameters
+	postIDstr := r.URL.Query().Get("post_id")
+	if postIDstr == "" {
+		utils.WriteErrorJSON(w, "Post ID is required", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := strconv.ParseInt(postIDstr, 10, 64)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Invalid Post ID format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PostService.RestorePost(postID, userID); err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			utils.WriteErrorJSON(w, "Post not found", http.StatusNotFound)
+		case err.Error() == "unauthorized: you are not the author of this post":
+			utils.WriteErrorJSON(w, "You can only restore your own posts", http.StatusForbidden)
+		case errors.Is(err, post.ErrPostNotDeleted):
+			utils.WriteErrorJSON(w, "Post is not deleted", http.StatusConflict)
+		case errors.Is(err, post.ErrRestoreWindowExpired):
+			utils.WriteErrorJSON(w, "Post was deleted too long ago to be restored", http.StatusGone)
+		case errors.Is(err, post.ErrPostRemovedByGroup):
+			utils.WriteErrorJSON(w, "Post was removed by a group moderator and cannot be restored", http.StatusForbidden)
+		case errors.Is(err, post.ErrAlreadyReposted):
+			utils.WriteErrorJSON(w, "You have reposted this post again since deleting this repost", http.StatusConflict)
+		default:
+			utils.WriteErrorJSON(w, "Failed to restore post: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "Post restored successfully", http.StatusOK)
+}
+
+// RepostPost reshares another user's public post (?post_id=) to the user's feed
+func (h *PostHandler) RepostPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get the user ID from the context
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Get post ID from URL parameters
+	postIDstr := r.URL.Query().Get("post_id")
+	if postIDstr == "" {
+		utils.WriteErrorJSON(w, "Post ID is required", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := strconv.ParseInt(postIDstr, 10, 64)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Invalid Post ID format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repostID, err := h.PostService.Repost(postID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			utils.WriteErrorJSON(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, post.ErrCannotRepost):
+			utils.WriteErrorJSON(w, "Only other users' public posts can be reposted", http.StatusForbidden)
+		case errors.Is(err, post.ErrAlreadyReposted):
+			utils.WriteErrorJSON(w, "You have already reposted this post", http.StatusConflict)
+		default:
+			utils.WriteErrorJSON(w, "Failed to repost: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]interface{}{"id": repostID}, http.StatusCreated)
+}
+
 func (h *PostHandler) LikePost(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -406,6 +620,14 @@ func (h *PostHandler) LikePost(w http.ResponseWriter, r *http.Request) {
 
 	isLiked, err, likeCount := h.PostService.LikePost(postID, userID)
 	if err != nil {
+		if errors.Is(err, post.ErrPostNotFound) {
+			utils.WriteErrorJSON(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, post.ErrPostDeleted) {
+			utils.WriteErrorJSON(w, "Cannot like a deleted post", http.StatusGone)
+			return
+		}
 		utils.WriteErrorJSON(w, "Failed to like post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -417,3 +639,128 @@ func (h *PostHandler) LikePost(w http.ResponseWriter, r *http.Request) {
 
 	utils.WriteSuccessJSON(w, response, http.StatusOK)
 }
+
+// ReactToPost sets, changes or removes the user's emoji reaction on a post
+func (h *PostHandler) ReactToPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get the user ID from the context
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Get post ID from URL parameters
+	postIDStr := r.URL.Query().Get("post_id")
+	if postIDStr == "" {
+		utils.WriteErrorJSON(w, "Post ID is required", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := strconv.ParseInt(postIDStr, 10, 64)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Invalid Post ID format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reaction string `json:"reaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.PostService.ReactToPost(postID, userID, req.Reaction)
+	if err != nil {
+		if errors.Is(err, post.ErrInvalidReaction) {
+			utils.WriteErrorJSON(w, "Invalid reaction: must be one of "+strings.Join(post.ReactionTypes, ", "), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, post.ErrPostNotFound) {
+			utils.WriteErrorJSON(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, post.ErrPostDeleted) {
+			utils.WriteErrorJSON(w, "Cannot react to a deleted post", http.StatusGone)
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			utils.WriteErrorJSON(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		utils.WriteErrorJSON(w, "Failed to react to post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, summary, http.StatusOK)
+}
+
+// GetPostsByHashtag returns the visible posts tagged with ?tag=, paginated with offset and limit
+func (h *PostHandler) GetPostsByHashtag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get the user ID from the context
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	tag := post.NormalizeHashtag(r.URL.Query().Get("tag"))
+	if tag == "" {
+		utils.WriteErrorJSON(w, "Tag parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	// Parse offset parameter (default to 0)
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			utils.WriteErrorJSON(w, "Invalid offset parameter: must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
+
+	// Parse limit parameter (default to 20, max 100)
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			utils.WriteErrorJSON(w, "Invalid limit parameter: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit > 100 {
+			limit = 100 // Cap at 100 to prevent excessive load
+		}
+	}
+
+	// Fetch one extra post to know whether another page exists
+	ctx, cancel := db.WithQueryTimeout(r.Context())
+	defer cancel()
+	posts, err := h.PostService.GetPostsByHashtag(ctx, tag, userID, limit+1, offset)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to retrieve posts: "+err.Error(), utils.QueryErrorStatus(err))
+		return
+	}
+
+	hasMore := len(posts) > limit
+	if hasMore {
+		posts = posts[:limit]
+	}
+
+	utils.WritePaginatedJSON(w, posts, hasMore, utils.TotalUnknown, offset, limit)
+}
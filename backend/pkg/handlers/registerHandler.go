@@ -2,11 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"social-network/pkg/models/user"
 	"social-network/pkg/utils"
-
 )
 
 // RegisterHandler handles user registration
@@ -24,19 +24,15 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 	newUser, err := user.Register(req)
 	if err != nil {
-		// Default error status
-		status := http.StatusBadRequest
-		switch err {
-			case user.ErrEmailAlreadyExists:
-				status = http.StatusConflict
-			case user.ErrNicknameAlreadyExists:
-				status = http.StatusConflict
-
-		default:
-			utils.WriteErrorJSON(w, err.Error(), status)
-			log.Printf("Error registering user: %v", err)
+		var validationErrs utils.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			utils.WriteValidationErrorsJSON(w, validationErrs, validationErrorsStatus(validationErrs))
 			return
 		}
+
+		utils.WriteErrorJSON(w, "Failed to register user", http.StatusInternalServerError)
+		log.Printf("Error registering user: %v", err)
+		return
 	}
 
 	// Dont return the password hash (security best practice)
@@ -49,6 +45,17 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(newUser)
 }
 
+// validationErrorsStatus picks the status for a failed validation: only an already used
+// email or nickname is a conflict, anything else is a bad request
+func validationErrorsStatus(errs utils.ValidationErrors) int {
+	for _, fieldErr := range errs {
+		if fieldErr.Code != utils.CodeTaken {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusConflict
+}
+
 // const (
 // 	MaxFileSize = 5 << 20 // 5MB
 // 	UploadDir   = "./uploads/avatars"
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"social-network/pkg/db"
+	"social-network/pkg/models/group"
+	"social-network/pkg/models/post"
+	"social-network/pkg/models/report"
+	"social-network/pkg/sockets/websocket"
+	"social-network/pkg/utils"
+)
+
+// ReportPostHandler reports a post the user can see. Reports of group posts are sent to
+// the group's moderators, other reports are kept for later review.
+func ReportPostHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PostID int64  `json:"post_id"`
+			Reason string `json:"reason"`
+		}
+		if !decodeReportRequest(w, r, &req) {
+			return
+		}
+		if req.PostID <= 0 {
+			utils.WriteErrorJSON(w, "post_id is required", http.StatusBadRequest)
+			return
+		}
+
+		createReport(w, r, hub, report.TargetPost, req.PostID, req.Reason)
+	}
+}
+
+// ReportCommentHandler reports a comment on a post the user can see, like ReportPostHandler
+func ReportCommentHandler(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CommentID string `json:"comment_id"`
+			Reason    string `json:"reason"`
+		}
+		if !decodeReportRequest(w, r, &req) {
+			return
+		}
+		commentID, err := strconv.ParseInt(req.CommentID, 10, 64)
+		if err != nil || commentID <= 0 {
+			utils.WriteErrorJSON(w, "comment_id is required", http.StatusBadRequest)
+			return
+		}
+
+		createReport(w, r, hub, report.TargetComment, commentID, req.Reason)
+	}
+}
+
+func decodeReportRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func createReport(w http.ResponseWriter, r *http.Request, hub *websocket.Hub, targetType string, targetID int64, reason string) {
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	target, err := report.GetTarget(db.DB, targetType, targetID)
+	if errors.Is(err, report.ErrTargetNotFound) {
+		utils.WriteErrorJSON(w, "Content not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get content: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Content the user can't see is reported as missing, so reports don't reveal it exists
+	canView, err := post.NewPostService(db.DB).CanViewPost(target.PostID, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to check post access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canView {
+		utils.WriteErrorJSON(w, "Content not found", http.StatusNotFound)
+		return
+	}
+	if target.AuthorID == userID {
+		utils.WriteErrorJSON(w, "You can't report your own content", http.StatusBadRequest)
+		return
+	}
+
+	created, err := report.CreateReport(db.DB, userID, target, reason)
+	switch {
+	case errors.Is(err, report.ErrAlreadyReported):
+		utils.WriteErrorJSON(w, "You have already reported this "+targetType, http.StatusConflict)
+		return
+	case errors.Is(err, report.ErrReasonRequired):
+		utils.WriteErrorJSON(w, "reason is required", http.StatusBadRequest)
+		return
+	case errors.Is(err, report.ErrReasonTooLong):
+		utils.WriteErrorJSON(w, "reason must be at most "+strconv.Itoa(report.MaxReasonLength)+" characters", http.StatusBadRequest)
+		return
+	case err != nil:
+		utils.WriteErrorJSON(w, "Failed to create report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if created.GroupID != nil {
+		go hub.NotifyContentReported(db.DB, strconv.FormatInt(*created.GroupID, 10), userID, targetType)
+	}
+
+	utils.WriteSuccessJSON(w, created, http.StatusCreated)
+}
+
+// GetPendingReportsHandler lists the pending reports of a group's content. Only moderators,
+// admins and the creator can see them.
+func GetPendingReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		utils.WriteErrorJSON(w, "Group ID is required", http.StatusBadRequest)
+		return
+	}
+
+	role, err := group.GetMemberRole(db.DB, groupID, userID)
+	if err == sql.ErrNoRows {
+		utils.WriteErrorJSON(w, "Group not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get group info: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !group.CanModerate(role) {
+		utils.WriteErrorJSON(w, "Unauthorized: Only group moderators, admins or the creator can view reports", http.StatusForbidden)
+		return
+	}
+
+	reports, err := report.GetPendingReports(db.DB, groupID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get reports: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, reports, http.StatusOK)
+}
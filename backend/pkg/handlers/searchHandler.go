@@ -6,7 +6,9 @@ import (
 	"social-network/pkg/db"
 	"social-network/pkg/models/group"
 	"social-network/pkg/models/post"
+	"social-network/pkg/models/search"
 	"social-network/pkg/models/user"
+	"social-network/pkg/utils"
 	"strconv"
 	"strings"
 )
@@ -66,6 +68,9 @@ func SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
 			offset = 0
 		}
 	}
+	if utils.RejectDeepOffset(w, offset, "narrow the search to find further results") {
+		return
+	}
 
 	// Use user model to search users
 	users, err := user.SearchUsers(db.DB, query, userID, limit, offset)
@@ -73,14 +78,16 @@ func SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
 		writeErrorJSON(w, "Failed to search users: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	total, err := user.CountSearchUsers(db.DB, query, userID)
+	if err != nil {
+		writeErrorJSON(w, "Failed to count users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"users": users,
-	})
+	utils.WritePaginatedJSON(w, users, offset+len(users) < total, total, offset, limit)
 }
 
-// SearchGroupsHandler searches for public groups by title or description
+// SearchGroupsHandler searches for groups by title or description; private groups only for their members
 func SearchGroupsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -125,6 +132,9 @@ func SearchGroupsHandler(w http.ResponseWriter, r *http.Request) {
 			offset = 0
 		}
 	}
+	if utils.RejectDeepOffset(w, offset, "narrow the search to find further results") {
+		return
+	}
 
 	// Use group model to search groups
 	groups, err := group.SearchGroups(db.DB, query, userID, limit, offset)
@@ -132,11 +142,13 @@ func SearchGroupsHandler(w http.ResponseWriter, r *http.Request) {
 		writeErrorJSON(w, "Failed to search groups: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	total, err := group.CountSearchGroups(db.DB, query, userID)
+	if err != nil {
+		writeErrorJSON(w, "Failed to count groups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"groups": groups,
-	})
+	utils.WritePaginatedJSON(w, groups, offset+len(groups) < total, total, offset, limit)
 }
 
 // SearchPostsHandler searches for posts by content (only posts user can see)
@@ -184,23 +196,46 @@ func SearchPostsHandler(w http.ResponseWriter, r *http.Request) {
 			offset = 0
 		}
 	}
+	if utils.RejectDeepOffset(w, offset, "narrow the search to find further results") {
+		return
+	}
+
+	// Parse optional group_id parameter to search within a single group
+	var groupID int64
+	if groupIDStr := r.URL.Query().Get("group_id"); groupIDStr != "" {
+		var err error
+		groupID, err = strconv.ParseInt(groupIDStr, 10, 64)
+		if err != nil || groupID <= 0 {
+			writeErrorJSON(w, "Invalid group_id parameter", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Create post service and search posts
 	postService := post.NewPostService(db.DB)
-	posts, err := postService.SearchPosts(query, userID, limit, offset)
+	posts, err := postService.SearchPosts(query, userID, groupID, limit, offset)
+	if err != nil {
+		switch err.Error() {
+		case "group not found":
+			writeErrorJSON(w, "Group not found", http.StatusNotFound)
+		case "unauthorized: user is not a member of this group":
+			writeErrorJSON(w, "You must be a member of this group to search its posts", http.StatusForbidden)
+		default:
+			writeErrorJSON(w, "Failed to search posts: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	total, err := postService.CountSearchPosts(query, userID, groupID)
 	if err != nil {
-		writeErrorJSON(w, "Failed to search posts: "+err.Error(), http.StatusInternalServerError)
+		writeErrorJSON(w, "Failed to count posts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"posts":   posts,
-		"hasMore": len(posts) >= limit,
-	})
+	utils.WritePaginatedJSON(w, posts, offset+len(posts) < total, total, offset, limit)
 }
 
-// GlobalSearchHandler performs a combined search across users, groups, and posts
+// GlobalSearchHandler performs a combined search across users, groups, and posts and returns
+// one list ranked by relevance (exact > prefix > substring match), with per-type counts
 func GlobalSearchHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -224,13 +259,17 @@ func GlobalSearchHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse type parameter (users, groups, posts, or all)
 	searchType := r.URL.Query().Get("type")
 	if searchType == "" {
-		searchType = "all"
+		searchType = search.TypeAll
+	}
+	if searchType != search.TypeAll && searchType != search.TypeUsers && searchType != search.TypeGroups && searchType != search.TypePosts {
+		writeErrorJSON(w, "Invalid type parameter: must be all, users, groups or posts", http.StatusBadRequest)
+		return
 	}
 
-	// Parse limit parameter (default to 10 for each type when all, 20 for specific type)
+	// Parse limit parameter (default to 10 when searching everything, 20 for a specific type)
 	limitStr := r.URL.Query().Get("limit")
 	limit := 10
-	if searchType != "all" {
+	if searchType != search.TypeAll {
 		limit = 20
 	}
 	if limitStr != "" {
@@ -244,39 +283,11 @@ func GlobalSearchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result := make(map[string]interface{})
-
-	// Search users
-	if searchType == "all" || searchType == "users" {
-		users, err := user.SearchUsers(db.DB, query, userID, limit, 0)
-		if err == nil {
-			result["users"] = users
-		} else {
-			result["users"] = []interface{}{}
-		}
-	}
-
-	// Search groups
-	if searchType == "all" || searchType == "groups" {
-		groups, err := group.SearchGroups(db.DB, query, userID, limit, 0)
-		if err == nil {
-			result["groups"] = groups
-		} else {
-			result["groups"] = []interface{}{}
-		}
-	}
-
-	// Search posts
-	if searchType == "all" || searchType == "posts" {
-		postService := post.NewPostService(db.DB)
-		posts, err := postService.SearchPosts(query, userID, limit, 0)
-		if err == nil {
-			result["posts"] = posts
-		} else {
-			result["posts"] = []interface{}{}
-		}
+	results, err := search.GlobalSearch(db.DB, userID, query, searchType, limit)
+	if err != nil {
+		writeErrorJSON(w, "Failed to search: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	utils.WriteSuccessJSON(w, results, http.StatusOK)
 }
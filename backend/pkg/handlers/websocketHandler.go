@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"social-network/pkg/db"
 	"social-network/pkg/sockets/websocket"
@@ -136,15 +137,47 @@ func GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notifications, err := websocket.GetNotificationsByUserID(db.DB, userID)
+	// Parse offset parameter (default to 0)
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			utils.WriteErrorJSON(w, "Invalid offset parameter: must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+	if utils.RejectDeepOffset(w, offset, "") {
+		return
+	}
+
+	// Parse limit parameter (default to 20, max 100)
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			utils.WriteErrorJSON(w, "Invalid limit parameter: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+		if limit > 100 {
+			limit = 100
+		}
+	}
+
+	notifications, err := websocket.GetNotificationsByUserID(db.DB, userID, limit, offset)
 	if err != nil {
 		utils.WriteErrorJSON(w, "Error fetching notifications", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(notifications)
+	total, unread, err := websocket.CountNotifications(db.DB, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteUnreadPaginatedJSON(w, notifications, offset+len(notifications) < total, total, unread, offset, limit)
 }
 
 func MarkNotificationAsReadHandler(w http.ResponseWriter, r *http.Request) {
@@ -182,6 +215,132 @@ func MarkNotificationAsReadHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Notification marked as read"})
 }
 
+// MarkAllNotificationsReadHandler marks all of the authenticated user's notifications as read
+func MarkAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	updated, err := websocket.MarkAllNotificationsRead(db.DB, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Error marking notifications as read: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]int64{"updated": updated}, http.StatusOK)
+}
+
+// NotificationPreferencesHandler returns (GET) or updates (PUT) which notification types the user receives.
+// PUT takes {"preferences": {"<type>": true|false}}; types not listed keep their current setting.
+func NotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			Preferences map[string]bool `json:"preferences"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorJSON(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Preferences) == 0 {
+			utils.WriteErrorJSON(w, "No preferences provided", http.StatusBadRequest)
+			return
+		}
+
+		if err := websocket.SetNotificationPrefs(db.DB, userID, req.Preferences); err != nil {
+			if errors.Is(err, websocket.ErrUnknownNotificationType) {
+				utils.WriteErrorJSON(w, "Unknown notification type", http.StatusBadRequest)
+				return
+			}
+			utils.WriteErrorJSON(w, "Failed to update notification preferences: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefs, err := websocket.GetNotificationPrefs(db.DB, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Failed to get notification preferences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccessJSON(w, prefs, http.StatusOK)
+}
+
+// DeleteNotificationHandler removes one of the authenticated user's notifications
+func DeleteNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	notificationIDStr := r.URL.Query().Get("id")
+	if notificationIDStr == "" {
+		utils.WriteErrorJSON(w, "Notification ID is required", http.StatusBadRequest)
+		return
+	}
+
+	notificationID, err := strconv.Atoi(notificationIDStr)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Invalid notification ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := websocket.DeleteNotification(db.DB, notificationID, userID); err != nil {
+		if errors.Is(err, websocket.ErrNotificationNotFound) {
+			utils.WriteErrorJSON(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteErrorJSON(w, "Error deleting notification: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, "Notification deleted", http.StatusOK)
+}
+
+// ClearReadNotificationsHandler removes all of the authenticated user's read notifications
+func ClearReadNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteErrorJSON(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("userID").(string)
+	if userID == "" {
+		utils.WriteErrorJSON(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	deleted, err := websocket.ClearReadNotifications(db.DB, userID)
+	if err != nil {
+		utils.WriteErrorJSON(w, "Error clearing notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccessJSON(w, map[string]int64{"deleted": deleted}, http.StatusOK)
+}
+
 func GetUserChatsHandler(hub *websocket.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -198,14 +357,21 @@ func GetUserChatsHandler(hub *websocket.Hub) http.HandlerFunc {
 		// Create chat service instance
 		chatService := websocket.NewChatService(db.DB)
 
-		chats, err := chatService.GetUserChats(userID)
+		// Parse limit and offset (default to the first page)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if utils.RejectDeepOffset(w, offset, "") {
+			return
+		}
+
+		ctx, cancel := db.WithQueryTimeout(r.Context())
+		defer cancel()
+		chats, err := chatService.GetUserChats(ctx, userID, limit, offset)
 		if err != nil {
-			utils.WriteErrorJSON(w, "Failed to get user chats: "+err.Error(), http.StatusInternalServerError)
+			utils.WriteErrorJSON(w, "Failed to get user chats: "+err.Error(), utils.QueryErrorStatus(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(chats)
+		utils.WritePaginatedJSON(w, chats.Chats, chats.HasMore, chats.Total, chats.Offset, chats.Limit)
 	}
 }
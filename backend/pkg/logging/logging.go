@@ -0,0 +1,74 @@
+// Package logging sets up the backend's structured logger. Records are JSON lines that
+// carry the request ID and user ID of the action they belong to, so a single user action
+// can be followed across the handlers and the websocket hub.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+)
+
+// Field names shared by every record
+const (
+	KeyRequestID = "request_id"
+	KeyUserID    = "user_id"
+	KeyHandler   = "handler"
+)
+
+// Init makes the structured logger the default one, which the log package's functions
+// also write through. dev switches to readable text lines including debug records.
+func Init(w io.Writer, dev bool) {
+	slog.SetDefault(New(w, dev))
+}
+
+// New returns a logger writing JSON lines to w, or text lines in dev mode
+func New(w io.Writer, dev bool) *slog.Logger {
+	if dev {
+		return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// Handler returns the default logger for one handler or component, e.g. "hub"
+func Handler(name string) *slog.Logger {
+	return slog.Default().With(KeyHandler, name)
+}
+
+// FromContext returns the logger of a handler with the request ID and the authenticated
+// user of ctx, when it has them
+func FromContext(ctx context.Context, handler string) *slog.Logger {
+	logger := Handler(handler)
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With(KeyRequestID, id)
+	}
+	// set by middleware.AuthMiddleware
+	if userID, ok := ctx.Value("userID").(string); ok && userID != "" {
+		logger = logger.With(KeyUserID, userID)
+	}
+	return logger
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, or "" outside of a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 16 character request ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"social-network/pkg/logging"
+)
+
+// RequestIDHeader carries the request ID, sent back on every response so a client can
+// report it and accepted from a proxy that already assigned one
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware gives each request an ID that is added to its context for the logger
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts short IDs of letters, digits, '-' and '_' so a client can't
+// inject anything into the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
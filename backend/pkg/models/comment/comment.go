@@ -2,24 +2,36 @@ package comment
 
 import (
 	"database/sql"
+	"errors"
+	"social-network/pkg/models/post"
 	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	ErrParentNotFound  = errors.New("parent comment not found on this post")
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrNotCommentOwner = errors.New("unauthorized: you are not the author of this comment")
+)
+
 type Comment struct {
 	ID        string         `json:"id"`
 	PostID    string         `json:"post_id"`
+	ParentID  *string        `json:"parent_id"` // ID of the comment this one replies to, nil for top-level comments
 	AuthorID  string         `json:"author_id"`
 	Content   string         `json:"content"`
 	CreatedAt string         `json:"created_at"`
 	Liked     int            `json:"liked"`
 	Media     []CommentMedia `json:"media"` // Add media field
 	IsLiked   bool           `json:"isLiked"`
+	Replies   []Comment      `json:"replies,omitempty"` // Nested replies, oldest first
 }
 
 type CommentRequest struct {
 	ID       string         `json:"id"`
 	PostID   string         `json:"post_id"`
+	ParentID *string        `json:"parent_id"`
 	AuthorID string         `json:"author_id"`
 	Content  string         `json:"content"`
 	Media    []CommentMedia `json:"media"` // Add media field
@@ -64,11 +76,38 @@ func CreateComment(db *sql.DB, c Comment) (Comment, error) {
 		}
 	}()
 
+	// Deleted posts can't be commented on, even while they can still be restored
+	var deletedAt sql.NullString
+	err = tx.QueryRow("SELECT deleted_at FROM posts WHERE id = ?", c.PostID).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		err = post.ErrPostNotFound
+	} else if err == nil && deletedAt.Valid {
+		err = post.ErrPostDeleted
+	}
+	if err != nil {
+		return Comment{}, err
+	}
+
+	// A reply must answer a comment on the same post
+	if c.ParentID != nil && *c.ParentID == "" {
+		c.ParentID = nil
+	}
+	if c.ParentID != nil {
+		var parentPostID string
+		err = tx.QueryRow("SELECT post_id FROM comments WHERE id = ?", *c.ParentID).Scan(&parentPostID)
+		if err == sql.ErrNoRows || (err == nil && parentPostID != c.PostID) {
+			err = ErrParentNotFound
+		}
+		if err != nil {
+			return Comment{}, err
+		}
+	}
+
 	// Insert the comment
-	query := `INSERT INTO comments (post_id, author_id, content)
-                VALUES (?, ?, ?)`
+	query := `INSERT INTO comments (post_id, parent_id, author_id, content)
+                VALUES (?, ?, ?, ?)`
 
-	result, err := tx.Exec(query, c.PostID, c.AuthorID, c.Content)
+	result, err := tx.Exec(query, c.PostID, c.ParentID, c.AuthorID, c.Content)
 	if err != nil {
 		return Comment{}, err
 	}
@@ -98,12 +137,13 @@ func CreateComment(db *sql.DB, c Comment) (Comment, error) {
 
 	// Retrieve the newly created comment with media
 	var newComment Comment
-	selectQuery := `SELECT id, post_id, author_id, content, created_at, COALESCE(liked, 0) as liked
+	selectQuery := `SELECT id, post_id, parent_id, author_id, content, created_at, COALESCE(liked, 0) as liked
                     FROM comments WHERE id = ?`
 
 	err = db.QueryRow(selectQuery, commentID).Scan(
 		&newComment.ID,
 		&newComment.PostID,
+		&newComment.ParentID,
 		&newComment.AuthorID,
 		&newComment.Content,
 		&newComment.CreatedAt,
@@ -149,16 +189,43 @@ func CreateComment(db *sql.DB, c Comment) (Comment, error) {
 	return newComment, nil
 }
 
+// checkCommentOwner verifies that the stored author of the comment is authorID
+func checkCommentOwner(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, commentID, authorID string) error {
+	var storedAuthorID string
+	err := q.QueryRow("SELECT author_id FROM comments WHERE id = ?", commentID).Scan(&storedAuthorID)
+	if err == sql.ErrNoRows {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if storedAuthorID != authorID {
+		return ErrNotCommentOwner
+	}
+	return nil
+}
+
+// DeleteComment removes a comment if C.AuthorID (the authenticated user) wrote it. Its replies
+// are deleted with it (and their replies in turn) through the ON DELETE CASCADE on
+// comments.parent_id, they are not reparented.
 func DeleteComment(db *sql.DB, C Comment) error {
-	query := `DELETE FROM comments WHERE id = ?`
+	if err := checkCommentOwner(db, C.ID, C.AuthorID); err != nil {
+		return err
+	}
 
-	_, err := db.Exec(query, C.ID)
+	query := `DELETE FROM comments WHERE id = ? AND author_id = ?`
+
+	_, err := db.Exec(query, C.ID, C.AuthorID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// UpdateComment edits a comment if C.AuthorID (the authenticated user) wrote it.
+// The comment stays on its post and keeps its author whatever the request says.
 func UpdateComment(db *sql.DB, C Comment) (Comment, error) {
 	tx, err := db.Begin()
 	if err != nil {
@@ -170,12 +237,16 @@ func UpdateComment(db *sql.DB, C Comment) (Comment, error) {
 		}
 	}()
 
+	if err = checkCommentOwner(tx, C.ID, C.AuthorID); err != nil {
+		return Comment{}, err
+	}
+
 	// Update the comment
 	query := `UPDATE comments 
-                SET post_id = ?, author_id = ?, content = ?, created_at = CURRENT_TIMESTAMP
+                SET content = ?, created_at = CURRENT_TIMESTAMP
                 WHERE id = ?`
 
-	_, err = tx.Exec(query, C.PostID, C.AuthorID, C.Content, C.ID)
+	_, err = tx.Exec(query, C.Content, C.ID)
 	if err != nil {
 		return Comment{}, err
 	}
@@ -208,12 +279,13 @@ func UpdateComment(db *sql.DB, C Comment) (Comment, error) {
 
 	// Retrieve the updated comment with media
 	var updatedComment Comment
-	selectQuery := `SELECT id, post_id, author_id, content, created_at, COALESCE(liked, 0) as liked
+	selectQuery := `SELECT id, post_id, parent_id, author_id, content, created_at, COALESCE(liked, 0) as liked
                     FROM comments WHERE id = ?`
 
 	err = db.QueryRow(selectQuery, C.ID).Scan(
 		&updatedComment.ID,
 		&updatedComment.PostID,
+		&updatedComment.ParentID,
 		&updatedComment.AuthorID,
 		&updatedComment.Content,
 		&updatedComment.CreatedAt,
@@ -259,10 +331,12 @@ func UpdateComment(db *sql.DB, C Comment) (Comment, error) {
 	return updatedComment, nil
 }
 
+// GetComment returns a page of the post's top-level comments, newest first, each with
+// its whole reply thread nested under Replies
 func GetComment(db *sql.DB, postID string, userID string, offset, limit int) ([]Comment, error) {
-	query := `SELECT id, post_id, author_id, content, created_at, liked
+	query := `SELECT id, post_id, parent_id, author_id, content, created_at, liked
                 FROM comments
-                WHERE post_id = ?
+                WHERE post_id = ? AND parent_id IS NULL
                 ORDER BY created_at DESC
                 LIMIT ? OFFSET ?`
 
@@ -270,26 +344,98 @@ func GetComment(db *sql.DB, postID string, userID string, offset, limit int) ([]
 	if err != nil {
 		return []Comment{}, err
 	}
-	defer rows.Close()
+	comments, err := scanComments(db, rows, userID)
+	if err != nil {
+		return []Comment{}, err
+	}
 
-	var comments []Comment
+	// A post without comments is not an error, callers get an empty slice
+	if len(comments) == 0 {
+		return []Comment{}, nil
+	}
 
+	if err := attachReplies(db, comments, userID); err != nil {
+		return []Comment{}, err
+	}
+
+	return comments, nil
+}
+
+// attachReplies loads every reply below the given comments in one recursive query and nests them
+func attachReplies(db *sql.DB, comments []Comment, userID string) error {
+	placeholders := make([]string, len(comments))
+	args := make([]interface{}, len(comments))
+	for i, c := range comments {
+		placeholders[i] = "?"
+		args[i] = c.ID
+	}
+
+	rows, err := db.Query(`
+        WITH RECURSIVE thread(id) AS (
+            SELECT id FROM comments WHERE parent_id IN (`+strings.Join(placeholders, ",")+`)
+            UNION
+            SELECT c.id FROM comments c JOIN thread t ON c.parent_id = t.id
+        )
+        SELECT id, post_id, parent_id, author_id, content, created_at, liked
+        FROM comments
+        WHERE id IN (SELECT id FROM thread)
+        ORDER BY created_at ASC, id ASC
+    `, args...)
+	if err != nil {
+		return err
+	}
+	replies, err := scanComments(db, rows, userID)
+	if err != nil {
+		return err
+	}
+
+	// Group replies by parent, then build the tree from the top-level comments down
+	children := make(map[string][]Comment)
+	for _, reply := range replies {
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+
+	var nest func(c *Comment)
+	nest = func(c *Comment) {
+		c.Replies = children[c.ID]
+		for i := range c.Replies {
+			nest(&c.Replies[i])
+		}
+	}
+	for i := range comments {
+		nest(&comments[i])
+	}
+
+	return nil
+}
+
+// scanComments reads comment rows and loads whether userID liked each one and its media
+func scanComments(db *sql.DB, rows *sql.Rows, userID string) ([]Comment, error) {
+	var comments []Comment
 	for rows.Next() {
 		var c Comment
-		if err := rows.Scan(&c.ID, &c.PostID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.Liked); err != nil {
-			return []Comment{}, err
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.Liked); err != nil {
+			rows.Close()
+			return nil, err
 		}
+		comments = append(comments, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range comments {
+		c := &comments[i]
 
 		// Check if liked by current user
-		var likedByUser bool
-		err = db.QueryRow(
+		err := db.QueryRow(
 			"SELECT EXISTS(SELECT 1 FROM comment_likes WHERE comment_id = ? AND user_id = ?)",
 			c.ID, userID,
-		).Scan(&likedByUser)
+		).Scan(&c.IsLiked)
 		if err != nil {
-			return []Comment{}, err
+			return nil, err
 		}
-		c.IsLiked = likedByUser // Add this field to your Comment struct: IsLiked bool `json:"isLiked"`
 
 		// Get media for each comment
 		mediaRows, err := db.Query(
@@ -297,7 +443,7 @@ func GetComment(db *sql.DB, postID string, userID string, offset, limit int) ([]
 			c.ID,
 		)
 		if err != nil {
-			return []Comment{}, err
+			return nil, err
 		}
 
 		for mediaRows.Next() {
@@ -312,24 +458,18 @@ func GetComment(db *sql.DB, postID string, userID string, offset, limit int) ([]
 			)
 			if err != nil {
 				mediaRows.Close()
-				return []Comment{}, err
+				return nil, err
 			}
 
 			media.CreatedAt, err = time.Parse("2006-01-02 15:04:05", mediaCreatedAtStr)
 			if err != nil {
 				mediaRows.Close()
-				return []Comment{}, err
+				return nil, err
 			}
 
 			c.Media = append(c.Media, media)
 		}
 		mediaRows.Close()
-
-		comments = append(comments, c)
-	}
-
-	if len(comments) == 0 {
-		return []Comment{}, sql.ErrNoRows
 	}
 
 	return comments, nil
@@ -0,0 +1,140 @@
+package comment_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"social-network/pkg/db/sqlite"
+	"social-network/pkg/models/comment"
+	"social-network/pkg/models/post"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupCommentsDB creates a database with a post by "author" and one comment on it by "commenter"
+func setupCommentsDB(t *testing.T) (*sql.DB, comment.Comment) {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../../db/migrations/sqlite"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, id := range []string{"author", "commenter", "intruder"} {
+		_, err = db.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+			VALUES (?, ? || '@example.com', 'x', 'First', 'Last', '2000-01-01', ?, '')`, id, id, id)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	_, err = db.Exec("INSERT INTO posts (id, author_id, content, privacy) VALUES (1, 'author', 'post', 'public')")
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+
+	c, err := comment.CreateComment(db, comment.Comment{PostID: "1", AuthorID: "commenter", Content: "original"})
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	return db, c
+}
+
+func countComments(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM comments").Scan(&count); err != nil {
+		t.Fatalf("Failed to count comments: %v", err)
+	}
+	return count
+}
+
+func TestDeleteCommentRejectsNonAuthor(t *testing.T) {
+	db, c := setupCommentsDB(t)
+
+	// Even the post author cannot delete someone else's comment
+	for _, userID := range []string{"intruder", "author"} {
+		err := comment.DeleteComment(db, comment.Comment{ID: c.ID, AuthorID: userID})
+		if !errors.Is(err, comment.ErrNotCommentOwner) {
+			t.Errorf("DeleteComment by %s: got error %v, want %v", userID, err, comment.ErrNotCommentOwner)
+		}
+	}
+
+	if count := countComments(t, db); count != 1 {
+		t.Fatalf("comment count = %d after rejected deletes, want 1", count)
+	}
+}
+
+func TestDeleteCommentByAuthor(t *testing.T) {
+	db, c := setupCommentsDB(t)
+
+	if err := comment.DeleteComment(db, comment.Comment{ID: c.ID, AuthorID: "commenter"}); err != nil {
+		t.Fatalf("DeleteComment by author: %v", err)
+	}
+
+	if count := countComments(t, db); count != 0 {
+		t.Fatalf("comment count = %d after delete, want 0", count)
+	}
+}
+
+func TestDeleteMissingComment(t *testing.T) {
+	db, _ := setupCommentsDB(t)
+
+	err := comment.DeleteComment(db, comment.Comment{ID: "999", AuthorID: "commenter"})
+	if !errors.Is(err, comment.ErrCommentNotFound) {
+		t.Fatalf("got error %v, want %v", err, comment.ErrCommentNotFound)
+	}
+}
+
+func TestUpdateCommentRejectsNonAuthor(t *testing.T) {
+	db, c := setupCommentsDB(t)
+
+	_, err := comment.UpdateComment(db, comment.Comment{ID: c.ID, PostID: "1", AuthorID: "intruder", Content: "hijacked"})
+	if !errors.Is(err, comment.ErrNotCommentOwner) {
+		t.Fatalf("got error %v, want %v", err, comment.ErrNotCommentOwner)
+	}
+
+	var content, authorID string
+	if err := db.QueryRow("SELECT content, author_id FROM comments WHERE id = ?", c.ID).Scan(&content, &authorID); err != nil {
+		t.Fatalf("Failed to load comment: %v", err)
+	}
+	if content != "original" || authorID != "commenter" {
+		t.Fatalf("comment changed to (%q, %q), want (original, commenter)", content, authorID)
+	}
+}
+
+func TestUpdateCommentByAuthor(t *testing.T) {
+	db, c := setupCommentsDB(t)
+
+	updated, err := comment.UpdateComment(db, comment.Comment{ID: c.ID, PostID: "1", AuthorID: "commenter", Content: "edited"})
+	if err != nil {
+		t.Fatalf("UpdateComment by author: %v", err)
+	}
+	if updated.Content != "edited" {
+		t.Fatalf("content = %q, want edited", updated.Content)
+	}
+}
+
+func TestCreateCommentRejectsDeletedPost(t *testing.T) {
+	db, _ := setupCommentsDB(t)
+	if _, err := db.Exec("UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+
+	_, err := comment.CreateComment(db, comment.Comment{PostID: "1", AuthorID: "commenter", Content: "too late"})
+	if !errors.Is(err, post.ErrPostDeleted) {
+		t.Fatalf("expected post deleted, got %v", err)
+	}
+	if n := countComments(t, db); n != 1 {
+		t.Errorf("expected the comment not to be stored, got %d comments", n)
+	}
+}
@@ -2,8 +2,11 @@ package event
 
 import (
 	"database/sql"
+	"log"
+	"social-network/pkg/models/group"
 	"social-network/pkg/sockets/websocket"
 	"strconv"
+	"time"
 )
 
 // -- Events in groups
@@ -23,13 +26,25 @@ import (
 //     id           INTEGER PRIMARY KEY AUTOINCREMENT,
 //     event_id     INTEGER NOT NULL,
 //     user_id      TEXT    NOT NULL,
-//     response     TEXT    NOT NULL CHECK(response IN ('going','not_going')),
+//     response     TEXT    NOT NULL CHECK(response IN ('going','not_going','maybe')),
 //     responded_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
 //     FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE,
 //     FOREIGN KEY(user_id)  REFERENCES users(id) ON DELETE CASCADE,
 //     UNIQUE(event_id, user_id)
 // );
 
+// RSVP responses
+const (
+	ResponseGoing    = "going"
+	ResponseNotGoing = "not_going"
+	ResponseMaybe    = "maybe"
+)
+
+// IsValidResponse reports whether response is one of the RSVP responses
+func IsValidResponse(response string) bool {
+	return response == ResponseGoing || response == ResponseNotGoing || response == ResponseMaybe
+}
+
 type Event struct {
 	ID          string `json:"id"`
 	GroupID     string `json:"group_id"`
@@ -84,6 +99,129 @@ func CreateEvent(db *sql.DB, e Event, hub *websocket.Hub) (Event, error) {
 	return e, nil
 }
 
+// getManageableEvent loads the event if userID may edit or delete it: the event's creator
+// and the group's admins (including the group creator) can.
+func getManageableEvent(db *sql.DB, eventID, userID string) (Event, error) {
+	var e Event
+	err := db.QueryRow(`
+        SELECT id, group_id, creator_id, title, description, event_time, created_at
+        FROM events WHERE id = ?
+    `, eventID).Scan(&e.ID, &e.GroupID, &e.CreatorID, &e.Title, &e.Description, &e.EventTime, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Event{}, ErrEventNotFound
+	}
+	if err != nil {
+		return Event{}, err
+	}
+
+	if e.CreatorID == userID {
+		return e, nil
+	}
+	role, err := group.GetMemberRole(db, e.GroupID, userID)
+	if err != nil {
+		return Event{}, err
+	}
+	if !group.CanAdminister(role) {
+		return Event{}, ErrNotEventManager
+	}
+	return e, nil
+}
+
+// getNotifiableAttendees returns who answered going or maybe, leaving out excludeID
+// and attendees who muted the group's notifications
+func getNotifiableAttendees(db *sql.DB, e Event, excludeID string) ([]string, error) {
+	rows, err := db.Query(`
+        SELECT er.user_id FROM event_responses er
+        WHERE er.event_id = ? AND er.response IN ('going', 'maybe') AND er.user_id != ?
+        AND NOT EXISTS (
+            SELECT 1 FROM group_notification_prefs p
+            WHERE p.group_id = ? AND p.user_id = er.user_id AND p.muted = 1
+        )
+    `, e.ID, excludeID, e.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// EditEvent updates the event's title, description and time and notifies its attendees.
+// Returns ErrEventNotFound or ErrNotEventManager if userID can't edit it.
+func EditEvent(db *sql.DB, eventID, userID string, changes Event, hub *websocket.Hub) (Event, error) {
+	e, err := getManageableEvent(db, eventID, userID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	_, err = db.Exec(`UPDATE events SET title = ?, description = ?, event_time = ? WHERE id = ?`,
+		changes.Title, changes.Description, changes.EventTime, e.ID)
+	if err != nil {
+		return Event{}, err
+	}
+	e.Title, e.Description, e.EventTime = changes.Title, changes.Description, changes.EventTime
+
+	attendees, err := getNotifiableAttendees(db, e, userID)
+	if err != nil {
+		log.Printf("error getting attendees of event %s: %v", e.ID, err)
+	} else if len(attendees) > 0 {
+		go hub.NotifyGroupEventChanged(db, e.ID, userID, e.Title, "group_event_updated", attendees)
+	}
+
+	return e, nil
+}
+
+// DeleteEvent removes the event with its responses and the notifications pointing at it,
+// then tells its attendees it was cancelled.
+// Returns ErrEventNotFound or ErrNotEventManager if userID can't delete it.
+func DeleteEvent(db *sql.DB, eventID, userID string, hub *websocket.Hub) error {
+	e, err := getManageableEvent(db, eventID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Collected first, the responses are deleted with the event
+	attendees, err := getNotifiableAttendees(db, e, userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM event_responses WHERE event_id = ?", e.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+        DELETE FROM notifications
+        WHERE type IN ('group_event_created', 'group_event_updated') AND ref_id = ?
+    `, e.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM events WHERE id = ?", e.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(attendees) > 0 {
+		go hub.NotifyGroupEventChanged(db, e.ID, userID, e.Title, "group_event_cancelled", attendees)
+	}
+	return nil
+}
+
 func CreateEventResponse(db *sql.DB, er EventResponse) (EventResponse, error) {
 	query := `INSERT INTO event_responses (event_id, user_id, response)
 	          VALUES (?, ?, ?)`
@@ -96,6 +234,89 @@ func CreateEventResponse(db *sql.DB, er EventResponse) (EventResponse, error) {
 	return er, nil
 }
 
+// UpdateEventResponse sets the user's RSVP for an event, replacing any earlier response
+func UpdateEventResponse(db *sql.DB, er EventResponse) (EventResponse, error) {
+	_, err := db.Exec(`
+        INSERT INTO event_responses (event_id, user_id, response)
+        VALUES (?, ?, ?)
+        ON CONFLICT(event_id, user_id) DO UPDATE SET
+            response = excluded.response,
+            responded_at = CURRENT_TIMESTAMP
+    `, er.EventID, er.UserID, er.Response)
+	if err != nil {
+		return EventResponse{}, err
+	}
+
+	saved, err := getUserEventResponse(db, er.EventID, er.UserID)
+	if err != nil {
+		return EventResponse{}, err
+	}
+	return *saved, nil
+}
+
+// Attendee is a user who responded to an event
+type Attendee struct {
+	UserID      string `json:"user_id"`
+	Nickname    string `json:"nickname"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	AvatarPath  string `json:"avatar_path"`
+	RespondedAt string `json:"responded_at"`
+}
+
+// EventAttendees lists an event's respondents grouped by response
+type EventAttendees struct {
+	EventID  string     `json:"event_id"`
+	Going    []Attendee `json:"going"`
+	Maybe    []Attendee `json:"maybe"`
+	NotGoing []Attendee `json:"not_going"`
+}
+
+// GetEventAttendees returns who responded to the event, most recent first within each response.
+// Only members of the event's group may see them: ErrEventNotFound and ErrNotGroupMember are
+// returned otherwise.
+func GetEventAttendees(db *sql.DB, eventID, userID string) (*EventAttendees, error) {
+	if err := checkEventAccess(db, eventID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+        SELECT er.response, u.id, COALESCE(u.nickname, ''), u.first_name, u.last_name,
+            COALESCE(u.avatar_path, ''), er.responded_at
+        FROM event_responses er
+        JOIN users u ON er.user_id = u.id
+        WHERE er.event_id = ?
+        ORDER BY er.responded_at DESC, er.id DESC
+    `, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendees := &EventAttendees{
+		EventID:  eventID,
+		Going:    []Attendee{},
+		Maybe:    []Attendee{},
+		NotGoing: []Attendee{},
+	}
+	for rows.Next() {
+		var response string
+		var a Attendee
+		if err := rows.Scan(&response, &a.UserID, &a.Nickname, &a.FirstName, &a.LastName, &a.AvatarPath, &a.RespondedAt); err != nil {
+			return nil, err
+		}
+		switch response {
+		case ResponseGoing:
+			attendees.Going = append(attendees.Going, a)
+		case ResponseMaybe:
+			attendees.Maybe = append(attendees.Maybe, a)
+		case ResponseNotGoing:
+			attendees.NotGoing = append(attendees.NotGoing, a)
+		}
+	}
+	return attendees, rows.Err()
+}
+
 // GetEventsByGroupID retrieves all events for a group with response counts and user lists
 func GetEventsByGroupID(db *sql.DB, groupID string, userID string) ([]map[string]interface{}, error) {
 	query := `
@@ -131,7 +352,7 @@ func GetEventsByGroupID(db *sql.DB, groupID string, userID string) ([]map[string
 		}
 
 		// Get response counts and user lists
-		goingUsers, notGoingUsers, err := getEventResponseUsers(db, event.ID)
+		goingUsers, notGoingUsers, maybeUsers, err := getEventResponseUsers(db, event.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -158,13 +379,15 @@ func GetEventsByGroupID(db *sql.DB, groupID string, userID string) ([]map[string
 			},
 			"going_count":     len(goingUsers),
 			"not_going_count": len(notGoingUsers),
-			"total_responses": len(goingUsers) + len(notGoingUsers),
+			"maybe_count":     len(maybeUsers),
+			"total_responses": len(goingUsers) + len(notGoingUsers) + len(maybeUsers),
 			"going_users":     goingUsers,
 			"not_going_users": notGoingUsers,
+			"maybe_users":     maybeUsers,
 		}
 
 		if userResponse != nil {
-			eventData["user_response"] = userResponse.Response // Just "going" or "not_going"
+			eventData["user_response"] = userResponse.Response // "going", "not_going" or "maybe"
 		}
 
 		events = append(events, eventData)
@@ -173,8 +396,52 @@ func GetEventsByGroupID(db *sql.DB, groupID string, userID string) ([]map[string
 	return events, nil
 }
 
-// getEventResponseUsers gets lists of user IDs for going and not going responses
-func getEventResponseUsers(db *sql.DB, eventID string) ([]string, []string, error) {
+// UpcomingEvent is an event from one of the user's groups with the user's RSVP, if any
+type UpcomingEvent struct {
+	Event
+	GroupTitle   string `json:"group_title"`
+	UserResponse string `json:"user_response,omitempty"`
+}
+
+// GetUserUpcomingEvents returns the events of every group the user belongs to (or created)
+// taking place in [from, to), ordered by event time. event_time is compared as an instant:
+// SQLite's datetime() converts times written with a UTC offset to UTC, and times without
+// one are taken as UTC, so from and to are converted to UTC as well.
+func GetUserUpcomingEvents(db *sql.DB, userID string, from, to time.Time) ([]UpcomingEvent, error) {
+	const layout = "2006-01-02 15:04:05"
+	rows, err := db.Query(`
+        SELECT e.id, e.group_id, e.creator_id, e.title, e.description, e.event_time, e.created_at,
+            g.title, COALESCE(er.response, '')
+        FROM events e
+        JOIN groups g ON e.group_id = g.id
+        LEFT JOIN group_memberships gm ON gm.group_id = e.group_id AND gm.user_id = ?
+        LEFT JOIN event_responses er ON er.event_id = e.id AND er.user_id = ?
+        WHERE (gm.user_id IS NOT NULL OR g.creator_id = ?)
+        AND datetime(e.event_time) >= datetime(?)
+        AND datetime(e.event_time) < datetime(?)
+        ORDER BY datetime(e.event_time) ASC, e.id ASC
+    `, userID, userID, userID, from.UTC().Format(layout), to.UTC().Format(layout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []UpcomingEvent{}
+	for rows.Next() {
+		var ue UpcomingEvent
+		if err := rows.Scan(
+			&ue.ID, &ue.GroupID, &ue.CreatorID, &ue.Title, &ue.Description, &ue.EventTime, &ue.CreatedAt,
+			&ue.GroupTitle, &ue.UserResponse,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, ue)
+	}
+	return events, rows.Err()
+}
+
+// getEventResponseUsers gets lists of user IDs for going, not going and maybe responses
+func getEventResponseUsers(db *sql.DB, eventID string) ([]string, []string, []string, error) {
 	query := `
         SELECT user_id, response
         FROM event_responses 
@@ -184,28 +451,31 @@ func getEventResponseUsers(db *sql.DB, eventID string) ([]string, []string, erro
 
 	rows, err := db.Query(query, eventID)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	defer rows.Close()
 
 	var goingUsers []string
 	var notGoingUsers []string
+	var maybeUsers []string
 
 	for rows.Next() {
 		var userID, response string
 		err := rows.Scan(&userID, &response)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 
 		if response == "going" {
 			goingUsers = append(goingUsers, userID)
 		} else if response == "not_going" {
 			notGoingUsers = append(notGoingUsers, userID)
+		} else if response == "maybe" {
+			maybeUsers = append(maybeUsers, userID)
 		}
 	}
 
-	return goingUsers, notGoingUsers, nil
+	return goingUsers, notGoingUsers, maybeUsers, nil
 }
 
 // getUserEventResponse gets the current user's response for an event
@@ -3,21 +3,23 @@ package event
 import (
 	"database/sql"
 	"errors"
+	"social-network/pkg/models/group"
+)
+
+var (
+	ErrEventNotFound   = errors.New("event does not exist")
+	ErrNotGroupMember  = errors.New("user is not a member of the event's group")
+	ErrNotEventManager = errors.New("only the event creator or a group admin can change the event")
 )
 
 // Function to validate event creation
 func (e *Event) ValidateEventCreation(db *sql.DB) error {
 	// general validation
-	if e.GroupID == "" || e.CreatorID == "" || e.Title == "" || e.Description == "" || e.EventTime == "" {
+	if e.GroupID == "" || e.CreatorID == "" {
 		return errors.New("all fields must be provided")
 	}
-	// validate title and description length
-	if len(e.Title) < 10 || len(e.Title) > 200 {
-		return errors.New("title must be between 10 and 200 characters")
-	}
-
-	if len(e.Description) < 10 || len(e.Description) > 500 {
-		return errors.New("description must be between 10 and 500 characters")
+	if err := e.ValidateEventUpdate(); err != nil {
+		return err
 	}
 
 	// Check if group exists
@@ -35,27 +37,54 @@ func (e *Event) ValidateEventCreation(db *sql.DB) error {
 	return nil
 }
 
+// ValidateEventUpdate checks the fields an event's creator or a group admin can edit
+func (e *Event) ValidateEventUpdate() error {
+	if e.Title == "" || e.Description == "" || e.EventTime == "" {
+		return errors.New("all fields must be provided")
+	}
+	// validate title and description length
+	if len(e.Title) < 10 || len(e.Title) > 200 {
+		return errors.New("title must be between 10 and 200 characters")
+	}
+
+	if len(e.Description) < 10 || len(e.Description) > 500 {
+		return errors.New("description must be between 10 and 500 characters")
+	}
+
+	return nil
+}
+
 // function to validate event response
 func (eventRes *EventResponse) ValidateEventResponse(db *sql.DB) error {
 	if eventRes.EventID == "" || eventRes.UserID == "" || eventRes.Response == "" {
 		return errors.New("all fields must be provided")
 	}
 
-	if eventRes.Response != "going" && eventRes.Response != "not_going" {
-		return errors.New("response must be either 'going' or 'not going'")
+	if !IsValidResponse(eventRes.Response) {
+		return errors.New("response must be 'going', 'not_going' or 'maybe'")
 	}
 
-	// Check if event exists and get its group_id
-	var groupID int
-	if err := db.QueryRow("SELECT group_id FROM events WHERE id = ?", eventRes.EventID).Scan(&groupID); err != nil {
-		return errors.New("event does not exist")
-	}
+	// The event must exist and the user must belong to its group
+	return checkEventAccess(db, eventRes.EventID, eventRes.UserID)
+}
 
-	// Check if user is a member of the event's group
-	var userCount int
-	if err := db.QueryRow("SELECT COUNT(*) FROM group_memberships WHERE group_id = ? AND user_id = ?", groupID, eventRes.UserID).Scan(&userCount); err != nil || userCount == 0 {
-		return errors.New("user is not a member of the event's group")
+// checkEventAccess returns ErrEventNotFound if the event doesn't exist and
+// ErrNotGroupMember if the user is neither a member nor the creator of its group
+func checkEventAccess(db *sql.DB, eventID, userID string) error {
+	var groupID string
+	if err := db.QueryRow("SELECT group_id FROM events WHERE id = ?", eventID).Scan(&groupID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrEventNotFound
+		}
+		return err
 	}
 
+	role, err := group.GetMemberRole(db, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return ErrNotGroupMember
+	}
 	return nil
 }
@@ -0,0 +1,67 @@
+package follow
+
+import (
+	"errors"
+)
+
+var (
+	ErrCannotBlockSelf = errors.New("you cannot block yourself")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrNotBlocked      = errors.New("user is not blocked")
+	ErrBlocked         = errors.New("you cannot follow this user")
+)
+
+// BlockUser stops direct messages and follow requests between the two users.
+// Existing chats keep their history.
+func (s *FollowService) BlockUser(blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return ErrCannotBlockSelf
+	}
+
+	var exists int
+	if err := s.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", blockedID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrUserNotFound
+	}
+
+	_, err := s.DB.Exec(
+		"INSERT OR IGNORE INTO blocked_users (blocker_id, blocked_id) VALUES (?, ?)",
+		blockerID, blockedID,
+	)
+	return err
+}
+
+// UnblockUser lifts a block the user placed
+func (s *FollowService) UnblockUser(blockerID, blockedID string) error {
+	result, err := s.DB.Exec(
+		"DELETE FROM blocked_users WHERE blocker_id = ? AND blocked_id = ?",
+		blockerID, blockedID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotBlocked
+	}
+	return nil
+}
+
+// IsBlocked reports whether either user has blocked the other
+func (s *FollowService) IsBlocked(userID1, userID2 string) (bool, error) {
+	var count int
+	err := s.DB.QueryRow(`
+        SELECT COUNT(*) FROM blocked_users
+        WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+    `, userID1, userID2, userID2, userID1).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
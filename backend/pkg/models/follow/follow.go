@@ -2,9 +2,16 @@ package follow
 
 import (
 	"database/sql"
+	"errors"
 	"social-network/pkg/sockets/websocket"
 )
 
+var (
+	ErrNoPendingRequest = errors.New("no pending follow request to cancel")
+	ErrCannotRemoveSelf = errors.New("you cannot remove yourself as a follower")
+	ErrNotAFollower     = errors.New("this user does not follow you")
+)
+
 type FollowRequest struct {
 	FollowerID   string   `json:"follower_id"`
 	FolloweeID   string   `json:"followee_id"`
@@ -12,6 +19,16 @@ type FollowRequest struct {
 	CreatedAt    string   `json:"created_at"`
 }
 
+// IncomingFollowRequest is a pending request to follow the user, with the requester's profile
+type IncomingFollowRequest struct {
+	FollowRequest
+	Nickname    string `json:"nickname"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	AvatarPath  string `json:"avatar_path"`
+	AvatarThumb string `json:"avatar_thumb"`
+}
+
 type FollowNotification struct {
 	Type         string   `json:"type"`
 	FollowerID   string   `json:"follower_is"`
@@ -216,31 +216,81 @@ func (s *FollowService) sendUnfollowNotification(followerID, followeeID string)
 		followeeName = "Unknown User"
 	}
 
+	// Not stored: the user unfollowed themselves and already got the HTTP response,
+	// so this is only a live confirmation for their other open clients
+	notificationMsg := websocket.NotificationMessage{
+		SenderID:     followerID,
+		RecipientID:  followerID, // Sending to self as confirmation
+		Type:         "unfollow",
+		RefID:        followeeID, // Using followeeID as reference
+		Message:      "You have unfollowed " + followeeName,
+		Timestamp:    time.Now(),
+		SenderAvatar: websocket.GetSenderAvatar(s.DB, followerID, "unfollow"),
+	}
+
+	s.Hub.SendNotificationToUser(followerID, notificationMsg)
+}
+
+// sendCancelRequestNotification clears the recipient's "wants to follow you" notification
+// and tells their client to drop the request from its UI
+func (s *FollowService) sendCancelRequestNotification(followerID, followeeID string) {
+	_, err := s.DB.Exec(
+		"DELETE FROM notifications WHERE user_id = ? AND sender_id = ? AND type = 'follow_request' AND ref_id = ?",
+		followeeID, followerID, followerID,
+	)
+	if err != nil {
+		log.Printf("Error removing follow request notification: %v", err)
+	}
+
+	// Not stored: the request no longer exists, so there is nothing for the recipient to act on
+	notificationMsg := websocket.NotificationMessage{
+		SenderID:    followerID,
+		RecipientID: followeeID,
+		Type:        "follow_request_cancelled",
+		RefID:       followerID, // Using followerID as reference, matching the original request
+		Timestamp:   time.Now(),
+	}
+
+	s.Hub.SendNotificationToUser(followeeID, notificationMsg)
+}
+
+func (s *FollowService) sendFollowerRemovedNotification(followerID, followeeID string) {
+	// Get followee name for notification
+	var followeeName string
+	err := s.DB.QueryRow(
+		"SELECT first_name || ' ' || last_name FROM users WHERE id = ?",
+		followeeID,
+	).Scan(&followeeName)
+	if err != nil {
+		log.Printf("error getting followee name: %v", err)
+		followeeName = "Unknown User"
+	}
+
 	// Create notification in database and get the real ID
 	notification := websocket.Notification{
 		UserID:   followerID,
-		SenderID: followerID,
-		Type:     "unfollow",
+		SenderID: followeeID,
+		Type:     "follower_removed",
 		RefID:    followeeID,
 		IsRead:   false,
-		Message:  "You have unfollowed " + followeeName,
+		Message:  "You no longer follow " + followeeName,
 	}
 
 	notificationID, err := websocket.CreateNotificationAndGetID(s.DB, notification)
 	if err != nil {
-		log.Printf("Error creating unfollow notification: %v", err)
+		log.Printf("Error creating follower removed notification: %v", err)
 		return
 	}
 
 	notificationMsg := websocket.NotificationMessage{
 		ID:           strconv.Itoa(notificationID),
-		SenderID:     followerID,
-		RecipientID:  followerID, // Sending to self as confirmation
-		Type:         "unfollow",
+		SenderID:     followeeID,
+		RecipientID:  followerID,
+		Type:         "follower_removed",
 		RefID:        followeeID, // Using followeeID as reference
-		Message:      "You have unfollowed " + followeeName,
+		Message:      "You no longer follow " + followeeName,
 		Timestamp:    time.Now(),
-		SenderAvatar: websocket.GetSenderAvatar(s.DB, followerID, "unfollow"),
+		SenderAvatar: websocket.GetSenderAvatar(s.DB, followeeID, "follower_removed"),
 	}
 
 	s.Hub.SendNotificationToUser(followerID, notificationMsg)
@@ -1,9 +1,12 @@
 package follow
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log"
+	"social-network/pkg/utils"
+	"strings"
 )
 
 func NewFollowService(db *sql.DB, hub WebSocketHub) *FollowService {
@@ -40,6 +43,14 @@ func (s *FollowService) followRequestExists(followerID, followeeID string) (bool
 }
 
 func (s *FollowService) SendFollowRequest(followerID, followeeID string) error {
+	blocked, err := s.IsBlocked(followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+
 	// Check if already following
 	isFollowing, err := s.IsFollowing(followerID, followeeID)
 	if err != nil {
@@ -160,7 +171,34 @@ func (s *FollowService) RejectFollowRequest(followerID, followeeID string) error
 	return nil
 }
 
-func (s *FollowService) GetPendingRequests(userID string) ([]FollowRequest, error) {
+// CancelFollowRequest withdraws a follow request the user sent that is still pending
+// and removes it from the recipient's notifications
+func (s *FollowService) CancelFollowRequest(followerID, followeeID string) error {
+	result, err := s.DB.Exec(
+		"DELETE FROM follow_requests WHERE requester_id = ? AND recipient_id = ? AND status = 'pending'",
+		followerID, followeeID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoPendingRequest
+	}
+
+	// Send real-time notification via WebSocket
+	s.sendCancelRequestNotification(followerID, followeeID)
+
+	log.Printf("Follow request cancelled from %s to %s", followerID, followeeID)
+	return nil
+}
+
+// GetOutgoingFollowRequests returns the follow requests the user sent that are still pending
+func (s *FollowService) GetOutgoingFollowRequests(ctx context.Context, userID string) ([]FollowRequest, error) {
 
 	query := `
 		SELECT requester_id, recipient_id, status, created_at
@@ -169,7 +207,7 @@ func (s *FollowService) GetPendingRequests(userID string) ([]FollowRequest, erro
 		ORDER BY created_at DESC
 	`
 
-	rows, err := s.DB.Query(query, userID)
+	rows, err := s.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -185,10 +223,41 @@ func (s *FollowService) GetPendingRequests(userID string) ([]FollowRequest, erro
 		requests = append(requests, req)
 	}
 
-	return requests, nil
+	return requests, rows.Err()
 }
 
-func (s *FollowService) CanViewUserData(requestinguserID, targetUserID string) (bool, error) {
+// GetIncomingFollowRequests returns the pending requests awaiting the user's approval,
+// newest first, with the requester's profile details
+func (s *FollowService) GetIncomingFollowRequests(ctx context.Context, userID string) ([]IncomingFollowRequest, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT fr.requester_id, fr.recipient_id, fr.status, fr.created_at,
+			u.nickname, u.first_name, u.last_name, COALESCE(u.avatar_path, '')
+		FROM follow_requests fr
+		JOIN users u ON fr.requester_id = u.id
+		WHERE fr.recipient_id = ? AND fr.status = 'pending'
+		ORDER BY fr.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []IncomingFollowRequest{}
+	for rows.Next() {
+		var req IncomingFollowRequest
+		err := rows.Scan(&req.FollowerID, &req.FolloweeID, &req.Status, &req.CreatedAt,
+			&req.Nickname, &req.FirstName, &req.LastName, &req.AvatarPath)
+		if err != nil {
+			return nil, err
+		}
+		req.AvatarThumb = utils.AvatarThumb(req.AvatarPath)
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+func (s *FollowService) CanViewUserData(ctx context.Context, requestinguserID, targetUserID string) (bool, error) {
 	// user can view their own data
 	if requestinguserID == targetUserID {
 		return true, nil
@@ -196,7 +265,7 @@ func (s *FollowService) CanViewUserData(requestinguserID, targetUserID string) (
 
 	// Check if the target user is private
 	var isPublic bool
-	err := s.DB.QueryRow("SELECT is_public FROM users WHERE id = ?", targetUserID).Scan(&isPublic)
+	err := s.DB.QueryRowContext(ctx, "SELECT is_public FROM users WHERE id = ?", targetUserID).Scan(&isPublic)
 	if err != nil {
 		return false, err
 	}
@@ -208,7 +277,7 @@ func (s *FollowService) CanViewUserData(requestinguserID, targetUserID string) (
 
 	// if targte is private
 	var count int
-	err = s.DB.QueryRow(
+	err = s.DB.QueryRowContext(ctx,
 		"SELECT COUNT(*) FROM followers WHERE followee_id = ? AND follower_id = ?",
 		targetUserID, requestinguserID,
 	).Scan(&count)
@@ -219,7 +288,53 @@ func (s *FollowService) CanViewUserData(requestinguserID, targetUserID string) (
 	return count > 0, nil
 }
 
-func (s *FollowService) GetUserFollowers(requestingUserID, userID string, offset, limit int) ([]map[string]interface{}, error) {
+// followListUser is a row of a follower or following list before it is shaped for the response
+type followListUser struct {
+	ID         string
+	Nickname   string
+	FirstName  string
+	LastName   string
+	AvatarPath string
+	CreatedAt  string
+}
+
+// GetFollowStatuses reports, for each of targetIDs, whether requestingUserID follows them
+func (s *FollowService) GetFollowStatuses(ctx context.Context, requestingUserID string, targetIDs []string) (map[string]bool, error) {
+	statuses := make(map[string]bool, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return statuses, nil
+	}
+
+	placeholders := make([]string, len(targetIDs))
+	args := make([]interface{}, 0, len(targetIDs)+1)
+	args = append(args, requestingUserID)
+	for i, id := range targetIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+		statuses[id] = false
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT followee_id FROM followers WHERE follower_id = ? AND followee_id IN ("+strings.Join(placeholders, ",")+")",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var followeeID string
+		if err := rows.Scan(&followeeID); err != nil {
+			return nil, err
+		}
+		statuses[followeeID] = true
+	}
+
+	return statuses, rows.Err()
+}
+
+func (s *FollowService) GetUserFollowers(ctx context.Context, requestingUserID, userID string, offset, limit int) ([]map[string]interface{}, error) {
 	query := `
 		SELECT u.id, u.nickname, u.first_name, u.last_name, u.avatar_path, f.created_at
 		FROM followers f
@@ -229,23 +344,16 @@ func (s *FollowService) GetUserFollowers(requestingUserID, userID string, offset
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := s.DB.Query(query, userID, limit, offset)
+	rows, err := s.DB.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var followers []map[string]interface{}
+	var scanned []followListUser
+	var ids []string
 	for rows.Next() {
-		var follower struct {
-			ID         string
-			Nickname   string
-			FirstName  string
-			LastName   string
-			AvatarPath string
-			CreatedAt  string
-		}
-
+		var follower followListUser
 		err := rows.Scan(
 			&follower.ID,
 			&follower.Nickname,
@@ -257,25 +365,30 @@ func (s *FollowService) GetUserFollowers(requestingUserID, userID string, offset
 		if err != nil {
 			return nil, err
 		}
+		scanned = append(scanned, follower)
+		ids = append(ids, follower.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// Check if requestingUserID follows this follower
-		var isFollowed bool
-		checkErr := s.DB.QueryRow(
-			"SELECT COUNT(*) > 0 FROM followers WHERE follower_id = ? AND followee_id = ?",
-			requestingUserID, follower.ID,
-		).Scan(&isFollowed)
-		if checkErr != nil {
-			isFollowed = false
-		}
+	// Check which of these users requestingUserID follows, in one query
+	followed, err := s.GetFollowStatuses(ctx, requestingUserID, ids)
+	if err != nil {
+		return nil, err
+	}
 
+	var followers []map[string]interface{}
+	for _, follower := range scanned {
 		followerData := map[string]interface{}{
-			"id":          follower.ID,
-			"nickname":    follower.Nickname,
-			"first_name":  follower.FirstName,
-			"last_name":   follower.LastName,
-			"avatar_path": follower.AvatarPath,
-			"created_at":  follower.CreatedAt,
-			"isFollowed":  isFollowed,
+			"id":           follower.ID,
+			"nickname":     follower.Nickname,
+			"first_name":   follower.FirstName,
+			"last_name":    follower.LastName,
+			"avatar_path":  follower.AvatarPath,
+			"avatar_thumb": utils.AvatarThumb(follower.AvatarPath),
+			"created_at":   follower.CreatedAt,
+			"isFollowed":   followed[follower.ID],
 		}
 
 		followers = append(followers, followerData)
@@ -284,7 +397,7 @@ func (s *FollowService) GetUserFollowers(requestingUserID, userID string, offset
 	return followers, nil
 }
 
-func (s *FollowService) GetUserFollowing(requestingUserID, userID string, offset, limit int) ([]map[string]interface{}, error) {
+func (s *FollowService) GetUserFollowing(ctx context.Context, requestingUserID, userID string, offset, limit int) ([]map[string]interface{}, error) {
 	query := `
 		SELECT u.id, u.nickname, u.first_name, u.last_name, u.avatar_path, f.created_at
 		FROM followers f
@@ -294,23 +407,16 @@ func (s *FollowService) GetUserFollowing(requestingUserID, userID string, offset
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := s.DB.Query(query, userID, limit, offset)
+	rows, err := s.DB.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var following []map[string]interface{}
+	var scanned []followListUser
+	var ids []string
 	for rows.Next() {
-		var followee struct {
-			ID         string
-			Nickname   string
-			FirstName  string
-			LastName   string
-			AvatarPath string
-			CreatedAt  string
-		}
-
+		var followee followListUser
 		err := rows.Scan(
 			&followee.ID,
 			&followee.Nickname,
@@ -322,25 +428,30 @@ func (s *FollowService) GetUserFollowing(requestingUserID, userID string, offset
 		if err != nil {
 			return nil, err
 		}
+		scanned = append(scanned, followee)
+		ids = append(ids, followee.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// Check if requestingUserID follows this followee
-		var isFollowed bool
-		checkErr := s.DB.QueryRow(
-			"SELECT COUNT(*) > 0 FROM followers WHERE follower_id = ? AND followee_id = ?",
-			requestingUserID, followee.ID,
-		).Scan(&isFollowed)
-		if checkErr != nil {
-			isFollowed = false
-		}
+	// Check which of these users requestingUserID follows, in one query
+	followed, err := s.GetFollowStatuses(ctx, requestingUserID, ids)
+	if err != nil {
+		return nil, err
+	}
 
+	var following []map[string]interface{}
+	for _, followee := range scanned {
 		followeeData := map[string]interface{}{
-			"id":          followee.ID,
-			"nickname":    followee.Nickname,
-			"first_name":  followee.FirstName,
-			"last_name":   followee.LastName,
-			"avatar_path": followee.AvatarPath,
-			"created_at":  followee.CreatedAt,
-			"isFollowed":  isFollowed,
+			"id":           followee.ID,
+			"nickname":     followee.Nickname,
+			"first_name":   followee.FirstName,
+			"last_name":    followee.LastName,
+			"avatar_path":  followee.AvatarPath,
+			"avatar_thumb": utils.AvatarThumb(followee.AvatarPath),
+			"created_at":   followee.CreatedAt,
+			"isFollowed":   followed[followee.ID],
 		}
 
 		following = append(following, followeeData)
@@ -389,6 +500,43 @@ func (s *FollowService) Unfollow(followerID, followeeID string) error {
 	return nil
 }
 
+// RemoveFollower lets a user drop someone who follows them. The accepted request
+// record is cleared too, so on a private account the ex-follower has to send a new
+// follow request to regain access.
+func (s *FollowService) RemoveFollower(userID, followerID string) error {
+	if userID == followerID {
+		return ErrCannotRemoveSelf
+	}
+
+	result, err := s.DB.Exec(
+		"DELETE FROM followers WHERE follower_id = ? AND followee_id = ?",
+		followerID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotAFollower
+	}
+
+	if err := s.removeFollowRequest(followerID, userID); err != nil {
+		// Log the error but don't fail the removal
+		// as the main relationship has already been removed
+		log.Printf("Warning: Failed to clean up follow request records: %v", err)
+	}
+
+	// notify the removed follower via WebSocket
+	s.sendFollowerRemovedNotification(followerID, userID)
+
+	log.Printf("Removed follower %s from %s", followerID, userID)
+	return nil
+}
+
 // Helper method to remove follow request records
 func (s *FollowService) removeFollowRequest(followerID, followeeID string) error {
 	query := `DELETE FROM follow_requests WHERE requester_id = ? AND recipient_id = ?`
@@ -2,8 +2,11 @@ package group
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"social-network/pkg/utils"
 	"strconv"
+	"strings"
 )
 
 type Group struct {
@@ -14,6 +17,11 @@ type Group struct {
 	IsPublic    bool   `json:"is_public"` // true if public group, false if private
 	CreatedAt   string `json:"created_at"`
 	ChatID      int64  `json:"chat_id,omitempty"`
+	JoinedAt    string `json:"joined_at,omitempty"` // when the listed user joined, only set for a user's groups
+	AvatarPath  string `json:"avatar_path"`         // empty means the default group image
+	CoverPath   string `json:"cover_path"`
+	MemberCount int    `json:"member_count,omitempty"`   // only set by GetGroupByID
+	PinnedPost  int64  `json:"pinned_post_id,omitempty"` // only set by GetGroupByID, 0 when nothing is pinned
 }
 
 type GroupInvitation struct {
@@ -43,8 +51,8 @@ func CreateGroup(db *sql.DB, g Group) (Group, error) {
     defer tx.Rollback()
 
     // 1. Insert group
-    query := `INSERT INTO groups (creator_id, title, description, is_public) VALUES (?, ?, ?, ?)`
-    result, err := tx.Exec(query, g.CreatorID, g.Title, g.Description, g.IsPublic)
+    query := `INSERT INTO groups (creator_id, title, description, is_public, avatar_path, cover_path) VALUES (?, ?, ?, ?, ?, ?)`
+    result, err := tx.Exec(query, g.CreatorID, g.Title, g.Description, g.IsPublic, g.AvatarPath, g.CoverPath)
     if err != nil {
         return Group{}, fmt.Errorf("failed to create group: %w", err)
     }
@@ -56,7 +64,7 @@ func CreateGroup(db *sql.DB, g Group) (Group, error) {
 
     // 2. Fetch the newly created group (including created_at)
     var created Group
-    getQuery := `SELECT id, creator_id, title, description, is_public, created_at FROM groups WHERE id = ?`
+    getQuery := `SELECT id, creator_id, title, description, is_public, created_at, avatar_path, cover_path FROM groups WHERE id = ?`
     err = tx.QueryRow(getQuery, lastID).Scan(
         &created.ID,
         &created.CreatorID,
@@ -64,6 +72,8 @@ func CreateGroup(db *sql.DB, g Group) (Group, error) {
         &created.Description,
         &created.IsPublic,
         &created.CreatedAt,
+        &created.AvatarPath,
+        &created.CoverPath,
     )
     if err != nil {
         return Group{}, fmt.Errorf("failed to fetch created group: %w", err)
@@ -196,6 +206,10 @@ func CreateGroupRequest(db *sql.DB, gr GroupRequest) (GroupRequest, error) {
 
 	result, err := db.Exec(query, gr.RequesterID, gr.GroupID, gr.Status)
 	if err != nil {
+		// A concurrent request got in between validation and insert (idx_group_requests_pending)
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: group_requests") {
+			return GroupRequest{}, ErrGroupRequestPending
+		}
 		return GroupRequest{}, err
 	}
 
@@ -215,6 +229,31 @@ func CreateGroupRequest(db *sql.DB, gr GroupRequest) (GroupRequest, error) {
 	return gr, nil
 }
 
+// ErrNoPendingGroupRequest is returned when there is no pending join request to cancel
+var ErrNoPendingGroupRequest = errors.New("no pending group request to cancel")
+
+// CancelGroupRequest withdraws the requester's pending join request. Requests that were
+// already accepted or declined are left alone and reported as ErrNoPendingGroupRequest.
+func CancelGroupRequest(db *sql.DB, groupID, requesterID string) error {
+	result, err := db.Exec(
+		"DELETE FROM group_requests WHERE group_id = ? AND requester_id = ? AND status = 'pending'",
+		groupID, requesterID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoPendingGroupRequest
+	}
+
+	return nil
+}
+
 // Function to accept a group invitation
 func AcceptGroupInvitation(db *sql.DB, gi GroupInvitation) error {
 	// Validate the invitation response
@@ -301,6 +340,58 @@ func DeclineGroupRequest(db *sql.DB, gr GroupRequest) error {
 	return nil
 }
 
+// ResolvePendingGroupRequestsTx marks the user's pending join requests for a group as accepted
+// once they became a member through another path (invitation or direct join)
+func ResolvePendingGroupRequestsTx(tx *sql.Tx, groupID, userID string) (int64, error) {
+	result, err := tx.Exec(`
+        UPDATE group_requests
+        SET status = 'accepted', responded_at = datetime('now')
+        WHERE group_id = ? AND requester_id = ? AND status = 'pending'
+    `, groupID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve pending group requests: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ResolvePendingGroupInvitationsTx marks the user's pending invitations to a group as accepted
+// once they became a member through another path (approved request or direct join)
+func ResolvePendingGroupInvitationsTx(tx *sql.Tx, groupID, userID string) (int64, error) {
+	result, err := tx.Exec(`
+        UPDATE group_invitations
+        SET status = 'accepted', responded_at = datetime('now')
+        WHERE group_id = ? AND invitee_id = ? AND status = 'pending'
+    `, groupID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve pending group invitations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetGroupAdminIDs returns the creator and admins of a group
+func GetGroupAdminIDs(db *sql.DB, groupID string) ([]string, error) {
+	rows, err := db.Query(`
+        SELECT user_id FROM group_memberships WHERE group_id = ? AND role = 'admin'
+        UNION
+        SELECT creator_id FROM groups WHERE id = ?
+    `, groupID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var adminIDs []string
+	for rows.Next() {
+		var adminID string
+		if err := rows.Scan(&adminID); err != nil {
+			return nil, err
+		}
+		adminIDs = append(adminIDs, adminID)
+	}
+
+	return adminIDs, rows.Err()
+}
+
 // function to add a user to a group
 func AddUserToGroup(db *sql.DB, groupID string, userID, role string) error {
 	query := `INSERT INTO group_memberships (group_id, user_id, role) VALUES (?, ?, ?)`
@@ -311,10 +402,12 @@ func AddUserToGroup(db *sql.DB, groupID string, userID, role string) error {
 // GetGroupsByUserID retrieves all groups for a specific user ID
 func GetGroupsByUserID(db *sql.DB, userID string) ([]Group, error) {
 	rows, err := db.Query(`
-        SELECT g.id, g.creator_id, g.title, g.description, g.is_public, g.created_at
+        SELECT g.id, g.creator_id, g.title, g.description, g.is_public, g.created_at, gm.joined_at,
+            g.avatar_path, g.cover_path
         FROM groups g
         INNER JOIN group_memberships gm ON g.id = gm.group_id
         WHERE gm.user_id = ?
+        ORDER BY gm.joined_at DESC, g.id DESC
     `, userID)
 	if err != nil {
 		return nil, err
@@ -324,7 +417,7 @@ func GetGroupsByUserID(db *sql.DB, userID string) ([]Group, error) {
 	var groups []Group
 	for rows.Next() {
 		var g Group
-		if err := rows.Scan(&g.ID, &g.CreatorID, &g.Title, &g.Description, &g.IsPublic, &g.CreatedAt); err != nil {
+		if err := rows.Scan(&g.ID, &g.CreatorID, &g.Title, &g.Description, &g.IsPublic, &g.CreatedAt, &g.JoinedAt, &g.AvatarPath, &g.CoverPath); err != nil {
 			return nil, err
 		}
 		groups = append(groups, g)
@@ -332,37 +425,142 @@ func GetGroupsByUserID(db *sql.DB, userID string) ([]Group, error) {
 	return groups, nil
 }
 
+// GetGroupByID returns the group with its chat ID and member count. The count matches the
+// unfiltered total of GetGroupMembers, plus the creator should they be missing from the memberships.
 func GetGroupByID(db *sql.DB, groupID string) (*Group, error) {
 	var g Group
 	err := db.QueryRow(`
-        SELECT id, creator_id, title, description, is_public, created_at
-        FROM groups
-        WHERE id = ?
-    `, groupID).Scan(&g.ID, &g.CreatorID, &g.Title, &g.Description, &g.IsPublic, &g.CreatedAt)
+        SELECT g.id, g.creator_id, g.title, g.description, g.is_public, g.created_at,
+            COALESCE(ct.id, 0) AS chat_id, g.avatar_path, g.cover_path,
+            (SELECT COUNT(*) FROM group_memberships gm JOIN users u ON gm.user_id = u.id WHERE gm.group_id = g.id)
+            + (SELECT COUNT(*) FROM users cu WHERE cu.id = g.creator_id AND NOT EXISTS (
+                SELECT 1 FROM group_memberships cm WHERE cm.group_id = g.id AND cm.user_id = g.creator_id
+            )) AS member_count,
+            COALESCE(g.pinned_post_id, 0)
+        FROM groups g
+        LEFT JOIN chat_threads ct ON ct.is_group = 1 AND ct.group_id = g.id
+        WHERE g.id = ?
+    `, groupID).Scan(&g.ID, &g.CreatorID, &g.Title, &g.Description, &g.IsPublic, &g.CreatedAt, &g.ChatID, &g.AvatarPath, &g.CoverPath, &g.MemberCount, &g.PinnedPost)
 	if err != nil {
 		return nil, err
 	}
 	return &g, nil
 }
 
-// SearchGroups searches for groups by title or description
+// GetGroupChatID returns the ID of the group's chat thread
+func GetGroupChatID(db *sql.DB, groupID string) (int64, error) {
+	var chatID int64
+	err := db.QueryRow(`
+        SELECT id FROM chat_threads
+        WHERE is_group = 1 AND group_id = ?
+    `, groupID).Scan(&chatID)
+	if err != nil {
+		return 0, err
+	}
+	return chatID, nil
+}
+
+const (
+	DefaultMembersLimit = 50
+	MaxMembersLimit     = 200
+)
+
+// MemberFilter narrows a group member listing; empty fields don't filter
+type MemberFilter struct {
+	Role  string // only members with this role
+	Query string // matched against nickname, first name and last name
+}
+
+// GetGroupMembers returns a page of the group's members matching filter, in the order they joined
+func GetGroupMembers(db *sql.DB, groupID string, filter MemberFilter, limit, offset int) ([]map[string]interface{}, error) {
+	searchPattern := "%" + filter.Query + "%"
+	rows, err := db.Query(`
+        SELECT gm.user_id, gm.role, u.nickname, u.first_name, u.last_name, u.avatar_path, gm.joined_at
+        FROM group_memberships gm
+        JOIN users u ON gm.user_id = u.id
+        WHERE gm.group_id = ? AND (? = '' OR gm.role = ?)
+        AND (? = '' OR u.nickname LIKE ? OR u.first_name LIKE ? OR u.last_name LIKE ?)
+        ORDER BY gm.joined_at ASC, gm.user_id ASC
+        LIMIT ? OFFSET ?
+    `, groupID, filter.Role, filter.Role,
+		filter.Query, searchPattern, searchPattern, searchPattern,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []map[string]interface{}{}
+	for rows.Next() {
+		var memberID, memberRole, nickname, firstName, lastName, avatarPath, joinedAt string
+		if err := rows.Scan(&memberID, &memberRole, &nickname, &firstName, &lastName, &avatarPath, &joinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, map[string]interface{}{
+			"id":           memberID,
+			"role":         memberRole,
+			"nickname":     nickname,
+			"first_name":   firstName,
+			"last_name":    lastName,
+			"avatar":       avatarPath,
+			"avatar_thumb": utils.AvatarThumb(avatarPath),
+			"joined_at":    joinedAt,
+		})
+	}
+
+	return members, rows.Err()
+}
+
+// CountGroupMembers returns how many of the group's members match filter
+func CountGroupMembers(db *sql.DB, groupID string, filter MemberFilter) (int, error) {
+	searchPattern := "%" + filter.Query + "%"
+	var total int
+	err := db.QueryRow(`
+        SELECT COUNT(*)
+        FROM group_memberships gm
+        JOIN users u ON gm.user_id = u.id
+        WHERE gm.group_id = ? AND (? = '' OR gm.role = ?)
+        AND (? = '' OR u.nickname LIKE ? OR u.first_name LIKE ? OR u.last_name LIKE ?)
+    `, groupID, filter.Role, filter.Role,
+		filter.Query, searchPattern, searchPattern, searchPattern).Scan(&total)
+	return total, err
+}
+
+// searchGroupsFrom is shared by SearchGroups and CountSearchGroups.
+// Placeholders: user ID, search pattern, search pattern.
+const searchGroupsFrom = `
+        FROM groups g
+        LEFT JOIN group_memberships gm ON g.id = gm.group_id AND gm.user_id = ?
+        WHERE (g.title LIKE ? OR g.description LIKE ?)
+        -- private groups only show up for their members
+        AND (g.is_public = 1 OR gm.user_id IS NOT NULL)`
+
+// CountSearchGroups returns how many groups SearchGroups can return for the query in total
+func CountSearchGroups(db *sql.DB, query, userID string) (int, error) {
+	searchPattern := "%" + utils.SanitizeSearchTerm(query) + "%"
+	var total int
+	err := db.QueryRow(`SELECT COUNT(DISTINCT g.id)`+searchGroupsFrom, userID, searchPattern, searchPattern).Scan(&total)
+	return total, err
+}
+
+// SearchGroups searches for groups by title or description, title matches first.
+// Private groups are only returned to their members.
 func SearchGroups(db *sql.DB, query, userID string, limit, offset int) ([]map[string]interface{}, error) {
-	searchPattern := "%" + query + "%"
+	searchPattern := "%" + utils.SanitizeSearchTerm(query) + "%"
 	rows, err := db.Query(`
         SELECT DISTINCT g.id, g.title, g.description, g.is_public, g.creator_id, g.created_at,
             CASE WHEN gm.user_id IS NOT NULL THEN 1 ELSE 0 END as is_member,
-            COALESCE(gm.role, '') as role
-        FROM groups g
-        LEFT JOIN group_memberships gm ON g.id = gm.group_id AND gm.user_id = ?
-        WHERE g.title LIKE ?
+            COALESCE(gm.role, '') as role`+searchGroupsFrom+`
         ORDER BY 
             is_member DESC,
             CASE 
                 WHEN g.title LIKE ? THEN 1 
                 ELSE 2
-            END
+            END,
+            g.title,
+            g.id
         LIMIT ? OFFSET ?
-    `, userID, searchPattern, searchPattern, limit, offset)
+    `, userID, searchPattern, searchPattern, searchPattern, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -390,3 +588,213 @@ func SearchGroups(db *sql.DB, query, userID string, limit, offset int) ([]map[st
 
 	return groups, nil
 }
+
+// GetSuggestedGroups recommends groups that the people a user follows are members of,
+// ranked by how many of those followees are in each group. Private groups are only
+// suggested to users with a pending invitation to them.
+func GetSuggestedGroups(db *sql.DB, userID string, limit int) ([]map[string]interface{}, error) {
+	rows, err := db.Query(`
+        SELECT g.id, g.title, g.description, g.is_public, g.creator_id, g.created_at,
+            (SELECT COUNT(*) FROM group_memberships m WHERE m.group_id = g.id) AS member_count,
+            COUNT(DISTINCT gm.user_id) AS mutual_count
+        FROM followers f
+        JOIN group_memberships gm ON gm.user_id = f.followee_id
+        JOIN groups g ON g.id = gm.group_id
+        WHERE f.follower_id = ?
+            AND g.creator_id != ?
+            AND (g.is_public = 1 OR EXISTS (
+                SELECT 1 FROM group_invitations gi
+                WHERE gi.group_id = g.id AND gi.invitee_id = ? AND gi.status = 'pending'
+            ))
+            AND NOT EXISTS (
+                SELECT 1 FROM group_memberships own
+                WHERE own.group_id = g.id AND own.user_id = ?
+            )
+            AND NOT EXISTS (
+                SELECT 1 FROM group_requests gr
+                WHERE gr.group_id = g.id AND gr.requester_id = ? AND gr.status = 'pending'
+            )
+        GROUP BY g.id
+        ORDER BY mutual_count DESC, member_count DESC, g.created_at DESC
+        LIMIT ?
+    `, userID, userID, userID, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []map[string]interface{}{}
+	for rows.Next() {
+		var id, title, description, creatorID, createdAt string
+		var isPublic, memberCount, mutualCount int
+		if err := rows.Scan(&id, &title, &description, &isPublic, &creatorID, &createdAt, &memberCount, &mutualCount); err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, map[string]interface{}{
+			"id":           id,
+			"title":        title,
+			"description":  description,
+			"is_public":    isPublic == 1,
+			"creator_id":   creatorID,
+			"created_at":   createdAt,
+			"member_count": memberCount,
+			"mutual_count": mutualCount,
+		})
+	}
+
+	return groups, rows.Err()
+}
+
+// Orderings for GetDiscoverableGroups
+const (
+	DiscoverSortPopular = "popular" // most members first
+	DiscoverSortRecent  = "recent"  // newest groups first
+)
+
+// discoverableGroupsWhere selects public groups the user hasn't joined or asked to join
+const discoverableGroupsWhere = `
+        WHERE g.is_public = 1
+            AND NOT EXISTS (
+                SELECT 1 FROM group_memberships own
+                WHERE own.group_id = g.id AND own.user_id = ?
+            )
+            AND NOT EXISTS (
+                SELECT 1 FROM group_requests gr
+                WHERE gr.group_id = g.id AND gr.requester_id = ? AND gr.status = 'pending'
+            )`
+
+// GetDiscoverableGroups lists public groups the user is neither a member of nor has a pending
+// request for, ordered by member count (DiscoverSortPopular) or creation date (DiscoverSortRecent)
+func GetDiscoverableGroups(db *sql.DB, userID, sort string, limit, offset int) ([]map[string]interface{}, error) {
+	orderBy := "member_count DESC, g.created_at DESC, g.id DESC"
+	if sort == DiscoverSortRecent {
+		orderBy = "g.created_at DESC, g.id DESC"
+	}
+
+	rows, err := db.Query(`
+        SELECT g.id, g.title, g.description, g.creator_id, g.created_at,
+            COALESCE(g.avatar_path, ''), COUNT(m.user_id) AS member_count
+        FROM groups g
+        LEFT JOIN group_memberships m ON m.group_id = g.id`+discoverableGroupsWhere+`
+        GROUP BY g.id
+        ORDER BY `+orderBy+`
+        LIMIT ? OFFSET ?
+    `, userID, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []map[string]interface{}{}
+	for rows.Next() {
+		var id, title, description, creatorID, createdAt, avatarPath string
+		var memberCount int
+		if err := rows.Scan(&id, &title, &description, &creatorID, &createdAt, &avatarPath, &memberCount); err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, map[string]interface{}{
+			"id":           id,
+			"title":        title,
+			"description":  description,
+			"is_public":    true,
+			"creator_id":   creatorID,
+			"created_at":   createdAt,
+			"avatar_path":  avatarPath,
+			"member_count": memberCount,
+		})
+	}
+
+	return groups, rows.Err()
+}
+
+// CountDiscoverableGroups returns how many groups GetDiscoverableGroups can list for the user
+func CountDiscoverableGroups(db *sql.DB, userID string) (int, error) {
+	var total int
+	err := db.QueryRow(`SELECT COUNT(*) FROM groups g`+discoverableGroupsWhere, userID, userID).Scan(&total)
+	return total, err
+}
+
+// DeleteGroupTx removes a group with its chat, events, memberships, requests, invitations and posts
+func DeleteGroupTx(tx *sql.Tx, groupID string) error {
+	// Delete chat participants first
+	_, err := tx.Exec(`
+        DELETE FROM chat_participants 
+        WHERE chat_id IN (
+            SELECT id FROM chat_threads 
+            WHERE is_group = 1 AND group_id = ?
+        )
+    `, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat participants: %v", err)
+	}
+
+	// Delete chat messages
+	_, err = tx.Exec(`
+        DELETE FROM messages 
+        WHERE chat_id IN (
+            SELECT id FROM chat_threads 
+            WHERE is_group = 1 AND group_id = ?
+        )
+    `, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat messages: %v", err)
+	}
+
+	// Delete chat threads
+	_, err = tx.Exec(`
+        DELETE FROM chat_threads 
+        WHERE is_group = 1 AND group_id = ?
+    `, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat threads: %v", err)
+	}
+
+	// Delete event responses first
+	_, err = tx.Exec(`
+        DELETE FROM event_responses 
+        WHERE event_id IN (SELECT id FROM events WHERE group_id = ?)
+    `, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete event responses: %v", err)
+	}
+
+	// Delete events
+	_, err = tx.Exec(`DELETE FROM events WHERE group_id = ?`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete events: %v", err)
+	}
+
+	// Delete group memberships
+	_, err = tx.Exec(`DELETE FROM group_memberships WHERE group_id = ?`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete group members: %v", err)
+	}
+
+	// Delete group requests
+	_, err = tx.Exec(`DELETE FROM group_requests WHERE group_id = ?`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete group requests: %v", err)
+	}
+
+	// Delete group invitations
+	_, err = tx.Exec(`DELETE FROM group_invitations WHERE group_id = ?`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete group invitations: %v", err)
+	}
+
+	// Delete posts in the group
+	_, err = tx.Exec(`DELETE FROM posts WHERE group_id = ?`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete group posts: %v", err)
+	}
+
+	// Finally delete the group itself
+	_, err = tx.Exec(`DELETE FROM groups WHERE id = ?`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %v", err)
+	}
+
+	return nil
+}
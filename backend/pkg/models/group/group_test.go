@@ -0,0 +1,81 @@
+package group_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"social-network/pkg/db/sqlite"
+	"social-network/pkg/models/group"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupGroupsDB creates a database where "user" follows "friend", who created a public
+// group (1) and a private group (2)
+func setupGroupsDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../../db/migrations/sqlite"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	statements := []string{
+		`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname)
+			VALUES ('user', 'user@example.com', 'x', 'First', 'Last', '2000-01-01', 'user'),
+			       ('friend', 'friend@example.com', 'x', 'First', 'Last', '2000-01-01', 'friend'),
+			       ('owner', 'owner@example.com', 'x', 'First', 'Last', '2000-01-01', 'owner')`,
+		`INSERT INTO followers (follower_id, followee_id) VALUES ('user', 'friend')`,
+		`INSERT INTO groups (id, creator_id, title, description, is_public)
+			VALUES (1, 'owner', 'Public', 'public group', 1), (2, 'owner', 'Private', 'private group', 0)`,
+		`INSERT INTO group_memberships (group_id, user_id, role) VALUES (1, 'friend', 'member'), (2, 'friend', 'member')`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to set up database: %v", err)
+		}
+	}
+	return db
+}
+
+func suggestedIDs(t *testing.T, db *sql.DB, userID string) []string {
+	t.Helper()
+
+	groups, err := group.GetSuggestedGroups(db, userID, 10)
+	if err != nil {
+		t.Fatalf("GetSuggestedGroups failed: %v", err)
+	}
+	var ids []string
+	for _, g := range groups {
+		ids = append(ids, g["id"].(string))
+	}
+	return ids
+}
+
+func TestGetSuggestedGroupsLeavesOutPrivateGroups(t *testing.T) {
+	db := setupGroupsDB(t)
+
+	ids := suggestedIDs(t, db, "user")
+	if len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("expected only the public group, got %v", ids)
+	}
+}
+
+func TestGetSuggestedGroupsIncludesPrivateGroupsTheUserIsInvitedTo(t *testing.T) {
+	db := setupGroupsDB(t)
+	_, err := db.Exec(`INSERT INTO group_invitations (group_id, inviter_id, invitee_id, status) VALUES (2, 'owner', 'user', 'pending')`)
+	if err != nil {
+		t.Fatalf("Failed to invite user: %v", err)
+	}
+
+	ids := suggestedIDs(t, db, "user")
+	if len(ids) != 2 {
+		t.Errorf("expected both groups, got %v", ids)
+	}
+}
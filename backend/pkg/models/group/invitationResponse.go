@@ -0,0 +1,152 @@
+package group
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// InvitationResponse is the outcome of an invitee answering a pending group invitation
+type InvitationResponse struct {
+	InvitationID     string `json:"invitation_id"`
+	GroupID          string `json:"group_id"`
+	GroupName        string `json:"group_name"`
+	InviterID        string `json:"inviter_id"`
+	InviteeID        string `json:"invitee_id"`
+	InviteeName      string `json:"invitee_name"`
+	ResolvedRequests int64  `json:"-"` // pending join requests closed by accepting
+}
+
+// findPendingInvitationTx loads the invitee's pending invitation to a group
+func findPendingInvitationTx(tx *sql.Tx, groupID, inviteeID string) (*InvitationResponse, error) {
+	resp := &InvitationResponse{GroupID: groupID, InviteeID: inviteeID}
+	err := tx.QueryRow(`
+        SELECT gi.id, gi.inviter_id, g.title
+        FROM group_invitations gi
+        JOIN groups g ON gi.group_id = g.id
+        WHERE gi.group_id = ? AND gi.invitee_id = ? AND gi.status = 'pending'
+    `, groupID, inviteeID).Scan(&resp.InvitationID, &resp.InviterID, &resp.GroupName)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invitation: %w", err)
+	}
+
+	err = tx.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", inviteeID).Scan(&resp.InviteeName)
+	if err != nil {
+		resp.InviteeName = "Unknown User"
+	}
+
+	return resp, nil
+}
+
+// AcceptPendingGroupInvitation accepts the invitee's pending invitation to a group in one
+// transaction: the invitation is marked accepted, the invitee becomes a member and joins the
+// group chat, and any join request they still had pending is resolved.
+func AcceptPendingGroupInvitation(db *sql.DB, groupID, inviteeID string) (*InvitationResponse, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	resp, err := findPendingInvitationTx(tx, groupID, inviteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE group_invitations
+        SET status = 'accepted', responded_at = datetime('now')
+        WHERE id = ?
+    `, resp.InvitationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	// Check if user is already a member (defensive check)
+	var exists int
+	err = tx.QueryRow("SELECT COUNT(*) FROM group_memberships WHERE group_id = ? AND user_id = ?", groupID, inviteeID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check group membership: %w", err)
+	}
+
+	if exists == 0 {
+		_, err = tx.Exec(`
+            INSERT INTO group_memberships (group_id, user_id, role, joined_at)
+            VALUES (?, ?, 'member', datetime('now'))
+        `, groupID, inviteeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add user to group: %w", err)
+		}
+	}
+
+	if err := AddUserToGroupChatTx(tx, inviteeID, groupID); err != nil {
+		return nil, err
+	}
+
+	resp.ResolvedRequests, err = ResolvePendingGroupRequestsTx(tx, groupID, inviteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return resp, nil
+}
+
+// DeclinePendingGroupInvitation declines the invitee's pending invitation to a group
+func DeclinePendingGroupInvitation(db *sql.DB, groupID, inviteeID string) (*InvitationResponse, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	resp, err := findPendingInvitationTx(tx, groupID, inviteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE group_invitations
+        SET status = 'declined', responded_at = datetime('now')
+        WHERE id = ?
+    `, resp.InvitationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decline invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return resp, nil
+}
+
+// AddUserToGroupChatTx adds the user to the group's chat thread within a transaction
+func AddUserToGroupChatTx(tx *sql.Tx, userID, groupID string) error {
+	var chatID int64
+	err := tx.QueryRow(`
+        SELECT id FROM chat_threads
+        WHERE is_group = 1 AND group_id = ?
+    `, groupID).Scan(&chatID)
+	if err != nil {
+		return fmt.Errorf("failed to find group chat thread: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT OR IGNORE INTO chat_participants (chat_id, user_id)
+        VALUES (?, ?)
+    `, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to add user to group chat: %w", err)
+	}
+
+	return nil
+}
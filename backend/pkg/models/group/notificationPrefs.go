@@ -0,0 +1,40 @@
+package group
+
+import (
+	"database/sql"
+)
+
+// SetGroupMuted mutes or unmutes a group's notifications for the user. Muting only stops
+// join request, invitation and event notifications; the group's content stays visible.
+// The group must exist, so sql.ErrNoRows is returned otherwise.
+func SetGroupMuted(db *sql.DB, groupID, userID string, muted bool) error {
+	var exists int
+	if err := db.QueryRow("SELECT 1 FROM groups WHERE id = ?", groupID).Scan(&exists); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+        INSERT INTO group_notification_prefs (group_id, user_id, muted, updated_at)
+        VALUES (?, ?, ?, datetime('now'))
+        ON CONFLICT(group_id, user_id) DO UPDATE SET
+            muted = excluded.muted,
+            updated_at = excluded.updated_at
+    `, groupID, userID, muted)
+	return err
+}
+
+// IsGroupMuted reports whether the user muted the group's notifications
+func IsGroupMuted(db *sql.DB, groupID, userID string) (bool, error) {
+	var muted int
+	err := db.QueryRow(
+		"SELECT muted FROM group_notification_prefs WHERE group_id = ? AND user_id = ?",
+		groupID, userID,
+	).Scan(&muted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return muted == 1, nil
+}
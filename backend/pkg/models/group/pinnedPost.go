@@ -0,0 +1,34 @@
+package group
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrPostNotInGroup is returned when pinning a post that isn't a live post of the group
+var ErrPostNotInGroup = errors.New("post does not belong to this group")
+
+// PinPost makes postID the group's announcement, replacing any previously pinned post.
+// Permission checks are left to the caller.
+func PinPost(db *sql.DB, groupID string, postID int64) error {
+	var exists int
+	err := db.QueryRow(
+		"SELECT 1 FROM posts WHERE id = ? AND group_id = ? AND privacy = 'group' AND deleted_at IS NULL",
+		postID, groupID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrPostNotInGroup
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE groups SET pinned_post_id = ? WHERE id = ?", postID, groupID)
+	return err
+}
+
+// UnpinPost clears the group's pinned post
+func UnpinPost(db *sql.DB, groupID string) error {
+	_, err := db.Exec("UPDATE groups SET pinned_post_id = NULL WHERE id = ?", groupID)
+	return err
+}
@@ -0,0 +1,72 @@
+package group
+
+import (
+	"database/sql"
+)
+
+// Group roles, from lowest to highest. The creator is not stored as a role in
+// group_memberships; it comes from groups.creator_id and outranks everyone.
+//
+//	member:    view and create posts, comment, chat, RSVP to events, leave the group
+//	moderator: everything a member can do, plus remove any post in the group and kick members
+//	admin:     everything a moderator can do, plus kick moderators, edit group settings,
+//	           pin the announcement post, accept/decline join requests, grant admin
+//	           and grant/revoke moderator
+//	creator:   everything an admin can do, plus kick or revoke admins, transfer
+//	           ownership and delete the group
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+	RoleCreator   = "creator"
+)
+
+// roleRanks orders the roles of the hierarchy creator > admin > moderator > member
+var roleRanks = map[string]int{
+	RoleMember:    1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+	RoleCreator:   4,
+}
+
+// RoleRank returns the position of the role in the hierarchy, 0 for non-members
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
+// IsMemberRole reports whether role can be stored in group_memberships
+func IsMemberRole(role string) bool {
+	return role == RoleMember || role == RoleModerator || role == RoleAdmin
+}
+
+// CanModerate reports whether the role may remove posts and kick lower-ranked members
+func CanModerate(role string) bool {
+	return RoleRank(role) >= RoleRank(RoleModerator)
+}
+
+// CanAdminister reports whether the role may edit group settings and manage roles
+func CanAdminister(role string) bool {
+	return RoleRank(role) >= RoleRank(RoleAdmin)
+}
+
+// Outranks reports whether a user with role can act on a user with target's role
+func Outranks(role, target string) bool {
+	return RoleRank(role) > RoleRank(target)
+}
+
+// GetMemberRole returns the user's effective role in the group: RoleCreator for
+// the creator, the membership role otherwise, or "" if the user is not a member.
+// sql.ErrNoRows is returned when the group doesn't exist.
+func GetMemberRole(db *sql.DB, groupID, userID string) (string, error) {
+	var role string
+	err := db.QueryRow(`
+        SELECT CASE WHEN g.creator_id = ? THEN 'creator' ELSE COALESCE(gm.role, '') END
+        FROM groups g
+        LEFT JOIN group_memberships gm ON gm.group_id = g.id AND gm.user_id = ?
+        WHERE g.id = ?
+    `, userID, userID, groupID).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
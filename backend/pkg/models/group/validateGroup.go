@@ -3,27 +3,58 @@ package group
 import (
 	"database/sql"
 	"errors"
+	"social-network/pkg/utils"
+	"strings"
 )
 
 // Function to validate group
+// Every failing field is reported in the returned utils.ValidationErrors
 func (g *Group) ValidateGroupCreation() error {
+	var errs utils.ValidationErrors
 
-	// Check if all required fields are provided
-	if g.Title == "" || g.Description == "" {
-		return errors.New("all fields must be provided")
+	//validate title
+	switch {
+	case strings.TrimSpace(g.Title) == "":
+		errs.Add("title", utils.CodeRequired, "title is required")
+	case len(g.Title) < 10:
+		errs.Add("title", utils.CodeTooShort, "title must be between 10 and 200 characters")
+	case len(g.Title) > 200:
+		errs.Add("title", utils.CodeTooLong, "title must be between 10 and 200 characters")
 	}
 
-	//validate title length
-	if len(g.Title) < 10 || len(g.Title) > 200 {
-		return errors.New("title must be between 10 and 200 characters")
+	//validate description
+	switch {
+	case strings.TrimSpace(g.Description) == "":
+		errs.Add("description", utils.CodeRequired, "description is required")
+	case len(g.Description) < 10:
+		errs.Add("description", utils.CodeTooShort, "description must be between 10 and 500 characters")
+	case len(g.Description) > 500:
+		errs.Add("description", utils.CodeTooLong, "description must be between 10 and 500 characters")
 	}
 
-	//validate description length
-	if len(g.Description) < 10 || len(g.Description) > 500 {
-		return errors.New("description must be between 10 and 500 characters")
+	//validate images
+	if !ValidGroupImagePath(g.AvatarPath) {
+		errs.Add("avatar_path", utils.CodeInvalid, "avatar must be an image uploaded to /uploads/media/")
+	}
+	if !ValidGroupImagePath(g.CoverPath) {
+		errs.Add("cover_path", utils.CodeInvalid, "cover must be an image uploaded to /uploads/media/")
 	}
 
-	return nil
+	return errs.Err()
+}
+
+// ValidGroupImagePath reports whether path is empty (use the default image)
+// or names a file directly under the uploads directory
+func ValidGroupImagePath(path string) bool {
+	if path == "" {
+		return true
+	}
+	const prefix = "/uploads/media/"
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	name := strings.TrimPrefix(path, prefix)
+	return name != "" && !strings.ContainsAny(name, "/\\") && name != "." && name != ".."
 }
 
 // Function to validate GroupInvitation - UPDATED to allow re-inviting
@@ -106,6 +137,9 @@ func (gi *GroupInvitation) ValidateGroupInvitation(db *sql.DB) error {
 	return nil
 }
 
+// ErrGroupRequestPending is returned when the user already has a pending request for the group
+var ErrGroupRequestPending = errors.New("request already pending")
+
 // Function to validate GroupRequest
 func (gr *GroupRequest) ValidateGroupRequest(db *sql.DB) error {
 	if gr.RequesterID == "" || gr.GroupID == "" || gr.Status == "" {
@@ -148,7 +182,7 @@ func (gr *GroupRequest) ValidateGroupRequest(db *sql.DB) error {
 		return err
 	}
 	if requestExists {
-		return errors.New("you have already sent a pending request to this group")
+		return ErrGroupRequestPending
 	}
 
 	if !isValidStatus(gr.Status) {
@@ -0,0 +1,105 @@
+package post
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// FeedPrefs holds a user's home feed preferences
+type FeedPrefs struct {
+	HideGroupPosts bool     `json:"hide_group_posts"`
+	MutedUsers     []string `json:"muted_users"`
+}
+
+// UpdateFeedPrefsRequest represents a request to change feed preferences
+type UpdateFeedPrefsRequest struct {
+	HideGroupPosts *bool `json:"hide_group_posts"`
+}
+
+// GetFeedPrefs returns the user's feed preferences, falling back to defaults when none are saved
+func (s *PostService) GetFeedPrefs(userID string) (*FeedPrefs, error) {
+	prefs := &FeedPrefs{MutedUsers: []string{}}
+
+	var hideGroupPosts int
+	err := s.DB.QueryRow("SELECT hide_group_posts FROM feed_prefs WHERE user_id = ?", userID).Scan(&hideGroupPosts)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	prefs.HideGroupPosts = hideGroupPosts == 1
+
+	rows, err := s.DB.Query("SELECT muted_id FROM post_mutes WHERE muter_id = ? ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mutedID string
+		if err := rows.Scan(&mutedID); err != nil {
+			return nil, err
+		}
+		prefs.MutedUsers = append(prefs.MutedUsers, mutedID)
+	}
+
+	return prefs, rows.Err()
+}
+
+// UpdateFeedPrefs saves the provided feed preferences for the user
+func (s *PostService) UpdateFeedPrefs(userID string, req *UpdateFeedPrefsRequest) error {
+	if req.HideGroupPosts == nil {
+		return errors.New("no preferences provided")
+	}
+
+	_, err := s.DB.Exec(`
+        INSERT INTO feed_prefs (user_id, hide_group_posts, updated_at)
+        VALUES (?, ?, datetime('now'))
+        ON CONFLICT(user_id) DO UPDATE SET
+            hide_group_posts = excluded.hide_group_posts,
+            updated_at = excluded.updated_at
+    `, userID, *req.HideGroupPosts)
+	return err
+}
+
+// MuteUserPosts hides the target user's posts from the user's home feed.
+// The follow relationship and notifications are left untouched.
+func (s *PostService) MuteUserPosts(userID, mutedUserID string) error {
+	if userID == mutedUserID {
+		return errors.New("you cannot mute yourself")
+	}
+
+	var exists int
+	err := s.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", mutedUserID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return errors.New("user not found")
+	}
+
+	_, err = s.DB.Exec(
+		"INSERT OR IGNORE INTO post_mutes (muter_id, muted_id) VALUES (?, ?)",
+		userID, mutedUserID,
+	)
+	return err
+}
+
+// UnmuteUserPosts shows the target user's posts in the user's home feed again
+func (s *PostService) UnmuteUserPosts(userID, mutedUserID string) error {
+	result, err := s.DB.Exec(
+		"DELETE FROM post_mutes WHERE muter_id = ? AND muted_id = ?",
+		userID, mutedUserID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("user is not muted")
+	}
+
+	return nil
+}
@@ -29,12 +29,36 @@ type Post struct {
 	GroupID   *int64      `json:"group_id,omitempty"` // ID of the group (for group posts)
 	CreatedAt time.Time   `json:"created_at"`         // Timestamp of when the post was created
 	UpdatedAt time.Time   `json:"updated_at"`         // Timestamp of when the post was last updated
+	Edited    bool        `json:"edited"`             // Whether the post was changed after it was created
 	Media     []PostMedia `json:"media"`              // List of media URLs associated with the post
 	Liked     int         `json:"liked"`
 	// Author details (populated when fetching posts)
 	Author             AuthorData `json:"author,omitempty"`
 	LikedByCurrentUser bool       `json:"liked_by_current_user"`
 	CommentCount       int        `json:"comment_count"`
+	// How many users other than the author opened the post, only set by GetPostByID
+	ViewCount int `json:"view_count"`
+	// Actions the requesting user is allowed to take on the post
+	Capabilities PostCapabilities `json:"capabilities"`
+	// Reaction counts by type and the requesting user's reaction (empty if none)
+	Reactions    map[string]int `json:"reactions"`
+	UserReaction string         `json:"user_reaction"`
+	// Latest comments, only populated when inlined comments are requested
+	Comments []PostComment `json:"comments,omitempty"`
+	// Whether this is the group's pinned announcement, only set in group feeds
+	Pinned bool `json:"pinned,omitempty"`
+	// Set on reposts: the ID of the reshared post, and the post itself when the
+	// requesting user can still see it
+	RepostOf *int64 `json:"repost_of,omitempty"`
+	Original *Post  `json:"original,omitempty"`
+}
+
+// PostCapabilities describes what the requesting user can do with a post
+type PostCapabilities struct {
+	CanComment bool `json:"can_comment"`
+	CanLike    bool `json:"can_like"`
+	CanEdit    bool `json:"can_edit"`
+	CanDelete  bool `json:"can_delete"`
 }
 
 type PostMedia struct {
@@ -0,0 +1,68 @@
+package post
+
+import (
+	"context"
+	"social-network/pkg/models/group"
+)
+
+// computeCapabilities works out what the user can do with a post they are able to see.
+// Only the author can edit, and reposts can't be edited at all; group moderators and above can also delete group posts.
+// Group posts can only be interacted with by group members.
+func computeCapabilities(p *Post, userID string, memberGroups map[int64]string) PostCapabilities {
+	isAuthor := p.AuthorID == userID
+
+	canInteract := true
+	canModerate := false
+	if p.Privacy == PrivacyGroup && p.GroupID != nil {
+		role := memberGroups[*p.GroupID]
+		if !isAuthor {
+			canInteract = role != ""
+		}
+		canModerate = group.CanModerate(role)
+	}
+
+	return PostCapabilities{
+		CanComment: canInteract,
+		CanLike:    canInteract,
+		CanEdit:    isAuthor && p.RepostOf == nil,
+		CanDelete:  isAuthor || canModerate,
+	}
+}
+
+// getUserGroupIDs returns the user's role in each group they are a member of, keyed by group ID
+func (s *PostService) getUserGroupIDs(ctx context.Context, userID string) (map[int64]string, error) {
+	groups := make(map[int64]string)
+	if userID == "" {
+		return groups, nil
+	}
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT group_id, role FROM group_memberships WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID int64
+		var role string
+		if err := rows.Scan(&groupID, &role); err != nil {
+			return nil, err
+		}
+		groups[groupID] = role
+	}
+
+	return groups, rows.Err()
+}
+
+// setCapabilities fills in the capabilities of each post for the requesting user
+func (s *PostService) setCapabilities(ctx context.Context, posts []Post, userID string) error {
+	memberGroups, err := s.getUserGroupIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Capabilities = computeCapabilities(&posts[i], userID, memberGroups)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package post
+
+import (
+	"context"
+	"strings"
+)
+
+// MaxInlineComments caps how many comments can be inlined per post in a feed response
+const MaxInlineComments = 10
+
+// PostComment is a lightweight comment preview inlined in feed responses
+type PostComment struct {
+	ID        int64      `json:"id"`
+	PostID    int64      `json:"post_id"`
+	ParentID  *int64     `json:"parent_id"` // set when the comment is a reply
+	AuthorID  string     `json:"author_id"`
+	Content   string     `json:"content"`
+	CreatedAt string     `json:"created_at"`
+	Author    AuthorData `json:"author"`
+}
+
+// attachLatestComments loads the latest n comments of every post in one query
+// (ranked per post with a window function) and attaches them to the posts.
+func (s *PostService) attachLatestComments(ctx context.Context, posts []Post, n int) error {
+	if n <= 0 || len(posts) == 0 {
+		return nil
+	}
+	if n > MaxInlineComments {
+		n = MaxInlineComments
+	}
+
+	placeholders := make([]string, len(posts))
+	args := make([]interface{}, 0, len(posts)+1)
+	index := make(map[int64]int, len(posts))
+	for i, p := range posts {
+		placeholders[i] = "?"
+		args = append(args, p.ID)
+		index[p.ID] = i
+		posts[i].Comments = []PostComment{}
+	}
+	args = append(args, n)
+
+	query := `
+        SELECT id, post_id, parent_id, author_id, content, created_at, nickname, first_name, last_name, avatar_path
+        FROM (
+            SELECT c.id, c.post_id, c.parent_id, c.author_id, c.content, c.created_at,
+                   u.nickname, u.first_name, u.last_name, COALESCE(u.avatar_path, '') AS avatar_path,
+                   ROW_NUMBER() OVER (PARTITION BY c.post_id ORDER BY c.created_at DESC, c.id DESC) AS rn
+            FROM comments c
+            JOIN users u ON c.author_id = u.id
+            WHERE c.post_id IN (` + strings.Join(placeholders, ",") + `)
+        )
+        WHERE rn <= ?
+        ORDER BY post_id, rn
+    `
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c PostComment
+		err := rows.Scan(
+			&c.ID,
+			&c.PostID,
+			&c.ParentID,
+			&c.AuthorID,
+			&c.Content,
+			&c.CreatedAt,
+			&c.Author.Nickname,
+			&c.Author.FirstName,
+			&c.Author.LastName,
+			&c.Author.Avatar,
+		)
+		if err != nil {
+			return err
+		}
+
+		if i, ok := index[c.PostID]; ok {
+			posts[i].Comments = append(posts[i].Comments, c)
+		}
+	}
+
+	return rows.Err()
+}
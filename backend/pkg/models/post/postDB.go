@@ -1,8 +1,12 @@
 package post
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"social-network/pkg/models/group"
+	"social-network/pkg/utils"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -18,6 +22,9 @@ func NewPostService(db *sql.DB) *PostService {
 }
 
 func (s *PostService) CreatePost(req *CreatePostRequest, authorID string) (int64, error) {
+	// Resolve @mentions before opening the transaction, the lookups go through the shared connection pool
+	mentionedIDs := resolveMentions(req.Content, authorID)
+
 	tx, err := s.DB.Begin()
 	if err != nil {
 		return 0, err
@@ -65,6 +72,19 @@ func (s *PostService) CreatePost(req *CreatePostRequest, authorID string) (int64
 		}
 	}
 
+	// Hide the post from excluded followers
+	if excludesFollowers(req.Privacy) {
+		for _, followerID := range req.ExcludedFollowers {
+			_, err = tx.Exec(
+				"INSERT OR IGNORE INTO post_excluded_followers (post_id, follower_id) VALUES (?, ?)",
+				postID, followerID,
+			)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
 	// Insert media if provided
 	for _, media := range req.Media {
 		_, err := tx.Exec(
@@ -78,6 +98,14 @@ func (s *PostService) CreatePost(req *CreatePostRequest, authorID string) (int64
 		}
 	}
 
+	if err = saveHashtags(tx, postID, req.Content); err != nil {
+		return 0, err
+	}
+
+	if err = saveMentions(tx, postID, mentionedIDs); err != nil {
+		return 0, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return 0, err
 	}
@@ -101,31 +129,99 @@ func (s *PostService) validateGroupMembership(userID string, groupID int64) erro
 	return nil
 }
 
-// GetPosts retrieves posts from the database (including group posts for members)
-func (s *PostService) GetPosts(userID string, offset, limit int) ([]Post, error) {
-	query := `
-		SELECT DISTINCT p.id, p.author_id, p.content, p.privacy, p.group_id, p.created_at, p.updated_at, p.liked,
+// visiblePostsQuery selects the posts userID is allowed to see; callers append the
+// page condition, ORDER BY and LIMIT
+const visiblePostsQuery = `
+		SELECT DISTINCT p.id, p.author_id, p.content, p.privacy, p.group_id, p.created_at, p.updated_at, p.liked, p.repost_of,
 			u.nickname, u.first_name, u.last_name, u.avatar_path,
 			EXISTS(SELECT 1 FROM post_likes pl WHERE pl.post_id = p.id AND pl.user_id = ?) AS liked_by_current_user,
 			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comment_count
 		FROM posts p
 		LEFT JOIN followers f ON p.author_id = f.followee_id AND f.follower_id = ?
 		LEFT JOIN post_allowed_followers paf ON p.id = paf.post_id AND paf.follower_id = ?
+		LEFT JOIN post_excluded_followers pef ON p.id = pef.post_id AND pef.follower_id = ?
 		LEFT JOIN group_memberships gm ON p.group_id = gm.group_id AND gm.user_id = ?
 		JOIN users u ON p.author_id = u.id
-		WHERE
+		WHERE (
 			p.privacy = 'public' OR
-			(p.privacy = 'followers' AND (p.author_id = ? OR f.follower_id IS NOT NULL)) OR
-			(p.privacy = 'custom' AND (p.author_id = ? OR paf.follower_id IS NOT NULL)) OR
+			-- an excluded follower loses access even when they are followers or on the allow list
+			(p.privacy = 'followers' AND (p.author_id = ? OR (f.follower_id IS NOT NULL AND pef.follower_id IS NULL))) OR
+			(p.privacy = 'custom' AND (p.author_id = ? OR (paf.follower_id IS NOT NULL AND pef.follower_id IS NULL))) OR
 			(p.privacy = 'group' AND (p.author_id = ? OR gm.user_id IS NOT NULL))
-		ORDER BY p.created_at DESC
+		)
+		AND p.deleted_at IS NULL
+		`
+
+// visiblePostsArgs returns the arguments for the placeholders of visiblePostsQuery
+func visiblePostsArgs(userID string) []interface{} {
+	return []interface{}{userID, userID, userID, userID, userID, userID, userID, userID}
+}
+
+// homeFeedQuery narrows visiblePostsQuery down to userID's home feed by applying the feed preferences
+const homeFeedQuery = visiblePostsQuery + `
+		-- feed preferences: optionally keep group posts in the groups feed only
+		AND NOT (p.privacy = 'group' AND EXISTS(SELECT 1 FROM feed_prefs fp WHERE fp.user_id = ? AND fp.hide_group_posts = 1))
+		-- hide posts from muted authors
+		AND NOT EXISTS(SELECT 1 FROM post_mutes pm WHERE pm.muter_id = ? AND pm.muted_id = p.author_id)
+		`
+
+// homeFeedArgs returns the arguments for the placeholders of homeFeedQuery
+func homeFeedArgs(userID string) []interface{} {
+	return append(visiblePostsArgs(userID), userID, userID)
+}
+
+// GetPosts returns the user's home feed; commentsPerPost > 0 inlines that many latest comments per post.
+// sinceID > 0 restricts the feed to posts newer than that post, so clients can fetch just the delta.
+func (s *PostService) GetPosts(ctx context.Context, userID string, sinceID int64, offset, limit, commentsPerPost int) ([]Post, error) {
+	query := homeFeedQuery + `
+		-- since cursor: only posts created after the given post
+		AND (? = 0 OR p.id > ?)
+		ORDER BY p.created_at DESC, p.id DESC
 		LIMIT ? OFFSET ?
 		`
 
-	rows, err := s.DB.Query(query, userID, userID, userID, userID, userID, userID, userID, limit, offset)
+	args := append(homeFeedArgs(userID), sinceID, sinceID, limit, offset)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.scanFeedPosts(ctx, rows, userID, commentsPerPost)
+}
+
+// GetPostsAfter returns the page of the home feed that follows the post identified by
+// (beforeCreatedAt, beforeID). Unlike offset paging it costs the same at any depth and
+// doesn't skip or repeat posts when new ones are created between requests.
+func (s *PostService) GetPostsAfter(ctx context.Context, userID string, beforeCreatedAt time.Time, beforeID int64, limit, commentsPerPost int) ([]Post, error) {
+	query := homeFeedQuery + `
+		-- keyset cursor: strictly older than the last post of the previous page
+		AND (p.created_at, p.id) < (?, ?)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ?
+		`
+
+	args := append(homeFeedArgs(userID), beforeCreatedAt.Format("2006-01-02 15:04:05"), beforeID, limit)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+
+	return s.scanFeedPosts(ctx, rows, userID, commentsPerPost)
+}
+
+// CountVisiblePosts returns how many posts are in userID's home feed, using the same
+// visibility rules and feed preferences as GetPosts
+func (s *PostService) CountVisiblePosts(ctx context.Context, userID string) (int, error) {
+	var total int
+	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+homeFeedQuery+")", homeFeedArgs(userID)...).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// scanFeedPosts reads the rows of homeFeedQuery and loads each post's media, capabilities and inline comments
+func (s *PostService) scanFeedPosts(ctx context.Context, rows *sql.Rows, userID string, commentsPerPost int) ([]Post, error) {
 	defer rows.Close()
 
 	var posts []Post
@@ -142,6 +238,7 @@ func (s *PostService) GetPosts(userID string, offset, limit int) ([]Post, error)
 			&createdAtstr,
 			&updatedAtstr,
 			&post.Liked,
+			&post.RepostOf,
 			&post.Author.Nickname,
 			&post.Author.FirstName,
 			&post.Author.LastName,
@@ -162,53 +259,43 @@ func (s *PostService) GetPosts(userID string, offset, limit int) ([]Post, error)
 		if err != nil {
 			return nil, err
 		}
+		post.Edited = post.UpdatedAt.After(post.CreatedAt)
 
-		// Get media for each post
-		mediaRows, err := s.DB.Query(
-			"SELECT id, media_type, file_path, created_at FROM post_media WHERE post_id = ?",
-			post.ID,
-		)
-		if err != nil {
-			return nil, err
-		}
+		posts = append(posts, post)
+	}
+	// a timeout ends the iteration early, don't serve it as a short page
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		for mediaRows.Next() {
-			var media PostMedia
-			var mediaCreatedAtStr string
-			media.PostID = strconv.FormatInt(post.ID, 10)
-			err := mediaRows.Scan(
-				&media.ID,
-				&media.MediaType,
-				&media.FilePath,
-				&mediaCreatedAtStr,
-			)
-			if err != nil {
-				mediaRows.Close()
-				return nil, err
-			}
+	if err := s.loadPostMedia(ctx, posts); err != nil {
+		return nil, err
+	}
 
-			// parse the media created_at string
-			media.CreatedAt, err = time.Parse("2006-01-02 15:04:05", mediaCreatedAtStr)
-			if err != nil {
-				mediaRows.Close()
-				return nil, err
-			}
+	if err := s.attachReactions(ctx, posts, userID); err != nil {
+		return nil, err
+	}
 
-			post.Media = append(post.Media, media)
-		}
-		mediaRows.Close()
+	if err := s.setCapabilities(ctx, posts, userID); err != nil {
+		return nil, err
+	}
 
-		posts = append(posts, post)
+	if err := s.attachLatestComments(ctx, posts, commentsPerPost); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, posts, userID); err != nil {
+		return nil, err
 	}
 
 	return posts, nil
 }
 
-// Add method to get posts for a specific group
-func (s *PostService) GetGroupPosts(userID string, groupID int64, offset, limit int) ([]Post, error) {
+// Add method to get posts for a specific group; commentsPerPost > 0 inlines that many latest comments per post
+func (s *PostService) GetGroupPosts(ctx context.Context, userID string, groupID int64, offset, limit, commentsPerPost int) ([]Post, error) {
 	// Check if group is public
 	var isPublic bool
-	err := s.DB.QueryRow("SELECT is_public FROM groups WHERE id = ?", groupID).Scan(&isPublic)
+	err := s.DB.QueryRowContext(ctx, "SELECT is_public FROM groups WHERE id = ?", groupID).Scan(&isPublic)
 	if err != nil {
 		return nil, err
 	}
@@ -220,17 +307,20 @@ func (s *PostService) GetGroupPosts(userID string, groupID int64, offset, limit
 		}
 	}
 
+	// The group's pinned post comes first, the rest newest first
 	query := `
         SELECT p.id, p.author_id, p.content, p.privacy, p.group_id, p.created_at, p.updated_at, p.liked,
-               u.nickname, u.first_name, u.last_name, u.avatar_path
+               u.nickname, u.first_name, u.last_name, u.avatar_path,
+               CASE WHEN p.id = g.pinned_post_id THEN 1 ELSE 0 END AS pinned
         FROM posts p
         JOIN users u ON p.author_id = u.id
-        WHERE p.group_id = ? AND p.privacy = 'group'
-        ORDER BY p.created_at DESC
+        JOIN groups g ON g.id = p.group_id
+        WHERE p.group_id = ? AND p.privacy = 'group' AND p.deleted_at IS NULL
+        ORDER BY pinned DESC, p.created_at DESC
         LIMIT ? OFFSET ?
     `
 
-	rows, err := s.DB.Query(query, groupID, limit, offset)
+	rows, err := s.DB.QueryContext(ctx, query, groupID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -254,6 +344,7 @@ func (s *PostService) GetGroupPosts(userID string, groupID int64, offset, limit
 			&post.Author.FirstName,
 			&post.Author.LastName,
 			&post.Author.Avatar,
+			&post.Pinned,
 		)
 		if err != nil {
 			return nil, err
@@ -268,57 +359,73 @@ func (s *PostService) GetGroupPosts(userID string, groupID int64, offset, limit
 		if err != nil {
 			return nil, err
 		}
+		post.Edited = post.UpdatedAt.After(post.CreatedAt)
 
-		// Get media for each post
-		mediaRows, err := s.DB.Query(
-			"SELECT id, media_type, file_path, created_at FROM post_media WHERE post_id = ?",
-			post.ID,
-		)
-		if err != nil {
-			return nil, err
-		}
+		posts = append(posts, post)
+	}
 
-		for mediaRows.Next() {
-			var media PostMedia
-			var mediaCreatedAtStr string
-			media.PostID = strconv.FormatInt(post.ID, 10)
-			err := mediaRows.Scan(
-				&media.ID,
-				&media.MediaType,
-				&media.FilePath,
-				&mediaCreatedAtStr,
-			)
-			if err != nil {
-				mediaRows.Close()
-				return nil, err
-			}
+	if err := s.loadPostMedia(ctx, posts); err != nil {
+		return nil, err
+	}
 
-			media.CreatedAt, err = time.Parse("2006-01-02 15:04:05", mediaCreatedAtStr)
-			if err != nil {
-				mediaRows.Close()
-				return nil, err
-			}
+	if err := s.attachReactions(ctx, posts, userID); err != nil {
+		return nil, err
+	}
 
-			post.Media = append(post.Media, media)
-		}
-		mediaRows.Close()
+	if err := s.setCapabilities(ctx, posts, userID); err != nil {
+		return nil, err
+	}
 
-		posts = append(posts, post)
+	if err := s.attachLatestComments(ctx, posts, commentsPerPost); err != nil {
+		return nil, err
 	}
 
 	return posts, nil
 }
 
-// GetPostByID retrieves a post by its ID
-func (s *PostService) GetPostByID(postID string, userID string) (*Post, error) {
+// CountGroupPosts returns the total number of posts in a group
+func (s *PostService) CountGroupPosts(groupID int64) (int, error) {
+	var total int
+	err := s.DB.QueryRow(
+		"SELECT COUNT(*) FROM posts WHERE group_id = ? AND privacy = 'group' AND deleted_at IS NULL",
+		groupID,
+	).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetPostByID retrieves a post by its ID, if userID can see it, and counts the view
+func (s *PostService) GetPostByID(ctx context.Context, postID string, userID string) (*Post, error) {
 	post := &Post{}
 	var createdAtStr, updatedAtStr string
 
-	err := s.DB.QueryRow(`
-        SELECT p.id, p.author_id, p.content, p.privacy, p.created_at, p.updated_at,
+	postIDInt, err := strconv.ParseInt(postID, 10, 64)
+	if err != nil {
+		return nil, ErrPostNotFound
+	}
+	if err := s.checkPostActive(postIDInt); err != nil {
+		return nil, err
+	}
+	// Posts the user can't see are reported as missing, and their views aren't counted
+	canView, err := s.CanViewPost(postIDInt, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrPostNotFound
+	}
+	if err := s.RecordView(postIDInt, userID); err != nil {
+		return nil, err
+	}
+
+	err = s.DB.QueryRowContext(ctx, `
+        SELECT p.id, p.author_id, p.content, p.privacy, p.group_id, p.created_at, p.updated_at, p.repost_of,
                u.nickname, u.first_name, u.last_name, u.avatar_path,
                EXISTS(SELECT 1 FROM post_likes WHERE post_id = p.id AND user_id = ?) AS liked_by_current_user,
-               (SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS comment_count
+               (SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS comment_count,
+               (SELECT COUNT(*) FROM post_views WHERE post_id = p.id) AS view_count
         FROM posts p
         JOIN users u ON p.author_id = u.id
         WHERE p.id = ?`,
@@ -328,14 +435,17 @@ func (s *PostService) GetPostByID(postID string, userID string) (*Post, error) {
 		&post.AuthorID,
 		&post.Content,
 		&post.Privacy,
+		&post.GroupID,
 		&createdAtStr,
 		&updatedAtStr,
+		&post.RepostOf,
 		&post.Author.Nickname,
 		&post.Author.FirstName,
 		&post.Author.LastName,
 		&post.Author.Avatar,
 		&post.LikedByCurrentUser,
 		&post.CommentCount,
+		&post.ViewCount,
 	)
 
 	if err != nil {
@@ -352,9 +462,10 @@ func (s *PostService) GetPostByID(postID string, userID string) (*Post, error) {
 	if err != nil {
 		return nil, err
 	}
+	post.Edited = post.UpdatedAt.After(post.CreatedAt)
 
 	// Get media for the post
-	mediaRows, err := s.DB.Query(
+	mediaRows, err := s.DB.QueryContext(ctx,
 		"SELECT id, media_type, file_path, created_at FROM post_media WHERE post_id = ?",
 		post.ID,
 	)
@@ -386,30 +497,50 @@ func (s *PostService) GetPostByID(postID string, userID string) (*Post, error) {
 		post.Media = append(post.Media, media)
 	}
 
-	return post, nil
+	reactions, err := s.getReactionSummaries(ctx, []int64{post.ID}, userID)
+	if err != nil {
+		return nil, err
+	}
+	post.Reactions = reactions[post.ID].Counts
+	post.UserReaction = reactions[post.ID].UserReaction
+
+	memberGroups, err := s.getUserGroupIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	post.Capabilities = computeCapabilities(post, userID, memberGroups)
+
+	single := []Post{*post}
+	if err := s.attachOriginals(ctx, single, userID); err != nil {
+		return nil, err
+	}
+
+	return &single[0], nil
 }
 
-func (s *PostService) GetUserPosts(userID, targetUserID string, offset, limit int) ([]Post, error) {
+func (s *PostService) GetUserPosts(ctx context.Context, userID, targetUserID string, offset, limit int) ([]Post, error) {
 	query := `
-        SELECT DISTINCT p.id, p.author_id, p.content, p.privacy, p.created_at, p.updated_at,
+        SELECT DISTINCT p.id, p.author_id, p.content, p.privacy, p.created_at, p.updated_at, p.repost_of,
             u.nickname, u.first_name, u.last_name, u.avatar_path,
             EXISTS(SELECT 1 FROM post_likes WHERE post_id = p.id AND user_id = ?) AS liked_by_current_user,
             (SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS comment_count
         FROM posts p
         LEFT JOIN followers f ON p.author_id = f.followee_id AND f.follower_id = ?
         LEFT JOIN post_allowed_followers paf ON p.id = paf.post_id AND paf.follower_id = ?
+        LEFT JOIN post_excluded_followers pef ON p.id = pef.post_id AND pef.follower_id = ?
         JOIN users u ON p.author_id = u.id
         WHERE p.author_id = ? AND (
             p.privacy = 'public' OR
             p.author_id = ? OR
-            (p.privacy = 'followers' AND f.follower_id IS NOT NULL) OR
-            (p.privacy = 'custom' AND paf.follower_id IS NOT NULL)
+            (p.privacy = 'followers' AND f.follower_id IS NOT NULL AND pef.follower_id IS NULL) OR
+            (p.privacy = 'custom' AND paf.follower_id IS NOT NULL AND pef.follower_id IS NULL)
         )
+        AND p.deleted_at IS NULL
         ORDER BY p.created_at DESC
         LIMIT ? OFFSET ?
     `
 
-	rows, err := s.DB.Query(query, userID, userID, userID, targetUserID, userID, limit, offset)
+	rows, err := s.DB.QueryContext(ctx, query, userID, userID, userID, userID, targetUserID, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -427,6 +558,7 @@ func (s *PostService) GetUserPosts(userID, targetUserID string, offset, limit in
 			&post.Privacy,
 			&createdAtStr,
 			&updatedAtStr,
+			&post.RepostOf,
 			&post.Author.Nickname,
 			&post.Author.FirstName,
 			&post.Author.LastName,
@@ -447,48 +579,55 @@ func (s *PostService) GetUserPosts(userID, targetUserID string, offset, limit in
 		if err != nil {
 			return nil, err
 		}
+		post.Edited = post.UpdatedAt.After(post.CreatedAt)
 
-		// Get media for each post
-		mediaRows, err := s.DB.Query(
-			"SELECT id, media_type, file_path, created_at FROM post_media WHERE post_id = ?",
-			post.ID,
-		)
-		if err != nil {
-			return nil, err
-		}
+		posts = append(posts, post)
+	}
 
-		for mediaRows.Next() {
-			var media PostMedia
-			var mediaCreatedAtStr string
-			media.PostID = strconv.FormatInt(post.ID, 10)
-			err := mediaRows.Scan(
-				&media.ID,
-				&media.MediaType,
-				&media.FilePath,
-				&mediaCreatedAtStr,
-			)
-			if err != nil {
-				mediaRows.Close()
-				return nil, err
-			}
+	if err := s.loadPostMedia(ctx, posts); err != nil {
+		return nil, err
+	}
 
-			// Parse the media created_at string
-			media.CreatedAt, err = time.Parse("2006-01-02 15:04:05", mediaCreatedAtStr)
-			if err != nil {
-				mediaRows.Close()
-				return nil, err
-			}
+	if err := s.attachReactions(ctx, posts, userID); err != nil {
+		return nil, err
+	}
 
-			post.Media = append(post.Media, media)
-		}
-		mediaRows.Close()
+	if err := s.setCapabilities(ctx, posts, userID); err != nil {
+		return nil, err
+	}
 
-		posts = append(posts, post)
+	if err := s.attachOriginals(ctx, posts, userID); err != nil {
+		return nil, err
 	}
 
 	return posts, nil
 }
 
+// CountUserPosts returns how many of targetUserID's posts userID can see, matching GetUserPosts
+func (s *PostService) CountUserPosts(userID, targetUserID string) (int, error) {
+	query := `
+        SELECT COUNT(DISTINCT p.id)
+        FROM posts p
+        LEFT JOIN followers f ON p.author_id = f.followee_id AND f.follower_id = ?
+        LEFT JOIN post_allowed_followers paf ON p.id = paf.post_id AND paf.follower_id = ?
+        LEFT JOIN post_excluded_followers pef ON p.id = pef.post_id AND pef.follower_id = ?
+        WHERE p.author_id = ? AND (
+            p.privacy = 'public' OR
+            p.author_id = ? OR
+            (p.privacy = 'followers' AND f.follower_id IS NOT NULL AND pef.follower_id IS NULL) OR
+            (p.privacy = 'custom' AND paf.follower_id IS NOT NULL AND pef.follower_id IS NULL)
+        )
+        AND p.deleted_at IS NULL
+    `
+
+	var total int
+	err := s.DB.QueryRow(query, userID, userID, userID, targetUserID, userID).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
 func (s *PostService) GetPostAuthor(postID int64) (string, error) {
 	var authorID string
 	err := s.DB.QueryRow(
@@ -518,10 +657,13 @@ func (s *PostService) GetAuthorData(authorID string) (AuthorData, error) {
 }
 
 // Edit post functions ================================================
-func (s *PostService) EditPost(postID int64, req *EditPostRequest, authorID string) error {
+
+// EditPost applies the edit and reports whether anything actually changed;
+// updated_at is only bumped for real changes so unchanged saves don't mark the post as edited
+func (s *PostService) EditPost(postID int64, req *EditPostRequest, authorID string) (bool, error) {
 	tx, err := s.DB.Begin()
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer func() {
 		if err != nil {
@@ -530,81 +672,218 @@ func (s *PostService) EditPost(postID int64, req *EditPostRequest, authorID stri
 	}()
 
 	// Verify if the post author
-	var currentAuthorID string
+	var currentAuthorID, currentContent string
+	var currentPrivacy PrivacyType
 	var currentGroupID *int64
+	var deletedAt sql.NullString
+	var repostOf sql.NullInt64
 	err = tx.QueryRow(
-		"SELECT author_id, group_id FROM posts WHERE id = ?", postID).Scan(&currentAuthorID, &currentGroupID)
+		"SELECT author_id, content, privacy, group_id, deleted_at, repost_of FROM posts WHERE id = ?", postID).Scan(&currentAuthorID, &currentContent, &currentPrivacy, &currentGroupID, &deletedAt, &repostOf)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if deletedAt.Valid {
+		err = ErrPostDeleted
+		return false, err
+	}
+	if repostOf.Valid {
+		err = ErrRepostNotEdited
+		return false, err
 	}
 	if currentAuthorID != authorID {
-		return errors.New("unauthorized: you are not the author of this post")
+		err = errors.New("unauthorized: you are not the author of this post")
+		return false, err
 	}
 
 	// For group posts, validate group membership
 	if req.Privacy == PrivacyGroup && req.GroupID != nil {
-		if err := s.validateGroupMembership(authorID, *req.GroupID); err != nil {
-			return err
+		if err = s.validateGroupMembership(authorID, *req.GroupID); err != nil {
+			return false, err
 		}
 	}
 
-	// Update the post
-	_, err = tx.Exec(
-		"UPDATE posts SET content = ?, privacy = ?, group_id = ? WHERE id = ?",
-		req.Content,
-		req.Privacy,
-		req.GroupID,
-		postID,
-	)
-	if err != nil {
-		return err
-	}
+	modified := currentContent != req.Content || currentPrivacy != req.Privacy || !sameGroupID(currentGroupID, req.GroupID)
 
 	// check if the edit request has media
 	if len(req.Media) > 0 {
-		// Delete media
-		_, err = tx.Exec("DELETE FROM post_media WHERE post_id = ?", postID)
+		var currentMedia []string
+		currentMedia, err = queryStrings(tx, "SELECT media_type || ' ' || file_path FROM post_media WHERE post_id = ? ORDER BY id", postID)
 		if err != nil {
-			return err
+			return false, err
+		}
+		newMedia := make([]string, len(req.Media))
+		for i, media := range req.Media {
+			newMedia[i] = media.MediaType + " " + media.FilePath
 		}
 
-		for _, media := range req.Media {
-			_, err = tx.Exec(
-				"INSERT INTO post_media (post_id, media_type, file_path) VALUES (?, ?, ?)",
-				postID,
-				media.MediaType,
-				media.FilePath,
-			)
+		if !sameStrings(currentMedia, newMedia) {
+			modified = true
+
+			// Delete media
+			_, err = tx.Exec("DELETE FROM post_media WHERE post_id = ?", postID)
 			if err != nil {
-				return err
+				return false, err
+			}
+
+			for _, media := range req.Media {
+				_, err = tx.Exec(
+					"INSERT INTO post_media (post_id, media_type, file_path) VALUES (?, ?, ?)",
+					postID,
+					media.MediaType,
+					media.FilePath,
+				)
+				if err != nil {
+					return false, err
+				}
 			}
 		}
 	}
 
 	// check if the privacy is custom
 	if req.Privacy == PrivacyCustom {
-		_, err = tx.Exec("DELETE FROM post_allowed_followers WHERE post_id = ?", postID)
+		var currentFollowers []string
+		currentFollowers, err = queryStrings(tx, "SELECT follower_id FROM post_allowed_followers WHERE post_id = ? ORDER BY follower_id", postID)
 		if err != nil {
-			return err
+			return false, err
 		}
+		newFollowers := append([]string(nil), req.AllowedFollowers...)
+		sort.Strings(newFollowers)
+
+		if !sameStrings(currentFollowers, newFollowers) {
+			modified = true
+
+			_, err = tx.Exec("DELETE FROM post_allowed_followers WHERE post_id = ?", postID)
+			if err != nil {
+				return false, err
+			}
 
-		if len(req.AllowedFollowers) > 0 {
 			for _, followerID := range req.AllowedFollowers {
 				_, err = tx.Exec(
 					"INSERT INTO post_allowed_followers (post_id, follower_id) VALUES (?, ?)",
 					postID, followerID,
 				)
 				if err != nil {
-					return err
+					return false, err
 				}
 			}
 		}
 	}
 
-	return tx.Commit()
+	// Excluded followers only apply to followers and custom posts, any other privacy clears them
+	var currentExcluded []string
+	currentExcluded, err = queryStrings(tx, "SELECT follower_id FROM post_excluded_followers WHERE post_id = ? ORDER BY follower_id", postID)
+	if err != nil {
+		return false, err
+	}
+	var newExcluded []string
+	if excludesFollowers(req.Privacy) {
+		newExcluded = uniqueSorted(req.ExcludedFollowers)
+	}
+
+	if !sameStrings(currentExcluded, newExcluded) {
+		modified = true
+
+		_, err = tx.Exec("DELETE FROM post_excluded_followers WHERE post_id = ?", postID)
+		if err != nil {
+			return false, err
+		}
+
+		for _, followerID := range newExcluded {
+			_, err = tx.Exec(
+				"INSERT INTO post_excluded_followers (post_id, follower_id) VALUES (?, ?)",
+				postID, followerID,
+			)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if !modified {
+		err = tx.Commit()
+		return false, err
+	}
+
+	// Update the post
+	_, err = tx.Exec(
+		"UPDATE posts SET content = ?, privacy = ?, group_id = ?, updated_at = datetime('now') WHERE id = ?",
+		req.Content,
+		req.Privacy,
+		req.GroupID,
+		postID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if currentContent != req.Content {
+		if err = saveHashtags(tx, postID, req.Content); err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	return err == nil, err
+}
+
+// excludesFollowers reports whether posts with this privacy honour an excluded followers list
+func excludesFollowers(privacy PrivacyType) bool {
+	return privacy == PrivacyFollowers || privacy == PrivacyCustom
+}
+
+// uniqueSorted returns the distinct values of s in sorted order
+func uniqueSorted(s []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sameGroupID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// queryStrings returns the single string column of every row of query
+func queryStrings(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
 }
 
-func (s *PostService) DeletePost(postID int64, authorID string) error {
+func (s *PostService) DeletePost(postID int64, userID string) error {
 	tx, err := s.DB.Begin()
 	if err != nil {
 		return err
@@ -615,27 +894,54 @@ func (s *PostService) DeletePost(postID int64, authorID string) error {
 		}
 	}()
 
-	// Verify if the post author
+	// Verify if the post author, or a moderator of the group the post was made in
 	var currentAuthorID string
-	err = tx.QueryRow("SELECT author_id FROM posts WHERE id = ?", postID).Scan(&currentAuthorID)
+	var groupID sql.NullInt64
+	var deletedAt sql.NullString
+	err = tx.QueryRow("SELECT author_id, group_id, deleted_at FROM posts WHERE id = ?", postID).Scan(&currentAuthorID, &groupID, &deletedAt)
 	if err != nil {
 		return err
 	}
-	if currentAuthorID != authorID {
-		return errors.New("unauthorized: you are not the author of this post")
+	if currentAuthorID != userID {
+		canModerate := false
+		if groupID.Valid {
+			var role string
+			role, err = group.GetMemberRole(s.DB, strconv.FormatInt(groupID.Int64, 10), userID)
+			if err != nil {
+				return err
+			}
+			canModerate = group.CanModerate(role)
+		}
+		if !canModerate {
+			err = errors.New("unauthorized: you are not the author of this post")
+			return err
+		}
+	}
+	if deletedAt.Valid {
+		err = ErrPostDeleted
+		return err
 	}
 
-	// Delete the post
-	_, err = tx.Exec("DELETE FROM posts WHERE id = ?", postID)
+	// Soft delete the post; comments, likes and media are kept so RestorePost can bring it back
+	_, err = tx.Exec("UPDATE posts SET deleted_at = datetime('now'), deleted_by = ? WHERE id = ?", userID, postID)
 	if err != nil {
 		return err
 	}
 
+	// Posts past their restore window are removed for good along the way
+	if err = purgeExpiredPostsTx(tx); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
 // LikePost adds a like to a post
 func (s *PostService) LikePost(postID int64, userID string) (bool, error, int) {
+	if err := s.checkPostActive(postID); err != nil {
+		return false, err, 0
+	}
+
 	// First check if user can access this post
 	var privacy string
 	var groupID *int64
@@ -738,12 +1044,9 @@ func (s *PostService) IsUserAllowedToViewPosts(requesterUserID, targetUserID str
 	return count > 0, nil
 }
 
-// SearchPosts searches for posts by content (only posts user can see)
-func (s *PostService) SearchPosts(query, userID string, limit, offset int) ([]map[string]interface{}, error) {
-	searchPattern := "%" + query + "%"
-	rows, err := s.DB.Query(`
-        SELECT DISTINCT p.id, p.author_id, p.content, p.privacy, p.group_id, p.created_at, p.updated_at,
-            u.nickname, u.first_name, u.last_name, u.avatar_path
+// searchPostsFrom is shared by SearchPosts and CountSearchPosts so both apply the same privacy rules.
+// Placeholders: user ID, search pattern, user ID, group ID, group ID.
+const searchPostsFrom = `
         FROM posts p
         JOIN users u ON p.author_id = u.id
         LEFT JOIN group_memberships gm ON p.group_id = gm.group_id AND gm.user_id = ?
@@ -757,9 +1060,44 @@ func (s *PostService) SearchPosts(query, userID string, limit, offset int) ([]ma
             -- Group posts (user is member or group is public)
             OR (p.privacy = 'group' AND (gm.user_id IS NOT NULL OR g.is_public = 1))
         )
+        AND (? = 0 OR (p.group_id = ? AND p.privacy = 'group'))
+        AND p.deleted_at IS NULL`
+
+// CountSearchPosts returns how many posts SearchPosts can return for the query in total.
+// Group access is checked by SearchPosts, which is called first.
+func (s *PostService) CountSearchPosts(query, userID string, groupID int64) (int, error) {
+	searchPattern := "%" + utils.SanitizeSearchTerm(query) + "%"
+	var total int
+	err := s.DB.QueryRow(`SELECT COUNT(DISTINCT p.id)`+searchPostsFrom, userID, searchPattern, userID, groupID, groupID).Scan(&total)
+	return total, err
+}
+
+// SearchPosts searches post content visible to the user; a non-zero groupID restricts results to that group
+func (s *PostService) SearchPosts(query, userID string, groupID int64, limit, offset int) ([]map[string]interface{}, error) {
+	if groupID != 0 {
+		// Searching inside a group requires the group to be public or the user to be a member
+		var isPublic bool
+		err := s.DB.QueryRow("SELECT is_public FROM groups WHERE id = ?", groupID).Scan(&isPublic)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.New("group not found")
+			}
+			return nil, err
+		}
+		if !isPublic {
+			if err := s.validateGroupMembership(userID, groupID); err != nil {
+				return nil, errors.New("unauthorized: user is not a member of this group")
+			}
+		}
+	}
+
+	searchPattern := "%" + utils.SanitizeSearchTerm(query) + "%"
+	rows, err := s.DB.Query(`
+        SELECT DISTINCT p.id, p.author_id, p.content, p.privacy, p.group_id, p.created_at, p.updated_at,
+            u.nickname, u.first_name, u.last_name, u.avatar_path`+searchPostsFrom+`
         ORDER BY p.created_at DESC
         LIMIT ? OFFSET ?
-    `, userID, searchPattern, userID, limit, offset)
+    `, userID, searchPattern, userID, groupID, groupID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,63 @@
+package post
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// hashtagPattern matches a # followed by letters, digits or underscores
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// NormalizeHashtag lowercases a tag and strips its leading #, so "#Golang" and "golang" match
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimLeft(strings.TrimSpace(tag), "#"))
+}
+
+// ExtractHashtags returns the distinct normalized hashtags in content, in order of appearance.
+// Content is decoded first so entities like &#39; in stored text aren't read as tags.
+func ExtractHashtags(content string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(html.UnescapeString(content), -1) {
+		tag := NormalizeHashtag(match[1])
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// saveHashtags replaces the stored hashtags of a post with the ones found in content
+func saveHashtags(tx *sql.Tx, postID int64, content string) error {
+	if _, err := tx.Exec("DELETE FROM post_hashtags WHERE post_id = ?", postID); err != nil {
+		return err
+	}
+
+	for _, tag := range ExtractHashtags(content) {
+		if _, err := tx.Exec("INSERT INTO post_hashtags (post_id, tag) VALUES (?, ?)", postID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPostsByHashtag returns the posts tagged with tag that the user is allowed to see, newest first
+func (s *PostService) GetPostsByHashtag(ctx context.Context, tag, userID string, limit, offset int) ([]Post, error) {
+	query := visiblePostsQuery + `
+		AND EXISTS(SELECT 1 FROM post_hashtags ph WHERE ph.post_id = p.id AND ph.tag = ?)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ? OFFSET ?
+		`
+
+	args := append(visiblePostsArgs(userID), NormalizeHashtag(tag), limit, offset)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.scanFeedPosts(ctx, rows, userID, 0)
+}
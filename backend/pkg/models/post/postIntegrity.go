@@ -0,0 +1,94 @@
+package post
+
+import "fmt"
+
+// Kinds of privacy/group_id mismatches found on posts
+const (
+	IssueGroupPostWithoutGroup = "group_post_without_group"   // privacy='group' but no group_id
+	IssueGroupIDOnNonGroupPost = "group_id_on_non_group_post" // group_id set but privacy is not 'group'
+)
+
+// InconsistentPost describes a post whose privacy does not match its group_id
+type InconsistentPost struct {
+	PostID        int64       `json:"post_id"`
+	AuthorID      string      `json:"author_id"`
+	Privacy       PrivacyType `json:"privacy"`
+	GroupID       *int64      `json:"group_id"`
+	Issue         string      `json:"issue"`
+	RepairPrivacy PrivacyType `json:"repair_privacy"` // privacy the post gets when repaired
+}
+
+// FindInconsistentPosts lists posts where privacy and group_id disagree. These posts never
+// match the group membership join in the feed queries, so they are invisible or leak.
+func (s *PostService) FindInconsistentPosts() ([]InconsistentPost, error) {
+	rows, err := s.DB.Query(`
+        SELECT id, author_id, privacy, group_id
+        FROM posts
+        WHERE (privacy = 'group' AND group_id IS NULL)
+            OR (privacy != 'group' AND group_id IS NOT NULL)
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find inconsistent posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts := []InconsistentPost{}
+	for rows.Next() {
+		var p InconsistentPost
+		if err := rows.Scan(&p.PostID, &p.AuthorID, &p.Privacy, &p.GroupID); err != nil {
+			return nil, fmt.Errorf("failed to scan inconsistent post: %w", err)
+		}
+
+		if p.GroupID == nil {
+			// The group is unknown, fall back to the author's followers rather than making it public
+			p.Issue = IssueGroupPostWithoutGroup
+			p.RepairPrivacy = PrivacyFollowers
+		} else {
+			// The group is known, so restricting the post to it never widens its audience
+			p.Issue = IssueGroupIDOnNonGroupPost
+			p.RepairPrivacy = PrivacyGroup
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// RepairInconsistentPosts reclassifies every inconsistent post and returns what was changed
+func (s *PostService) RepairInconsistentPosts() ([]InconsistentPost, error) {
+	posts, err := s.FindInconsistentPosts()
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return posts, nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range posts {
+		if _, err := tx.Exec("UPDATE posts SET privacy = ? WHERE id = ?", p.RepairPrivacy, p.PostID); err != nil {
+			return nil, fmt.Errorf("failed to repair post %d: %w", p.PostID, err)
+		}
+		if p.RepairPrivacy == PrivacyGroup {
+			// Custom audiences do not apply to group posts
+			if _, err := tx.Exec("DELETE FROM post_allowed_followers WHERE post_id = ?", p.PostID); err != nil {
+				return nil, fmt.Errorf("failed to clear allowed followers of post %d: %w", p.PostID, err)
+			}
+			if _, err := tx.Exec("DELETE FROM post_excluded_followers WHERE post_id = ?", p.PostID); err != nil {
+				return nil, fmt.Errorf("failed to clear excluded followers of post %d: %w", p.PostID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,63 @@
+package post
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadPostMedia loads the media of every post in one query instead of one query per post
+func (s *PostService) loadPostMedia(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(posts))
+	args := make([]interface{}, len(posts))
+	index := make(map[int64]int, len(posts))
+	for i, p := range posts {
+		placeholders[i] = "?"
+		args[i] = p.ID
+		index[p.ID] = i
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT id, post_id, media_type, file_path, created_at
+        FROM post_media
+        WHERE post_id IN (`+strings.Join(placeholders, ",")+`)
+        ORDER BY id
+    `, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var media PostMedia
+		var postID int64
+		var mediaCreatedAtStr string
+		if err := rows.Scan(
+			&media.ID,
+			&postID,
+			&media.MediaType,
+			&media.FilePath,
+			&mediaCreatedAtStr,
+		); err != nil {
+			return err
+		}
+		media.PostID = strconv.FormatInt(postID, 10)
+
+		// parse the media created_at string
+		media.CreatedAt, err = time.Parse("2006-01-02 15:04:05", mediaCreatedAtStr)
+		if err != nil {
+			return err
+		}
+
+		if i, ok := index[postID]; ok {
+			posts[i].Media = append(posts[i].Media, media)
+		}
+	}
+
+	return rows.Err()
+}
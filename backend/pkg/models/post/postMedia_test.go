@@ -0,0 +1,97 @@
+package post_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"social-network/pkg/db/dbtest"
+	"social-network/pkg/db/sqlite"
+	"social-network/pkg/models/post"
+	"strings"
+	"testing"
+)
+
+// postQueries counts the statements that read posts or post media
+var postQueries = dbtest.NewQueryCounter(func(query string) bool {
+	return strings.Contains(query, "FROM posts") || strings.Contains(query, "FROM post_media")
+})
+
+// setupPostsDB creates a database with one author who has n posts of two media each
+func setupPostsDB(tb testing.TB, n int) *post.PostService {
+	tb.Helper()
+
+	dbPath := filepath.Join(tb.TempDir(), "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../../db/migrations/sqlite"); err != nil {
+		tb.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	db := postQueries.Open(dbPath + "?_foreign_keys=on")
+	tb.Cleanup(func() { db.Close() })
+
+	_, err := db.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+		VALUES ('author', 'author@example.com', 'x', 'Author', 'Author', '2000-01-01', 'author', '')`)
+	if err != nil {
+		tb.Fatalf("Failed to create user: %v", err)
+	}
+
+	service := post.NewPostService(db)
+	for i := 0; i < n; i++ {
+		_, err := service.CreatePost(&post.CreatePostRequest{
+			Content: fmt.Sprintf("post %d", i),
+			Privacy: post.PrivacyPublic,
+			Media: []post.MediaItem{
+				{MediaType: "image/png", FilePath: fmt.Sprintf("/uploads/%d-a.png", i)},
+				{MediaType: "image/png", FilePath: fmt.Sprintf("/uploads/%d-b.png", i)},
+			},
+		}, "author")
+		if err != nil {
+			tb.Fatalf("Failed to create post: %v", err)
+		}
+	}
+
+	return service
+}
+
+func TestGetUserPostsLoadsMediaInOneQuery(t *testing.T) {
+	const n = 20
+	service := setupPostsDB(t, n)
+
+	postQueries.Reset()
+	posts, err := service.GetUserPosts(context.Background(), "author", "author", 0, n)
+	if err != nil {
+		t.Fatalf("GetUserPosts failed: %v", err)
+	}
+
+	if len(posts) != n {
+		t.Fatalf("expected %d posts, got %d", n, len(posts))
+	}
+	for _, p := range posts {
+		if len(p.Media) != 2 {
+			t.Fatalf("post %d: expected 2 media, got %d", p.ID, len(p.Media))
+		}
+		if p.Media[0].PostID != fmt.Sprint(p.ID) {
+			t.Fatalf("post %d: media attached to post %s", p.ID, p.Media[0].PostID)
+		}
+	}
+
+	// One query for the posts and one for all of their media, instead of N+1
+	if got := postQueries.Count(); got != 2 {
+		t.Fatalf("expected 2 post queries, got %d", got)
+	}
+}
+
+func BenchmarkGetUserPosts(b *testing.B) {
+	const n = 50
+	service := setupPostsDB(b, n)
+
+	postQueries.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := service.GetUserPosts(context.Background(), "author", "author", 0, n); err != nil {
+			b.Fatalf("GetUserPosts failed: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(postQueries.Count())/float64(b.N), "queries/op")
+}
@@ -0,0 +1,102 @@
+package post
+
+import (
+	"database/sql"
+	"html"
+	"regexp"
+	"social-network/pkg/models/user"
+	"strings"
+)
+
+// mentionPattern matches @nickname at the start of the content or after a non-word
+// character, so email addresses like bob@example.com aren't treated as mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9]+)`)
+
+// ExtractMentions returns the distinct lowercased nicknames mentioned in content, which is
+// decoded first since stored text is HTML-escaped
+func ExtractMentions(content string) []string {
+	var nicknames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(html.UnescapeString(content), -1) {
+		nickname := strings.ToLower(match[1])
+		if !seen[nickname] {
+			seen[nickname] = true
+			nicknames = append(nicknames, nickname)
+		}
+	}
+	return nicknames
+}
+
+// resolveMentions maps the nicknames mentioned in content to user IDs.
+// Unknown nicknames and the author mentioning themselves are skipped.
+func resolveMentions(content, authorID string) []string {
+	var userIDs []string
+	for _, nickname := range ExtractMentions(content) {
+		mentioned, err := user.GetUserByNickname(nickname)
+		if err != nil || mentioned.ID == authorID {
+			continue
+		}
+		userIDs = append(userIDs, mentioned.ID)
+	}
+	return userIDs
+}
+
+// saveMentions records the users mentioned in a post
+func saveMentions(tx *sql.Tx, postID int64, userIDs []string) error {
+	for _, userID := range userIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO post_mentions (post_id, user_id) VALUES (?, ?)", postID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMentionRecipients returns the users mentioned in a post who are allowed to see it
+func (s *PostService) GetMentionRecipients(postID int64) ([]string, error) {
+	rows, err := s.DB.Query("SELECT user_id FROM post_mentions WHERE post_id = ?", postID)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentioned []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		mentioned = append(mentioned, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var recipients []string
+	for _, userID := range mentioned {
+		canView, err := s.CanViewPost(postID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if canView {
+			recipients = append(recipients, userID)
+		}
+	}
+	return recipients, nil
+}
+
+// CanViewPost reports whether the post passes userID's privacy checks. Posts in public
+// groups are open to non-members too, as in the group's feed and in search, even though
+// visiblePostsQuery leaves them out so they don't flood everyone's home feed.
+func (s *PostService) CanViewPost(postID int64, userID string) (bool, error) {
+	query := "SELECT EXISTS(" + visiblePostsQuery + ` AND p.id = ?)
+		OR EXISTS(
+			SELECT 1 FROM posts p
+			JOIN groups g ON g.id = p.group_id
+			WHERE p.id = ? AND p.privacy = 'group' AND g.is_public = 1 AND p.deleted_at IS NULL
+		)`
+
+	var canView bool
+	err := s.DB.QueryRow(query, append(visiblePostsArgs(userID), postID, postID)...).Scan(&canView)
+	return canView, err
+}
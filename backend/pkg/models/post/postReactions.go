@@ -0,0 +1,164 @@
+package post
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Reaction types a user can leave on a post
+const (
+	ReactionLike  = "like"
+	ReactionLove  = "love"
+	ReactionLaugh = "laugh"
+	ReactionSad   = "sad"
+	ReactionAngry = "angry"
+)
+
+// ReactionTypes lists every supported reaction
+var ReactionTypes = []string{ReactionLike, ReactionLove, ReactionLaugh, ReactionSad, ReactionAngry}
+
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+// ReactionSummary is the reaction breakdown of a post and the requesting user's own reaction
+type ReactionSummary struct {
+	Counts       map[string]int `json:"counts"`
+	UserReaction string         `json:"user_reaction"` // empty when the user has not reacted
+}
+
+func newReactionCounts() map[string]int {
+	counts := make(map[string]int, len(ReactionTypes))
+	for _, reaction := range ReactionTypes {
+		counts[reaction] = 0
+	}
+	return counts
+}
+
+func isValidReaction(reaction string) bool {
+	for _, r := range ReactionTypes {
+		if r == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// ReactToPost sets the user's reaction on a post, replacing any previous one.
+// Sending the reaction the user already has removes it, like toggling a like.
+func (s *PostService) ReactToPost(postID int64, userID, reaction string) (*ReactionSummary, error) {
+	if !isValidReaction(reaction) {
+		return nil, ErrInvalidReaction
+	}
+
+	if err := s.checkPostActive(postID); err != nil {
+		return nil, err
+	}
+
+	// If it's a group post, check group membership
+	var privacy string
+	var groupID *int64
+	if err := s.DB.QueryRow("SELECT privacy, group_id FROM posts WHERE id = ?", postID).Scan(&privacy, &groupID); err != nil {
+		return nil, err
+	}
+	if privacy == string(PrivacyGroup) && groupID != nil {
+		if err := s.validateGroupMembership(userID, *groupID); err != nil {
+			return nil, errors.New("unauthorized: cannot react to group post - not a member")
+		}
+	}
+
+	var current string
+	err := s.DB.QueryRow("SELECT reaction FROM post_reactions WHERE post_id = ? AND user_id = ?", postID, userID).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if current == reaction {
+		_, err = s.DB.Exec("DELETE FROM post_reactions WHERE post_id = ? AND user_id = ?", postID, userID)
+	} else {
+		_, err = s.DB.Exec(`
+            INSERT INTO post_reactions (post_id, user_id, reaction)
+            VALUES (?, ?, ?)
+            ON CONFLICT(post_id, user_id) DO UPDATE SET
+                reaction = excluded.reaction,
+                created_at = CURRENT_TIMESTAMP
+        `, postID, userID, reaction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The reaction is saved by now, so reading the summary back isn't tied to a request
+	summaries, err := s.getReactionSummaries(context.Background(), []int64{postID}, userID)
+	if err != nil {
+		return nil, err
+	}
+	return summaries[postID], nil
+}
+
+// getReactionSummaries returns the reaction breakdown of every post in one query
+func (s *PostService) getReactionSummaries(ctx context.Context, postIDs []int64, userID string) (map[int64]*ReactionSummary, error) {
+	summaries := make(map[int64]*ReactionSummary, len(postIDs))
+	if len(postIDs) == 0 {
+		return summaries, nil
+	}
+
+	placeholders := make([]string, len(postIDs))
+	args := make([]interface{}, 0, len(postIDs)+1)
+	args = append(args, userID)
+	for i, id := range postIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+		summaries[id] = &ReactionSummary{Counts: newReactionCounts()}
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT post_id, reaction, COUNT(*), MAX(user_id = ?)
+        FROM post_reactions
+        WHERE post_id IN (`+strings.Join(placeholders, ",")+`)
+        GROUP BY post_id, reaction
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int64
+		var reaction string
+		var count int
+		var mine bool
+		if err := rows.Scan(&postID, &reaction, &count, &mine); err != nil {
+			return nil, err
+		}
+
+		summary := summaries[postID]
+		summary.Counts[reaction] = count
+		if mine {
+			summary.UserReaction = reaction
+		}
+	}
+
+	return summaries, rows.Err()
+}
+
+// attachReactions fills in the reaction breakdown and the user's reaction of every post
+func (s *PostService) attachReactions(ctx context.Context, posts []Post, userID string) error {
+	postIDs := make([]int64, len(posts))
+	for i, p := range posts {
+		postIDs[i] = p.ID
+	}
+
+	summaries, err := s.getReactionSummaries(ctx, postIDs, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if summary, ok := summaries[posts[i].ID]; ok {
+			posts[i].Reactions = summary.Counts
+			posts[i].UserReaction = summary.UserReaction
+		}
+	}
+	return nil
+}
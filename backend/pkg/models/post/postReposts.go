@@ -0,0 +1,105 @@
+package post
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrCannotRepost    = errors.New("only other users' public posts can be reposted")
+	ErrAlreadyReposted = errors.New("post already reposted")
+	ErrRepostNotEdited = errors.New("reposts cannot be edited")
+)
+
+// Repost reshares another user's public post to userID's feed, as a public post without
+// content of its own that references the original. Reposting a repost reshares its
+// original. Group posts and posts shared with followers only can't be reposted, which
+// keeps them within the audience they were shared with.
+func (s *PostService) Repost(originalPostID int64, userID string) (int64, error) {
+	var authorID string
+	var privacy PrivacyType
+	var repostOf sql.NullInt64
+	err := s.DB.QueryRow(
+		"SELECT author_id, privacy, repost_of FROM posts WHERE id = ? AND deleted_at IS NULL",
+		originalPostID,
+	).Scan(&authorID, &privacy, &repostOf)
+	if err == sql.ErrNoRows {
+		return 0, ErrPostNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if repostOf.Valid {
+		// Originals are never reposts themselves, so this goes one level deep at most
+		return s.Repost(repostOf.Int64, userID)
+	}
+
+	canView, err := s.CanViewPost(originalPostID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !canView {
+		return 0, ErrPostNotFound
+	}
+	if privacy != PrivacyPublic || authorID == userID {
+		return 0, ErrCannotRepost
+	}
+
+	result, err := s.DB.Exec(`
+        INSERT INTO posts (author_id, content, privacy, repost_of) VALUES (?, '', 'public', ?)
+        ON CONFLICT(author_id, repost_of) WHERE repost_of IS NOT NULL AND deleted_at IS NULL DO NOTHING
+    `, userID, originalPostID)
+	if err != nil {
+		return 0, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if inserted == 0 {
+		return 0, ErrAlreadyReposted
+	}
+
+	return result.LastInsertId()
+}
+
+// attachOriginals sets the original post of each repost, loaded like a feed post for userID.
+// An original the user can no longer see, because it was deleted or its privacy changed,
+// is left nil.
+func (s *PostService) attachOriginals(ctx context.Context, posts []Post, userID string) error {
+	seen := make(map[int64]bool)
+	var ids []interface{}
+	for _, p := range posts {
+		if p.RepostOf != nil && !seen[*p.RepostOf] {
+			seen[*p.RepostOf] = true
+			ids = append(ids, *p.RepostOf)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	query := visiblePostsQuery + " AND p.id IN (" + placeholders + ")"
+	rows, err := s.DB.QueryContext(ctx, query, append(visiblePostsArgs(userID), ids...)...)
+	if err != nil {
+		return err
+	}
+	originals, err := s.scanFeedPosts(ctx, rows, userID, 0)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int64]*Post, len(originals))
+	for i := range originals {
+		byID[originals[i].ID] = &originals[i]
+	}
+	for i := range posts {
+		if posts[i].RepostOf != nil {
+			posts[i].Original = byID[*posts[i].RepostOf]
+		}
+	}
+	return nil
+}
@@ -1,22 +1,25 @@
 package post
 
-
 type CreatePostRequest struct {
-	Content   string          `json:"content"` 
-	Privacy   PrivacyType     `json:"privacy" oneof:"public followers custom group"` 
-	GroupID   *int64          `json:"group_id,omitempty"` // Add group ID for group posts
-	Media     []MediaItem     `json:"media"` 
+	Content string      `json:"content"`
+	Privacy PrivacyType `json:"privacy" oneof:"public followers custom group"`
+	GroupID *int64      `json:"group_id,omitempty"` // Add group ID for group posts
+	Media   []MediaItem `json:"media"`
 	// for custom privacy, this will be a list of user IDs
 	AllowedFollowers []string `json:"allowed_followers,omitempty"`
+	// for followers and custom privacy, followers who must not see the post.
+	// Exclusion takes precedence: a user in both lists cannot see the post.
+	ExcludedFollowers []string `json:"excluded_followers,omitempty"`
 }
+
 // CreatePostResponse represents the response after creating a post.
 type CreatePostResponse struct {
-	Success   bool        `json:"success"`
-	PostID    int64       `json:"post_id,omitempty"` // ID of the created post, if successful
-	Error     string      `json:"error,omitempty"`   // Error message, if any
-	AuthorID  string      `json:"author_id,omitempty"` // ID of the author, if successful
-	Author    AuthorData  `json:"author,omitempty"` // Author of the post, if successful
-	CreatedAt string      `json:"created_at,omitempty"` // Timestamp of post creation
+	Success   bool       `json:"success"`
+	PostID    int64      `json:"post_id,omitempty"`    // ID of the created post, if successful
+	Error     string     `json:"error,omitempty"`      // Error message, if any
+	AuthorID  string     `json:"author_id,omitempty"`  // ID of the author, if successful
+	Author    AuthorData `json:"author,omitempty"`     // Author of the post, if successful
+	CreatedAt string     `json:"created_at,omitempty"` // Timestamp of post creation
 }
 
 type GetPostsResponse struct {
@@ -31,24 +34,26 @@ type MediaItem struct {
 	FilePath  string `json:"file_path"`
 }
 
-
 // Edit ===============================================
 type EditPostRequest struct {
-	Content           string           `json:"content"`
-	Privacy           PrivacyType      `json:"privacy" oneof:"public followers custom group"`
-	GroupID           *int64           `json:"group_id,omitempty"` // Add group ID support
-	Media             []MediaItem      `json:"media"`
+	Content string      `json:"content"`
+	Privacy PrivacyType `json:"privacy" oneof:"public followers custom group"`
+	GroupID *int64      `json:"group_id,omitempty"` // Add group ID support
+	Media   []MediaItem `json:"media"`
 	// For custome privacy
-	AllowedFollowers  []string         `json:"allowed_followers,omitempty"` 	
+	AllowedFollowers []string `json:"allowed_followers,omitempty"`
+	// Followers hidden from the post, see CreatePostRequest.ExcludedFollowers
+	ExcludedFollowers []string `json:"excluded_followers,omitempty"`
 }
 
 type EditPostResponse struct {
-	Success    bool    `json:"success"`
-	Error      string   `json:"error,omitempty"` 
+	Success  bool   `json:"success"`
+	Modified bool   `json:"modified"` // false when the edit did not change anything
+	Error    string `json:"error,omitempty"`
 }
 
 // Delete ===============================================
 type DeletePostResponse struct {
-	Success   bool     `json:"success"`
-	Error     string   `json:"error,omitempty"` // Error message, if any
-}
\ No newline at end of file
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"` // Error message, if any
+}
@@ -0,0 +1,95 @@
+package post
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RestoreWindow is how long a deleted post can still be restored by its author. Posts
+// deleted longer ago are purged for good the next time any post is deleted.
+const RestoreWindow = 7 * 24 * time.Hour
+
+var (
+	ErrPostNotFound         = errors.New("post not found")
+	ErrPostDeleted          = errors.New("post has been deleted")
+	ErrPostNotDeleted       = errors.New("post is not deleted")
+	ErrRestoreWindowExpired = errors.New("post can no longer be restored")
+	ErrPostRemovedByGroup   = errors.New("post was removed by a group moderator")
+)
+
+// checkPostActive returns ErrPostNotFound or ErrPostDeleted when the post can't be interacted with
+func (s *PostService) checkPostActive(postID int64) error {
+	var deletedAt sql.NullString
+	err := s.DB.QueryRow("SELECT deleted_at FROM posts WHERE id = ?", postID).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if deletedAt.Valid {
+		return ErrPostDeleted
+	}
+	return nil
+}
+
+// RestorePost undoes a soft delete as long as it happened within RestoreWindow
+func (s *PostService) RestorePost(postID int64, authorID string) error {
+	var currentAuthorID string
+	var deletedAt, deletedBy sql.NullString
+	err := s.DB.QueryRow("SELECT author_id, deleted_at, deleted_by FROM posts WHERE id = ?", postID).Scan(&currentAuthorID, &deletedAt, &deletedBy)
+	if err == sql.ErrNoRows {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if currentAuthorID != authorID {
+		return errors.New("unauthorized: you are not the author of this post")
+	}
+	if !deletedAt.Valid {
+		return ErrPostNotDeleted
+	}
+	if deletedBy.Valid && deletedBy.String != authorID {
+		return ErrPostRemovedByGroup
+	}
+
+	deletedTime, err := time.Parse("2006-01-02 15:04:05", deletedAt.String)
+	if err != nil {
+		return err
+	}
+	if time.Since(deletedTime) > RestoreWindow {
+		return ErrRestoreWindowExpired
+	}
+
+	_, err = s.DB.Exec("UPDATE posts SET deleted_at = NULL, deleted_by = NULL WHERE id = ?", postID)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed: posts.author_id, posts.repost_of") {
+		// The user has reposted the same post again since deleting this repost
+		return ErrAlreadyReposted
+	}
+	return err
+}
+
+// purgeExpiredPostsTx hard-deletes the posts that were deleted longer than RestoreWindow
+// ago. Media, comments, likes and the other rows of the post go with it through
+// ON DELETE CASCADE; reports and group pins, which have no foreign key, are cleared here.
+func purgeExpiredPostsTx(tx *sql.Tx) error {
+	expired := `SELECT id FROM posts WHERE deleted_at IS NOT NULL AND datetime(deleted_at) < datetime('now', ?)`
+	window := fmt.Sprintf("-%d seconds", int64(RestoreWindow.Seconds()))
+
+	queries := []string{
+		"DELETE FROM reports WHERE target_type = 'post' AND target_id IN (" + expired + ")",
+		"DELETE FROM reports WHERE target_type = 'comment' AND target_id IN (SELECT id FROM comments WHERE post_id IN (" + expired + "))",
+		"UPDATE groups SET pinned_post_id = NULL WHERE pinned_post_id IN (" + expired + ")",
+		"DELETE FROM posts WHERE id IN (" + expired + ")",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, window); err != nil {
+			return err
+		}
+	}
+	return nil
+}
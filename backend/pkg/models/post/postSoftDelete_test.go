@@ -0,0 +1,69 @@
+package post_test
+
+import (
+	"errors"
+	"social-network/pkg/models/post"
+	"testing"
+)
+
+func TestDeletePostPurgesPostsPastTheRestoreWindow(t *testing.T) {
+	service := setupPostsDB(t, 2)
+
+	var oldID, recentID int64
+	if err := service.DB.QueryRow("SELECT MIN(id), MAX(id) FROM posts").Scan(&oldID, &recentID); err != nil {
+		t.Fatalf("Failed to get posts: %v", err)
+	}
+	_, err := service.DB.Exec("UPDATE posts SET deleted_at = datetime('now', '-8 days'), deleted_by = 'author' WHERE id = ?", oldID)
+	if err != nil {
+		t.Fatalf("Failed to soft delete post: %v", err)
+	}
+
+	if err := service.DeletePost(recentID, "author"); err != nil {
+		t.Fatalf("DeletePost failed: %v", err)
+	}
+
+	var posts, media int
+	if err := service.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE id = ?", oldID).Scan(&posts); err != nil {
+		t.Fatalf("Failed to count posts: %v", err)
+	}
+	if err := service.DB.QueryRow("SELECT COUNT(*) FROM post_media WHERE post_id = ?", oldID).Scan(&media); err != nil {
+		t.Fatalf("Failed to count media: %v", err)
+	}
+	if posts != 0 || media != 0 {
+		t.Errorf("expected the expired post and its media to be purged, got %d posts and %d media", posts, media)
+	}
+
+	// The post deleted just now can still be restored
+	if err := service.RestorePost(recentID, "author"); err != nil {
+		t.Errorf("RestorePost failed: %v", err)
+	}
+}
+
+func TestRestoreRepostConflictsWithNewerRepost(t *testing.T) {
+	service := setupPostsDB(t, 1)
+	_, err := service.DB.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+		VALUES ('reposter', 'reposter@example.com', 'x', 'First', 'Last', '2000-01-01', 'reposter', '')`)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	var originalID int64
+	if err := service.DB.QueryRow("SELECT id FROM posts WHERE author_id = 'author'").Scan(&originalID); err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+
+	firstID, err := service.Repost(originalID, "reposter")
+	if err != nil {
+		t.Fatalf("Repost failed: %v", err)
+	}
+	if err := service.DeletePost(firstID, "reposter"); err != nil {
+		t.Fatalf("DeletePost failed: %v", err)
+	}
+	if _, err := service.Repost(originalID, "reposter"); err != nil {
+		t.Fatalf("second Repost failed: %v", err)
+	}
+
+	if err := service.RestorePost(firstID, "reposter"); !errors.Is(err, post.ErrAlreadyReposted) {
+		t.Fatalf("expected ErrAlreadyReposted, got %v", err)
+	}
+}
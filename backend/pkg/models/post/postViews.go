@@ -0,0 +1,11 @@
+package post
+
+// RecordView counts userID as a viewer of the post. A user counts once however often they
+// open the post, and the author's own views are not counted.
+func (s *PostService) RecordView(postID int64, userID string) error {
+	_, err := s.DB.Exec(`
+        INSERT OR IGNORE INTO post_views (post_id, user_id)
+        SELECT id, ? FROM posts WHERE id = ? AND author_id != ?
+    `, userID, postID, userID)
+	return err
+}
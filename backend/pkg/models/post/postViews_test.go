@@ -0,0 +1,95 @@
+package post_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"social-network/pkg/models/post"
+	"testing"
+)
+
+func TestGetPostByIDCountsViews(t *testing.T) {
+	service := setupPostsDB(t, 1)
+	for _, id := range []string{"viewer", "other"} {
+		_, err := service.DB.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+			VALUES (?, ? || '@example.com', 'x', 'First', 'Last', '2000-01-01', ?, '')`, id, id, id)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	var postID int64
+	if err := service.DB.QueryRow("SELECT id FROM posts WHERE author_id = 'author'").Scan(&postID); err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+
+	// Opening a post again and the author's own views don't add to the count
+	for _, viewer := range []string{"viewer", "viewer", "author", "other"} {
+		if _, err := service.GetPostByID(context.Background(), fmt.Sprint(postID), viewer); err != nil {
+			t.Fatalf("GetPostByID as %s failed: %v", viewer, err)
+		}
+	}
+	p, err := service.GetPostByID(context.Background(), fmt.Sprint(postID), "author")
+	if err != nil {
+		t.Fatalf("GetPostByID failed: %v", err)
+	}
+	if p.ViewCount != 2 {
+		t.Errorf("expected 2 views, got %d", p.ViewCount)
+	}
+}
+
+func TestGetPostByIDHidesPostsTheUserCantSee(t *testing.T) {
+	service := setupPostsDB(t, 0)
+	_, err := service.DB.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+		VALUES ('stranger', 'stranger@example.com', 'x', 'First', 'Last', '2000-01-01', 'stranger', '')`)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	postID, err := service.CreatePost(&post.CreatePostRequest{Content: "followers only", Privacy: post.PrivacyFollowers}, "author")
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+
+	if _, err := service.GetPostByID(context.Background(), fmt.Sprint(postID), "stranger"); !errors.Is(err, post.ErrPostNotFound) {
+		t.Fatalf("expected post not found, got %v", err)
+	}
+
+	var views int
+	if err := service.DB.QueryRow("SELECT COUNT(*) FROM post_views WHERE post_id = ?", postID).Scan(&views); err != nil {
+		t.Fatalf("Failed to count views: %v", err)
+	}
+	if views != 0 {
+		t.Errorf("expected no views to be recorded, got %d", views)
+	}
+}
+
+func TestGetPostByIDShowsPublicGroupPostsToNonMembers(t *testing.T) {
+	service := setupPostsDB(t, 0)
+	_, err := service.DB.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+		VALUES ('stranger', 'stranger@example.com', 'x', 'First', 'Last', '2000-01-01', 'stranger', '')`)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	postIDs := make(map[bool]int64)
+	for _, isPublic := range []bool{true, false} {
+		result, err := service.DB.Exec("INSERT INTO groups (creator_id, title, description, is_public) VALUES ('author', 'Group', '', ?)", isPublic)
+		if err != nil {
+			t.Fatalf("Failed to create group: %v", err)
+		}
+		groupID, _ := result.LastInsertId()
+		result, err = service.DB.Exec("INSERT INTO posts (author_id, content, privacy, group_id) VALUES ('author', 'group post', 'group', ?)", groupID)
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		postIDs[isPublic], _ = result.LastInsertId()
+	}
+
+	if _, err := service.GetPostByID(context.Background(), fmt.Sprint(postIDs[true]), "stranger"); err != nil {
+		t.Errorf("expected the public group post to be visible to a non-member, got %v", err)
+	}
+	if _, err := service.GetPostByID(context.Background(), fmt.Sprint(postIDs[false]), "stranger"); !errors.Is(err, post.ErrPostNotFound) {
+		t.Errorf("expected the private group post to be hidden from a non-member, got %v", err)
+	}
+}
@@ -2,124 +2,182 @@ package post
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"social-network/pkg/utils"
 	"strings"
 )
 
+// MaxMediaPerPost is the most media items a single post can carry
+const MaxMediaPerPost = 4
+
+// mediaUploadPrefix is the public path every uploaded media file is served under
+const mediaUploadPrefix = "/uploads/"
+
+// ValidateCreatePostRequest checks a new post; every failing field is reported in the returned utils.ValidationErrors
 func ValidateCreatePostRequest(req *CreatePostRequest) (bool, error) {
 	if req == nil {
 		return false, errors.New("request cannot be null")
 	}
 
-	if strings.TrimSpace(req.Content) == "" {
-		return false, errors.New("content cannot be empty")
+	var errs utils.ValidationErrors
+	validateContent(req.Content, &errs)
+
+	if validatePrivacy(req.Privacy, &errs) {
+		validatePrivacyAudience(req, &errs)
+		validateExcludedFollowers(req.Privacy, req.ExcludedFollowers, &errs)
 	}
 
-	if len(req.Content) > 500 {
-		return false, errors.New("content cannot exceed 500 characters")
+	validateMedia(req.Media, &errs)
+
+	if err := errs.Err(); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	// Validate privacy setting
-	validPrivacyTypes := []PrivacyType{PrivacyPublic, PrivacyFollowers, PrivacyCustom, PrivacyGroup}
-	isValidPrivacy := false
-	for _, validType := range validPrivacyTypes {
-		if req.Privacy == validType {
-			isValidPrivacy = true
-			break
-		}
+func validateContent(content string, errs *utils.ValidationErrors) {
+	if strings.TrimSpace(content) == "" {
+		errs.Add("content", utils.CodeRequired, "content cannot be empty")
+	} else if len(content) > 500 {
+		errs.Add("content", utils.CodeTooLong, "content cannot exceed 500 characters")
 	}
-	if !isValidPrivacy {
-		return false, errors.New("invalid privacy setting")
+}
+
+// validatePrivacy reports whether privacy is one of the known settings
+func validatePrivacy(privacy PrivacyType, errs *utils.ValidationErrors) bool {
+	switch privacy {
+	case PrivacyPublic, PrivacyFollowers, PrivacyCustom, PrivacyGroup:
+		return true
 	}
+	errs.Add("privacy", utils.CodeInvalid, "invalid privacy setting")
+	return false
+}
 
-	// Validate group post requirements
-	if req.Privacy == PrivacyGroup {
+// validatePrivacyAudience enforces which of group_id and allowed_followers each privacy setting may carry
+func validatePrivacyAudience(req *CreatePostRequest, errs *utils.ValidationErrors) {
+	switch req.Privacy {
+	case PrivacyGroup:
 		if req.GroupID == nil || *req.GroupID <= 0 {
-			return false, errors.New("group_id is required for group posts")
+			errs.Add("group_id", utils.CodeRequired, "group_id is required for group posts")
+		}
+		if len(req.AllowedFollowers) > 0 {
+			errs.Add("allowed_followers", utils.CodeInvalid, "allowed followers can only be set for custom privacy")
 		}
-	} else {
+	case PrivacyCustom:
 		if req.GroupID != nil {
-			return false, errors.New("group_id should only be provided for group posts")
+			errs.Add("group_id", utils.CodeInvalid, "group_id should only be provided for group posts")
+		}
+		if len(req.AllowedFollowers) == 0 {
+			errs.Add("allowed_followers", utils.CodeRequired, "allowed followers cannot be empty for custom privacy")
+		}
+		for _, followerID := range req.AllowedFollowers {
+			if strings.TrimSpace(followerID) == "" {
+				errs.Add("allowed_followers", utils.CodeInvalid, "allowed followers cannot contain empty user IDs")
+				break
+			}
+		}
+	default:
+		if req.GroupID != nil {
+			errs.Add("group_id", utils.CodeInvalid, "group_id should only be provided for group posts")
+		}
+		if len(req.AllowedFollowers) > 0 {
+			errs.Add("allowed_followers", utils.CodeInvalid, "allowed followers can only be set for custom privacy")
 		}
 	}
+}
 
-	// req custom privacy, ensure allowed followers are provided
-	if req.Privacy == PrivacyCustom && len(req.AllowedFollowers) == 0 {
-		return false, errors.New("allowed followers cannot be empty for custom privacy")
+// validateExcludedFollowers only allows an exclude list on followers and custom posts.
+// When both lists are given on a custom post, exclusion wins over the allow list.
+func validateExcludedFollowers(privacy PrivacyType, excluded []string, errs *utils.ValidationErrors) {
+	if len(excluded) == 0 {
+		return
 	}
-
-	// Validate each media item
-	for i, media := range req.Media {
-		if err := validateMediaItem(media, i); err != nil {
-			return false, err
+	if privacy != PrivacyFollowers && privacy != PrivacyCustom {
+		errs.Add("excluded_followers", utils.CodeInvalid, "excluded followers can only be set for followers or custom privacy")
+		return
+	}
+	for _, followerID := range excluded {
+		if strings.TrimSpace(followerID) == "" {
+			errs.Add("excluded_followers", utils.CodeInvalid, "excluded followers cannot contain empty user IDs")
+			return
 		}
 	}
-
-	return true, nil
 }
 
-func validateMediaItem(media MediaItem, index int) error {
-	// Validate media type
-	validMediaTypes := map[string]bool{
-		"image/jpeg": true,
-		"image/png":  true,
-		"image/gif":  true,
+// validateMedia checks the number of media items and each item in turn
+func validateMedia(media []MediaItem, errs *utils.ValidationErrors) {
+	if len(media) > MaxMediaPerPost {
+		errs.Add("media", utils.CodeTooLong, fmt.Sprintf("a post can have at most %d media items", MaxMediaPerPost))
+	}
+
+	for i, item := range media {
+		validateMediaItem(item, i, errs)
 	}
+}
+
+// mediaKind reduces a media type to image, video or gif. Both the bare kind and the
+// MIME type sent back by the upload endpoint (e.g. "image/png") are accepted.
+func mediaKind(mediaType string) string {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case mediaType == "gif" || mediaType == "image/gif":
+		return "gif"
+	case mediaType == "image" || strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case mediaType == "video" || strings.HasPrefix(mediaType, "video/"):
+		return "video"
+	}
+	return ""
+}
+
+func validateMediaItem(media MediaItem, index int, errs *utils.ValidationErrors) {
+	field := fmt.Sprintf("media[%d]", index)
 
-	if !validMediaTypes[media.MediaType] {
-		return errors.New("invalid media type at index " + string(index) + "it must be either of these options (image/jpeg, image/png, image/gif)")
+	// Validate media type
+	if mediaKind(media.MediaType) == "" {
+		errs.Add(field, utils.CodeInvalid, "invalid media type, it must be an image, video or gif")
+		return
 	}
 
 	if media.FilePath == "" {
-		return errors.New("file path cannot be empty at index " + string(index))
+		errs.Add(field, utils.CodeRequired, "file path cannot be empty")
+		return
 	}
 
-	return nil
+	// The file must be one of ours: no external URLs and no escaping the uploads directory
+	if strings.Contains(media.FilePath, "..") || !strings.HasPrefix(path.Clean(media.FilePath), mediaUploadPrefix) {
+		errs.Add(field, utils.CodeInvalid, "file path must point to an uploaded file under "+mediaUploadPrefix)
+	}
 }
 
+// ValidateEditPostRequest checks a post edit; every failing field is reported in the returned utils.ValidationErrors
 func ValidateEditPostRequest(req *EditPostRequest) (bool, error) {
 	if req == nil {
 		return false, errors.New("request cannot be null")
 	}
 
-	if strings.TrimSpace(req.Content) == "" {
-		return false, errors.New("content cannot be empty")
-	}
-
-	if len(req.Content) > 500 {
-		return false, errors.New("content cannot exceed 500 characters")
-	}
+	var errs utils.ValidationErrors
+	validateContent(req.Content, &errs)
 
-	// Validate privacy setting
-	validPrivacyTypes := []PrivacyType{PrivacyPublic, PrivacyFollowers, PrivacyCustom, PrivacyGroup}
-	isValidPrivacy := false
-	for _, validType := range validPrivacyTypes {
-		if req.Privacy == validType {
-			isValidPrivacy = true
-			break
+	if validatePrivacy(req.Privacy, &errs) {
+		// Validate group post requirements
+		if req.Privacy == PrivacyGroup && (req.GroupID == nil || *req.GroupID <= 0) {
+			errs.Add("group_id", utils.CodeRequired, "group_id is required for group posts")
 		}
-	}
-	if !isValidPrivacy {
-		return false, errors.New("invalid privacy setting")
-	}
 
-	// Validate group post requirements
-	if req.Privacy == PrivacyGroup {
-		if req.GroupID == nil || *req.GroupID <= 0 {
-			return false, errors.New("group_id is required for group posts")
+		// for custome ensure allowed followers are provided
+		if req.Privacy == PrivacyCustom && len(req.AllowedFollowers) == 0 {
+			errs.Add("allowed_followers", utils.CodeRequired, "allowed followers cannot be empty for custom privacy")
 		}
-	}
 
-	// for custome ensure allowed followers are provided
-	if req.Privacy == PrivacyCustom && len(req.AllowedFollowers) == 0 {
-		return false, errors.New("allowed followers cannot be empty for custom privacy")
+		validateExcludedFollowers(req.Privacy, req.ExcludedFollowers, &errs)
 	}
 
-	// Validate each media item
-	for i, media := range req.Media {
-		if err := validateMediaItem(media, i); err != nil {
-			return false, err
-		}
-	}
+	validateMedia(req.Media, &errs)
 
+	if err := errs.Err(); err != nil {
+		return false, err
+	}
 	return true, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"database/sql"
+	"errors"
+	"social-network/pkg/utils"
+	"strings"
+	"time"
+)
+
+// Reported content types
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// Report statuses. New reports are pending until a moderator deals with them.
+const (
+	StatusPending   = "pending"
+	StatusResolved  = "resolved"
+	StatusDismissed = "dismissed"
+)
+
+// MaxReasonLength caps the length of a report's reason, in characters
+const MaxReasonLength = 500
+
+var (
+	ErrTargetNotFound  = errors.New("reported content not found")
+	ErrAlreadyReported = errors.New("content already reported")
+	ErrReasonRequired  = errors.New("reason is required")
+	ErrReasonTooLong   = errors.New("reason is too long")
+)
+
+type Report struct {
+	ID         int64     `json:"id"`
+	ReporterID string    `json:"reporter_id"`
+	TargetType string    `json:"target_type"`
+	TargetID   int64     `json:"target_id"`
+	GroupID    *int64    `json:"group_id,omitempty"` // Set for content posted in a group
+	AuthorID   string    `json:"author_id"`          // Author of the reported content
+	Reason     string    `json:"reason"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Target is the reported post or comment
+type Target struct {
+	Type     string
+	ID       int64
+	PostID   int64  // The post itself, or the post the comment is on
+	AuthorID string // Author of the post or comment
+	GroupID  *int64 // Group of the post, nil outside groups
+}
+
+// GetTarget looks up a reported post or comment. ErrTargetNotFound is returned for
+// deleted posts and for comments on them.
+func GetTarget(db *sql.DB, targetType string, targetID int64) (*Target, error) {
+	target := &Target{Type: targetType, ID: targetID}
+	var err error
+	switch targetType {
+	case TargetPost:
+		target.PostID = targetID
+		err = db.QueryRow(
+			"SELECT author_id, group_id FROM posts WHERE id = ? AND deleted_at IS NULL",
+			targetID,
+		).Scan(&target.AuthorID, &target.GroupID)
+	case TargetComment:
+		err = db.QueryRow(`
+            SELECT c.post_id, c.author_id, p.group_id
+            FROM comments c
+            JOIN posts p ON c.post_id = p.id
+            WHERE c.id = ? AND p.deleted_at IS NULL
+        `, targetID).Scan(&target.PostID, &target.AuthorID, &target.GroupID)
+	default:
+		return nil, ErrTargetNotFound
+	}
+	if err == sql.ErrNoRows {
+		return nil, ErrTargetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// CreateReport stores a pending report of the target. A user can report an item only
+// once, ErrAlreadyReported is returned for a second report. Permission checks are left
+// to the caller.
+func CreateReport(db *sql.DB, reporterID string, target *Target, reason string) (*Report, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if len([]rune(reason)) > MaxReasonLength {
+		return nil, ErrReasonTooLong
+	}
+	// Reasons are shown to moderators, so they are escaped like posts and comments
+	reason = utils.SanitizeText(reason)
+
+	// The unique constraint is the last word when two reports race
+	result, err := db.Exec(`
+        INSERT INTO reports (reporter_id, target_type, target_id, group_id, reason)
+        VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT(reporter_id, target_type, target_id) DO NOTHING
+    `, reporterID, target.Type, target.ID, target.GroupID, reason)
+	if err != nil {
+		return nil, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if inserted == 0 {
+		return nil, ErrAlreadyReported
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		ID:         id,
+		ReporterID: reporterID,
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		GroupID:    target.GroupID,
+		AuthorID:   target.AuthorID,
+		Reason:     reason,
+		Status:     StatusPending,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// GetPendingReports returns the group's pending reports, oldest first. Reports of content
+// that has been deleted since are left out.
+func GetPendingReports(db *sql.DB, groupID string) ([]Report, error) {
+	rows, err := db.Query(`
+        SELECT r.id, r.reporter_id, r.target_type, r.target_id, r.group_id, r.reason, r.status, r.created_at,
+               COALESCE(p.author_id, c.author_id)
+        FROM reports r
+        LEFT JOIN posts p ON r.target_type = 'post' AND p.id = r.target_id AND p.deleted_at IS NULL
+        LEFT JOIN comments c ON r.target_type = 'comment' AND c.id = r.target_id
+            AND EXISTS(SELECT 1 FROM posts cp WHERE cp.id = c.post_id AND cp.deleted_at IS NULL)
+        WHERE r.group_id = ? AND r.status = 'pending'
+        AND COALESCE(p.author_id, c.author_id) IS NOT NULL
+        ORDER BY r.created_at ASC, r.id ASC
+    `, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var r Report
+		var createdAt string
+		err := rows.Scan(&r.ID, &r.ReporterID, &r.TargetType, &r.TargetID, &r.GroupID,
+			&r.Reason, &r.Status, &createdAt, &r.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+		r.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAt)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+
+	return reports, rows.Err()
+}
@@ -0,0 +1,151 @@
+package search
+
+import (
+	"database/sql"
+	"social-network/pkg/models/group"
+	"social-network/pkg/models/post"
+	"social-network/pkg/models/user"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Result types, also the accepted values of the type filter besides TypeAll
+const (
+	TypeAll    = "all"
+	TypeUsers  = "users"
+	TypeGroups = "groups"
+	TypePosts  = "posts"
+)
+
+// Relevance scores, from best to worst match of the query against a result's text
+const (
+	ScoreExact     = 3
+	ScorePrefix    = 2
+	ScoreSubstring = 1
+)
+
+// Result is one ranked search hit; Item has the same shape as in the per-type search endpoints
+type Result struct {
+	Type  string                 `json:"type"`
+	Score int                    `json:"score"`
+	Item  map[string]interface{} `json:"item"`
+}
+
+// Results is the merged, ranked list with the number of results of each type in it
+type Results struct {
+	Results []Result       `json:"results"`
+	Counts  map[string]int `json:"counts"`
+}
+
+// GlobalSearch runs the user, group and post searches concurrently, each with its own privacy
+// rules, and merges them into one list of at most limit results ranked by relevance.
+// searchType restricts the search to one type; TypeAll searches everything.
+func GlobalSearch(db *sql.DB, userID, query, searchType string, limit int) (*Results, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		results  []Result
+		firstErr error
+	)
+
+	run := func(resultType string, search func() ([]map[string]interface{}, error), score func(map[string]interface{}) int) {
+		if searchType != TypeAll && searchType != resultType {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items, err := search()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, item := range items {
+				results = append(results, Result{Type: resultType, Score: score(item), Item: item})
+			}
+		}()
+	}
+
+	run(TypeUsers, func() ([]map[string]interface{}, error) {
+		return user.SearchUsers(db, query, userID, limit, 0)
+	}, func(item map[string]interface{}) int {
+		fullName := stringField(item, "first_name") + " " + stringField(item, "last_name")
+		return bestScore(query, stringField(item, "nickname"), stringField(item, "first_name"), stringField(item, "last_name"), fullName)
+	})
+
+	run(TypeGroups, func() ([]map[string]interface{}, error) {
+		return group.SearchGroups(db, query, userID, limit, 0)
+	}, func(item map[string]interface{}) int {
+		// A match only in the description ranks as a substring match
+		if score := bestScore(query, stringField(item, "title")); score > 0 {
+			return score
+		}
+		return ScoreSubstring
+	})
+
+	run(TypePosts, func() ([]map[string]interface{}, error) {
+		return post.NewPostService(db).SearchPosts(query, userID, 0, limit, 0)
+	}, func(item map[string]interface{}) int {
+		return bestScore(query, stringField(item, "content"))
+	})
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// Best matches first; ties keep users, groups, posts order and each search's own ranking
+	typeOrder := map[string]int{TypeUsers: 0, TypeGroups: 1, TypePosts: 2}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return typeOrder[results[i].Type] < typeOrder[results[j].Type]
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	merged := &Results{
+		Results: []Result{},
+		Counts:  map[string]int{TypeUsers: 0, TypeGroups: 0, TypePosts: 0},
+	}
+	merged.Results = append(merged.Results, results...)
+	for _, r := range results {
+		merged.Counts[r.Type]++
+	}
+	return merged, nil
+}
+
+// bestScore returns how well query matches the best of fields, 0 when none contain it
+func bestScore(query string, fields ...string) int {
+	query = strings.ToLower(strings.TrimSpace(query))
+	best := 0
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		score := 0
+		switch {
+		case field == query:
+			score = ScoreExact
+		case strings.HasPrefix(field, query):
+			score = ScorePrefix
+		case strings.Contains(field, query):
+			score = ScoreSubstring
+		}
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+func stringField(item map[string]interface{}, key string) string {
+	s, _ := item[key].(string)
+	return s
+}
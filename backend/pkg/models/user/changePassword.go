@@ -0,0 +1,60 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"social-network/pkg/db"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWrongCurrentPassword = errors.New("current password is incorrect")
+	ErrSamePassword         = errors.New("new password must be different from the current one")
+)
+
+// CheckPassword returns ErrWrongCurrentPassword unless password is the user's current password
+func CheckPassword(userID, password string) error {
+	var currentHash string
+	err := db.DB.QueryRow("SELECT password_hash FROM users WHERE id = ?", userID).Scan(&currentHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(password)); err != nil {
+		return ErrWrongCurrentPassword
+	}
+	return nil
+}
+
+// ChangePassword replaces the user's password after checking the current one
+func ChangePassword(userID, oldPassword, newPassword string) error {
+	if err := CheckPassword(userID, oldPassword); err != nil {
+		return err
+	}
+
+	if valid, err := ValidatePassword(newPassword); !valid {
+		return err
+	}
+
+	if oldPassword == newPassword {
+		return ErrSamePassword
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(hashed), userID)
+	return err
+}
+
+// InvalidateOtherSessions logs the user out everywhere except the session holding keepToken
+func InvalidateOtherSessions(userID, keepToken string) error {
+	_, err := db.DB.Exec("DELETE FROM sessions WHERE user_id = ? AND token != ?", userID, keepToken)
+	return err
+}
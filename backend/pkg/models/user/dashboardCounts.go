@@ -0,0 +1,48 @@
+package user
+
+import (
+	"social-network/pkg/db"
+)
+
+// DashboardCounts holds the badge counters shown in the app shell
+type DashboardCounts struct {
+	UnreadNotifications   int `json:"unread_notifications"`
+	UnreadMessages        int `json:"unread_messages"`
+	PendingFollowRequests int `json:"pending_follow_requests"`
+	PendingGroupRequests  int `json:"pending_group_requests"`
+}
+
+// GetDashboardCounts computes all badge counters for the user in a single query.
+// Pending group requests only cover groups the user created or administers.
+func GetDashboardCounts(userID string) (*DashboardCounts, error) {
+	query := `
+        SELECT
+            (SELECT COUNT(*) FROM notifications
+                WHERE user_id = ? AND is_read = 0),
+            (SELECT COUNT(*) FROM messages m
+                JOIN chat_participants cp ON cp.chat_id = m.chat_id AND cp.user_id = ?
+                LEFT JOIN message_reads mr ON mr.message_id = m.id AND mr.user_id = ?
+                WHERE mr.message_id IS NULL AND m.sender_id != ?),
+            (SELECT COUNT(*) FROM follow_requests
+                WHERE recipient_id = ? AND status = 'pending'),
+            (SELECT COUNT(*) FROM group_requests gr
+                WHERE gr.status = 'pending' AND gr.group_id IN (
+                    SELECT group_id FROM group_memberships WHERE user_id = ? AND role = 'admin'
+                    UNION
+                    SELECT id FROM groups WHERE creator_id = ?
+                ))
+    `
+
+	var counts DashboardCounts
+	err := db.DB.QueryRow(query, userID, userID, userID, userID, userID, userID, userID).Scan(
+		&counts.UnreadNotifications,
+		&counts.UnreadMessages,
+		&counts.PendingFollowRequests,
+		&counts.PendingGroupRequests,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
@@ -0,0 +1,140 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"social-network/pkg/db"
+	"social-network/pkg/models/group"
+)
+
+// accountCleanupQueries remove the rows that belong to a user being deleted. Each takes the
+// user ID once per placeholder; anything not listed here goes with the users row through
+// ON DELETE CASCADE.
+var accountCleanupQueries = []struct {
+	what  string
+	query string
+	args  int
+}{
+	{"comments", "DELETE FROM comments WHERE author_id = ?", 1},
+	{"posts", "DELETE FROM posts WHERE author_id = ?", 1},
+	{"post likes", "DELETE FROM post_likes WHERE user_id = ?", 1},
+	{"comment likes", "DELETE FROM comment_likes WHERE user_id = ?", 1},
+	{"post reactions", "DELETE FROM post_reactions WHERE user_id = ?", 1},
+	{"followers", "DELETE FROM followers WHERE follower_id = ? OR followee_id = ?", 2},
+	{"follow requests", "DELETE FROM follow_requests WHERE requester_id = ? OR recipient_id = ?", 2},
+	{"blocks", "DELETE FROM blocked_users WHERE blocker_id = ? OR blocked_id = ?", 2},
+	{"group memberships", "DELETE FROM group_memberships WHERE user_id = ?", 1},
+	{"group invitations", "DELETE FROM group_invitations WHERE inviter_id = ? OR invitee_id = ?", 2},
+	{"group requests", "DELETE FROM group_requests WHERE requester_id = ?", 1},
+	{"event responses", "DELETE FROM event_responses WHERE user_id = ?", 1},
+	{"chat participation", "DELETE FROM chat_participants WHERE user_id = ?", 1},
+	{"notifications", "DELETE FROM notifications WHERE user_id = ? OR sender_id = ?", 2},
+	{"sessions", "DELETE FROM sessions WHERE user_id = ?", 1},
+}
+
+// DeleteAccount permanently removes a user and their data. Groups they created are handed
+// to another member (admins first, then the longest-standing member) or deleted when
+// nobody else is in them, and their private chats are removed along with the messages.
+func DeleteAccount(userID string) error {
+	var avatarPath string
+	err := db.DB.QueryRow("SELECT COALESCE(avatar_path, '') FROM users WHERE id = ?", userID).Scan(&avatarPath)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := releaseOwnedGroupsTx(tx, userID); err != nil {
+		return err
+	}
+
+	// A private chat is meaningless with one side gone
+	_, err = tx.Exec(`
+        DELETE FROM chat_threads
+        WHERE is_group = 0 AND id IN (SELECT chat_id FROM chat_participants WHERE user_id = ?)
+    `, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete private chats: %v", err)
+	}
+
+	for _, q := range accountCleanupQueries {
+		args := make([]interface{}, q.args)
+		for i := range args {
+			args[i] = userID
+		}
+		if _, err := tx.Exec(q.query, args...); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", q.what, err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if avatarPath != "" {
+		removeAvatarFile(avatarPath)
+	}
+
+	return nil
+}
+
+// releaseOwnedGroupsTx transfers or deletes every group the user created
+func releaseOwnedGroupsTx(tx *sql.Tx, userID string) error {
+	rows, err := tx.Query("SELECT id FROM groups WHERE creator_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to get owned groups: %v", err)
+	}
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			rows.Close()
+			return err
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, groupID := range groupIDs {
+		var successorID string
+		err := tx.QueryRow(`
+            SELECT user_id FROM group_memberships
+            WHERE group_id = ? AND user_id != ?
+            ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, joined_at ASC
+            LIMIT 1
+        `, groupID, userID).Scan(&successorID)
+		if err == sql.ErrNoRows {
+			if err := group.DeleteGroupTx(tx, groupID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find new group creator: %v", err)
+		}
+
+		if _, err := tx.Exec("UPDATE groups SET creator_id = ? WHERE id = ?", successorID, groupID); err != nil {
+			return fmt.Errorf("failed to transfer group: %v", err)
+		}
+		_, err = tx.Exec("UPDATE group_memberships SET role = 'admin' WHERE group_id = ? AND user_id = ?", groupID, successorID)
+		if err != nil {
+			return fmt.Errorf("failed to grant admin role to new creator: %v", err)
+		}
+	}
+
+	return nil
+}
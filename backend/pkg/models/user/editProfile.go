@@ -1,26 +1,105 @@
 package user
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"social-network/pkg/db"
 	"social-network/pkg/models/follow"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrPasswordNotEditable is returned for profile edits that try to change the password.
+// Passwords are only changed by ChangePassword, which also signs out the other sessions.
+var ErrPasswordNotEditable = errors.New("password can't be changed here, use /api/change-password")
+
 type EditProfileRequest struct {
-	FirstName          *string `json:"first_name,omitempty"`
-	LastName           *string `json:"last_name,omitempty"`
-	Nickname           *string `json:"nickname,omitempty"`
-	Email              *string `json:"email,omitempty"`
-	IsPublic           *bool   `json:"is_public,omitempty"`
-	AboutMe            *string `json:"about_me,omitempty"`
-	AvatarPath         *string `json:"avatar_path,omitempty"` // Changed from Avatar to AvatarPath
-	DOB                *string `json:"dob,omitempty"`
-	OldPassword        *string `json:"old_password,omitempty"`
-	NewPassword        *string `json:"new_password,omitempty"`
-	ConfirmNewPassword *string `json:"confirm_new_password,omitempty"`
+	FirstName    *string `json:"first_name,omitempty"`
+	LastName     *string `json:"last_name,omitempty"`
+	Nickname     *string `json:"nickname,omitempty"`
+	Email        *string `json:"email,omitempty"`
+	IsPublic     *bool   `json:"is_public,omitempty"`
+	AboutMe      *string `json:"about_me,omitempty"`
+	AvatarPath   *string `json:"avatar_path,omitempty"`   // Changed from Avatar to AvatarPath
+	RemoveAvatar bool    `json:"remove_avatar,omitempty"` // clears the avatar and deletes the file
+	DOB          *string `json:"dob,omitempty"`
+}
+
+// patchableProfileFields lists the fields a PATCH request may change
+var patchableProfileFields = map[string]bool{
+	"first_name":    true,
+	"last_name":     true,
+	"nickname":      true,
+	"email":         true,
+	"is_public":     true,
+	"about_me":      true,
+	"avatar_path":   true,
+	"remove_avatar": true,
+	"dob":           true,
+}
+
+// passwordFields are the fields of the old password change form, now refused
+var passwordFields = map[string]bool{
+	"old_password":         true,
+	"new_password":         true,
+	"confirm_new_password": true,
+}
+
+// ParsePatchProfileRequest builds an EditProfileRequest holding only the fields present in a PATCH body,
+// so fields the client did not send are left untouched
+func ParsePatchProfileRequest(fields map[string]json.RawMessage) (*EditProfileRequest, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields provided to update")
+	}
+
+	for name, value := range fields {
+		if passwordFields[name] {
+			return nil, ErrPasswordNotEditable
+		}
+		if !patchableProfileFields[name] {
+			return nil, fmt.Errorf("unknown or read-only field: %s", name)
+		}
+		if string(value) == "null" {
+			return nil, fmt.Errorf("field %s cannot be null", name)
+		}
+	}
+
+	return ParseEditProfileRequest(fields)
+}
+
+// ParseEditProfileRequest builds an EditProfileRequest from a PUT body. Unknown fields are
+// ignored, but password fields are refused so a client can't believe it changed the password.
+func ParseEditProfileRequest(fields map[string]json.RawMessage) (*EditProfileRequest, error) {
+	for name := range fields {
+		if passwordFields[name] {
+			return nil, ErrPasswordNotEditable
+		}
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var req EditProfileRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid field value: %v", err)
+	}
+
+	return &req, nil
+}
+
+// EmailTakenByOther reports whether an account other than userID already uses email, in any case
+func EmailTakenByOther(email, userID string) (bool, error) {
+	var count int
+	err := db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? COLLATE NOCASE AND id != ?", email, userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check email: %v", err)
+	}
+	return count > 0, nil
 }
 
 func UpdateUserProfile(userID string, req *EditProfileRequest, followService *follow.FollowService) error {
@@ -50,6 +129,19 @@ func UpdateUserProfile(userID string, req *EditProfileRequest, followService *fo
 		args = append(args, *req.AvatarPath) // Changed from *req.Avatar to *req.AvatarPath
 	}
 
+	// Removing the avatar reverts to the default one; remember the old file so it can be deleted
+	var oldAvatar string
+	if req.RemoveAvatar {
+		if req.AvatarPath != nil {
+			return fmt.Errorf("cannot set and remove the avatar at the same time")
+		}
+		err := db.DB.QueryRow("SELECT COALESCE(avatar_path, '') FROM users WHERE id = ?", userID).Scan(&oldAvatar)
+		if err != nil {
+			return fmt.Errorf("failed to get current avatar: %v", err)
+		}
+		setParts = append(setParts, "avatar_path = ''")
+	}
+
 	if req.IsPublic != nil {
 		setParts = append(setParts, "is_public = ?")
 		var publicValue int
@@ -68,6 +160,8 @@ func UpdateUserProfile(userID string, req *EditProfileRequest, followService *fo
 	}
 
 	if req.Email != nil {
+		email := NormalizeEmail(*req.Email)
+		req.Email = &email
 		// Validate email
 		if valid, err := ValidateEmail(*req.Email); !valid {
 			return fmt.Errorf("invalid email: %v", err)
@@ -76,46 +170,11 @@ func UpdateUserProfile(userID string, req *EditProfileRequest, followService *fo
 		args = append(args, *req.Email)
 	}
 
-	if req.NewPassword != nil {
-		// Validate password
-		if valid, err := ValidatePassword(*req.NewPassword); !valid {
-			return fmt.Errorf("invalid password: %v", err)
-		}
-		// Hash the password before saving!
-		hashed, err := bcrypt.GenerateFromPassword([]byte(*req.NewPassword), bcrypt.DefaultCost)
-		if err != nil {
-			return fmt.Errorf("failed to hash password: %v", err)
-		}
-		setParts = append(setParts, "password = ?")
-		args = append(args, string(hashed))
-	}
-
 	if req.DOB != nil {
-		setParts = append(setParts, "dob = ?")
+		setParts = append(setParts, "date_of_birth = ?")
 		args = append(args, *req.DOB)
 	}
 
-	// Password change logic
-	if req.OldPassword != nil && req.NewPassword != nil && req.ConfirmNewPassword != nil {
-		// Fetch current password hash
-		var currentHash string
-		err := db.DB.QueryRow("SELECT password FROM users WHERE id = ?", userID).Scan(&currentHash)
-		if err != nil {
-			return fmt.Errorf("failed to verify old password: %v", err)
-		}
-		// Check old password
-		if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(*req.OldPassword)); err != nil {
-			return fmt.Errorf("old password is incorrect")
-		}
-		// Hash new password
-		hashed, err := bcrypt.GenerateFromPassword([]byte(*req.NewPassword), bcrypt.DefaultCost)
-		if err != nil {
-			return fmt.Errorf("failed to hash new password: %v", err)
-		}
-		setParts = append(setParts, "password = ?")
-		args = append(args, string(hashed))
-	}
-
 	// If no fields to update, return
 	if len(setParts) == 0 {
 		return fmt.Errorf("no fields provided to update")
@@ -129,6 +188,13 @@ func UpdateUserProfile(userID string, req *EditProfileRequest, followService *fo
 
 	result, err := db.DB.Exec(query, args...)
 	if err != nil {
+		// The handlers check both first, this only catches a concurrent update
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: users.email") {
+			return takenError("email", ErrEmailAlreadyExists)
+		}
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: users.nickname") {
+			return takenError("nickname", ErrNicknameAlreadyExists)
+		}
 		return err
 	}
 
@@ -142,6 +208,10 @@ func UpdateUserProfile(userID string, req *EditProfileRequest, followService *fo
 		return fmt.Errorf("user not found or no changes made")
 	}
 
+	if oldAvatar != "" {
+		removeAvatarFile(oldAvatar)
+	}
+
 	if changingToPublic {
 		if err := AcceptAllPendingFollowRequests(userID, followService); err != nil {
 			return fmt.Errorf("profile updated but failed to accept follow requests: %v", err)
@@ -151,6 +221,49 @@ func UpdateUserProfile(userID string, req *EditProfileRequest, followService *fo
 	return nil
 }
 
+// SetProfileVisibility makes the user's profile public or private.
+// Switching to private keeps existing followers; only new followers need approval from then on.
+// Switching to public accepts any pending follow requests, since a public profile has none.
+func SetProfileVisibility(userID string, isPublic bool, followService *follow.FollowService) error {
+	publicValue := 0
+	if isPublic {
+		publicValue = 1
+	}
+
+	result, err := db.DB.Exec("UPDATE users SET is_public = ? WHERE id = ?", publicValue, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	if isPublic {
+		if err := AcceptAllPendingFollowRequests(userID, followService); err != nil {
+			return fmt.Errorf("profile updated but failed to accept follow requests: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// removeAvatarFile deletes an uploaded avatar from disk; failures are only logged
+func removeAvatarFile(avatarPath string) {
+	if !strings.HasPrefix(avatarPath, "/uploads/media/") {
+		return
+	}
+
+	filePath := filepath.Join(".", "uploads", "media", filepath.Base(avatarPath))
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to delete avatar file %s: %v", filePath, err)
+	}
+}
+
 func AcceptAllPendingFollowRequests(userID string, followService *follow.FollowService) error {
 	tx, err := db.DB.Begin()
 	if err != nil {
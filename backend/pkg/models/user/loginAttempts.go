@@ -0,0 +1,142 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"social-network/pkg/db"
+	"strings"
+	"time"
+)
+
+// Failed logins are tracked in the login_attempts table, so lockouts survive a restart and
+// hold across instances sharing the database. An account is locked out after
+// MaxLoginFailures consecutive failures, whether they used its email or its nickname, an
+// unknown identifier likewise, and a client IP after MaxIPLoginFailures,
+// which covers credential stuffing across many accounts. Failures further apart than
+// LoginLockout are not consecutive.
+const (
+	MaxLoginFailures   = 5
+	MaxIPLoginFailures = 20
+	LoginLockout       = 15 * time.Minute
+)
+
+// LoginLockedError is returned by Login while the identifier or the client IP is locked out
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %d seconds", int(e.RetryAfter.Seconds()))
+}
+
+// identifierAttemptKey returns the key counting failures for the identifier: the account
+// it names, so its email and nickname share one count, or the identifier itself when no
+// account has it
+func identifierAttemptKey(identifier string) (string, error) {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+
+	// Emails contain '@' and nicknames can't, so at most one account matches
+	var userID string
+	err := db.DB.QueryRow(
+		"SELECT id FROM users WHERE email = ? COLLATE NOCASE OR nickname = ? COLLATE NOCASE",
+		identifier, identifier,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "identifier:" + identifier, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "user:" + userID, nil
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginLockedFor returns how long logins with the identifier, or from the IP, stay locked out
+func loginLockedFor(identifier, ip string, now time.Time) (time.Duration, error) {
+	identifierKey, err := identifierAttemptKey(identifier)
+	if err != nil {
+		return 0, err
+	}
+
+	var lockedUntil *string
+	err = db.DB.QueryRow(
+		"SELECT MAX(locked_until) FROM login_attempts WHERE attempt_key IN (?, ?) AND locked_until > ?",
+		identifierKey, ipAttemptKey(ip), formatAttemptTime(now),
+	).Scan(&lockedUntil)
+	if err != nil || lockedUntil == nil {
+		return 0, err
+	}
+
+	until, err := time.Parse(time.RFC3339, *lockedUntil)
+	if err != nil {
+		return 0, err
+	}
+	return until.Sub(now), nil
+}
+
+// recordLoginFailure counts a failed login for the identifier and the IP and locks out
+// whichever reached its limit
+func recordLoginFailure(identifier, ip string, now time.Time) error {
+	// Forget keys whose failures and lockout are both over
+	cutoff := formatAttemptTime(now.Add(-LoginLockout))
+	_, err := db.DB.Exec(
+		"DELETE FROM login_attempts WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+		cutoff, formatAttemptTime(now),
+	)
+	if err != nil {
+		return err
+	}
+
+	identifierKey, err := identifierAttemptKey(identifier)
+	if err != nil {
+		return err
+	}
+
+	limits := []struct {
+		key         string
+		maxFailures int
+	}{
+		{identifierKey, MaxLoginFailures},
+		{ipAttemptKey(ip), MaxIPLoginFailures},
+	}
+	for _, limit := range limits {
+		_, err := db.DB.Exec(`
+            INSERT INTO login_attempts (attempt_key, failures, last_failure_at) VALUES (?, 1, ?)
+            ON CONFLICT(attempt_key) DO UPDATE SET
+                failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+                last_failure_at = excluded.last_failure_at
+        `, limit.key, formatAttemptTime(now), cutoff)
+		if err != nil {
+			return err
+		}
+
+		// The count starts over once the lockout is set
+		_, err = db.DB.Exec(
+			"UPDATE login_attempts SET failures = 0, locked_until = ? WHERE attempt_key = ? AND failures >= ?",
+			formatAttemptTime(now.Add(LoginLockout)), limit.key, limit.maxFailures,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetLoginFailures clears the identifier's failures after a successful login. The IP's
+// failures are kept, or an attacker could reset them by logging into their own account.
+func resetLoginFailures(identifier string) error {
+	identifierKey, err := identifierAttemptKey(identifier)
+	if err != nil {
+		return err
+	}
+	_, err = db.DB.Exec("DELETE FROM login_attempts WHERE attempt_key = ?", identifierKey)
+	return err
+}
+
+// formatAttemptTime formats the login_attempts times: RFC3339 in UTC, so they compare as strings
+func formatAttemptTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"regexp"
 	"social-network/pkg/db"
+	"social-network/pkg/utils"
 	"strings"
 	"time"
 
@@ -79,6 +80,11 @@ func ValidateEmail(email string) (bool, error) {
 	return true, nil
 }
 
+// NormalizeEmail trims and lowercases an email, so the same address always maps to one account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // ValidatePassword checks if the password is strong enough
 func ValidatePassword(password string) (bool, error) {
 	// checking password lengeth
@@ -122,7 +128,7 @@ func generateNickname() (string, error) {
 		nicnkname := fmt.Sprintf("user%d", randNum)
 
 		// check if it exists or not
-		query := `SELECT COUNT(*) FROM users WHERE nickname = ?`
+		query := `SELECT COUNT(*) FROM users WHERE nickname = ? COLLATE NOCASE`
 		var count int
 		err := db.DB.QueryRow(query, nicnkname).Scan(&count)
 		if err != nil {
@@ -158,7 +164,7 @@ func ValidateNickname(nickname string) (bool, error) {
 	}
 
 	// Check if the nickname already exists in the database
-	query := `SELECT COUNT(*) FROM users WHERE nickname = ?`
+	query := `SELECT COUNT(*) FROM users WHERE nickname = ? COLLATE NOCASE`
 
 	var count int
 	err := db.DB.QueryRow(query, nickname).Scan(&count)
@@ -175,27 +181,37 @@ func ValidateNickname(nickname string) (bool, error) {
 
 // ValidateName checks if the first and last names are valid
 func ValidateName(firstName, LastName string) (bool, error) {
-	if len(firstName) < 3 {
-		return false, ErrFirstNameTooShort
+	if valid, err := ValidateFirstName(firstName); !valid {
+		return false, err
 	}
-	if len(LastName) < 3 {
-		return false, ErrLastNameTooShort
+	if valid, err := ValidateLastName(LastName); !valid {
+		return false, err
 	}
 
-	if len(firstName) > 15 {
-		return false, ErrFirstNameTooLong
+	return true, nil
+}
+
+// ValidateFirstName checks the first name on its own
+func ValidateFirstName(firstName string) (bool, error) {
+	return validateNamePart(firstName, ErrFirstNameTooShort, ErrFirstNameTooLong, ErrFirstNameFormat)
+}
+
+// ValidateLastName checks the last name on its own
+func ValidateLastName(lastName string) (bool, error) {
+	return validateNamePart(lastName, ErrLastNameTooShort, ErrLastNameTooLong, ErrLastNameFormat)
+}
+
+func validateNamePart(name string, errTooShort, errTooLong, errFormat error) (bool, error) {
+	if len(name) < 3 {
+		return false, errTooShort
 	}
-	if len(LastName) > 15 {
-		return false, ErrLastNameTooLong
+	if len(name) > 15 {
+		return false, errTooLong
 	}
 
-	firstNameRegex := regexp.MustCompile(`^[a-zA-Z]+$`)
-	if !firstNameRegex.MatchString(firstName) {
-		return false, ErrFirstNameFormat
-	}
-	lastNameRegex := regexp.MustCompile(`^[a-zA-Z]+$`)
-	if !lastNameRegex.MatchString(LastName) {
-		return false, ErrLastNameFormat
+	nameRegex := regexp.MustCompile(`^[a-zA-Z]+$`)
+	if !nameRegex.MatchString(name) {
+		return false, errFormat
 	}
 
 	return true, nil
@@ -248,32 +264,88 @@ func ValidateAboutMe(aboutME string) (bool, error) {
 	return true, nil
 }
 
-// Register creates a new user account
-func Register(req RegisterRequest) (*User, error) {
-	// Validate the email
-	if valid, err := ValidateEmail(req.Email); !valid {
-		return nil, err
-	}
+// validationCodes maps each validation sentinel error to the code reported for its field
+var validationCodes = map[error]string{
+	ErrInvalidEmail:          utils.CodeInvalid,
+	ErrEmailAlreadyExists:    utils.CodeTaken,
+	ErrEmailTooShort:         utils.CodeTooShort,
+	ErrEmailTooLong:          utils.CodeTooLong,
+	ErrPasswordTooShort:      utils.CodeTooShort,
+	ErrPasswordTooLong:       utils.CodeTooLong,
+	ErrPasswordNoUpper:       utils.CodeInvalid,
+	ErrPasswordNoLower:       utils.CodeInvalid,
+	ErrPasswordNoDigit:       utils.CodeInvalid,
+	ErrNicknameAlreadyExists: utils.CodeTaken,
+	ErrNicknameTooShort:      utils.CodeTooShort,
+	ErrNicknameTooLong:       utils.CodeTooLong,
+	ErrNicknameFormat:        utils.CodeInvalid,
+	ErrFirstNameTooShort:     utils.CodeTooShort,
+	ErrFirstNameTooLong:      utils.CodeTooLong,
+	ErrFirstNameFormat:       utils.CodeInvalid,
+	ErrLastNameTooShort:      utils.CodeTooShort,
+	ErrLastNameTooLong:       utils.CodeTooLong,
+	ErrLastNameFormat:        utils.CodeInvalid,
+	ErrInvalidDOB:            utils.CodeInvalid,
+	ErrDOBInFuture:           utils.CodeInvalid,
+	ErrDOBTooOld:             utils.CodeInvalid,
+	ErrDOBTooYoung:           utils.CodeInvalid,
+	ErrAboutMeTooLong:        utils.CodeTooLong,
+}
 
-	// Validate password
-	if valid, err := ValidatePassword(req.Password); !valid {
-		return nil, err
+// AddValidationError records err as the failure for field. Errors that are not validation
+// failures (e.g. a database error from the nickname check) are returned so they are not hidden.
+func AddValidationError(errs *utils.ValidationErrors, field string, err error) error {
+	code, ok := validationCodes[err]
+	if !ok {
+		return err
 	}
+	errs.AddErr(field, code, err)
+	return nil
+}
 
-	// Validate first and last names
-	if valid, err := ValidateName(req.FirstName, req.LastName); !valid {
-		return nil, err
-	}
+type fieldCheck struct {
+	field    string
+	validate func() (bool, error)
+}
 
-	// validate date of birth
-	if valid, err := ValidateDOB(req.DOB); !valid {
-		return nil, err
+// ValidateRegisterRequest checks every field of a sign up request and reports all failures
+// together in utils.ValidationErrors
+func ValidateRegisterRequest(req RegisterRequest) error {
+	var errs utils.ValidationErrors
+
+	checks := []fieldCheck{
+		{"email", func() (bool, error) { return ValidateEmail(req.Email) }},
+		{"password", func() (bool, error) { return ValidatePassword(req.Password) }},
+		{"firstName", func() (bool, error) { return ValidateFirstName(req.FirstName) }},
+		{"lastName", func() (bool, error) { return ValidateLastName(req.LastName) }},
+		{"dob", func() (bool, error) { return ValidateDOB(req.DOB) }},
+		{"aboutMe", func() (bool, error) { return ValidateAboutMe(req.AboutMe) }},
+	}
+	// An empty nickname is generated later
+	if req.Nickname != "" {
+		checks = append(checks, fieldCheck{"nickname", func() (bool, error) { return ValidateNickname(req.Nickname) }})
+	}
+
+	for _, check := range checks {
+		if valid, err := check.validate(); !valid {
+			if err := AddValidationError(&errs, check.field, err); err != nil {
+				return err
+			}
+		}
 	}
 
-	// Validate about me
-	if valid, err := ValidateAboutMe(req.AboutMe); !valid {
+	return errs.Err()
+}
+
+// Register creates a new user account
+func Register(req RegisterRequest) (*User, error) {
+	req.AboutMe = strings.TrimSpace(req.AboutMe)
+	req.Email = NormalizeEmail(req.Email)
+
+	if err := ValidateRegisterRequest(req); err != nil {
 		return nil, err
 	}
+	req.AboutMe = utils.SanitizeText(req.AboutMe)
 
 	// check if name is empty and generate one
 	if req.Nickname == "" {
@@ -282,17 +354,12 @@ func Register(req RegisterRequest) (*User, error) {
 			return nil, err
 		}
 		req.Nickname = generateNickname
-	} else {
-		// Validate nickname
-		if valid, err := ValidateNickname(req.Nickname); !valid {
-			return nil, err
-		}
 	}
 
 	// Check if the email already exists
 	_, err := GetUserByEmail(req.Email)
 	if err == nil {
-		return nil, ErrEmailAlreadyExists
+		return nil, takenError("email", ErrEmailAlreadyExists)
 	} else if err != ErrUserNotFound {
 		return nil, err
 	}
@@ -321,10 +388,10 @@ func Register(req RegisterRequest) (*User, error) {
 		// Check for specific database constraint errors
 		// also handle the case of race conditions
 		if strings.Contains(err.Error(), "UNIQUE constraint failed: users.email") {
-			return nil, ErrEmailAlreadyExists
+			return nil, takenError("email", ErrEmailAlreadyExists)
 		}
 		if strings.Contains(err.Error(), "UNIQUE constraint failed: users.nickname") {
-			return nil, ErrNicknameAlreadyExists
+			return nil, takenError("nickname", ErrNicknameAlreadyExists)
 		}
 		// Other database errors
 		return nil, err
@@ -334,3 +401,8 @@ func Register(req RegisterRequest) (*User, error) {
 
 	return &user, nil
 }
+
+// takenError reports a uniqueness conflict on field as a validation error
+func takenError(field string, err error) error {
+	return utils.ValidationErrors{{Field: field, Code: utils.CodeTaken, Message: err.Error(), Err: err}}
+}
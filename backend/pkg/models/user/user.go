@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"social-network/pkg/db"
+	"social-network/pkg/utils"
 
 	"github.com/google/uuid"
 )
@@ -62,19 +63,20 @@ func CreateUser(user User) (string, error) {
 	return id, nil
 }
 
-// GetUserByEmail retrieves a user by their Email with follower counts
+// GetUserByEmail retrieves a user by their Email with follower counts. The email is
+// matched regardless of case.
 func GetUserByEmail(email string) (User, error) {
 	// First get the basic user data
 	query := `
         SELECT id, nickname, email, password_hash, first_name, last_name, 
-                about_me, avatar_path, is_public, created_at
+                COALESCE(about_me, ''), COALESCE(avatar_path, ''), is_public, created_at
         FROM users 
-        WHERE email = ?
+        WHERE email = ? COLLATE NOCASE
     `
 
 	var user User
 	var isPublicInt int
-	err := db.DB.QueryRow(query, email).Scan(
+	err := db.DB.QueryRow(query, NormalizeEmail(email)).Scan(
 		&user.ID,
 		&user.Nickname,
 		&user.Email,
@@ -118,10 +120,10 @@ func GetUserByEmail(email string) (User, error) {
 func GetUserByNickname(nickname string) (User, error) {
 	// First get the basic user data
 	query := `
-        SELECT id, email, password_hash, first_name, last_name, date_of_birth,
-                nickname, about_me, avatar_path, is_public, created_at
+        SELECT id, email, password_hash, first_name, last_name, COALESCE(date_of_birth, ''),
+                nickname, COALESCE(about_me, ''), COALESCE(avatar_path, ''), is_public, created_at
         FROM users 
-        WHERE nickname = ?
+        WHERE nickname = ? COLLATE NOCASE
     `
 
 	var user User
@@ -168,11 +170,11 @@ func GetUserByNickname(nickname string) (User, error) {
 	return user, nil
 }
 
-// GetUserByID retrieves a user by their ID with follower counts
+// GetUserByID retrieves a user by their ID with follower counts, as seen by currentUserID
 func GetUserByID(id string, currentUserID string) (User, error) {
 	query := `
-        SELECT id, email, first_name, last_name, date_of_birth,
-                nickname, about_me, avatar_path, is_public, created_at
+        SELECT id, email, first_name, last_name, COALESCE(date_of_birth, ''),
+                nickname, COALESCE(about_me, ''), COALESCE(avatar_path, ''), is_public, created_at
         FROM users 
         WHERE id = ?
     `
@@ -216,7 +218,7 @@ func GetUserByID(id string, currentUserID string) (User, error) {
 	}
 
 	// Get posts count
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE author_id = ?", user.ID).Scan(&user.PostsCount)
+	err = db.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE author_id = ? AND deleted_at IS NULL", user.ID).Scan(&user.PostsCount)
 	if err != nil {
 		log.Printf("Error getting posts count: %v", err)
 		user.PostsCount = 0
@@ -237,9 +239,44 @@ func GetUserByID(id string, currentUserID string) (User, error) {
 		user.IsFollowing = false
 	}
 
+	// Contact details are only for the owner; private profiles also hide
+	// their personal fields from anyone who doesn't follow them
+	if currentUserID != user.ID {
+		user.Email = ""
+	}
+	if !user.CanBeViewedFullyBy(currentUserID) {
+		user.RedactPrivateProfile()
+	}
+
 	return user, nil
 }
 
+// CanBeViewedFullyBy reports whether the viewer can see the full profile:
+// the owner, anyone for public profiles, and followers for private ones
+func (u *User) CanBeViewedFullyBy(viewerID string) bool {
+	return u.IsPublic || u.ID == viewerID || u.IsFollowed
+}
+
+// RedactPrivateProfile clears the fields that are hidden on a private profile
+func (u *User) RedactPrivateProfile() {
+	u.Email = ""
+	u.DOB = ""
+	u.AboutMe = ""
+}
+
+// CountSearchUsers returns how many users SearchUsers can return for the query in total
+func CountSearchUsers(db *sql.DB, query, currentUserID string) (int, error) {
+	searchPattern := "%" + query + "%"
+	var total int
+	err := db.QueryRow(`
+        SELECT COUNT(*)
+        FROM users
+        WHERE (nickname LIKE ? OR first_name LIKE ? OR last_name LIKE ?)
+        AND id != ?
+    `, searchPattern, searchPattern, searchPattern, currentUserID).Scan(&total)
+	return total, err
+}
+
 // SearchUsers searches for users by nickname, first name, or last name
 func SearchUsers(db *sql.DB, query, currentUserID string, limit, offset int) ([]map[string]interface{}, error) {
 	searchPattern := "%" + query + "%"
@@ -276,11 +313,12 @@ func SearchUsers(db *sql.DB, query, currentUserID string, limit, offset int) ([]
 		}
 
 		users = append(users, map[string]interface{}{
-			"id":         id,
-			"nickname":   nickname,
-			"first_name": firstName,
-			"last_name":  lastName,
-			"avatar":     avatar,
+			"id":           id,
+			"nickname":     nickname,
+			"first_name":   firstName,
+			"last_name":    lastName,
+			"avatar":       avatar,
+			"avatar_thumb": utils.AvatarThumb(avatar),
 		})
 	}
 
@@ -0,0 +1,270 @@
+package user_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"social-network/pkg/db"
+	"social-network/pkg/db/sqlite"
+	"social-network/pkg/models/user"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupUsersDB creates a database with a public user "owner", a private user "private"
+// and a "stranger", and points db.DB at it
+func setupUsersDB(t *testing.T) {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../../db/migrations/sqlite"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	conn, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+
+	users := []struct {
+		id       string
+		isPublic int
+	}{{"owner", 1}, {"private", 0}, {"stranger", 1}}
+	for _, u := range users {
+		_, err = conn.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, about_me, avatar_path, is_public)
+			VALUES (?, ? || '@example.com', 'x', 'First', 'Last', '2000-01-01', ?, 'about', '', ?)`, u.id, u.id, u.id, u.isPublic)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+}
+
+func TestGetUserByIDHidesEmailFromOtherUsers(t *testing.T) {
+	setupUsersDB(t)
+
+	u, err := user.GetUserByID("owner", "stranger")
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if u.Email != "" {
+		t.Errorf("Expected empty email for a non-owner, got %q", u.Email)
+	}
+	if u.AboutMe != "about" {
+		t.Errorf("Expected public profile fields to stay visible, got about_me %q", u.AboutMe)
+	}
+}
+
+func TestGetUserByIDShowsEmailToOwner(t *testing.T) {
+	setupUsersDB(t)
+
+	u, err := user.GetUserByID("owner", "owner")
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if u.Email != "owner@example.com" {
+		t.Errorf("Expected the owner to see their email, got %q", u.Email)
+	}
+}
+
+func TestGetUserByIDRedactsPrivateProfileForNonFollowers(t *testing.T) {
+	setupUsersDB(t)
+
+	u, err := user.GetUserByID("private", "stranger")
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if u.Email != "" || u.DOB != "" || u.AboutMe != "" {
+		t.Errorf("Expected private fields to be hidden, got email %q, dob %q, about_me %q", u.Email, u.DOB, u.AboutMe)
+	}
+
+	if _, err := db.DB.Exec("INSERT INTO followers (follower_id, followee_id) VALUES ('stranger', 'private')"); err != nil {
+		t.Fatalf("Failed to follow: %v", err)
+	}
+
+	u, err = user.GetUserByID("private", "stranger")
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if u.Email != "" {
+		t.Errorf("Expected email to stay hidden from a follower, got %q", u.Email)
+	}
+	if u.AboutMe != "about" {
+		t.Errorf("Expected a follower to see the private profile, got about_me %q", u.AboutMe)
+	}
+}
+
+// setPassword gives the user a real password hash so Login can succeed
+func setPassword(t *testing.T, userID, password string) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if _, err := db.DB.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(hash), userID); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+}
+
+func login(identifier, password string) error {
+	_, _, err := user.Login(user.LoginRequest{Identifier: identifier, Password: password, IPAddress: "192.0.2.1"})
+	return err
+}
+
+func TestLoginLockedOutAfterRepeatedFailures(t *testing.T) {
+	setupUsersDB(t)
+	setPassword(t, "owner", "Correct123")
+
+	for i := 0; i < user.MaxLoginFailures; i++ {
+		if err := login("owner@example.com", "Wrong1234"); !errors.Is(err, user.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i+1, err)
+		}
+	}
+
+	// Even the right password is refused during the lockout, in any letter case
+	err := login("Owner@Example.com", "Correct123")
+	var locked *user.LoginLockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected a lockout, got %v", err)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > user.LoginLockout {
+		t.Errorf("expected a retry after within the lockout, got %v", locked.RetryAfter)
+	}
+
+	// Other accounts can still log in from the same client
+	setPassword(t, "stranger", "Correct123")
+	if err := login("stranger", "Correct123"); err != nil {
+		t.Errorf("expected another account to log in, got %v", err)
+	}
+}
+
+func TestLoginLockoutCountsEmailAndNicknameTogether(t *testing.T) {
+	setupUsersDB(t)
+	setPassword(t, "owner", "Correct123")
+
+	// Switching between the email and the nickname doesn't earn extra attempts
+	for i := 0; i < user.MaxLoginFailures; i++ {
+		identifier := "owner"
+		if i%2 == 0 {
+			identifier = "owner@example.com"
+		}
+		if err := login(identifier, "Wrong1234"); !errors.Is(err, user.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i+1, err)
+		}
+	}
+
+	var locked *user.LoginLockedError
+	for _, identifier := range []string{"owner", "owner@example.com"} {
+		if err := login(identifier, "Correct123"); !errors.As(err, &locked) {
+			t.Errorf("login as %q: expected a lockout, got %v", identifier, err)
+		}
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	setupUsersDB(t)
+	setPassword(t, "owner", "Correct123")
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < user.MaxLoginFailures-1; i++ {
+			if err := login("owner", "Wrong1234"); !errors.Is(err, user.ErrInvalidCredentials) {
+				t.Fatalf("expected invalid credentials, got %v", err)
+			}
+		}
+		if err := login("owner", "Correct123"); err != nil {
+			t.Fatalf("round %d: expected the login to succeed, got %v", round+1, err)
+		}
+	}
+}
+
+func TestRegisterNormalizesEmail(t *testing.T) {
+	setupUsersDB(t)
+
+	registered, err := user.Register(user.RegisterRequest{
+		Email:     "  New.User@Example.COM ",
+		Password:  "Correct123",
+		FirstName: "New",
+		LastName:  "User",
+	})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if registered.Email != "new.user@example.com" {
+		t.Errorf("expected the email to be stored lowercased, got %q", registered.Email)
+	}
+
+	for _, identifier := range []string{"new.user@example.com", "NEW.USER@example.com", " New.User@Example.Com "} {
+		if err := login(identifier, "Correct123"); err != nil {
+			t.Errorf("login as %q: expected success, got %v", identifier, err)
+		}
+	}
+
+	found, err := user.GetUserByEmail("New.User@EXAMPLE.com")
+	if err != nil || found.ID != registered.ID {
+		t.Errorf("expected to find the user by a mixed-case email, got %v", err)
+	}
+}
+
+func TestRegisterRejectsEmailDifferingOnlyInCase(t *testing.T) {
+	setupUsersDB(t)
+
+	_, err := user.Register(user.RegisterRequest{
+		Email:     "Owner@Example.com",
+		Password:  "Correct123",
+		FirstName: "Other",
+		LastName:  "Owner",
+	})
+	if !errors.Is(err, user.ErrEmailAlreadyExists) {
+		t.Fatalf("expected email already exists, got %v", err)
+	}
+
+	// The index holds even when the check is bypassed
+	_, err = db.DB.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, nickname)
+		VALUES ('dup', 'OWNER@example.com', 'x', 'First', 'Last', 'dup')`)
+	if err == nil {
+		t.Error("expected the unique index to reject an email differing only in case")
+	}
+}
+
+func TestProfileEditRejectsEmailOfAnotherAccount(t *testing.T) {
+	setupUsersDB(t)
+
+	taken, err := user.EmailTakenByOther("OWNER@example.com", "stranger")
+	if err != nil || !taken {
+		t.Errorf("expected owner's email to be taken for stranger in any case, got %v, %v", taken, err)
+	}
+	taken, err = user.EmailTakenByOther("owner@example.com", "owner")
+	if err != nil || taken {
+		t.Errorf("expected owner's own email not to count as taken, got %v, %v", taken, err)
+	}
+
+	// A concurrent change that slips past the check is still reported as taken, not as a raw SQLite error
+	email := "owner@example.com"
+	err = user.UpdateUserProfile("stranger", &user.EditProfileRequest{Email: &email}, nil)
+	if !errors.Is(err, user.ErrEmailAlreadyExists) {
+		t.Errorf("expected email already exists, got %v", err)
+	}
+}
+
+func TestProfileEditsRefusePasswordChanges(t *testing.T) {
+	fields := map[string]json.RawMessage{
+		"first_name":   json.RawMessage(`"New"`),
+		"old_password": json.RawMessage(`"Correct123"`),
+		"new_password": json.RawMessage(`"Changed123"`),
+	}
+
+	if _, err := user.ParseEditProfileRequest(fields); !errors.Is(err, user.ErrPasswordNotEditable) {
+		t.Errorf("PUT: expected password changes to be refused, got %v", err)
+	}
+	if _, err := user.ParsePatchProfileRequest(fields); !errors.Is(err, user.ErrPasswordNotEditable) {
+		t.Errorf("PATCH: expected password changes to be refused, got %v", err)
+	}
+}
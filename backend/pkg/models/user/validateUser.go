@@ -6,6 +6,9 @@ import (
 	"regexp"
 	"social-network/pkg/auth"
 	"social-network/pkg/db"
+	"strings"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,6 +21,10 @@ var (
 type LoginRequest struct {
 	Identifier string `json:"identifier"` // can be email or nickname
 	Password   string `json:"password"`
+
+	// Filled in by the handler so the session can show where it was created
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
 // Login function validates the login request
@@ -36,16 +43,27 @@ func Login(request LoginRequest) (*User, string, error) {
 		return nil, "", ErrPasswordTooLong
 	}
 
+	// Refuse identifiers and clients with too many recent failures before checking anything
+	now := time.Now()
+	wait, err := loginLockedFor(request.Identifier, request.IPAddress, now)
+	if err != nil {
+		log.Printf("Error checking login attempts: %v", err)
+		return nil, "", err
+	}
+	if wait > 0 {
+		return nil, "", &LoginLockedError{RetryAfter: wait}
+	}
+
 	identifier := request.Identifier
 
 	// check if its email or nickname
 	var user User
-	var err error
 
 	// to know if the identifier is an email check if it contains '@'
 	emailRegex := regexp.MustCompile(`@`)
 	if emailRegex.MatchString(identifier) {
 		// If it contains '@', treat it as an email
+		identifier = NormalizeEmail(identifier)
 		if valid, err := ValidateEmail(identifier); !valid {
 			return nil, "", err
 		}
@@ -57,6 +75,9 @@ func Login(request LoginRequest) (*User, string, error) {
 			return nil, "", ErrNicknameTooShort
 		}
 
+		// Nicknames are matched case-insensitively
+		identifier = strings.ToLower(identifier)
+
 		// Format validation if needed (alphanumeric in lowercase)
 		nicknameRegex := regexp.MustCompile(`^[a-z0-9]+$`)
 		if !nicknameRegex.MatchString(identifier) {
@@ -72,7 +93,7 @@ func Login(request LoginRequest) (*User, string, error) {
 	if err != nil {
 		if err == ErrUserNotFound {
 			log.Printf("User not found: %v", err)
-			return nil, "", ErrInvalidCredentials
+			return nil, "", loginFailed(request, now)
 		}
 		log.Printf("Error retrieving user: %v", err)
 		return nil, "", err
@@ -82,17 +103,21 @@ func Login(request LoginRequest) (*User, string, error) {
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.Password))
 	if err != nil {
 		// again dont show that the password matched or not
-		return nil, "", ErrInvalidCredentials
+		return nil, "", loginFailed(request, now)
+	}
+
+	if err := resetLoginFailures(request.Identifier); err != nil {
+		log.Printf("Error resetting login attempts: %v", err)
 	}
 
-	// Cleanup old sessions for the user
-	if err := cleanupOldSessions(user.ID); err != nil {
-		log.Printf("Error cleaning up old sessions: %v", err)
+	// Cleanup expired sessions for the user; live ones on other devices stay signed in
+	if err := cleanupExpiredSessions(user.ID); err != nil {
+		log.Printf("Error cleaning up expired sessions: %v", err)
 		return nil, "", err
 	}
 
 	// Generate a session token
-	token, err := auth.GenerateToken(user.ID)
+	token, err := auth.GenerateToken(user.ID, request.UserAgent, request.IPAddress)
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		return nil, "", err
@@ -101,9 +126,16 @@ func Login(request LoginRequest) (*User, string, error) {
 	return &user, token, nil
 }
 
+// loginFailed records a failed login attempt and returns ErrInvalidCredentials
+func loginFailed(request LoginRequest, now time.Time) error {
+	if err := recordLoginFailure(request.Identifier, request.IPAddress, now); err != nil {
+		log.Printf("Error recording failed login: %v", err)
+	}
+	return ErrInvalidCredentials
+}
 
-func cleanupOldSessions(userID string) error {
-	query := `DELETE FROM sessions WHERE user_id = ?`
+func cleanupExpiredSessions(userID string) error {
+	query := `DELETE FROM sessions WHERE user_id = ? AND datetime(expires_at) < datetime('now')`
 	_, err := db.DB.Exec(query, userID)
 	return err
 }
\ No newline at end of file
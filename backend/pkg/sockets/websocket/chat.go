@@ -1,21 +1,30 @@
 package websocket
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"social-network/pkg/db"
+	"social-network/pkg/utils"
 	"strconv"
 	"time"
 )
 
 type ChatService struct {
 	DB *sql.DB
+	// EditWindow is how long after sending a message its sender may still edit it
+	EditWindow time.Duration
+
+	chatLists *chatListCache
 }
 
 func NewChatService(db *sql.DB) *ChatService {
 	return &ChatService{
-		DB: db,
+		DB:         db,
+		EditWindow: DefaultMessageEditWindow,
+		chatLists:  chatListCacheFor(db),
 	}
 }
 
@@ -61,6 +70,14 @@ func (c *Client) handleMessage(message []byte) {
 		c.handleChatListRequest(wsMsg.Data)
 	case TypeChatMessages: // New case
 		c.handleChatMessagesRequest(wsMsg.Data)
+	case TypeResync:
+		c.handleResyncRequest(wsMsg.Data)
+	case TypePresenceQuery:
+		c.handlePresenceQuery(wsMsg.Data)
+	case TypeEditMessage:
+		c.handleEditMessage(wsMsg.Data)
+	case TypeDeleteMessage:
+		c.handleDeleteMessage(wsMsg.Data)
 	case "join_group": // handle group sync from frontend
 		c.handleJoinGroup(wsMsg.Data)
 	case "leave_group":
@@ -69,6 +86,10 @@ func (c *Client) handleMessage(message []byte) {
 }
 
 func (c *Client) handleChatMessage(data interface{}) {
+	if !c.allowChatMessage() {
+		return
+	}
+
 	chatMsg, err := unmarshalData[ChatMessage](data)
 	if err != nil {
 		return
@@ -76,14 +97,33 @@ func (c *Client) handleChatMessage(data interface{}) {
 
 	chatMsg.Timestamp = time.Now()
 	chatMsg.SenderID = c.userID
+	chatMsg.Content = utils.SanitizeText(chatMsg.Content)
 	// DO NOT set chatMsg.ID here!
 
+	// The quoted preview is filled in by the server
+	chatMsg.ReplyTo = nil
+	if chatMsg.ReplyToID != nil && *chatMsg.ReplyToID == "" {
+		chatMsg.ReplyToID = nil
+	}
+
 	// Validate message type
 	if chatMsg.MessageType != "text" && chatMsg.MessageType != "emoji" &&
 		chatMsg.MessageType != "media" && chatMsg.MessageType != "gif" {
 		chatMsg.MessageType = "text"
 	}
 
+	// Direct messages between blocked users are rejected, group chats are unaffected
+	if chatMsg.RecipientID != "" {
+		blocked, err := c.hub.chatService.IsBlocked(c.userID, chatMsg.RecipientID)
+		if err != nil {
+			return
+		}
+		if blocked {
+			c.sendChatError("blocked_error", ErrUserBlocked.Error())
+			return
+		}
+	}
+
 	// Get sender info
 	var senderName, senderAvatar string
 	err = c.hub.chatService.DB.QueryRow(
@@ -99,15 +139,47 @@ func (c *Client) handleChatMessage(data interface{}) {
 	// Save to DB and get chat_id and real message ID
 	chatID, messageID, err := c.hub.chatService.SaveMessageAndGetIDs(chatMsg, chatMsg.GroupID)
 	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyAttachments), errors.Is(err, ErrInvalidAttachment), errors.Is(err, ErrAttachmentTooLarge):
+			c.sendChatError("attachment_error", err.Error())
+		case errors.Is(err, ErrInvalidReply), errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrMessageDeleted):
+			c.sendChatError("reply_error", err.Error())
+		}
 		return
 	}
 	chatMsg.ChatID = strconv.FormatInt(chatID, 10)
 	chatMsg.ID = strconv.FormatInt(messageID, 10) // Use the real DB ID
 
+	// Quote the replied message so clients can render the preview
+	if chatMsg.ReplyToID != nil {
+		previews, err := c.hub.chatService.getReplyPreviews([]string{*chatMsg.ReplyToID})
+		if err == nil {
+			chatMsg.ReplyTo = previews[*chatMsg.ReplyToID]
+		}
+	}
+
 	// Send to recipients
 	c.sendMessageToRecipients(chatMsg)
 }
 
+// sendChatError tells the sender why their chat message was rejected
+func (c *Client) sendChatError(errorType, message string) {
+	errorResponse := map[string]interface{}{
+		"error":   true,
+		"message": message,
+		"type":    errorType,
+	}
+
+	wsMessage := WSMessage{
+		Type:      TypeChat,
+		Data:      errorResponse,
+		Timestamp: time.Now(),
+	}
+
+	msgData, _ := json.Marshal(wsMessage)
+	c.hub.SendToUser(c.userID, msgData)
+}
+
 func (c *Client) handleTypingMessage(data interface{}) {
 	typingMsg, err := unmarshalData[TypingMessage](data)
 	if err != nil {
@@ -124,6 +196,7 @@ func (c *Client) handleMessagesRead(data interface{}) {
 	}
 
 	readMsg.UserID = c.userID
+	readMsg.ReadAt = time.Now().UTC()
 
 	if err := c.updateReadMessages(*readMsg); err != nil {
 		return
@@ -156,7 +229,7 @@ func (s *ChatService) SaveMessageAndGetChatID(msg *ChatMessage, groupID string)
 	_, err = tx.Exec(`
         INSERT INTO messages (chat_id, sender_id, content, message_type, created_at)
         VALUES (?, ?, ?, ?, ?)
-        `, chatID, msg.SenderID, msg.Content, msg.MessageType, msg.Timestamp.Format(time.RFC3339))
+        `, chatID, msg.SenderID, msg.Content, msg.MessageType, formatMessageTimestamp(msg.Timestamp))
 	if err != nil {
 		return 0, fmt.Errorf("failed to save message: %w", err)
 	}
@@ -164,11 +237,16 @@ func (s *ChatService) SaveMessageAndGetChatID(msg *ChatMessage, groupID string)
 	if err = tx.Commit(); err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	s.invalidateChat(strconv.FormatInt(chatID, 10))
 
 	return chatID, nil
 }
 
 func (s *ChatService) SaveMessageAndGetIDs(msg *ChatMessage, groupID string) (chatID int64, messageID int64, err error) {
+	if err := validateAttachments(msg.Attachments); err != nil {
+		return 0, 0, err
+	}
+
 	tx, err := s.DB.Begin()
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
@@ -184,10 +262,18 @@ func (s *ChatService) SaveMessageAndGetIDs(msg *ChatMessage, groupID string) (ch
 		return 0, 0, fmt.Errorf("failed to get or create chat thread: %w", err)
 	}
 
+	var replyToID sql.NullString
+	if msg.ReplyToID != nil {
+		if err = checkReplyTarget(tx, chatID, *msg.ReplyToID); err != nil {
+			return 0, 0, err
+		}
+		replyToID = sql.NullString{String: *msg.ReplyToID, Valid: true}
+	}
+
 	result, err := tx.Exec(`
-        INSERT INTO messages (chat_id, sender_id, content, message_type, created_at)
-        VALUES (?, ?, ?, ?, ?)`,
-		chatID, msg.SenderID, msg.Content, msg.MessageType, msg.Timestamp.Format(time.RFC3339))
+        INSERT INTO messages (chat_id, sender_id, content, message_type, created_at, reply_to_id)
+        VALUES (?, ?, ?, ?, ?, ?)`,
+		chatID, msg.SenderID, msg.Content, msg.MessageType, formatMessageTimestamp(msg.Timestamp), replyToID)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to save message: %w", err)
 	}
@@ -196,9 +282,14 @@ func (s *ChatService) SaveMessageAndGetIDs(msg *ChatMessage, groupID string) (ch
 		return 0, 0, fmt.Errorf("failed to get message ID: %w", err)
 	}
 
+	if err = saveAttachments(tx, messageID, msg.Attachments); err != nil {
+		return 0, 0, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	s.invalidateChat(strconv.FormatInt(chatID, 10))
 
 	return chatID, messageID, nil
 }
@@ -336,6 +427,7 @@ func (s *ChatService) AddUserToGroupChat(userID, groupID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to add user to group chat: %w", err)
 	}
+	s.invalidateChat(strconv.FormatInt(chatID, 10))
 
 	return nil
 }
@@ -360,11 +452,14 @@ func (s *ChatService) RemoveUserFromGroupChat(userID, groupID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to remove user from group chat: %w", err)
 	}
+	s.invalidateChat(strconv.FormatInt(chatID, 10))
+	s.chatLists.invalidate(userID)
 
 	return nil
 }
 
-// AddUserToGroupChatTx adds a user to a group's chat thread within a transaction
+// AddUserToGroupChatTx adds a user to a group's chat thread within a transaction.
+// The caller invalidates the chat lists with InvalidateGroupChat once it commits.
 func (s *ChatService) AddUserToGroupChatTx(tx *sql.Tx, userID, groupID string) error {
 	// Get the group's chat thread ID
 	var chatID int64
@@ -388,7 +483,8 @@ func (s *ChatService) AddUserToGroupChatTx(tx *sql.Tx, userID, groupID string) e
 	return nil
 }
 
-// RemoveUserFromGroupChatTx removes a user from a group's chat thread within a transaction
+// RemoveUserFromGroupChatTx removes a user from a group's chat thread within a transaction.
+// The caller invalidates the chat lists with InvalidateGroupChat and InvalidateUserChats once it commits.
 func (s *ChatService) RemoveUserFromGroupChatTx(tx *sql.Tx, userID, groupID string) error {
 	// Get the group's chat thread ID
 	var chatID int64
@@ -449,17 +545,27 @@ func (s *ChatService) SyncGroupChatParticipants(groupID string) error {
 		return fmt.Errorf("failed to sync chat participants: %w", err)
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// Members may have been dropped too, whose lists can't be found anymore
+	s.chatLists.invalidateAll()
+	return nil
 }
 
 func (s *ChatService) GetChatMessages(chatID string, limit int, offset int) ([]ChatMessage, error) {
+	recipientCount, err := s.groupRecipientCount(chatID)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT m.id, m.chat_id, m.sender_id, u.first_name || ' ' || u.last_name as sender_name,
-			COALESCE(u.avatar_path, '') as sender_avatar, m.content, m.message_type, m.created_at,
-			CASE WHEN mr.message_id IS NOT NULL THEN 1 ELSE 0 END as is_read
+			COALESCE(u.avatar_path, '') as sender_avatar, ` + messageContentColumn + `, m.message_type, m.created_at,
+			(SELECT COUNT(*) FROM message_reads mr WHERE mr.message_id = m.id AND mr.user_id != m.sender_id) as read_count,
+			m.edited_at, m.deleted_at, m.reply_to_id
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
-		LEFT JOIN message_reads mr ON m.id = mr.message_id
 		WHERE m.chat_id = ?
 		ORDER BY m.created_at DESC
 		LIMIT ? OFFSET ?
@@ -475,35 +581,94 @@ func (s *ChatService) GetChatMessages(chatID string, limit int, offset int) ([]C
 	for rows.Next() {
 		var msg ChatMessage
 		var createdAt string
-		var isRead int
+		var readCount int
+		var editedAt, deletedAt, replyToID sql.NullString
 
 		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.SenderID, &msg.SenderName,
-			&msg.SenderAvatar, &msg.Content, &msg.MessageType, &createdAt, &isRead)
+			&msg.SenderAvatar, &msg.Content, &msg.MessageType, &createdAt, &readCount, &editedAt, &deletedAt, &replyToID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chat message: %w", err)
 		}
 
-		//Handle both old format and new RFC3339 format
-		if timestamp, err := time.Parse(time.RFC3339, createdAt); err == nil {
-			// New format with timezone: 2025-09-19T19:37:47+03:00
-			msg.Timestamp = timestamp
-		} else if timestamp, err := time.Parse("2006-01-02 15:04:05", createdAt); err == nil {
-			// Old format without timezone: 2025-09-19 01:26:38
-			// Assume it's in UTC+3 timezone to match WebSocket format
-			loc, _ := time.LoadLocation("UTC")
-			timestamp = timestamp.In(loc).Add(3 * time.Hour) // Add 3 hours for UTC+3
-			msg.Timestamp = timestamp
-		} else {
-			return nil, fmt.Errorf("failed to parse timestamp: %s", createdAt)
+		msg.Timestamp, err = parseMessageTimestamp(createdAt)
+		if err != nil {
+			return nil, err
 		}
 
-		msg.IsRead = isRead == 1
+		if err := setMessageEdited(&msg, editedAt); err != nil {
+			return nil, err
+		}
+		setMessageDeleted(&msg, deletedAt)
+		setMessageReplyTo(&msg, replyToID)
+
+		msg.IsRead = readCount > 0
+		if recipientCount > 0 {
+			// Group chats show "read by N of M"
+			msg.ReadByCount = readCount
+			msg.RecipientCount = recipientCount
+		}
 		messages = append(messages, msg)
 	}
+
+	if err := s.attachAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := s.attachReplyPreviews(messages); err != nil {
+		return nil, err
+	}
 	return messages, nil
 }
 
-func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
+// formatMessageTimestamp is the format of every messages timestamp column: RFC3339 in UTC,
+// so that the columns sort chronologically as plain strings
+func formatMessageTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseMessageTimestamp parses a messages timestamp column written by formatMessageTimestamp
+func parseMessageTimestamp(createdAt string) (time.Time, error) {
+	timestamp, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid message timestamp %q: %w", createdAt, err)
+	}
+	return timestamp, nil
+}
+
+// Chat list page sizes, the chat list is sent one page at a time
+const (
+	DefaultChatListLimit = 50
+	MaxChatListLimit     = 100
+)
+
+// GetUserChats returns one page of the user's chats, most recently active first, with the
+// total number of chats so the list can be paged. The queries are cancelled with ctx.
+func (s *ChatService) GetUserChats(ctx context.Context, userID string, limit, offset int) (*ChatListMessage, error) {
+	if limit <= 0 || limit > MaxChatListLimit {
+		limit = DefaultChatListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	page := chatListPage{limit: limit, offset: offset}
+	if cached, ok := s.chatLists.get(userID, page, time.Now()); ok {
+		return cached, nil
+	}
+	loadedAt := time.Now()
+
+	var total int
+	err := s.DB.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM chat_threads ct
+        JOIN chat_participants cp ON ct.id = cp.chat_id
+        LEFT JOIN groups g ON ct.group_id = g.id
+        WHERE cp.user_id = ?
+        AND NOT (ct.group_id IS NOT NULL AND g.id IS NULL)
+    `, userID).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user chats: %w", err)
+	}
+
 	query := `
         SELECT 
             ct.id, 
@@ -514,10 +679,11 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
                  FROM chat_participants cp
                  JOIN users u ON cp.user_id = u.id
                  WHERE cp.chat_id = ct.id AND cp.user_id != ?
-                 LIMIT 1)
+                 LIMIT 1),
+                'Deleted user' -- the other participant's account no longer exists
             ) as chat_name,
             CASE 
-                WHEN ct.is_group = 1 THEN '/images/default-group.png'
+                WHEN ct.is_group = 1 THEN COALESCE(NULLIF(g.avatar_path, ''), '/images/default-group.png')
                 ELSE (
                     SELECT u.avatar_path
                     FROM chat_participants cp
@@ -526,12 +692,23 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
                     LIMIT 1
                 )
             END as chat_avatar,
+            -- When the other participant of a private chat was last seen
+            CASE
+                WHEN ct.is_group = 0 THEN (
+                    SELECT u.last_seen
+                    FROM chat_participants cp
+                    JOIN users u ON cp.user_id = u.id
+                    WHERE cp.chat_id = ct.id AND cp.user_id != ?
+                    LIMIT 1
+                )
+            END as other_last_seen,
             -- Get last message data
             lm.id as last_msg_id,
             lm.sender_id as last_msg_sender_id,
-            lm.content as last_msg_content,
+            CASE WHEN lm.deleted_at IS NULL THEN lm.content ELSE '' END as last_msg_content,
             lm.message_type as last_msg_type,
             lm.created_at as last_msg_timestamp,
+            lm.deleted_at as last_msg_deleted_at,
             u_sender.first_name || ' ' || u_sender.last_name as last_msg_sender_name,
             u_sender.avatar_path as last_msg_sender_avatar,
             -- Unread count
@@ -540,15 +717,14 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
         LEFT JOIN groups g ON ct.group_id = g.id
         JOIN chat_participants cp ON ct.id = cp.chat_id
         -- Get last message
-        LEFT JOIN (
-            SELECT m1.chat_id, m1.id, m1.sender_id, m1.content, m1.message_type, m1.created_at
+        -- (the newest one only, ties on created_at would otherwise list the chat twice)
+        LEFT JOIN messages lm ON lm.id = (
+            SELECT m1.id
             FROM messages m1
-            INNER JOIN (
-                SELECT chat_id, MAX(created_at) as max_created_at
-                FROM messages
-                GROUP BY chat_id
-            ) m2 ON m1.chat_id = m2.chat_id AND m1.created_at = m2.max_created_at
-        ) lm ON ct.id = lm.chat_id
+            WHERE m1.chat_id = ct.id
+            ORDER BY m1.created_at DESC, m1.id DESC
+            LIMIT 1
+        )
         LEFT JOIN users u_sender ON lm.sender_id = u_sender.id
         -- Get unread count
         LEFT JOIN (
@@ -559,11 +735,14 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
             GROUP BY m.chat_id
         ) unread_count ON ct.id = unread_count.chat_id
         WHERE cp.user_id = ?
+        -- skip threads left behind by a deleted group
+        AND NOT (ct.group_id IS NOT NULL AND g.id IS NULL)
         -- NOTE: removed filter that excluded chats without any messages
-        ORDER BY COALESCE(lm.created_at, ct.created_at) DESC
+        ORDER BY datetime(COALESCE(lm.created_at, ct.created_at)) DESC, ct.id DESC
+        LIMIT ? OFFSET ?
     `
 
-	rows, err := s.DB.Query(query, userID, userID, userID, userID, userID)
+	rows, err := s.DB.QueryContext(ctx, query, userID, userID, userID, userID, userID, userID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user chats: %w", err)
 	}
@@ -574,13 +753,13 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
 		var chat ChatRoom
 		var isGroup int
 		var groupID sql.NullString
-		var avatar sql.NullString
+		var avatar, lastSeen sql.NullString
 		var lastMsgID, lastMsgSenderID, lastMsgContent, lastMsgType, lastMsgTimestamp sql.NullString
-		var lastMsgSenderName, lastMsgSenderAvatar sql.NullString
+		var lastMsgSenderName, lastMsgSenderAvatar, lastMsgDeletedAt sql.NullString
 		var unreadCount int
 
-		err := rows.Scan(&chat.ID, &isGroup, &groupID, &chat.Name, &avatar,
-			&lastMsgID, &lastMsgSenderID, &lastMsgContent, &lastMsgType, &lastMsgTimestamp,
+		err := rows.Scan(&chat.ID, &isGroup, &groupID, &chat.Name, &avatar, &lastSeen,
+			&lastMsgID, &lastMsgSenderID, &lastMsgContent, &lastMsgType, &lastMsgTimestamp, &lastMsgDeletedAt,
 			&lastMsgSenderName, &lastMsgSenderAvatar, &unreadCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chat room: %w", err)
@@ -602,24 +781,17 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
 		if avatar.Valid {
 			chat.Avatar = avatar.String
 		}
+		chat.AvatarThumb = utils.AvatarThumb(chat.Avatar)
+		chat.LastSeen = parseLastSeen(lastSeen)
 
 		// Set unread count
 		chat.UnreadCount = unreadCount
 
 		// Set last message if exists
 		if lastMsgID.Valid {
-			//Handle both timestamp formats
-			var timestamp time.Time
-			if t, err := time.Parse(time.RFC3339, lastMsgTimestamp.String); err == nil {
-				// New format with timezone
-				timestamp = t
-			} else if t, err := time.Parse("2006-01-02 15:04:05", lastMsgTimestamp.String); err == nil {
-				// Old format without timezone - assume UTC+3
-				loc, _ := time.LoadLocation("UTC")
-				timestamp = t.In(loc).Add(3 * time.Hour)
-			} else {
-				// Fallback to current time if parsing fails
-				timestamp = time.Now()
+			timestamp, err := parseMessageTimestamp(lastMsgTimestamp.String)
+			if err != nil {
+				return nil, err
 			}
 
 			chat.LastMessage = &ChatMessage{
@@ -634,10 +806,11 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
 				RecipientID:  "",
 				GroupID:      chat.GroupID,
 			}
+			setMessageDeleted(chat.LastMessage, lastMsgDeletedAt)
 		}
 
 		// Get participants
-		participants, err := s.getChatParticipants(chat.ID)
+		participants, err := s.getChatParticipantsContext(ctx, chat.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get chat participants: %w", err)
 		}
@@ -650,15 +823,39 @@ func (s *ChatService) GetUserChats(userID string) ([]ChatRoom, error) {
 
 		chats = append(chats, chat)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user chats: %w", err)
+	}
 
-	return chats, nil
+	list := &ChatListMessage{
+		Chats:   chats,
+		Total:   total,
+		Offset:  offset,
+		Limit:   limit,
+		HasMore: offset+len(chats) < total,
+	}
+	s.chatLists.put(userID, page, list, loadedAt)
+	return list, nil
+}
+
+// userChats is GetUserChats for chat lists the hub sends on its own, outside of any request
+func (s *ChatService) userChats(userID string, limit, offset int) (*ChatListMessage, error) {
+	ctx, cancel := db.WithQueryTimeout(context.Background())
+	defer cancel()
+	return s.GetUserChats(ctx, userID, limit, offset)
 }
 
 func (s *ChatService) getChatParticipants(chatID string) ([]string, error) {
-	rows, err := s.DB.Query(`
-	    SELECT user_id
-		FROM chat_participants
-		WHERE chat_id = ?
+	return s.getChatParticipantsContext(context.Background(), chatID)
+}
+
+func (s *ChatService) getChatParticipantsContext(ctx context.Context, chatID string) ([]string, error) {
+	// Only participants whose account still exists
+	rows, err := s.DB.QueryContext(ctx, `
+	    SELECT cp.user_id
+		FROM chat_participants cp
+		JOIN users u ON cp.user_id = u.id
+		WHERE cp.chat_id = ?
 	`, chatID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chat participants: %w", err)
@@ -673,7 +870,7 @@ func (s *ChatService) getChatParticipants(chatID string) ([]string, error) {
 		}
 		participants = append(participants, userID)
 	}
-	return participants, nil
+	return participants, rows.Err()
 }
 
 // Add method to get users that are related (following/followed by) to a specific user
@@ -722,6 +919,133 @@ func (s *ChatService) IsUserChatParticipant(userID, chatID string) (bool, error)
 	return count > 0, nil
 }
 
+var (
+	ErrChatNotFound       = errors.New("chat not found")
+	ErrNotChatParticipant = errors.New("user is not a participant of this chat")
+	ErrGroupChatLeave     = errors.New("cannot leave a group chat while in the group")
+	ErrUserBlocked        = errors.New("you cannot message this user")
+)
+
+// IsBlocked reports whether either user has blocked the other, which rules out direct messages
+func (s *ChatService) IsBlocked(senderID, recipientID string) (bool, error) {
+	var count int
+	err := s.DB.QueryRow(`
+        SELECT COUNT(*) FROM blocked_users
+        WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+    `, senderID, recipientID, recipientID, senderID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return count > 0, nil
+}
+
+// LeaveChat removes the user from an ad-hoc chat (private or group chat without a group).
+// Group-backed chats follow group membership, so leaving them is refused: the user has to
+// mute the chat or leave the group instead.
+func (s *ChatService) LeaveChat(userID, chatID string) error {
+	var groupID sql.NullInt64
+	err := s.DB.QueryRow("SELECT group_id FROM chat_threads WHERE id = ?", chatID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return ErrChatNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	if groupID.Valid {
+		return ErrGroupChatLeave
+	}
+
+	result, err := s.DB.Exec("DELETE FROM chat_participants WHERE chat_id = ? AND user_id = ?", chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to leave chat: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to leave chat: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotChatParticipant
+	}
+	s.invalidateChat(chatID)
+	s.chatLists.invalidate(userID)
+
+	return nil
+}
+
+// ChatStats holds summary statistics shown in a chat header
+type ChatStats struct {
+	ChatID        string                   `json:"chat_id"`
+	TotalMessages int                      `json:"total_messages"`
+	FirstMessage  *time.Time               `json:"first_message_at,omitempty"`
+	MostActive    *ChatParticipantActivity `json:"most_active,omitempty"`
+}
+
+// ChatParticipantActivity is the message count of one chat participant
+type ChatParticipantActivity struct {
+	UserID       string `json:"user_id"`
+	Name         string `json:"name"`
+	MessageCount int    `json:"message_count"`
+}
+
+// GetChatStats returns message statistics for a chat the user participates in
+func (s *ChatService) GetChatStats(userID, chatID string) (*ChatStats, error) {
+	isParticipant, err := s.IsUserChatParticipant(userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotChatParticipant
+	}
+
+	stats := &ChatStats{ChatID: chatID}
+
+	stats.TotalMessages, err = s.GetChatMessageCount(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if stats.TotalMessages == 0 {
+		return stats, nil
+	}
+
+	var firstCreatedAt string
+	err = s.DB.QueryRow(`
+        SELECT created_at
+        FROM messages
+        WHERE chat_id = ?
+        ORDER BY datetime(created_at) ASC, id ASC
+        LIMIT 1
+    `, chatID).Scan(&firstCreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get first message: %w", err)
+	}
+	firstMessage, err := parseMessageTimestamp(firstCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	stats.FirstMessage = &firstMessage
+
+	var mostActive ChatParticipantActivity
+	err = s.DB.QueryRow(`
+        SELECT m.sender_id, u.first_name || ' ' || u.last_name, COUNT(*) AS message_count
+        FROM messages m
+        JOIN users u ON m.sender_id = u.id
+        WHERE m.chat_id = ?
+        GROUP BY m.sender_id
+        ORDER BY message_count DESC, MAX(m.id) DESC
+        LIMIT 1
+    `, chatID).Scan(&mostActive.UserID, &mostActive.Name, &mostActive.MessageCount)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get most active participant: %w", err)
+	}
+	if err == nil {
+		stats.MostActive = &mostActive
+	}
+
+	return stats, nil
+}
+
 // Add method to get total message count for a chat
 func (s *ChatService) GetChatMessageCount(chatID string) (int, error) {
 	var count int
@@ -738,6 +1062,63 @@ func (s *ChatService) GetChatMessageCount(chatID string) (int, error) {
 	return count, nil
 }
 
+// SearchChatMessages returns the messages of a chat whose content contains query, newest first.
+// Only participants may search a chat, and deleted messages never match.
+func (s *ChatService) SearchChatMessages(chatID, userID, query string, limit, offset int) ([]ChatMessage, error) {
+	isParticipant, err := s.IsUserChatParticipant(userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotChatParticipant
+	}
+
+	rows, err := s.DB.Query(`
+        SELECT m.id, m.chat_id, m.sender_id, u.first_name || ' ' || u.last_name as sender_name,
+            COALESCE(u.avatar_path, '') as sender_avatar, m.content, m.message_type, m.created_at, m.edited_at
+        FROM messages m
+        JOIN users u ON m.sender_id = u.id
+        WHERE m.chat_id = ? AND m.deleted_at IS NULL
+        AND m.content LIKE ?
+        ORDER BY m.created_at DESC, m.id DESC
+        LIMIT ? OFFSET ?
+    `, chatID, "%"+utils.SanitizeSearchTerm(query)+"%", limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []ChatMessage{}
+	for rows.Next() {
+		var msg ChatMessage
+		var createdAt string
+		var editedAt sql.NullString
+
+		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.SenderID, &msg.SenderName,
+			&msg.SenderAvatar, &msg.Content, &msg.MessageType, &createdAt, &editedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat message: %w", err)
+		}
+
+		msg.Timestamp, err = parseMessageTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := setMessageEdited(&msg, editedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachAttachments(messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
 func (c *Client) saveMessageToDatabase(chatMsg *ChatMessage) (int64, error) {
 	if chatMsg.RecipientID != "" {
 		return c.chatService.SavePrivateMessageAndGetChatID(chatMsg)
@@ -758,7 +1139,7 @@ func (c *Client) sendMessageToRecipients(chatMsg *ChatMessage) {
 
 	if chatMsg.RecipientID != "" {
 		// Private message
-		c.hub.SendToUser(chatMsg.RecipientID, msgData)
+		c.hub.SendToUserOrQueue(chatMsg.RecipientID, msgData)
 		c.hub.SendToUser(chatMsg.SenderID, msgData) // ack
 	} else if chatMsg.GroupID != "" {
 		// Group message
@@ -766,7 +1147,7 @@ func (c *Client) sendMessageToRecipients(chatMsg *ChatMessage) {
 		if err != nil {
 			return
 		}
-		c.hub.SendToUsers(participants, msgData)
+		c.hub.SendToUsersOrQueue(participants, msgData)
 	}
 }
 
@@ -793,11 +1174,9 @@ func (c *Client) updateReadMessages(readMsg MessagesReadMessage) error {
 
 		// Only insert if not already marked as read
 		if count == 0 {
-			// Use RFC3339 format instead of datetime('now')
-			now := time.Now().Format(time.RFC3339)
 			_, err = tx.Exec(
 				"INSERT INTO message_reads (message_id, user_id, read_at) VALUES (?, ?, ?)",
-				messageID, readMsg.UserID, now,
+				messageID, readMsg.UserID, formatMessageTimestamp(readMsg.ReadAt),
 			)
 			if err != nil {
 				return err
@@ -805,10 +1184,22 @@ func (c *Client) updateReadMessages(readMsg MessagesReadMessage) error {
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.hub.chatService.InvalidateUserChats(readMsg.UserID)
+	return nil
 }
 
 func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 string) (*ChatRoom, error) {
+	blocked, err := s.IsBlocked(userID1, userID2)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrUserBlocked
+	}
+
 	// Always order user IDs to avoid duplicate chats
 	if userID1 > userID2 {
 		userID1, userID2 = userID2, userID1
@@ -821,6 +1212,7 @@ func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 string) (*ChatRoom
 	defer tx.Rollback()
 
 	var chatID int64
+	created := false
 	query := `
         SELECT ct.id
         FROM chat_threads ct
@@ -857,6 +1249,7 @@ func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 string) (*ChatRoom
 			if err != nil {
 				return nil, err
 			}
+			created = true
 		} else {
 			return nil, err
 		}
@@ -866,6 +1259,9 @@ func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 string) (*ChatRoom
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
+	if created {
+		s.chatLists.invalidate(userID1, userID2)
+	}
 
 	// Now fetch the chat room info
 	chatRoom, err := s.getChatRoomByID(chatID, userID1)
@@ -925,6 +1321,7 @@ func (s *ChatService) getChatRoomByID(chatID int64, currentUserID string) (*Chat
 	if avatar.Valid {
 		chat.Avatar = avatar.String
 	}
+	chat.AvatarThumb = utils.AvatarThumb(chat.Avatar)
 
 	// Get participants
 	participants, err := s.getChatParticipants(chat.ID)
@@ -994,13 +1391,13 @@ func (c *Client) handleLeaveGroup(data interface{}) {
 }
 
 func (c *Client) sendChatList() {
-	chats, err := c.hub.chatService.GetUserChats(c.userID)
+	chats, err := c.hub.chatService.userChats(c.userID, DefaultChatListLimit, 0)
 	if err != nil {
 		return
 	}
 	resp := WSMessage{
 		Type:      TypeChatList,
-		Data:      map[string]interface{}{"chats": chats},
+		Data:      chats,
 		Timestamp: time.Now(),
 	}
 	b, _ := json.Marshal(resp)
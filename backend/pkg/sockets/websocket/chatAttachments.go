@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	MaxMessageAttachments = 4
+	MaxAttachmentSize     = 10 << 20 // same cap as the media upload endpoint
+
+	attachmentURLPrefix = "/uploads/media/"
+	attachmentDir       = "./uploads/media"
+)
+
+var (
+	ErrTooManyAttachments = fmt.Errorf("a message can have at most %d attachments", MaxMessageAttachments)
+	ErrInvalidAttachment  = errors.New("attachment must be a file uploaded to /uploads/media/")
+	ErrAttachmentTooLarge = fmt.Errorf("attachments cannot be larger than %d MB", MaxAttachmentSize>>20)
+)
+
+// attachmentMediaTypes mirrors the extensions accepted by the media upload handler
+var attachmentMediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+}
+
+// validateAttachments checks every attachment against the uploads directory and fills in
+// its media type and size from the stored file, so client supplied values are never trusted
+func validateAttachments(attachments []MessageAttachment) error {
+	if len(attachments) > MaxMessageAttachments {
+		return ErrTooManyAttachments
+	}
+
+	for i := range attachments {
+		filePath := attachments[i].FilePath
+		if !strings.HasPrefix(filePath, attachmentURLPrefix) || strings.Contains(filePath, "..") {
+			return ErrInvalidAttachment
+		}
+		name := strings.TrimPrefix(filePath, attachmentURLPrefix)
+		if name == "" || name != path.Base(name) {
+			return ErrInvalidAttachment
+		}
+
+		mediaType, ok := attachmentMediaTypes[strings.ToLower(filepath.Ext(name))]
+		if !ok {
+			return ErrInvalidAttachment
+		}
+
+		info, err := os.Stat(filepath.Join(attachmentDir, name))
+		if err != nil || !info.Mode().IsRegular() {
+			return ErrInvalidAttachment
+		}
+		if info.Size() > MaxAttachmentSize {
+			return ErrAttachmentTooLarge
+		}
+
+		attachments[i].MediaType = mediaType
+		attachments[i].Size = info.Size()
+	}
+	return nil
+}
+
+// saveAttachments stores the message's attachments inside the message's transaction
+func saveAttachments(tx *sql.Tx, messageID int64, attachments []MessageAttachment) error {
+	for i := range attachments {
+		result, err := tx.Exec(
+			"INSERT INTO message_media (message_id, file_path, media_type, size) VALUES (?, ?, ?, ?)",
+			messageID, attachments[i].FilePath, attachments[i].MediaType, attachments[i].Size,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save attachment: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get attachment ID: %w", err)
+		}
+		attachments[i].ID = id
+		attachments[i].MessageID = strconv.FormatInt(messageID, 10)
+	}
+	return nil
+}
+
+// attachAttachments loads the attachments of all given messages in one query.
+// Deleted messages keep their rows but don't expose them.
+func (s *ChatService) attachAttachments(messages []ChatMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(messages))
+	args := make([]interface{}, len(messages))
+	index := make(map[string]int, len(messages))
+	for i, msg := range messages {
+		placeholders[i] = "?"
+		args[i] = msg.ID
+		index[msg.ID] = i
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT id, message_id, file_path, media_type, size
+		FROM message_media
+		WHERE message_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY id ASC`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get message attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a MessageAttachment
+		if err := rows.Scan(&a.ID, &a.MessageID, &a.FilePath, &a.MediaType, &a.Size); err != nil {
+			return fmt.Errorf("failed to scan message attachment: %w", err)
+		}
+		i, ok := index[a.MessageID]
+		if !ok || messages[i].Deleted {
+			continue
+		}
+		messages[i].Attachments = append(messages[i].Attachments, a)
+	}
+	return rows.Err()
+}
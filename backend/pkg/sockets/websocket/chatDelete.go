@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"social-network/pkg/models/group"
+	"time"
+)
+
+// DeletedMessageText replaces the content of a deleted message
+const DeletedMessageText = "This message was deleted"
+
+// messageContentColumn selects messages.content (as m) but never the content of a deleted message
+const messageContentColumn = "CASE WHEN m.deleted_at IS NULL THEN m.content ELSE '' END"
+
+var ErrCannotDeleteMessage = errors.New("you can only delete your own messages")
+
+func (c *Client) handleDeleteMessage(data interface{}) {
+	req, err := unmarshalData[DeleteMessageRequest](data)
+	if err != nil {
+		return
+	}
+
+	msg, err := c.hub.chatService.DeleteMessage(req.MessageID, c.userID)
+	if err != nil {
+		c.sendDeleteMessageError(req.MessageID, err.Error())
+		return
+	}
+
+	// Broadcast the tombstone so every client replaces the message with the placeholder
+	participants, err := c.hub.chatService.getChatParticipants(msg.ChatID)
+	if err != nil {
+		return
+	}
+	message := WSMessage{
+		Type:      TypeDeleteMessage,
+		Data:      msg,
+		Timestamp: time.Now(),
+	}
+	msgData, _ := json.Marshal(message)
+	c.hub.SendToUsers(participants, msgData)
+}
+
+// DeleteMessage marks a message as deleted. The sender can delete their own messages and
+// the admins of a group can delete any message in that group's chat. The row is kept as a
+// tombstone so the conversation keeps its shape.
+func (s *ChatService) DeleteMessage(messageID, userID string) (*ChatMessage, error) {
+	var senderID string
+	var groupID, deletedAt sql.NullString
+	err := s.DB.QueryRow(`
+		SELECT m.sender_id, ct.group_id, m.deleted_at
+		FROM messages m
+		JOIN chat_threads ct ON m.chat_id = ct.id
+		WHERE m.id = ?
+	`, messageID).Scan(&senderID, &groupID, &deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	if deletedAt.Valid {
+		return nil, ErrMessageDeleted
+	}
+
+	if senderID != userID {
+		isAdmin := false
+		if groupID.Valid {
+			adminIDs, err := group.GetGroupAdminIDs(s.DB, groupID.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get group admins: %w", err)
+			}
+			for _, adminID := range adminIDs {
+				if adminID == userID {
+					isAdmin = true
+					break
+				}
+			}
+		}
+		if !isAdmin {
+			return nil, ErrCannotDeleteMessage
+		}
+	}
+
+	_, err = s.DB.Exec(
+		"UPDATE messages SET deleted_at = ? WHERE id = ?",
+		formatMessageTimestamp(time.Now()), messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	msg, err := s.getChatMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateChat(msg.ChatID)
+	if err := s.updateQueuedMessage(msg); err != nil {
+		log.Printf("[WS] Failed to update queued copies of message %s: %v", messageID, err)
+	}
+	return msg, nil
+}
+
+// setMessageDeleted turns a message into its tombstone when messages.deleted_at is set
+func setMessageDeleted(msg *ChatMessage, deletedAt sql.NullString) {
+	if !deletedAt.Valid {
+		return
+	}
+	msg.Deleted = true
+	msg.Content = DeletedMessageText
+}
+
+func (c *Client) sendDeleteMessageError(messageID, message string) {
+	errorResponse := map[string]interface{}{
+		"error":      true,
+		"message":    message,
+		"message_id": messageID,
+		"type":       "delete_message_error",
+	}
+
+	wsMessage := WSMessage{
+		Type:      TypeDeleteMessage,
+		Data:      errorResponse,
+		Timestamp: time.Now(),
+	}
+
+	msgData, _ := json.Marshal(wsMessage)
+	c.hub.SendToUser(c.userID, msgData)
+}
@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"social-network/pkg/utils"
+	"time"
+)
+
+// DefaultMessageEditWindow is how long a message stays editable unless ChatService.EditWindow says otherwise
+const DefaultMessageEditWindow = 15 * time.Minute
+
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrNotMessageSender   = errors.New("you can only change your own messages")
+	ErrMessageNotEditable = errors.New("media and gif messages cannot be edited")
+	ErrEditWindowExpired  = errors.New("message is too old to edit")
+	ErrMessageDeleted     = errors.New("message has been deleted")
+)
+
+func (c *Client) handleEditMessage(data interface{}) {
+	req, err := unmarshalData[EditMessageRequest](data)
+	if err != nil {
+		return
+	}
+
+	content := utils.SanitizeText(req.Content)
+	if content == "" {
+		c.sendEditMessageError(req.MessageID, "message content cannot be empty")
+		return
+	}
+
+	msg, err := c.hub.chatService.EditMessage(req.MessageID, c.userID, content)
+	if err != nil {
+		c.sendEditMessageError(req.MessageID, err.Error())
+		return
+	}
+
+	// Rebroadcast the edited message to everyone in the chat
+	participants, err := c.hub.chatService.getChatParticipants(msg.ChatID)
+	if err != nil {
+		return
+	}
+	message := WSMessage{
+		Type:      TypeEditMessage,
+		Data:      msg,
+		Timestamp: time.Now(),
+	}
+	msgData, _ := json.Marshal(message)
+	c.hub.SendToUsers(participants, msgData)
+}
+
+// EditMessage replaces the content of a text message after checking that userID sent it,
+// that it isn't a media/gif message and that it is still inside the edit window
+func (s *ChatService) EditMessage(messageID, userID, content string) (*ChatMessage, error) {
+	var senderID, messageType, createdAt string
+	var deletedAt sql.NullString
+	err := s.DB.QueryRow(
+		"SELECT sender_id, message_type, created_at, deleted_at FROM messages WHERE id = ?",
+		messageID,
+	).Scan(&senderID, &messageType, &createdAt, &deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	if senderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	if deletedAt.Valid {
+		return nil, ErrMessageDeleted
+	}
+	if messageType == "media" || messageType == "gif" {
+		return nil, ErrMessageNotEditable
+	}
+
+	sentAt, err := parseMessageTimestamp(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(sentAt) > s.EditWindow {
+		return nil, ErrEditWindowExpired
+	}
+
+	_, err = s.DB.Exec(
+		"UPDATE messages SET content = ?, edited_at = ? WHERE id = ?",
+		content, formatMessageTimestamp(time.Now()), messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	msg, err := s.getChatMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateChat(msg.ChatID)
+	if err := s.updateQueuedMessage(msg); err != nil {
+		log.Printf("[WS] Failed to update queued copies of message %s: %v", messageID, err)
+	}
+	return msg, nil
+}
+
+// getChatMessage loads a single message with its sender details, attachments and reply preview
+func (s *ChatService) getChatMessage(messageID string) (*ChatMessage, error) {
+	var msg ChatMessage
+	var createdAt string
+	var editedAt, deletedAt, replyToID sql.NullString
+	err := s.DB.QueryRow(`
+		SELECT m.id, m.chat_id, m.sender_id, u.first_name || ' ' || u.last_name as sender_name,
+			COALESCE(u.avatar_path, '') as sender_avatar, `+messageContentColumn+`, m.message_type, m.created_at,
+			m.edited_at, m.deleted_at, m.reply_to_id
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		WHERE m.id = ?
+	`, messageID).Scan(&msg.ID, &msg.ChatID, &msg.SenderID, &msg.SenderName,
+		&msg.SenderAvatar, &msg.Content, &msg.MessageType, &createdAt, &editedAt, &deletedAt, &replyToID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	msg.Timestamp, err = parseMessageTimestamp(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := setMessageEdited(&msg, editedAt); err != nil {
+		return nil, err
+	}
+	setMessageDeleted(&msg, deletedAt)
+	setMessageReplyTo(&msg, replyToID)
+
+	messages := []ChatMessage{msg}
+	if err := s.attachAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := s.attachReplyPreviews(messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// setMessageEdited fills in Edited and EditedAt from the messages.edited_at column
+func setMessageEdited(msg *ChatMessage, editedAt sql.NullString) error {
+	if !editedAt.Valid {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, editedAt.String)
+	if err != nil {
+		return fmt.Errorf("failed to parse edited_at: %w", err)
+	}
+	msg.Edited = true
+	msg.EditedAt = &t
+	return nil
+}
+
+func (c *Client) sendEditMessageError(messageID, message string) {
+	errorResponse := map[string]interface{}{
+		"error":      true,
+		"message":    message,
+		"message_id": messageID,
+		"type":       "edit_message_error",
+	}
+
+	wsMessage := WSMessage{
+		Type:      TypeEditMessage,
+		Data:      errorResponse,
+		Timestamp: time.Now(),
+	}
+
+	msgData, _ := json.Marshal(wsMessage)
+	c.hub.SendToUser(c.userID, msgData)
+}
@@ -0,0 +1,28 @@
+package websocket_test
+
+import (
+	"social-network/pkg/sockets/websocket"
+	"testing"
+)
+
+func TestEditedMessageKeepsAttachments(t *testing.T) {
+	db := setupChatDB(t)
+	service := websocket.NewChatService(db)
+
+	var messageID string
+	if err := db.QueryRow("SELECT id FROM messages").Scan(&messageID); err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO message_media (message_id, file_path, media_type, size) VALUES (?, 'uploads/a.png', 'image/png', 10)", messageID); err != nil {
+		t.Fatalf("Failed to attach media: %v", err)
+	}
+
+	// The edited message is what gets broadcast, so it must still carry the attachment
+	edited, err := service.EditMessage(messageID, "alice", "hello again")
+	if err != nil {
+		t.Fatalf("EditMessage failed: %v", err)
+	}
+	if len(edited.Attachments) != 1 || edited.Attachments[0].FilePath != "uploads/a.png" {
+		t.Errorf("expected the edited message to keep its attachment, got %+v", edited.Attachments)
+	}
+}
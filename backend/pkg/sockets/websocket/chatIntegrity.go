@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrphanedChat describes a chat thread that references data which no longer exists
+type OrphanedChat struct {
+	ChatID         string   `json:"chat_id"`
+	IsGroup        bool     `json:"is_group"`
+	GroupID        string   `json:"group_id,omitempty"`
+	MissingGroup   bool     `json:"missing_group"`
+	MissingUsers   []string `json:"missing_users"`
+	ExistingUsers  int      `json:"existing_users"`
+	MessageCount   int      `json:"message_count"`
+	RemoveOnRepair bool     `json:"remove_on_repair"` // the whole thread is deleted, not only dangling participants
+	SyncOnRepair   bool     `json:"sync_on_repair"`   // the group still exists, its members are added back
+}
+
+// FindOrphanedChats lists chat threads whose group was deleted, that have participants
+// without a user account, or that have no participants left at all
+func (s *ChatService) FindOrphanedChats() ([]OrphanedChat, error) {
+	rows, err := s.DB.Query(`
+        SELECT ct.id, ct.is_group, COALESCE(ct.group_id, ''),
+            CASE WHEN ct.group_id IS NOT NULL AND g.id IS NULL THEN 1 ELSE 0 END AS missing_group,
+            COALESCE((SELECT GROUP_CONCAT(cp.user_id)
+                FROM chat_participants cp
+                LEFT JOIN users u ON cp.user_id = u.id
+                WHERE cp.chat_id = ct.id AND u.id IS NULL), '') AS missing_users,
+            (SELECT COUNT(*)
+                FROM chat_participants cp
+                JOIN users u ON cp.user_id = u.id
+                WHERE cp.chat_id = ct.id) AS existing_users,
+            (SELECT COUNT(*) FROM messages m WHERE m.chat_id = ct.id) AS message_count
+        FROM chat_threads ct
+        LEFT JOIN groups g ON ct.group_id = g.id
+        ORDER BY ct.id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphaned chats: %w", err)
+	}
+	defer rows.Close()
+
+	orphans := []OrphanedChat{}
+	for rows.Next() {
+		var chat OrphanedChat
+		var isGroup, missingGroup int
+		var missingUsers string
+		if err := rows.Scan(&chat.ChatID, &isGroup, &chat.GroupID, &missingGroup, &missingUsers,
+			&chat.ExistingUsers, &chat.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan orphaned chat: %w", err)
+		}
+
+		chat.IsGroup = isGroup == 1
+		chat.MissingGroup = missingGroup == 1
+		chat.MissingUsers = []string{}
+		if missingUsers != "" {
+			chat.MissingUsers = strings.Split(missingUsers, ",")
+		}
+
+		if !chat.MissingGroup && len(chat.MissingUsers) == 0 && chat.ExistingUsers > 0 {
+			continue
+		}
+
+		// A group thread whose group still exists stays usable once its members are added back,
+		// only threads of deleted groups and ad-hoc threads nobody is left in are removed
+		hasGroup := chat.GroupID != "" && !chat.MissingGroup
+		chat.RemoveOnRepair = chat.MissingGroup || (chat.ExistingUsers == 0 && !hasGroup)
+		chat.SyncOnRepair = chat.ExistingUsers == 0 && hasGroup
+		orphans = append(orphans, chat)
+	}
+
+	return orphans, rows.Err()
+}
+
+// RepairOrphanedChats removes dangling participants, adds the members of existing groups back
+// to their emptied threads and deletes threads that can no longer be used (group gone, or
+// nobody left in an ad-hoc chat). Returns what was repaired.
+func (s *ChatService) RepairOrphanedChats() ([]OrphanedChat, error) {
+	orphans, err := s.FindOrphanedChats()
+	if err != nil {
+		return nil, err
+	}
+	if len(orphans) == 0 {
+		return orphans, nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, chat := range orphans {
+		if chat.RemoveOnRepair {
+			if _, err := tx.Exec("DELETE FROM messages WHERE chat_id = ?", chat.ChatID); err != nil {
+				return nil, fmt.Errorf("failed to delete messages of chat %s: %w", chat.ChatID, err)
+			}
+			if _, err := tx.Exec("DELETE FROM chat_participants WHERE chat_id = ?", chat.ChatID); err != nil {
+				return nil, fmt.Errorf("failed to delete participants of chat %s: %w", chat.ChatID, err)
+			}
+			if _, err := tx.Exec("DELETE FROM chat_threads WHERE id = ?", chat.ChatID); err != nil {
+				return nil, fmt.Errorf("failed to delete chat %s: %w", chat.ChatID, err)
+			}
+			continue
+		}
+
+		for _, userID := range chat.MissingUsers {
+			if _, err := tx.Exec("DELETE FROM chat_participants WHERE chat_id = ? AND user_id = ?", chat.ChatID, userID); err != nil {
+				return nil, fmt.Errorf("failed to remove participant %s from chat %s: %w", userID, chat.ChatID, err)
+			}
+		}
+	}
+
+	// message_reads has no foreign key to messages, drop reads of messages that are gone
+	if _, err := tx.Exec("DELETE FROM message_reads WHERE message_id NOT IN (SELECT CAST(id AS TEXT) FROM messages)"); err != nil {
+		return nil, fmt.Errorf("failed to clean up message reads: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, chat := range orphans {
+		if chat.SyncOnRepair {
+			if err := s.SyncGroupChatParticipants(chat.GroupID); err != nil {
+				return nil, fmt.Errorf("failed to sync participants of chat %s: %w", chat.ChatID, err)
+			}
+		}
+	}
+
+	return orphans, nil
+}
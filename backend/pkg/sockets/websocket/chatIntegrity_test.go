@@ -0,0 +1,53 @@
+package websocket_test
+
+import (
+	"social-network/pkg/sockets/websocket"
+	"testing"
+)
+
+func TestRepairOrphanedChatsKeepsThreadsOfExistingGroups(t *testing.T) {
+	db := setupChatDB(t)
+	service := websocket.NewChatService(db)
+
+	// The group's chat lost all of its participants, the ad-hoc chat has nobody left to use it
+	statements := []string{
+		`INSERT INTO groups (id, creator_id, title, description, is_public) VALUES (1, 'alice', 'Group', '', 1)`,
+		`INSERT INTO group_memberships (group_id, user_id, role) VALUES (1, 'bob', 'member')`,
+		`INSERT INTO chat_threads (id, is_group, group_id, created_at) VALUES (100, 1, 1, datetime('now')), (101, 0, NULL, datetime('now'))`,
+		`INSERT INTO messages (chat_id, sender_id, content, message_type, created_at) VALUES (100, 'bob', 'kept', 'text', '2026-01-01T00:00:00Z')`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to set up database: %v", err)
+		}
+	}
+
+	repaired, err := service.RepairOrphanedChats()
+	if err != nil {
+		t.Fatalf("RepairOrphanedChats failed: %v", err)
+	}
+	if len(repaired) != 2 {
+		t.Fatalf("expected 2 repaired chats, got %+v", repaired)
+	}
+
+	var messages, participants, deleted int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE chat_id = 100").Scan(&messages); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM chat_participants WHERE chat_id = 100").Scan(&participants); err != nil {
+		t.Fatalf("Failed to count participants: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM chat_threads WHERE id = 101").Scan(&deleted); err != nil {
+		t.Fatalf("Failed to count threads: %v", err)
+	}
+	if messages != 1 || participants != 2 {
+		t.Errorf("expected the group chat to keep its message and get alice and bob back, got %d messages and %d participants", messages, participants)
+	}
+	if deleted != 0 {
+		t.Error("expected the empty ad-hoc chat to be removed")
+	}
+
+	if err := service.AddUserToGroupChat("bob", "1"); err != nil {
+		t.Errorf("expected the group chat to stay usable, got %v", err)
+	}
+}
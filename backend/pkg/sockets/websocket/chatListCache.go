@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"database/sql"
+	"social-network/pkg/db"
+	"sync"
+	"time"
+)
+
+// ChatListCacheTTL is how long a page of a user's chat list is served from memory. Every
+// connect and every status change of a related user asks for chat lists, and loading one
+// is a heavy query. Writes made through ChatService drop the affected lists right away;
+// the TTL bounds how stale a list gets after writes made elsewhere.
+const ChatListCacheTTL = 5 * time.Second
+
+// chatListCaches holds one cache per database, shared by every ChatService using it so a
+// write through any of them (the hub's or a handler's) invalidates the others' lists
+var chatListCaches sync.Map // map[*sql.DB]*chatListCache
+
+func chatListCacheFor(conn *sql.DB) *chatListCache {
+	cache, _ := chatListCaches.LoadOrStore(conn, &chatListCache{
+		ttl:         ChatListCacheTTL,
+		lists:       make(map[string]map[chatListPage]cachedChatList),
+		invalidated: make(map[string]time.Time),
+	})
+	return cache.(*chatListCache)
+}
+
+type chatListPage struct {
+	limit, offset int
+}
+
+type cachedChatList struct {
+	list    *ChatListMessage
+	expires time.Time
+}
+
+type chatListCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	lists map[string]map[chatListPage]cachedChatList
+
+	// When each user's lists, or all lists, were last invalidated. A list loaded before
+	// that is not cached, it may have missed the write.
+	invalidated map[string]time.Time
+	clearedAt   time.Time
+	sweptAt     time.Time
+}
+
+// get returns a copy of the cached page, which the caller is free to modify
+func (c *chatListCache) get(userID string, page chatListPage, now time.Time) (*ChatListMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.lists[userID][page]
+	if !ok || now.After(cached.expires) {
+		return nil, false
+	}
+	return copyChatList(cached.list), true
+}
+
+// put caches a copy of a page loaded from the database since loadedAt
+func (c *chatListCache) put(userID string, page chatListPage, list *ChatListMessage, loadedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !loadedAt.After(c.invalidated[userID]) || !loadedAt.After(c.clearedAt) {
+		return
+	}
+
+	now := time.Now()
+	c.sweep(now)
+	if c.lists[userID] == nil {
+		c.lists[userID] = make(map[chatListPage]cachedChatList)
+	}
+	c.lists[userID][page] = cachedChatList{list: copyChatList(list), expires: now.Add(c.ttl)}
+}
+
+// sweep drops expired lists, and invalidation times no load can still predate, once per TTL
+func (c *chatListCache) sweep(now time.Time) {
+	if now.Sub(c.sweptAt) < c.ttl {
+		return
+	}
+	c.sweptAt = now
+
+	for userID, pages := range c.lists {
+		for page, cached := range pages {
+			if now.After(cached.expires) {
+				delete(pages, page)
+			}
+		}
+		if len(pages) == 0 {
+			delete(c.lists, userID)
+		}
+	}
+	for userID, at := range c.invalidated {
+		if now.Sub(at) > db.QueryTimeout {
+			delete(c.invalidated, userID)
+		}
+	}
+}
+
+func (c *chatListCache) invalidate(userIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, userID := range userIDs {
+		delete(c.lists, userID)
+		c.invalidated[userID] = now
+	}
+}
+
+func (c *chatListCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lists = make(map[string]map[chatListPage]cachedChatList)
+	c.clearedAt = time.Now()
+}
+
+// copyChatList copies what callers modify in place, the online status enrichment sets
+// IsOnline and MemberCount on each chat. Participants are shared and must not be changed.
+func copyChatList(list *ChatListMessage) *ChatListMessage {
+	copied := *list
+	copied.Chats = make([]ChatRoom, len(list.Chats))
+	copy(copied.Chats, list.Chats)
+	for i := range copied.Chats {
+		if last := copied.Chats[i].LastMessage; last != nil {
+			lastCopy := *last
+			copied.Chats[i].LastMessage = &lastCopy
+		}
+	}
+	return &copied
+}
+
+// InvalidateUserChats drops the cached chat lists of the users, for changes to their chats
+// made without ChatService, e.g. group membership changes in a transaction
+func (s *ChatService) InvalidateUserChats(userIDs ...string) {
+	s.chatLists.invalidate(userIDs...)
+}
+
+// invalidateChat drops the cached chat lists of the chat's participants
+func (s *ChatService) invalidateChat(chatID string) {
+	participants, err := s.getChatParticipants(chatID)
+	if err != nil {
+		// Can't tell whose lists show the chat
+		s.chatLists.invalidateAll()
+		return
+	}
+	s.chatLists.invalidate(participants...)
+}
+
+// InvalidateGroupChat drops the cached chat lists of the group chat's participants
+func (s *ChatService) InvalidateGroupChat(groupID string) {
+	var chatID string
+	err := s.DB.QueryRow("SELECT id FROM chat_threads WHERE is_group = 1 AND group_id = ?", groupID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		s.chatLists.invalidateAll()
+		return
+	}
+	s.invalidateChat(chatID)
+}
@@ -0,0 +1,146 @@
+package websocket_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"social-network/pkg/db/dbtest"
+	"social-network/pkg/db/sqlite"
+	"social-network/pkg/sockets/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chatQueries counts the statements that read or write chat threads and their participants
+var chatQueries = dbtest.NewQueryCounter(func(query string) bool {
+	return strings.Contains(query, "chat_threads") || strings.Contains(query, "chat_participants")
+})
+
+// setupChatDB creates a database where alice and bob share a private chat with one message
+func setupChatDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../../db/migrations/sqlite"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	db := chatQueries.Open(dbPath + "?_foreign_keys=on")
+	t.Cleanup(func() { db.Close() })
+
+	for _, name := range []string{"alice", "bob"} {
+		_, err := db.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+			VALUES (?, ?, 'x', ?, 'Test', '2000-01-01', ?, '')`, name, name+"@example.com", name, name)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	sendMessage(t, websocket.NewChatService(db), "alice", "bob", "hello")
+	return db
+}
+
+func sendMessage(t *testing.T, service *websocket.ChatService, senderID, recipientID, content string) {
+	t.Helper()
+
+	_, _, err := service.SaveMessageAndGetIDs(&websocket.ChatMessage{
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Content:     content,
+		MessageType: "text",
+		Timestamp:   time.Now(),
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to save message: %v", err)
+	}
+}
+
+func getChats(t *testing.T, service *websocket.ChatService, userID string) *websocket.ChatListMessage {
+	t.Helper()
+
+	chats, err := service.GetUserChats(context.Background(), userID, websocket.DefaultChatListLimit, 0)
+	if err != nil {
+		t.Fatalf("GetUserChats failed: %v", err)
+	}
+	return chats
+}
+
+func TestGetUserChatsServedFromCache(t *testing.T) {
+	db := setupChatDB(t)
+	service := websocket.NewChatService(db)
+
+	chatQueries.Reset()
+	getChats(t, service, "bob")
+	perLoad := chatQueries.Count()
+	if perLoad == 0 {
+		t.Fatal("expected the first call to query the database")
+	}
+
+	// A status change of a related user asks for the chat list again and again, and
+	// handlers build their own ChatService: none of it goes to the database
+	const calls = 20
+	for i := 0; i < calls; i++ {
+		getChats(t, service, "bob")
+		getChats(t, websocket.NewChatService(db), "bob")
+	}
+	if got := chatQueries.Count(); got != perLoad {
+		t.Fatalf("expected %d chat queries for %d calls, got %d (%d per uncached call)", perLoad, 2*calls+1, got, perLoad)
+	}
+}
+
+func TestGetUserChatsReturnsCopies(t *testing.T) {
+	service := websocket.NewChatService(setupChatDB(t))
+
+	chats := getChats(t, service, "bob")
+	if len(chats.Chats) != 1 {
+		t.Fatalf("expected 1 chat, got %d", len(chats.Chats))
+	}
+	// the hub sets the online status on the list it is given
+	chats.Chats[0].IsOnline = true
+	chats.Chats[0].LastMessage.Content = "changed"
+
+	chats = getChats(t, service, "bob")
+	if chats.Chats[0].IsOnline || chats.Chats[0].LastMessage.Content != "hello" {
+		t.Fatalf("the cached list was modified through a returned copy: %+v", chats.Chats[0])
+	}
+}
+
+func TestGetUserChatsInvalidatedByNewMessage(t *testing.T) {
+	service := websocket.NewChatService(setupChatDB(t))
+
+	getChats(t, service, "alice")
+	getChats(t, service, "bob")
+	sendMessage(t, service, "alice", "bob", "are you there?")
+
+	// Both participants see the new last message, the recipient as unread
+	for _, userID := range []string{"alice", "bob"} {
+		chatQueries.Reset()
+		chats := getChats(t, service, userID)
+		if chatQueries.Count() == 0 {
+			t.Fatalf("%s: expected the chat list to be reloaded after a new message", userID)
+		}
+		if got := chats.Chats[0].LastMessage.Content; got != "are you there?" {
+			t.Fatalf("%s: expected the new last message, got %q", userID, got)
+		}
+	}
+	if chats := getChats(t, service, "bob"); chats.Chats[0].UnreadCount != 2 {
+		t.Fatalf("expected 2 unread messages, got %d", chats.Chats[0].UnreadCount)
+	}
+}
+
+func TestGetUserChatsInvalidatedByLeavingChat(t *testing.T) {
+	service := websocket.NewChatService(setupChatDB(t))
+
+	chats := getChats(t, service, "alice")
+	if err := service.LeaveChat("alice", chats.Chats[0].ID); err != nil {
+		t.Fatalf("LeaveChat failed: %v", err)
+	}
+
+	if chats := getChats(t, service, "alice"); chats.Total != 0 || len(chats.Chats) != 0 {
+		t.Fatalf("expected no chats after leaving, got %d", chats.Total)
+	}
+	if chats := getChats(t, service, "bob"); chats.Total != 1 {
+		t.Fatalf("expected bob to keep the chat, got %d chats", chats.Total)
+	}
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MessageReadReceipt records when a participant read a message
+type MessageReadReceipt struct {
+	UserID string    `json:"user_id"`
+	Name   string    `json:"name"`
+	ReadAt time.Time `json:"read_at"`
+}
+
+// GetMessageReadReceipts returns who has read a message and when, oldest read first.
+// The sender's own read, if any, is left out. Only participants of the message's chat
+// can see them.
+func (s *ChatService) GetMessageReadReceipts(userID, messageID string) ([]MessageReadReceipt, error) {
+	var chatID string
+	err := s.DB.QueryRow("SELECT chat_id FROM messages WHERE id = ?", messageID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	isParticipant, err := s.IsUserChatParticipant(userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotChatParticipant
+	}
+
+	rows, err := s.DB.Query(`
+        SELECT mr.user_id, u.first_name || ' ' || u.last_name, mr.read_at
+        FROM message_reads mr
+        JOIN messages m ON m.id = mr.message_id
+        JOIN users u ON u.id = mr.user_id
+        WHERE mr.message_id = ? AND mr.user_id != m.sender_id
+        ORDER BY mr.read_at ASC, mr.id ASC
+    `, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read receipts: %w", err)
+	}
+	defer rows.Close()
+
+	receipts := []MessageReadReceipt{}
+	for rows.Next() {
+		var receipt MessageReadReceipt
+		var readAt string
+		if err := rows.Scan(&receipt.UserID, &receipt.Name, &readAt); err != nil {
+			return nil, fmt.Errorf("failed to scan read receipt: %w", err)
+		}
+		receipt.ReadAt, err = parseMessageTimestamp(readAt)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, rows.Err()
+}
+
+// groupRecipientCount returns how many participants other than the sender a group chat
+// message goes to, or 0 for private chats where read-by counts aren't shown
+func (s *ChatService) groupRecipientCount(chatID string) (int, error) {
+	var isGroup, participants int
+	err := s.DB.QueryRow(`
+        SELECT ct.is_group, (SELECT COUNT(*) FROM chat_participants cp WHERE cp.chat_id = ct.id)
+        FROM chat_threads ct
+        WHERE ct.id = ?
+    `, chatID).Scan(&isGroup, &participants)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chat participants count: %w", err)
+	}
+	if isGroup == 0 || participants == 0 {
+		return 0, nil
+	}
+	return participants - 1, nil
+}
@@ -0,0 +1,44 @@
+package websocket_test
+
+import (
+	"errors"
+	"social-network/pkg/sockets/websocket"
+	"testing"
+)
+
+func TestGetMessageReadReceiptsForParticipantsOnly(t *testing.T) {
+	db := setupChatDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, date_of_birth, nickname, avatar_path)
+		VALUES ('eve', 'eve@example.com', 'x', 'eve', 'Test', '2000-01-01', 'eve', '')`)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	var messageID string
+	if err := db.QueryRow("SELECT id FROM messages").Scan(&messageID); err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO message_reads (message_id, user_id, read_at)
+		VALUES (?, 'alice', '2026-01-01T10:00:00Z'), (?, 'bob', '2026-01-01T10:05:00Z')`, messageID, messageID)
+	if err != nil {
+		t.Fatalf("Failed to mark message read: %v", err)
+	}
+
+	service := websocket.NewChatService(db)
+
+	// The sender's own read is left out
+	receipts, err := service.GetMessageReadReceipts("alice", messageID)
+	if err != nil {
+		t.Fatalf("GetMessageReadReceipts failed: %v", err)
+	}
+	if len(receipts) != 1 || receipts[0].UserID != "bob" {
+		t.Errorf("expected bob's read receipt, got %+v", receipts)
+	}
+
+	if _, err := service.GetMessageReadReceipts("eve", messageID); !errors.Is(err, websocket.ErrNotChatParticipant) {
+		t.Errorf("expected a non-participant to be refused, got %v", err)
+	}
+	if _, err := service.GetMessageReadReceipts("alice", "999"); !errors.Is(err, websocket.ErrMessageNotFound) {
+		t.Errorf("expected message not found, got %v", err)
+	}
+}
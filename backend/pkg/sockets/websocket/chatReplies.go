@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// replySnippetLength is how many characters of the quoted message are sent with a reply
+const replySnippetLength = 100
+
+var ErrInvalidReply = errors.New("you can only reply to a message of the same chat")
+
+// MessageReply is the preview of the message a reply quotes
+type MessageReply struct {
+	ID         string `json:"id"`
+	SenderID   string `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	Content    string `json:"content"`
+	Deleted    bool   `json:"deleted"`
+}
+
+// checkReplyTarget makes sure a reply points at a live message of the chat it is sent to
+func checkReplyTarget(tx *sql.Tx, chatID int64, replyToID string) error {
+	var targetChatID int64
+	var deletedAt sql.NullString
+	err := tx.QueryRow("SELECT chat_id, deleted_at FROM messages WHERE id = ?", replyToID).Scan(&targetChatID, &deletedAt)
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get replied message: %w", err)
+	}
+	if targetChatID != chatID {
+		return ErrInvalidReply
+	}
+	if deletedAt.Valid {
+		return ErrMessageDeleted
+	}
+	return nil
+}
+
+// getReplyPreviews loads the previews of the given messages, keyed by message ID.
+// A message deleted after it was replied to is previewed as the deleted placeholder.
+func (s *ChatService) getReplyPreviews(messageIDs []string) (map[string]*MessageReply, error) {
+	previews := make(map[string]*MessageReply, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return previews, nil
+	}
+
+	placeholders := make([]string, len(messageIDs))
+	args := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT m.id, m.sender_id, u.first_name || ' ' || u.last_name, `+messageContentColumn+`, m.deleted_at
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		WHERE m.id IN (`+strings.Join(placeholders, ",")+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replied messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reply MessageReply
+		var deletedAt sql.NullString
+		if err := rows.Scan(&reply.ID, &reply.SenderID, &reply.SenderName, &reply.Content, &deletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan replied message: %w", err)
+		}
+		if deletedAt.Valid {
+			reply.Deleted = true
+			reply.Content = DeletedMessageText
+		} else {
+			reply.Content = replySnippet(reply.Content)
+		}
+		previews[reply.ID] = &reply
+	}
+	return previews, rows.Err()
+}
+
+// attachReplyPreviews sets ReplyTo on every message that replies to another one
+func (s *ChatService) attachReplyPreviews(messages []ChatMessage) error {
+	var ids []string
+	for _, msg := range messages {
+		if msg.ReplyToID != nil {
+			ids = append(ids, *msg.ReplyToID)
+		}
+	}
+
+	previews, err := s.getReplyPreviews(ids)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if messages[i].ReplyToID != nil {
+			messages[i].ReplyTo = previews[*messages[i].ReplyToID]
+		}
+	}
+	return nil
+}
+
+// setMessageReplyTo fills in ReplyToID from the messages.reply_to_id column
+func setMessageReplyTo(msg *ChatMessage, replyToID sql.NullString) {
+	if replyToID.Valid {
+		msg.ReplyToID = &replyToID.String
+	}
+}
+
+func replySnippet(content string) string {
+	runes := []rune(content)
+	if len(runes) <= replySnippetLength {
+		return content
+	}
+	return string(runes[:replySnippetLength]) + "…"
+}
@@ -16,7 +16,14 @@ func (h *Hub) NotifyGroupEventCreated(db *sql.DB, eventID, groupID, creatorID, t
 		return
 	}
 
-	rows, err := db.Query("SELECT user_id FROM group_memberships WHERE group_id = ?", groupID)
+	// Members who muted the group's notifications are left out
+	rows, err := db.Query(`
+        SELECT gm.user_id FROM group_memberships gm
+        WHERE gm.group_id = ? AND NOT EXISTS (
+            SELECT 1 FROM group_notification_prefs p
+            WHERE p.group_id = gm.group_id AND p.user_id = gm.user_id AND p.muted = 1
+        )
+    `, groupID)
 	if err != nil {
 		log.Printf("error getting group members: %v", err)
 		return
@@ -79,3 +86,50 @@ func (h *Hub) NotifyGroupEventCreated(db *sql.DB, eventID, groupID, creatorID, t
 		h.SendNotificationToUser(userID, message)
 	}
 }
+
+// NotifyGroupEventChanged tells an event's attendees that it was edited ("group_event_updated")
+// or cancelled ("group_event_cancelled"). The caller collects attendeeIDs, since the responses
+// of a cancelled event are already gone when this runs.
+func (h *Hub) NotifyGroupEventChanged(db *sql.DB, eventID, actorID, title, notifType string, attendeeIDs []string) {
+	var actorName string
+	err := db.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", actorID).Scan(&actorName)
+	if err != nil {
+		log.Printf("error getting actor name: %v", err)
+		return
+	}
+
+	messageText := fmt.Sprintf("%s updated the event: %s", actorName, title)
+	if notifType == "group_event_cancelled" {
+		messageText = fmt.Sprintf("%s cancelled the event: %s", actorName, title)
+	}
+
+	for _, userID := range attendeeIDs {
+		notification := Notification{
+			UserID:   userID,
+			SenderID: actorID,
+			Type:     notifType,
+			RefID:    eventID,
+			IsRead:   false,
+			Message:  messageText,
+		}
+
+		notificationID, err := CreateNotificationAndGetID(db, notification)
+		if err != nil {
+			log.Printf("Error creating %s notification for user %s: %v", notifType, userID, err)
+			continue
+		}
+
+		message := NotificationMessage{
+			ID:           strconv.Itoa(notificationID),
+			SenderID:     actorID,
+			RecipientID:  userID,
+			Type:         notifType,
+			RefID:        eventID,
+			Message:      messageText,
+			Timestamp:    time.Now(),
+			SenderAvatar: GetSenderAvatar(db, actorID, notifType),
+		}
+
+		h.SendNotificationToUser(userID, message)
+	}
+}
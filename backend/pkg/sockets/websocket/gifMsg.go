@@ -8,6 +8,11 @@ import (
 )
 
 func (c *Client) handleGifMessage(data interface{}) {
+	// GIFs are chat messages and share their rate limit
+	if !c.allowChatMessage() {
+		return
+	}
+
 	// convert data to gifMessage type
 	jsonData, _ := json.Marshal(data)
 	var gifMsg ChatMessage
@@ -26,6 +31,17 @@ func (c *Client) handleGifMessage(data interface{}) {
 	// DO NOT set gifMsg.ID here!
 	gifMsg.MessageType = "media"
 
+	if gifMsg.RecipientID != "" {
+		blocked, err := c.hub.chatService.IsBlocked(c.userID, gifMsg.RecipientID)
+		if err != nil {
+			return
+		}
+		if blocked {
+			c.sendGifError(ErrUserBlocked.Error())
+			return
+		}
+	}
+
 	// Get sender information from database
 	var senderName, senderAvatar string
 	err := c.hub.chatService.DB.QueryRow(
@@ -67,7 +83,7 @@ func (c *Client) handleGifMessage(data interface{}) {
 
 	if gifMsg.RecipientID != "" {
 		// private: send to both users
-		c.hub.SendToUser(gifMsg.RecipientID, msgData)
+		c.hub.SendToUserOrQueue(gifMsg.RecipientID, msgData)
 		c.hub.SendToUser(c.userID, msgData)
 	} else if gifMsg.GroupID != "" {
 		// group: send to all group participants (implement as needed)
@@ -2,8 +2,10 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"social-network/pkg/db"
+	"social-network/pkg/models/group"
 	"strconv"
 	"time"
 )
@@ -21,6 +23,10 @@ func (c *Client) handleGroupInvitationMessage(data interface{}) {
 		c.handleNotifyGroupInvitation(inviteMsg)
 	case "notify_response":
 		c.handleNotifyInvitationResponse(inviteMsg)
+	case "accept":
+		c.handleRespondToGroupInvitation(inviteMsg, true)
+	case "decline":
+		c.handleRespondToGroupInvitation(inviteMsg, false)
 	default:
 		log.Printf("Unknown group invitation action: %s", inviteMsg.Action)
 	}
@@ -34,7 +40,7 @@ func (c *Client) handleNotifyGroupInvitation(inviteMsg GroupInvitationMessage) {
 	}
 
 	msgData, _ := json.Marshal(invitationMessage)
-	c.hub.SendToUser(inviteMsg.InviteeID, msgData)
+	c.hub.SendToUserOrQueue(inviteMsg.InviteeID, msgData)
 }
 
 func (c *Client) handleNotifyInvitationResponse(inviteMsg GroupInvitationMessage) {
@@ -45,11 +51,94 @@ func (c *Client) handleNotifyInvitationResponse(inviteMsg GroupInvitationMessage
 	}
 
 	msgData, _ := json.Marshal(wsMessage)
-	c.hub.SendToUser(inviteMsg.InviterID, msgData)
+	c.hub.SendToUserOrQueue(inviteMsg.InviterID, msgData)
+}
+
+// handleRespondToGroupInvitation lets the invitee accept or decline an invitation over the socket.
+// It runs the same transactional logic as the HTTP handlers; the invitee is always the connected user.
+func (c *Client) handleRespondToGroupInvitation(inviteMsg GroupInvitationMessage, accept bool) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[WS] Panic in handleRespondToGroupInvitation for user %s: %v", c.userID, r)
+			}
+		}()
+
+		if inviteMsg.GroupID == "" {
+			c.sendGroupInvitationResult(inviteMsg.GroupID, "", "error", "group_id is required")
+			return
+		}
+
+		var resp *group.InvitationResponse
+		var err error
+		action := "declined"
+		if accept {
+			action = "accepted"
+			resp, err = group.AcceptPendingGroupInvitation(db.DB, inviteMsg.GroupID, c.userID)
+		} else {
+			resp, err = group.DeclinePendingGroupInvitation(db.DB, inviteMsg.GroupID, c.userID)
+		}
+		if err != nil {
+			log.Printf("[WS] Error responding to group invitation for user %s, group %s: %v", c.userID, inviteMsg.GroupID, err)
+			message := "Failed to respond to invitation"
+			if errors.Is(err, group.ErrInvitationNotFound) {
+				message = "Invitation not found"
+			}
+			c.sendGroupInvitationResult(inviteMsg.GroupID, "", "error", message)
+			return
+		}
+
+		// Let the inviter know, exactly like the HTTP flow does
+		go c.hub.NotifyInvitationResponse(resp.InviterID, c.userID, resp.GroupID, resp.GroupName, resp.InviteeName, action)
+
+		if resp.ResolvedRequests > 0 {
+			adminIDs, err := group.GetGroupAdminIDs(db.DB, resp.GroupID)
+			if err != nil {
+				log.Printf("[WS] Failed to get admins for group %s: %v", resp.GroupID, err)
+			} else {
+				go SendGroupRequestResolvedNotification(c.hub, c.userID, resp.InviteeName, adminIDs, resp.GroupID, resp.GroupName)
+			}
+		}
+
+		c.sendGroupInvitationResult(resp.GroupID, resp.GroupName, action, "Group invitation "+action+" successfully")
+
+		// The group chat shows up for all of the user's connections
+		if accept {
+			c.chatService.InvalidateGroupChat(resp.GroupID)
+			if chats, err := c.chatService.userChats(c.userID, DefaultChatListLimit, 0); err == nil {
+				c.hub.updateChatsWithOnlineStatus(chats.Chats, c.userID)
+				c.hub.SendChatListToUser(c.userID, chats)
+			}
+		}
+	}()
+}
+
+// sendGroupInvitationResult reports the outcome of an invitation response to the invitee's connections
+func (c *Client) sendGroupInvitationResult(groupID, groupName, action, message string) {
+	wsMessage := WSMessage{
+		Type: TypeGroupInvitation,
+		Data: GroupInvitationMessage{
+			GroupID:   groupID,
+			GroupName: groupName,
+			InviteeID: c.userID,
+			Action:    action,
+			Message:   message,
+			Timestamp: time.Now(),
+		},
+		Timestamp: time.Now(),
+	}
+
+	msgData, _ := json.Marshal(wsMessage)
+	c.hub.SendToUser(c.userID, msgData)
 }
 
 // ----------------- http function ---------------------
 func (h *Hub) NotifyGroupInvitation(inviterID, inviteeID, groupID, groupName, inviterName string) {
+	// The detailed invitation message below is skipped along with the notification
+	if groupMutedFor(groupID, inviteeID) || !notificationEnabled(db.DB, inviteeID, "group_invitation") {
+		return
+	}
+
 	// Create notification in database and get the real ID
 	notification := Notification{
 		UserID:   inviteeID,
@@ -102,10 +191,14 @@ func (h *Hub) NotifyGroupInvitation(inviterID, inviteeID, groupID, groupName, in
 	}
 
 	msgData, _ := json.Marshal(wsMessage)
-	h.SendToUser(inviteeID, msgData)
+	h.SendToUserOrQueue(inviteeID, msgData)
 }
 
 func (h *Hub) NotifyInvitationResponse(inviterID, inviteeID, groupID, groupName, inviteeName, action string) {
+	if !notificationEnabled(db.DB, inviterID, "group_invitation_response") {
+		return
+	}
+
 	var message string
 	if action == "accepted" {
 		message = inviteeName + " accepted your invitation to " + groupName
@@ -165,11 +258,26 @@ func (h *Hub) NotifyInvitationResponse(inviterID, inviteeID, groupID, groupName,
 	}
 
 	msgData, _ := json.Marshal(wsMessage)
-	h.SendToUser(inviterID, msgData)
+	h.SendToUserOrQueue(inviterID, msgData)
+}
+
+// groupMutedFor reports whether the user muted the group's notifications. Errors are logged
+// and treated as not muted so a failing lookup never swallows a notification.
+func groupMutedFor(groupID, userID string) bool {
+	muted, err := group.IsGroupMuted(db.DB, groupID, userID)
+	if err != nil {
+		log.Printf("Error checking group notification prefs for user %s: %v", userID, err)
+		return false
+	}
+	return muted
 }
 
 // SendGroupJoinRequestNotification sends a notification to the group admin when someone requests to join
 func SendGroupJoinRequestNotification(hub *Hub, requesterID, requesterName, adminID, groupID, groupName string) error {
+	if groupMutedFor(groupID, adminID) {
+		return nil
+	}
+
 	// Create notification in database and get the real ID
 	notification := Notification{
 		UserID:   adminID,
@@ -246,6 +354,46 @@ func SendGroupRequestResponseNotification(hub *Hub, requesterID, groupID, groupN
 	return nil
 }
 
+// SendGroupRequestResolvedNotification tells the group admins that a pending join request
+// was resolved because the requester joined through another path
+func SendGroupRequestResolvedNotification(hub *Hub, requesterID, requesterName string, adminIDs []string, groupID, groupName string) {
+	message := requesterName + " joined '" + groupName + "', their pending join request has been resolved"
+
+	for _, adminID := range adminIDs {
+		if adminID == requesterID {
+			continue
+		}
+
+		notification := Notification{
+			UserID:   adminID,
+			SenderID: requesterID,
+			Type:     "group_request_resolved",
+			RefID:    groupID,
+			IsRead:   false,
+			Message:  message,
+		}
+
+		notificationID, err := CreateNotificationAndGetID(db.DB, notification)
+		if err != nil {
+			log.Printf("Error creating group request resolved notification: %v", err)
+			continue
+		}
+
+		notificationMsg := NotificationMessage{
+			ID:           strconv.Itoa(notificationID),
+			SenderID:     requesterID,
+			RecipientID:  adminID,
+			Type:         "group_request_resolved",
+			RefID:        groupID,
+			Message:      message,
+			Timestamp:    time.Now(),
+			SenderAvatar: GetSenderAvatar(db.DB, requesterID, "group_request_resolved"),
+		}
+
+		hub.SendNotificationToUser(adminID, notificationMsg)
+	}
+}
+
 // SendGroupKickNotification notifies a user that they have been removed from a group
 func SendGroupKickNotification(hub *Hub, kickedUserID, groupID, senderID string) error {
 	var groupName string
@@ -284,3 +432,75 @@ func SendGroupKickNotification(hub *Hub, kickedUserID, groupID, senderID string)
 	go hub.SendNotificationToUser(kickedUserID, notificationMsg)
 	return nil
 }
+
+// SendGroupDeletedNotification tells the former members that the creator deleted the group
+func SendGroupDeletedNotification(hub *Hub, memberIDs []string, groupID, groupName, creatorID string) {
+	message := "'" + groupName + "' has been deleted by its creator"
+
+	for _, memberID := range memberIDs {
+		if memberID == creatorID {
+			continue
+		}
+
+		notification := Notification{
+			UserID:   memberID,
+			SenderID: creatorID,
+			Type:     "group_deleted",
+			RefID:    groupID,
+			IsRead:   false,
+			Message:  message,
+		}
+
+		notificationID, err := CreateNotificationAndGetID(db.DB, notification)
+		if err != nil {
+			log.Printf("Error creating group deleted notification: %v", err)
+			continue
+		}
+
+		notificationMsg := NotificationMessage{
+			ID:           strconv.Itoa(notificationID),
+			SenderID:     creatorID,
+			RecipientID:  memberID,
+			Type:         "group_deleted",
+			RefID:        groupID,
+			Message:      message,
+			Timestamp:    time.Now(),
+			SenderAvatar: GetSenderAvatar(db.DB, creatorID, "group_deleted"),
+		}
+
+		hub.SendNotificationToUser(memberID, notificationMsg)
+	}
+}
+
+// SendGroupRequestCancelledNotification clears the admins' "request to join" notification after the
+// requester withdrew it, and tells their clients to drop the request from the pending list
+func SendGroupRequestCancelledNotification(hub *Hub, requesterID string, adminIDs []string, groupID string) {
+	_, err := db.DB.Exec(
+		"DELETE FROM notifications WHERE sender_id = ? AND type = 'group_join_request' AND ref_id = ?",
+		requesterID, groupID,
+	)
+	if err != nil {
+		log.Printf("Error removing group join request notification: %v", err)
+	}
+
+	for _, adminID := range adminIDs {
+		if adminID == requesterID {
+			continue
+		}
+
+		if groupMutedFor(groupID, adminID) {
+			continue
+		}
+
+		// Not stored: the request no longer exists, so there is nothing for the admins to act on
+		notificationMsg := NotificationMessage{
+			SenderID:    requesterID,
+			RecipientID: adminID,
+			Type:        "group_request_cancelled",
+			RefID:       groupID,
+			Timestamp:   time.Now(),
+		}
+
+		hub.SendNotificationToUser(adminID, notificationMsg)
+	}
+}
@@ -3,9 +3,11 @@ package websocket
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"social-network/pkg/logging"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -37,22 +39,46 @@ type Hub struct {
 	// Typing staus tracker
 	typingUsers map[string]map[string]*TypingMessage // map[chatID]map[userID]*TypingMessage
 
+	// Clears a typing entry once the client stops refreshing it
+	typingTimers map[string]map[string]*time.Timer // map[chatID]map[userID]*time.Timer
+
 	// User status tracker
 	userStatus map[string]*UserStatusMessage // map[userID]*UserStatusMessage
 
 	// database service
 	chatService *ChatService
 
+	// Carries SendToUser and Broadcast messages to the instance holding the connections
+	pubsub PubSub
+
+	// Per-user token buckets throttling chat messages
+	chatLimiter *chatRateLimiter
+
+	// Also resend related users' chat lists on status changes, see SetStatusChatListRefresh
+	statusChatListRefresh atomic.Bool
+
 	// Mutex to protect the server
 	mutex sync.RWMutex
 
 	// Channel to stop the hub
-	stop chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// Running writePumps, Stop waits for them to send their close frame
+	writers sync.WaitGroup
+}
+
+// TypingTimeout is how long a typing indicator lasts without a new typing event from the client
+const TypingTimeout = 8 * time.Second
+
+// hubLog is the logger of the hub's own work
+func hubLog() *slog.Logger {
+	return logging.Handler("hub")
 }
 
 // Function to create a new Hub with better channel sizes
 func NewHub(db *sql.DB) *Hub {
-	return &Hub{
+	h := &Hub{
 		clients:         make(map[*Client]bool),
 		register:        make(chan *Client, 1000), // Increased buffer size
 		unregister:      make(chan *Client, 1000), // Increased buffer size
@@ -60,9 +86,14 @@ func NewHub(db *sql.DB) *Hub {
 		chatService:     NewChatService(db),
 		userConnections: make(map[string][]*Client),
 		typingUsers:     make(map[string]map[string]*TypingMessage),
+		typingTimers:    make(map[string]map[string]*time.Timer),
 		userStatus:      make(map[string]*UserStatusMessage),
+		chatLimiter:     &chatRateLimiter{limit: DefaultChatRateLimit},
 		stop:            make(chan struct{}),
 	}
+	// The in-memory PubSub can't fail to start
+	h.SetPubSub(NewLocalPubSub())
+	return h
 }
 
 // main function to run the Hub ongoing loop
@@ -79,7 +110,7 @@ func (h *Hub) Run() {
 			h.handleBroadcast(message)
 
 		case <-h.stop:
-			log.Println("[WS] Hub stopping...")
+			hubLog().Info("Hub stopping")
 			return
 		}
 	}
@@ -89,28 +120,36 @@ func (h *Hub) handleRegister(client *Client) {
 	h.mutex.Lock()
 	h.clients[client] = true
 	h.addUserConnectionUnsafe(client)
+	firstConnection := len(h.userConnections[client.userID]) == 1
 	h.mutex.Unlock()
 
+	// Messages for the user published by any instance now reach this one
+	if firstConnection {
+		h.subscribeUser(client.userID)
+	}
+	// Then what was queued while the user was offline
+	go h.flushQueuedMessages(client)
+
 	h.updateUserStatus(client.userID, true)
 
-	log.Printf("[WS] Client registered: %s (Total clients: %d)", client.userID, len(h.clients))
+	hubLog().Info("Client registered", logging.KeyUserID, client.userID, "clients", len(h.clients))
 
 	// Send chat list with online status to the newly connected user (non-blocking)
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WS] Panic in sending chat list: %v", r)
+				hubLog().Error("Panic in sending chat list", logging.KeyUserID, client.userID, "panic", r)
 			}
 		}()
 
-		chats, err := h.chatService.GetUserChats(client.userID)
+		chats, err := h.chatService.userChats(client.userID, DefaultChatListLimit, 0)
 		if err != nil {
-			log.Printf("[WS] Error getting user chats for %s: %v", client.userID, err)
+			hubLog().Error("Failed to get user chats", logging.KeyUserID, client.userID, "error", err)
 			return
 		}
 
 		// Update online status for each chat
-		h.updateChatsWithOnlineStatus(chats, client.userID)
+		h.updateChatsWithOnlineStatus(chats.Chats, client.userID)
 
 		h.SendChatListToUser(client.userID, chats)
 	}()
@@ -119,7 +158,7 @@ func (h *Hub) handleRegister(client *Client) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WS] Panic in broadcasting user status: %v", r)
+				hubLog().Error("Panic in broadcasting user status", logging.KeyUserID, client.userID, "panic", r)
 			}
 		}()
 		h.broadcastUserStatus(client.userID, true)
@@ -136,12 +175,17 @@ func (h *Hub) updateChatsWithOnlineStatus(chats []ChatRoom, currentUserID string
 		chats[i].IsOnline = false
 
 		if chats[i].Type == "private" {
-			// For private chats, check if the other participant is online
+			// For private chats, check if the other participant is online, or else since
+			// when they are offline; without a status here the stored time is kept
 			for _, participantID := range chats[i].Participants {
 				if participantID != currentUserID {
 					if status, exists := h.userStatus[participantID]; exists && status.IsOnline {
 						chats[i].IsOnline = true
+						chats[i].LastSeen = nil
 						break
+					} else if exists && !status.LastSeen.IsZero() {
+						lastSeen := status.LastSeen
+						chats[i].LastSeen = &lastSeen
 					}
 				}
 			}
@@ -162,6 +206,15 @@ func (h *Hub) updateChatsWithOnlineStatus(chats []ChatRoom, currentUserID string
 }
 
 func (h *Hub) handleUnregister(client *Client) {
+	lastConnection := false
+	// Deferred first so it runs after the unlock below
+	defer func() {
+		if lastConnection {
+			h.unsubscribeUser(client.userID)
+			h.chatLimiter.forget(client.userID, time.Now())
+		}
+	}()
+
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
@@ -178,15 +231,25 @@ func (h *Hub) handleUnregister(client *Client) {
 			close(client.send)
 		}
 
+		// A client that drops mid-typing never sends the stop event itself
+		if cleared := h.clearUserTypingUnsafe(client.userID); len(cleared) > 0 {
+			go func() {
+				for _, typing := range cleared {
+					h.HandleTyping(typing.ChatID, typing.UserID, typing.NickName, false)
+				}
+			}()
+		}
+
 		// Check if the user has any other connections
 		if len(h.userConnections[client.userID]) == 0 {
+			lastConnection = true
 			go func() {
 				h.updateUserStatus(client.userID, false)
 				h.broadcastUserStatus(client.userID, false)
 			}()
 		}
 
-		log.Printf("[WS] Client unregistered: %s (Total clients: %d)", client.userID, len(h.clients))
+		hubLog().Info("Client unregistered", logging.KeyUserID, client.userID, "clients", len(h.clients))
 	}
 }
 
@@ -205,12 +268,12 @@ func (h *Hub) handleBroadcast(message []byte) {
 			// Message sent successfully
 		default:
 			// Channel is full or closed, unregister client
-			log.Printf("[WS] Failed to send broadcast message - channel blocked for user: %s", client.userID)
+			hubLog().Warn("Failed to send broadcast message, channel blocked", logging.KeyUserID, client.userID)
 			go func(c *Client) {
 				select {
 				case h.unregister <- c:
 				default:
-					log.Printf("[WS] Failed to unregister client %s - unregister channel full", c.userID)
+					hubLog().Warn("Failed to unregister client, unregister channel full", logging.KeyUserID, c.userID)
 				}
 			}(client)
 		}
@@ -248,21 +311,30 @@ func (h *Hub) removeUserConnection(client *Client) {
 }
 
 func (h *Hub) updateUserStatus(userID string, isOnline bool) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	now := time.Now()
 
+	h.mutex.Lock()
 	h.userStatus[userID] = &UserStatusMessage{
 		UserID:   userID,
 		IsOnline: isOnline,
-		LastSeen: time.Now(),
+		LastSeen: now,
 	}
+	h.mutex.Unlock()
+
+	// Stored so offline users still have a last seen time after a restart; off the
+	// hub's loop as it is a database write
+	go func() {
+		if err := h.chatService.saveLastSeen(userID, now); err != nil {
+			hubLog().Error("Failed to save last seen", logging.KeyUserID, userID, "error", err)
+		}
+	}()
 }
 
 func (h *Hub) broadcastUserStatus(userID string, isOnline bool) {
 	// Get users who should be notified about this user's status change
 	relatedUsers, err := h.chatService.getRelatedUsers(userID)
 	if err != nil {
-		log.Printf("[WS] Error getting related users for status broadcast: %v", err)
+		hubLog().Error("Failed to get related users for status broadcast", logging.KeyUserID, userID, "error", err)
 		return
 	}
 
@@ -278,19 +350,24 @@ func (h *Hub) broadcastUserStatus(userID string, isOnline bool) {
 
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("[WS] Error marshaling user status message: %v", err)
+		hubLog().Error("Failed to marshal user status message", logging.KeyUserID, userID, "error", err)
 		return
 	}
 
-	// Send status update to related users (non-blocking)
+	// Send status update to related users (non-blocking). Clients apply it to the chat
+	// list they have and ask for a fresh one with a chat list request when they need it.
 	go h.SendToUsers(relatedUsers, data)
 
+	if !h.statusChatListRefresh.Load() {
+		return
+	}
+
 	// Send updated chat list to each related user to show updated online status
 	for _, relatedUserID := range relatedUsers {
 		go func(targetUserID string) {
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("[WS] Panic in sending updated chat list to %s: %v", targetUserID, r)
+					hubLog().Error("Panic in sending updated chat list", logging.KeyUserID, targetUserID, "panic", r)
 				}
 			}()
 
@@ -298,14 +375,14 @@ func (h *Hub) broadcastUserStatus(userID string, isOnline bool) {
 			time.Sleep(100 * time.Millisecond)
 
 			// Get updated chat list for the related user
-			chats, err := h.chatService.GetUserChats(targetUserID)
+			chats, err := h.chatService.userChats(targetUserID, DefaultChatListLimit, 0)
 			if err != nil {
-				log.Printf("[WS] Error getting chats for user %s: %v", targetUserID, err)
+				hubLog().Error("Failed to get user chats", logging.KeyUserID, targetUserID, "error", err)
 				return
 			}
 
 			// Update online status for each chat
-			h.updateChatsWithOnlineStatus(chats, targetUserID)
+			h.updateChatsWithOnlineStatus(chats.Chats, targetUserID)
 
 			// Send updated chat list
 			h.SendChatListToUser(targetUserID, chats)
@@ -313,19 +390,36 @@ func (h *Hub) broadcastUserStatus(userID string, isOnline bool) {
 	}
 }
 
-// Send message to a specific user (non-blocking)
+// SetStatusChatListRefresh restores the old presence behaviour: on every status change each
+// related user is also sent their whole chat list again, rebuilt from the database. Only
+// needed for clients that don't apply user_status_update messages themselves.
+func (h *Hub) SetStatusChatListRefresh(enabled bool) {
+	h.statusChatListRefresh.Store(enabled)
+}
+
+// Send message to a specific user (non-blocking), wherever the user is connected
 func (h *Hub) SendToUser(userID string, message []byte) {
+	if _, err := h.pubsub.Publish(userChannel(userID), message); err != nil {
+		hubLog().Warn("Failed to publish message, delivering locally only", logging.KeyUserID, userID, "error", err)
+		h.deliverToUser(userID, message)
+	}
+}
+
+// deliverToUser sends the message to the user's connections on this instance and
+// returns how many of them took it
+func (h *Hub) deliverToUser(userID string, message []byte) int {
 	h.mutex.RLock()
 	connections := make([]*Client, len(h.userConnections[userID]))
 	copy(connections, h.userConnections[userID])
 	h.mutex.RUnlock()
 
 	if len(connections) == 0 {
-		return
+		return 0
 	}
 
-	log.Printf("[WS] Sending message to user: %s", userID)
+	hubLog().Debug("Sending message to user", logging.KeyUserID, userID, "connections", len(connections))
 
+	delivered := 0
 	for _, client := range connections {
 		// Check if client is still registered before sending
 		h.mutex.RLock()
@@ -339,18 +433,20 @@ func (h *Hub) SendToUser(userID string, message []byte) {
 		select {
 		case client.send <- message:
 			// Message sent successfully
+			delivered++
 		default:
-			log.Printf("[WS] Failed to send message - channel blocked for user: %s", userID)
+			hubLog().Warn("Failed to send message, channel blocked", logging.KeyUserID, userID)
 			// Don't block here, just skip this client
 			go func(c *Client) {
 				select {
 				case h.unregister <- c:
 				default:
-					log.Printf("[WS] Failed to unregister client %s - unregister channel full", c.userID)
+					hubLog().Warn("Failed to unregister client, unregister channel full", logging.KeyUserID, c.userID)
 				}
 			}(client)
 		}
 	}
+	return delivered
 }
 
 func (h *Hub) SendToUsers(userIDs []string, message []byte) {
@@ -360,8 +456,6 @@ func (h *Hub) SendToUsers(userIDs []string, message []byte) {
 }
 
 func (h *Hub) HandleTyping(chatID, userID, nickName string, isTyping bool) {
-	log.Printf("[WS] HandleTyping: user=%s, chat=%s, isTyping=%v", userID, chatID, isTyping)
-
 	// Create the typing message that we'll broadcast
 	typingMessage := TypingMessage{
 		UserID:   userID,
@@ -381,15 +475,13 @@ func (h *Hub) HandleTyping(chatID, userID, nickName string, isTyping bool) {
 
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("[WS] Error marshaling typing message: %v", err)
+		hubLog().Error("Failed to marshal typing message", "chat_id", chatID, "error", err)
 		return
 	}
 
-	log.Printf("[WS] Sending typing data: %s", string(data))
-
 	participants, err := h.chatService.getChatParticipants(chatID)
 	if err != nil {
-		log.Printf("[WS] Error getting chat participants: %v", err)
+		hubLog().Error("Failed to get chat participants", "chat_id", chatID, "error", err)
 		return
 	}
 
@@ -406,14 +498,67 @@ func (h *Hub) HandleTyping(chatID, userID, nickName string, isTyping bool) {
 
 	if isTyping {
 		h.typingUsers[chatID][userID] = &typingMessage
-		log.Printf("[WS] User %s started typing in chat %s", userID, chatID)
+		h.resetTypingTimerUnsafe(chatID, userID, &typingMessage)
+		hubLog().Debug("User started typing", logging.KeyUserID, userID, "chat_id", chatID)
 	} else {
 		delete(h.typingUsers[chatID], userID)
 		if len(h.typingUsers[chatID]) == 0 {
 			delete(h.typingUsers, chatID)
 		}
-		log.Printf("[WS] User %s stopped typing in chat %s", userID, chatID)
+		h.stopTypingTimerUnsafe(chatID, userID)
+		hubLog().Debug("User stopped typing", logging.KeyUserID, userID, "chat_id", chatID)
+	}
+}
+
+// resetTypingTimerUnsafe (re)starts the timer that ends the typing entry after TypingTimeout,
+// every new typing event from the client pushes the expiry back
+func (h *Hub) resetTypingTimerUnsafe(chatID, userID string, typing *TypingMessage) {
+	h.stopTypingTimerUnsafe(chatID, userID)
+
+	if h.typingTimers[chatID] == nil {
+		h.typingTimers[chatID] = make(map[string]*time.Timer)
+	}
+	h.typingTimers[chatID][userID] = time.AfterFunc(TypingTimeout, func() {
+		h.mutex.RLock()
+		current := h.typingUsers[chatID][userID]
+		h.mutex.RUnlock()
+
+		// A newer typing event or an explicit stop already replaced this entry
+		if current != typing {
+			return
+		}
+		hubLog().Debug("Typing expired", logging.KeyUserID, userID, "chat_id", chatID)
+		h.HandleTyping(chatID, userID, typing.NickName, false)
+	})
+}
+
+func (h *Hub) stopTypingTimerUnsafe(chatID, userID string) {
+	if timer, ok := h.typingTimers[chatID][userID]; ok {
+		timer.Stop()
+		delete(h.typingTimers[chatID], userID)
+		if len(h.typingTimers[chatID]) == 0 {
+			delete(h.typingTimers, chatID)
+		}
+	}
+}
+
+// clearUserTypingUnsafe ends every typing entry of the user and returns them
+// so the stop events can be broadcast once the lock is released
+func (h *Hub) clearUserTypingUnsafe(userID string) []TypingMessage {
+	var cleared []TypingMessage
+	for chatID, users := range h.typingUsers {
+		typing, ok := users[userID]
+		if !ok {
+			continue
+		}
+		cleared = append(cleared, *typing)
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(h.typingUsers, chatID)
+		}
+		h.stopTypingTimerUnsafe(chatID, userID)
 	}
+	return cleared
 }
 
 func (h *Hub) GetOnlineUsers(requestingUserID string) []string {
@@ -425,7 +570,7 @@ func (h *Hub) GetOnlineUsers(requestingUserID string) []string {
 	// Get the list of users that the requesting user follows or is followed by
 	relatedUsers, err := h.chatService.getRelatedUsers(requestingUserID)
 	if err != nil {
-		log.Printf("[WS] Error getting related users for %s: %v", requestingUserID, err)
+		hubLog().Error("Failed to get related users", logging.KeyUserID, requestingUserID, "error", err)
 		return onlineUsers
 	}
 
@@ -445,7 +590,43 @@ func (h *Hub) GetOnlineUsers(requestingUserID string) []string {
 	return onlineUsers
 }
 
-// Stop the hub gracefully
+// Stop the hub gracefully: every connection is sent a close frame saying the server is
+// going away, so clients reconnect to another instance or once it is back instead of
+// treating it as a network error. Stop returns once the close frames are written, or
+// after writeWait.
 func (h *Hub) Stop() {
-	close(h.stop)
+	h.stopOnce.Do(func() {
+		close(h.stop)
+
+		h.mutex.Lock()
+		closing := len(h.clients)
+		for client := range h.clients {
+			delete(h.clients, client)
+			h.removeUserConnectionUnsafe(client)
+			client.closeMessage = shutdownCloseMessage()
+			close(client.send) // writePump sends the close frame and exits
+		}
+		h.mutex.Unlock()
+		hubLog().Info("Hub stopping, closing connections", "connections", closing)
+
+		done := make(chan struct{})
+		go func() {
+			h.writers.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(writeWait):
+			hubLog().Warn("Timed out waiting for connections to close")
+		}
+
+		if err := h.pubsub.Close(); err != nil {
+			hubLog().Error("Failed to close pub/sub", "error", err)
+		}
+	})
+}
+
+// shutdownCloseMessage is the close frame sent to clients when the server shuts down
+func shutdownCloseMessage() []byte {
+	return websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
 }
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// NotificationTypes are the stored notification types a user can turn off
+var NotificationTypes = []string{
+	"follow_request",
+	"follow_success",
+	"follow",
+	"follow_accepted",
+	"follow_rejected",
+	"unfollow",
+	"follower_removed",
+	"group_invitation",
+	"group_invitation_response",
+	"group_event_created",
+	"group_event_updated",
+	"group_event_cancelled",
+	"group_join_request",
+	"group_request_approved",
+	"group_request_declined",
+	"group_request_resolved",
+	"group_kick",
+	"group_deleted",
+	"content_report",
+	"post_mention",
+	"post_comment",
+	"message",
+}
+
+// ErrUnknownNotificationType is returned when a preference names a type that doesn't exist
+var ErrUnknownNotificationType = errors.New("unknown notification type")
+
+// GetNotificationPrefs returns whether each notification type is enabled for the user.
+// Types the user never changed are enabled.
+func GetNotificationPrefs(db *sql.DB, userID string) (map[string]bool, error) {
+	prefs := make(map[string]bool, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		prefs[t] = true
+	}
+
+	rows, err := db.Query("SELECT type, enabled FROM notification_prefs WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notifType string
+		var enabled int
+		if err := rows.Scan(&notifType, &enabled); err != nil {
+			return nil, err
+		}
+		if _, known := prefs[notifType]; known {
+			prefs[notifType] = enabled == 1
+		}
+	}
+
+	return prefs, rows.Err()
+}
+
+// SetNotificationPrefs saves the given types as enabled or disabled; types not included are left unchanged
+func SetNotificationPrefs(db *sql.DB, userID string, prefs map[string]bool) error {
+	for notifType := range prefs {
+		if !isNotificationType(notifType) {
+			return ErrUnknownNotificationType
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for notifType, enabled := range prefs {
+		_, err := tx.Exec(`
+            INSERT INTO notification_prefs (user_id, type, enabled, updated_at)
+            VALUES (?, ?, ?, datetime('now'))
+            ON CONFLICT(user_id, type) DO UPDATE SET
+                enabled = excluded.enabled,
+                updated_at = excluded.updated_at
+        `, userID, notifType, enabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// notificationEnabled reports whether the user wants notifications of this type. Errors are
+// logged and treated as enabled so a failing lookup never swallows a notification.
+func notificationEnabled(db *sql.DB, userID, notifType string) bool {
+	var enabled int
+	err := db.QueryRow(
+		"SELECT enabled FROM notification_prefs WHERE user_id = ? AND type = ?",
+		userID, notifType,
+	).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		log.Printf("Error checking notification prefs for user %s: %v", userID, err)
+		return true
+	}
+	return enabled == 1
+}
+
+func isNotificationType(notifType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notifType {
+			return true
+		}
+	}
+	return false
+}
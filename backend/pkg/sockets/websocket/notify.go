@@ -3,6 +3,7 @@ package websocket
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
@@ -81,8 +82,13 @@ func (c *Client) handleNotificationMessage(data interface{}) {
 	c.hub.SendToUser(c.userID, ackData)
 }
 
-// New function that returns the inserted ID
+// New function that returns the inserted ID.
+// Nothing is stored and 0 is returned when the recipient turned this notification type off.
 func CreateNotificationAndGetID(db *sql.DB, notification Notification) (int, error) {
+	if !notificationEnabled(db, notification.UserID, notification.Type) {
+		return 0, nil
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return 0, err
@@ -117,17 +123,62 @@ func CreateNotification(db *sql.DB, notification Notification) error {
 	return err
 }
 
-func GetNotificationsByUserID(db *sql.DB, userID string) ([]NotificationMessage, error) {
+// GetNotificationsByUserID returns a page of the user's notifications, newest first
+func GetNotificationsByUserID(db *sql.DB, userID string, limit, offset int) ([]NotificationMessage, error) {
 	query := `
 		SELECT id, user_id, COALESCE(sender_id, ''), type, ref_id, is_read, created_at, message
 		FROM notifications
 		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(db, rows)
+}
+
+// CountNotifications returns how many notifications the user has in total and how many are unread
+func CountNotifications(db *sql.DB, userID string) (total, unread int, err error) {
+	err = db.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(CASE WHEN is_read = 0 THEN 1 ELSE 0 END), 0) FROM notifications WHERE user_id = ?",
+		userID,
+	).Scan(&total, &unread)
+	return total, unread, err
+}
+
+// GetUnreadNotificationsByUserID returns only the user's unread notifications, newest first
+func GetUnreadNotificationsByUserID(db *sql.DB, userID string) ([]NotificationMessage, error) {
+	query := `
+		SELECT id, user_id, COALESCE(sender_id, ''), type, ref_id, is_read, created_at, message
+		FROM notifications
+		WHERE user_id = ? AND is_read = 0
 		ORDER BY created_at DESC
 	`
 	rows, err := db.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
+	return scanNotifications(db, rows)
+}
+
+// GetNotificationsSince returns the user's notifications created after since, newest first
+func GetNotificationsSince(db *sql.DB, userID string, since time.Time) ([]NotificationMessage, error) {
+	query := `
+		SELECT id, user_id, COALESCE(sender_id, ''), type, ref_id, is_read, created_at, message
+		FROM notifications
+		WHERE user_id = ? AND datetime(created_at) > datetime(?)
+		ORDER BY created_at DESC
+	`
+	rows, err := db.Query(query, userID, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(db, rows)
+}
+
+func scanNotifications(db *sql.DB, rows *sql.Rows) ([]NotificationMessage, error) {
 	defer rows.Close()
 
 	var notifications []NotificationMessage
@@ -163,6 +214,44 @@ func MarkAsRead(db *sql.DB, notificationID int) error {
 	return err
 }
 
+// ErrNotificationNotFound is returned when the notification doesn't exist or belongs to another user
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// DeleteNotification removes one of the user's notifications
+func DeleteNotification(db *sql.DB, notificationID int, userID string) error {
+	result, err := db.Exec(`DELETE FROM notifications WHERE id = ? AND user_id = ?`, notificationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// ClearReadNotifications removes all of the user's read notifications and returns how many were removed
+func ClearReadNotifications(db *sql.DB, userID string) (int64, error) {
+	result, err := db.Exec(`DELETE FROM notifications WHERE user_id = ? AND is_read = 1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user as read and returns how many changed
+func MarkAllNotificationsRead(db *sql.DB, userID string) (int64, error) {
+	result, err := db.Exec(`UPDATE notifications SET is_read = 1 WHERE user_id = ? AND is_read = 0`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // Remove the fake ID generator - we don't need this anymore
 // func GenerateNotificationID() string {
 //     return "notif-" + generateMessageID()
@@ -0,0 +1,153 @@
+package websocket
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Messages that must not be lost (notifications, chat messages, invitations) are queued in
+// undelivered_messages when their recipient has no connection on any instance, and sent to
+// the recipient's next connection. Typing, presence and acks are only sent live.
+const (
+	// QueueRetention is how long a queued message waits for its recipient
+	QueueRetention = 7 * 24 * time.Hour
+
+	// maxQueuedPerUser keeps the flush within a client's send buffer; the oldest go first
+	maxQueuedPerUser = 200
+)
+
+// SendToUserOrQueue sends the message like SendToUser, or queues it when the user is offline
+func (h *Hub) SendToUserOrQueue(userID string, message []byte) {
+	receivers, err := h.pubsub.Publish(userChannel(userID), message)
+	if err != nil {
+		log.Printf("[WS] Failed to publish message for user %s, delivering locally only: %v", userID, err)
+		receivers = h.deliverToUser(userID, message)
+	}
+	if receivers == 0 {
+		h.queueMessage(userID, message)
+	}
+}
+
+// SendToUsersOrQueue is SendToUserOrQueue for several users
+func (h *Hub) SendToUsersOrQueue(userIDs []string, message []byte) {
+	for _, userID := range userIDs {
+		go h.SendToUserOrQueue(userID, message)
+	}
+}
+
+func (h *Hub) queueMessage(userID string, message []byte) {
+	db := h.chatService.DB
+	if _, err := db.Exec("INSERT INTO undelivered_messages (user_id, payload) VALUES (?, ?)", userID, string(message)); err != nil {
+		log.Printf("[WS] Failed to queue message for offline user %s: %v", userID, err)
+		return
+	}
+
+	_, err := db.Exec(`
+        DELETE FROM undelivered_messages
+        WHERE user_id = ? AND id NOT IN (
+            SELECT id FROM undelivered_messages WHERE user_id = ? ORDER BY id DESC LIMIT ?
+        )
+    `, userID, userID, maxQueuedPerUser)
+	if err != nil {
+		log.Printf("[WS] Failed to trim message queue of user %s: %v", userID, err)
+	}
+}
+
+// flushQueuedMessages sends the messages queued while the user was offline to the newly
+// connected client, oldest first. Messages are claimed by deleting them, so a user
+// connecting on two instances at once gets each message once; what doesn't fit in the
+// client's send buffer is queued again.
+func (h *Hub) flushQueuedMessages(client *Client) {
+	db := h.chatService.DB
+	cutoff := time.Now().Add(-QueueRetention).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec("DELETE FROM undelivered_messages WHERE created_at < ?", cutoff); err != nil {
+		log.Printf("[WS] Failed to drop expired queued messages: %v", err)
+	}
+
+	rows, err := db.Query("DELETE FROM undelivered_messages WHERE user_id = ? RETURNING id, payload", client.userID)
+	if err != nil {
+		log.Printf("[WS] Failed to load queued messages of user %s: %v", client.userID, err)
+		return
+	}
+	type queued struct {
+		id      int64
+		payload string
+	}
+	var messages []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.payload); err != nil {
+			log.Printf("[WS] Failed to read queued message of user %s: %v", client.userID, err)
+			continue
+		}
+		messages = append(messages, q)
+	}
+	rows.Close()
+	if len(messages) == 0 {
+		return
+	}
+	// RETURNING gives no order guarantee
+	sort.Slice(messages, func(i, j int) bool { return messages[i].id < messages[j].id })
+
+	// The read lock keeps handleUnregister from closing the send channel meanwhile
+	sent := 0
+	h.mutex.RLock()
+	if h.clients[client] {
+	send:
+		for _, q := range messages {
+			select {
+			case client.send <- []byte(q.payload):
+				sent++
+			default:
+				break send
+			}
+		}
+	}
+	h.mutex.RUnlock()
+
+	if sent < len(messages) {
+		log.Printf("[WS] Could not deliver %d queued messages to user %s, requeueing them", len(messages)-sent, client.userID)
+		for _, q := range messages[sent:] {
+			h.queueMessage(client.userID, []byte(q.payload))
+		}
+	}
+	if sent > 0 {
+		log.Printf("[WS] Delivered %d queued messages to user %s", sent, client.userID)
+	}
+}
+
+// updateQueuedMessage rewrites the queued copies of a chat message after it was edited or
+// deleted, and the reply previews quoting it, so a recipient who was offline never gets
+// content that is gone. A deleted message arrives as its tombstone.
+func (s *ChatService) updateQueuedMessage(msg *ChatMessage) error {
+	var editedAt *string
+	if msg.EditedAt != nil {
+		formatted := formatMessageTimestamp(*msg.EditedAt)
+		editedAt = &formatted
+	}
+
+	_, err := s.DB.Exec(`
+        UPDATE undelivered_messages
+        SET payload = json_set(
+            CASE WHEN ? THEN json_remove(payload, '$.data.attachments') ELSE payload END,
+            '$.data.content', ?, '$.data.edited', json(?), '$.data.edited_at', ?, '$.data.deleted', json(?))
+        WHERE json_valid(payload)
+            AND json_extract(payload, '$.type') IN ('chat', 'gif')
+            AND json_extract(payload, '$.data.id') = ?
+    `, msg.Deleted, msg.Content, strconv.FormatBool(msg.Edited), editedAt,
+		strconv.FormatBool(msg.Deleted), msg.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`
+        UPDATE undelivered_messages
+        SET payload = json_set(payload, '$.data.reply_to.content', ?, '$.data.reply_to.deleted', json(?))
+        WHERE json_valid(payload)
+            AND json_extract(payload, '$.type') IN ('chat', 'gif')
+            AND json_extract(payload, '$.data.reply_to.id') = ?
+    `, msg.Content, strconv.FormatBool(msg.Deleted), msg.ID)
+	return err
+}
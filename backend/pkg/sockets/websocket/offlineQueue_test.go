@@ -0,0 +1,64 @@
+package websocket_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"social-network/pkg/sockets/websocket"
+	"testing"
+)
+
+// queuedChatMessage reads back a chat message queued for userID
+func queuedChatMessage(t *testing.T, db *sql.DB, userID string, id int64) websocket.ChatMessage {
+	t.Helper()
+
+	var payload string
+	if err := db.QueryRow("SELECT payload FROM undelivered_messages WHERE user_id = ? AND id = ?", userID, id).Scan(&payload); err != nil {
+		t.Fatalf("Failed to read queued message: %v", err)
+	}
+	var queued struct {
+		Data websocket.ChatMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(payload), &queued); err != nil {
+		t.Fatalf("Failed to decode queued message: %v", err)
+	}
+	return queued.Data
+}
+
+func TestQueuedMessagesFollowEditsAndDeletes(t *testing.T) {
+	db := setupChatDB(t)
+	service := websocket.NewChatService(db)
+
+	var messageID string
+	if err := db.QueryRow("SELECT id FROM messages").Scan(&messageID); err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+
+	// bob was offline: the message and a reply quoting it wait in his queue
+	message := fmt.Sprintf(`{"type":"chat","data":{"id":%q,"sender_id":"alice","content":"hello"}}`, messageID)
+	reply := fmt.Sprintf(`{"type":"chat","data":{"id":"999","sender_id":"alice","content":"see above","reply_to":{"id":%q,"content":"hello"}}}`, messageID)
+	_, err := db.Exec("INSERT INTO undelivered_messages (id, user_id, payload) VALUES (1, 'bob', ?), (2, 'bob', ?)", message, reply)
+	if err != nil {
+		t.Fatalf("Failed to queue messages: %v", err)
+	}
+
+	if _, err := service.EditMessage(messageID, "alice", "hello again"); err != nil {
+		t.Fatalf("EditMessage failed: %v", err)
+	}
+	queued := queuedChatMessage(t, db, "bob", 1)
+	if queued.Content != "hello again" || !queued.Edited {
+		t.Errorf("expected the queued message to be edited, got %+v", queued)
+	}
+
+	if _, err := service.DeleteMessage(messageID, "alice"); err != nil {
+		t.Fatalf("DeleteMessage failed: %v", err)
+	}
+	queued = queuedChatMessage(t, db, "bob", 1)
+	if queued.Content != websocket.DeletedMessageText || !queued.Deleted {
+		t.Errorf("expected the queued message to be a tombstone, got %+v", queued)
+	}
+	quoting := queuedChatMessage(t, db, "bob", 2)
+	if quoting.ReplyTo == nil || quoting.ReplyTo.Content != websocket.DeletedMessageText || !quoting.ReplyTo.Deleted {
+		t.Errorf("expected the reply preview to be a tombstone, got %+v", quoting.ReplyTo)
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"log"
+	"social-network/pkg/db"
+	"strconv"
+	"time"
+)
+
+// SendPostMentionNotification notifies the users mentioned with @nickname in a post
+func SendPostMentionNotification(hub *Hub, authorID string, mentionedIDs []string, postID string) {
+	var authorName string
+	err := db.DB.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", authorID).Scan(&authorName)
+	if err != nil {
+		log.Printf("error getting author name: %v", err)
+		authorName = "Someone"
+	}
+	message := authorName + " mentioned you in a post"
+
+	for _, userID := range mentionedIDs {
+		notification := Notification{
+			UserID:   userID,
+			SenderID: authorID,
+			Type:     "post_mention",
+			RefID:    postID,
+			IsRead:   false,
+			Message:  message,
+		}
+
+		notificationID, err := CreateNotificationAndGetID(db.DB, notification)
+		if err != nil {
+			log.Printf("Error creating post mention notification: %v", err)
+			continue
+		}
+
+		notificationMsg := NotificationMessage{
+			ID:           strconv.Itoa(notificationID),
+			SenderID:     authorID,
+			RecipientID:  userID,
+			Type:         "post_mention",
+			RefID:        postID,
+			Message:      message,
+			Timestamp:    time.Now(),
+			SenderAvatar: GetSenderAvatar(db.DB, authorID, "post_mention"),
+		}
+
+		hub.SendNotificationToUser(userID, notificationMsg)
+	}
+}
+
+// SendPostCommentNotification notifies a post's author that someone commented on their post
+func SendPostCommentNotification(hub *Hub, commenterID, postAuthorID, postID string) {
+	var commenterName string
+	err := db.DB.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", commenterID).Scan(&commenterName)
+	if err != nil {
+		log.Printf("error getting commenter name: %v", err)
+		commenterName = "Someone"
+	}
+	message := commenterName + " commented on your post"
+
+	notification := Notification{
+		UserID:   postAuthorID,
+		SenderID: commenterID,
+		Type:     "post_comment",
+		RefID:    postID,
+		IsRead:   false,
+		Message:  message,
+	}
+
+	notificationID, err := CreateNotificationAndGetID(db.DB, notification)
+	if err != nil {
+		log.Printf("Error creating post comment notification: %v", err)
+		return
+	}
+
+	notificationMsg := NotificationMessage{
+		ID:           strconv.Itoa(notificationID),
+		SenderID:     commenterID,
+		RecipientID:  postAuthorID,
+		Type:         "post_comment",
+		RefID:        postID,
+		Message:      message,
+		Timestamp:    time.Now(),
+		SenderAvatar: GetSenderAvatar(db.DB, commenterID, "post_comment"),
+	}
+
+	hub.SendNotificationToUser(postAuthorID, notificationMsg)
+}
@@ -0,0 +1,215 @@
+package websocket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// maxPresenceQueryUsers caps how many user IDs a single presence query may ask about
+const maxPresenceQueryUsers = 200
+
+// handlePresenceQuery returns the online/last-seen state of the requested users.
+// Users the requester is not allowed to see are left out of the response.
+func (c *Client) handlePresenceQuery(data interface{}) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[WS] Panic in handlePresenceQuery for user %s: %v", c.userID, r)
+			}
+		}()
+
+		req, err := unmarshalData[PresenceQueryRequest](data)
+		if err != nil {
+			log.Printf("[WS] Error unmarshaling presence query for user %s: %v", c.userID, err)
+			return
+		}
+
+		// Drop blanks and duplicates, then cap the batch size
+		seen := make(map[string]bool)
+		userIDs := make([]string, 0, len(req.UserIDs))
+		for _, id := range req.UserIDs {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+		if len(userIDs) > maxPresenceQueryUsers {
+			userIDs = userIDs[:maxPresenceQueryUsers]
+		}
+
+		visible, err := c.chatService.getPresenceVisibleUsers(c.userID, userIDs)
+		if err != nil {
+			log.Printf("[WS] Error checking presence visibility for user %s: %v", c.userID, err)
+			return
+		}
+
+		allowed := make([]string, 0, len(userIDs))
+		for _, id := range userIDs {
+			if visible[id] {
+				allowed = append(allowed, id)
+			}
+		}
+
+		wsMessage := WSMessage{
+			Type:      TypePresenceQuery,
+			Data:      PresenceQueryResponse{Users: c.hub.GetUserStatuses(allowed)},
+			Timestamp: time.Now(),
+		}
+
+		msgData, err := json.Marshal(wsMessage)
+		if err != nil {
+			log.Printf("[WS] Error marshaling presence query response: %v", err)
+			return
+		}
+
+		c.hub.SendToUser(c.userID, msgData)
+	}()
+}
+
+// GetUserStatus returns whether the user is online and when they were last seen
+func (h *Hub) GetUserStatus(userID string) UserStatusMessage {
+	return h.GetUserStatuses([]string{userID})[0]
+}
+
+// GetUserStatuses returns a snapshot of the presence of the given users. Users that haven't
+// connected since the server started are offline since their stored last-seen time, and
+// users that never connected are reported as offline without a last-seen time.
+func (h *Hub) GetUserStatuses(userIDs []string) []UserStatusMessage {
+	statuses := make([]UserStatusMessage, 0, len(userIDs))
+	var unknown []string
+
+	h.mutex.RLock()
+	for _, id := range userIDs {
+		if status, exists := h.userStatus[id]; exists {
+			statuses = append(statuses, *status)
+			continue
+		}
+		statuses = append(statuses, UserStatusMessage{UserID: id})
+		unknown = append(unknown, id)
+	}
+	h.mutex.RUnlock()
+
+	if len(unknown) == 0 {
+		return statuses
+	}
+	lastSeen, err := h.chatService.getLastSeen(unknown)
+	if err != nil {
+		hubLog().Error("Failed to get last seen times", "error", err)
+		return statuses
+	}
+	for i := range statuses {
+		if !statuses[i].IsOnline && statuses[i].LastSeen.IsZero() {
+			statuses[i].LastSeen = lastSeen[statuses[i].UserID]
+		}
+	}
+
+	return statuses
+}
+
+// saveLastSeen stores when the user was last seen, unless a later time is already stored
+func (s *ChatService) saveLastSeen(userID string, at time.Time) error {
+	formatted := formatMessageTimestamp(at)
+	_, err := s.DB.Exec(`
+        UPDATE users SET last_seen = ?
+        WHERE id = ? AND (last_seen IS NULL OR last_seen <= ?)
+    `, formatted, userID, formatted)
+	return err
+}
+
+// getLastSeen returns the stored last-seen times of those of userIDs that have one
+func (s *ChatService) getLastSeen(userIDs []string) (map[string]time.Time, error) {
+	lastSeen := make(map[string]time.Time)
+	if len(userIDs) == 0 {
+		return lastSeen, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(userIDs)), ",")
+	args := make([]interface{}, 0, len(userIDs))
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+
+	rows, err := s.DB.Query(`SELECT id, last_seen FROM users WHERE last_seen IS NOT NULL AND id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var at sql.NullString
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		if t := parseLastSeen(at); t != nil {
+			lastSeen[id] = *t
+		}
+	}
+
+	return lastSeen, rows.Err()
+}
+
+// parseLastSeen parses a users.last_seen column, nil when it is unset
+func parseLastSeen(column sql.NullString) *time.Time {
+	if !column.Valid {
+		return nil
+	}
+	t, err := parseMessageTimestamp(column.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// getPresenceVisibleUsers reports which of userIDs the user may see the presence of:
+// themselves, anyone they follow or are followed by, and anyone they share a group or chat with
+func (s *ChatService) getPresenceVisibleUsers(userID string, userIDs []string) (map[string]bool, error) {
+	visible := make(map[string]bool)
+	if len(userIDs) == 0 {
+		return visible, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(userIDs)), ",")
+	query := `
+        SELECT id FROM users
+        WHERE id IN (` + placeholders + `) AND (
+            id = ?
+            OR EXISTS(SELECT 1 FROM followers f
+                WHERE (f.follower_id = ? AND f.followee_id = users.id)
+                   OR (f.followee_id = ? AND f.follower_id = users.id))
+            OR EXISTS(SELECT 1 FROM group_memberships gm1
+                JOIN group_memberships gm2 ON gm1.group_id = gm2.group_id
+                WHERE gm1.user_id = ? AND gm2.user_id = users.id)
+            OR EXISTS(SELECT 1 FROM chat_participants cp1
+                JOIN chat_participants cp2 ON cp1.chat_id = cp2.chat_id
+                WHERE cp1.user_id = ? AND cp2.user_id = users.id)
+        )
+    `
+
+	args := make([]interface{}, 0, len(userIDs)+5)
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	args = append(args, userID, userID, userID, userID, userID)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		visible[id] = true
+	}
+
+	return visible, rows.Err()
+}
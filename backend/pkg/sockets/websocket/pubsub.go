@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// PubSub carries the hub's outgoing messages to the instance holding the recipient's
+// connections. SendToUser publishes on the recipient's user channel and broadcasts on the
+// broadcast channel; every instance delivers what it receives to its own clients. With a
+// single backend instance the in-memory LocalPubSub is enough and is the default.
+//
+// The hub subscribes to a user channel when the user's first connection registers on this
+// instance and unsubscribes when the last one goes away. Both calls are made from the
+// hub's Run loop, one at a time and in order.
+type PubSub interface {
+	// Start begins delivering messages of subscribed channels to handler
+	Start(handler func(channel string, message []byte)) error
+	// Publish returns how many instances subscribe to the channel; for a user channel
+	// 0 means the user is connected nowhere
+	Publish(channel string, message []byte) (int, error)
+	Subscribe(channel string) error
+	Unsubscribe(channel string) error
+	Close() error
+}
+
+// Channel names used by the hub
+const (
+	broadcastChannel  = "broadcast"
+	userChannelPrefix = "user:"
+)
+
+func userChannel(userID string) string {
+	return userChannelPrefix + userID
+}
+
+// LocalPubSub delivers published messages straight back to its own hub
+type LocalPubSub struct {
+	handler func(channel string, message []byte)
+
+	mu       sync.RWMutex
+	channels map[string]bool
+}
+
+// NewLocalPubSub returns the in-memory PubSub of a single instance deployment
+func NewLocalPubSub() *LocalPubSub {
+	return &LocalPubSub{channels: make(map[string]bool)}
+}
+
+func (p *LocalPubSub) Start(handler func(channel string, message []byte)) error {
+	p.handler = handler
+	return nil
+}
+
+func (p *LocalPubSub) Publish(channel string, message []byte) (int, error) {
+	p.mu.RLock()
+	subscribed := p.channels[channel]
+	p.mu.RUnlock()
+
+	if !subscribed || p.handler == nil {
+		return 0, nil
+	}
+	p.handler(channel, message)
+	return 1, nil
+}
+
+func (p *LocalPubSub) Subscribe(channel string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.channels[channel] = true
+	return nil
+}
+
+func (p *LocalPubSub) Unsubscribe(channel string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.channels, channel)
+	return nil
+}
+
+func (p *LocalPubSub) Close() error { return nil }
+
+// SetPubSub replaces the hub's PubSub, closing the previous one. It must be called before
+// Run, i.e. before any client registers.
+func (h *Hub) SetPubSub(ps PubSub) error {
+	if err := ps.Start(h.handlePubSubMessage); err != nil {
+		return err
+	}
+	if err := ps.Subscribe(broadcastChannel); err != nil {
+		ps.Close()
+		return err
+	}
+
+	old := h.pubsub
+	h.pubsub = ps
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// Broadcast sends the message to every connected client on every instance
+func (h *Hub) Broadcast(message []byte) {
+	if _, err := h.pubsub.Publish(broadcastChannel, message); err != nil {
+		log.Printf("[WS] Failed to publish broadcast, delivering locally only: %v", err)
+		h.queueBroadcast(message)
+	}
+}
+
+// handlePubSubMessage delivers a message received from the PubSub to this instance's clients
+func (h *Hub) handlePubSubMessage(channel string, message []byte) {
+	switch {
+	case channel == broadcastChannel:
+		h.queueBroadcast(message)
+	case strings.HasPrefix(channel, userChannelPrefix):
+		h.deliverToUser(strings.TrimPrefix(channel, userChannelPrefix), message)
+	default:
+		log.Printf("[WS] Ignoring message on unknown channel %q", channel)
+	}
+}
+
+// queueBroadcast hands the message to the Run loop without blocking the caller
+func (h *Hub) queueBroadcast(message []byte) {
+	select {
+	case h.broadcast <- message:
+	default:
+		log.Printf("[WS] Dropping broadcast message - broadcast channel full")
+	}
+}
+
+// subscribeUser and unsubscribeUser follow the user's first and last connection on this instance
+func (h *Hub) subscribeUser(userID string) {
+	if err := h.pubsub.Subscribe(userChannel(userID)); err != nil {
+		log.Printf("[WS] Failed to subscribe to messages of user %s: %v", userID, err)
+	}
+}
+
+func (h *Hub) unsubscribeUser(userID string) {
+	if err := h.pubsub.Unsubscribe(userChannel(userID)); err != nil {
+		log.Printf("[WS] Failed to unsubscribe from messages of user %s: %v", userID, err)
+	}
+}
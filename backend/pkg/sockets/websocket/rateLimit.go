@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// ChatRateLimit lets a user send Messages chat messages per Per. It works as a token bucket:
+// a full bucket allows a burst of Messages, then it refills continuously at Messages per Per.
+// A limit with Messages <= 0 turns rate limiting off.
+type ChatRateLimit struct {
+	Messages int
+	Per      time.Duration
+}
+
+// DefaultChatRateLimit is the limit of a new hub, see Hub.SetChatRateLimit
+var DefaultChatRateLimit = ChatRateLimit{Messages: 20, Per: 10 * time.Second}
+
+// chatRateLimiter keeps one token bucket per user
+type chatRateLimiter struct {
+	mu      sync.RWMutex
+	limit   ChatRateLimit
+	buckets sync.Map // map[userID]*tokenBucket
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the user's bucket, false when it is empty
+func (l *chatRateLimiter) allow(userID string, now time.Time) bool {
+	l.mu.RLock()
+	limit := l.limit
+	l.mu.RUnlock()
+	if limit.Messages <= 0 || limit.Per <= 0 {
+		return true
+	}
+
+	value, _ := l.buckets.LoadOrStore(userID, &tokenBucket{tokens: float64(limit.Messages), last: now})
+	bucket := value.(*tokenBucket)
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	bucket.refill(limit, now)
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func (b *tokenBucket) refill(limit ChatRateLimit, now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += elapsed.Seconds() * float64(limit.Messages) / limit.Per.Seconds()
+	if b.tokens > float64(limit.Messages) {
+		b.tokens = float64(limit.Messages)
+	}
+	b.last = now
+}
+
+// forget drops the user's bucket once it has refilled; a partly used bucket is kept so that
+// reconnecting doesn't reset the limit
+func (l *chatRateLimiter) forget(userID string, now time.Time) {
+	value, ok := l.buckets.Load(userID)
+	if !ok {
+		return
+	}
+	bucket := value.(*tokenBucket)
+
+	l.mu.RLock()
+	limit := l.limit
+	l.mu.RUnlock()
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	bucket.refill(limit, now)
+	if bucket.tokens >= float64(limit.Messages) {
+		l.buckets.Delete(userID)
+	}
+}
+
+// SetChatRateLimit changes how many chat messages a user may send; existing buckets
+// adopt the new limit as they refill
+func (h *Hub) SetChatRateLimit(limit ChatRateLimit) {
+	h.chatLimiter.mu.Lock()
+	defer h.chatLimiter.mu.Unlock()
+	h.chatLimiter.limit = limit
+}
+
+// allowChatMessage reports whether the client may send another chat message and tells it
+// when it may not
+func (c *Client) allowChatMessage() bool {
+	if c.hub.chatLimiter.allow(c.userID, time.Now()) {
+		return true
+	}
+	c.sendChatError("rate_limit_error", "You are sending messages too fast, please slow down")
+	return false
+}
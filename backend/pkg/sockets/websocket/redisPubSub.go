@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPubSub fans hub messages out between backend instances through Redis pub/sub.
+// Channels are namespaced with the prefix so several deployments can share a server.
+// The client reconnects and resubscribes by itself when the connection drops.
+type RedisPubSub struct {
+	client *redis.Client
+	sub    *redis.PubSub
+	prefix string
+}
+
+const (
+	redisPingTimeout = 5 * time.Second
+	// A Redis server that stops replying fails the command instead of stalling
+	// every SendToUser and Broadcast behind it
+	redisCommandTimeout = 3 * time.Second
+)
+
+// NewRedisPubSub connects to the Redis server at addr to check it is reachable.
+// password may be empty when the server requires no AUTH.
+func NewRedisPubSub(addr, password, prefix string) (*RedisPubSub, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     password,
+		ReadTimeout:  redisCommandTimeout,
+		WriteTimeout: redisCommandTimeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisPingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis ping: %w", err)
+	}
+
+	return &RedisPubSub{
+		client: client,
+		sub:    client.Subscribe(context.Background()),
+		prefix: prefix,
+	}, nil
+}
+
+func (p *RedisPubSub) Start(handler func(channel string, message []byte)) error {
+	messages := p.sub.Channel()
+	go func() {
+		// The channel is closed by Close
+		for msg := range messages {
+			if !strings.HasPrefix(msg.Channel, p.prefix) {
+				log.Printf("[WS] Ignoring Redis message on channel %q", msg.Channel)
+				continue
+			}
+			handler(strings.TrimPrefix(msg.Channel, p.prefix), []byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+func (p *RedisPubSub) Publish(channel string, message []byte) (int, error) {
+	// The reply is the number of subscribers that received the message
+	receivers, err := p.client.Publish(context.Background(), p.prefix+channel, message).Result()
+	return int(receivers), err
+}
+
+func (p *RedisPubSub) Subscribe(channel string) error {
+	return p.sub.Subscribe(context.Background(), p.prefix+channel)
+}
+
+func (p *RedisPubSub) Unsubscribe(channel string) error {
+	return p.sub.Unsubscribe(context.Background(), p.prefix+channel)
+}
+
+func (p *RedisPubSub) Close() error {
+	subErr := p.sub.Close()
+	if err := p.client.Close(); err != nil {
+		return err
+	}
+	return subErr
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// NotifyContentReported tells the moderators, admins and creator of a group that a post or
+// comment in it was reported ("content_report"). Those who muted the group are left out,
+// and so is the reporter.
+func (h *Hub) NotifyContentReported(db *sql.DB, groupID, reporterID, targetType string) {
+	var reporterName, groupName string
+	err := db.QueryRow("SELECT first_name || ' ' || last_name FROM users WHERE id = ?", reporterID).Scan(&reporterName)
+	if err != nil {
+		log.Printf("error getting reporter name: %v", err)
+		return
+	}
+	err = db.QueryRow("SELECT title FROM groups WHERE id = ?", groupID).Scan(&groupName)
+	if err != nil {
+		log.Printf("error getting group name: %v", err)
+		return
+	}
+
+	rows, err := db.Query(`
+        SELECT g.creator_id FROM groups g WHERE g.id = ?
+        UNION
+        SELECT gm.user_id FROM group_memberships gm
+        WHERE gm.group_id = ? AND gm.role IN ('moderator', 'admin')
+    `, groupID, groupID)
+	if err != nil {
+		log.Printf("error getting group moderators: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			log.Printf("error scanning user ID: %v", err)
+			continue
+		}
+		if userID == reporterID {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("error iterating group moderators: %v", err)
+		return
+	}
+
+	messageText := fmt.Sprintf("%s reported a %s in '%s'", reporterName, targetType, groupName)
+
+	for _, userID := range userIDs {
+		if groupMutedFor(groupID, userID) {
+			continue
+		}
+
+		notification := Notification{
+			UserID:   userID,
+			SenderID: reporterID,
+			Type:     "content_report",
+			RefID:    groupID,
+			IsRead:   false,
+			Message:  messageText,
+		}
+
+		notificationID, err := CreateNotificationAndGetID(db, notification)
+		if err != nil {
+			log.Printf("Error creating content report notification for user %s: %v", userID, err)
+			continue
+		}
+
+		message := NotificationMessage{
+			ID:           strconv.Itoa(notificationID),
+			SenderID:     reporterID,
+			RecipientID:  userID,
+			Type:         "content_report",
+			RefID:        groupID,
+			Message:      messageText,
+			Timestamp:    time.Now(),
+			SenderAvatar: GetSenderAvatar(db, reporterID, "content_report"),
+		}
+
+		h.SendNotificationToUser(userID, message)
+	}
+}
@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxResyncMessages caps how many missed messages are returned in one resync
+const maxResyncMessages = 500
+
+// handleResyncRequest answers a reconnecting client with everything it missed:
+// the current chat list and presence, plus messages and notifications newer than last_seen
+func (c *Client) handleResyncRequest(data interface{}) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[WS] Panic in handleResyncRequest for user %s: %v", c.userID, r)
+			}
+		}()
+
+		req, err := unmarshalData[ResyncRequest](data)
+		if err != nil {
+			log.Printf("[WS] Error unmarshaling resync request for user %s: %v", c.userID, err)
+			return
+		}
+
+		response := ResyncResponse{
+			Messages:      []ChatMessage{},
+			Notifications: []NotificationMessage{},
+			SyncedAt:      time.Now(),
+		}
+
+		chats, err := c.chatService.userChats(c.userID, DefaultChatListLimit, 0)
+		if err != nil {
+			log.Printf("[WS] Error getting user chats for resync of %s: %v", c.userID, err)
+			return
+		}
+		c.hub.updateChatsWithOnlineStatus(chats.Chats, c.userID)
+		response.Chats = chats.Chats
+		response.OnlineUsers = c.hub.GetOnlineUsers(c.userID)
+
+		if req.LastSeen.IsZero() {
+			// No cursor: the chat list already carries unread counts, only send unread notifications
+			notifications, err := GetUnreadNotificationsByUserID(c.chatService.DB, c.userID)
+			if err != nil {
+				log.Printf("[WS] Error getting notifications for resync of %s: %v", c.userID, err)
+				return
+			}
+			response.Notifications = append(response.Notifications, notifications...)
+		} else {
+			messages, err := c.chatService.GetUserMessagesSince(c.userID, req.LastSeen, maxResyncMessages+1)
+			if err != nil {
+				log.Printf("[WS] Error getting missed messages for resync of %s: %v", c.userID, err)
+				return
+			}
+			if len(messages) > maxResyncMessages {
+				response.HasMore = true
+				messages = messages[:maxResyncMessages]
+			}
+			response.Messages = append(response.Messages, messages...)
+
+			notifications, err := GetNotificationsSince(c.chatService.DB, c.userID, req.LastSeen)
+			if err != nil {
+				log.Printf("[WS] Error getting missed notifications for resync of %s: %v", c.userID, err)
+				return
+			}
+			response.Notifications = append(response.Notifications, notifications...)
+		}
+
+		wsMessage := WSMessage{
+			Type:      TypeResync,
+			Data:      response,
+			Timestamp: response.SyncedAt,
+		}
+
+		msgData, err := json.Marshal(wsMessage)
+		if err != nil {
+			log.Printf("[WS] Error marshaling resync response: %v", err)
+			return
+		}
+
+		c.hub.SendToUser(c.userID, msgData)
+	}()
+}
+
+// GetUserMessagesSince returns messages from all of the user's chats created after since, oldest first
+func (s *ChatService) GetUserMessagesSince(userID string, since time.Time, limit int) ([]ChatMessage, error) {
+	query := `
+		SELECT m.id, m.chat_id, m.sender_id, u.first_name || ' ' || u.last_name as sender_name,
+			COALESCE(u.avatar_path, '') as sender_avatar, ` + messageContentColumn + `, m.message_type, m.created_at,
+			CASE WHEN mr.message_id IS NOT NULL THEN 1 ELSE 0 END as is_read,
+			COALESCE(ct.group_id, '') as group_id, m.edited_at, m.deleted_at, m.reply_to_id
+		FROM messages m
+		JOIN chat_participants cp ON m.chat_id = cp.chat_id AND cp.user_id = ?
+		JOIN chat_threads ct ON m.chat_id = ct.id
+		JOIN users u ON m.sender_id = u.id
+		LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.user_id = ?
+		WHERE datetime(m.created_at) > datetime(?)
+		ORDER BY datetime(m.created_at) ASC, m.id ASC
+		LIMIT ?
+	`
+
+	rows, err := s.DB.Query(query, userID, userID, since.UTC().Format("2006-01-02 15:04:05"), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missed messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []ChatMessage
+	for rows.Next() {
+		var msg ChatMessage
+		var createdAt string
+		var isRead int
+		var editedAt, deletedAt, replyToID sql.NullString
+
+		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.SenderID, &msg.SenderName,
+			&msg.SenderAvatar, &msg.Content, &msg.MessageType, &createdAt, &isRead, &msg.GroupID,
+			&editedAt, &deletedAt, &replyToID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan missed message: %w", err)
+		}
+
+		msg.Timestamp, err = parseMessageTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := setMessageEdited(&msg, editedAt); err != nil {
+			return nil, err
+		}
+		setMessageDeleted(&msg, deletedAt)
+		setMessageReplyTo(&msg, replyToID)
+
+		msg.IsRead = isRead == 1
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Resynced messages carry the same details as the ones loaded with the chat history
+	if err := s.attachAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := s.attachReplyPreviews(messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,49 @@
+package websocket_test
+
+import (
+	"social-network/pkg/sockets/websocket"
+	"testing"
+	"time"
+)
+
+func TestResyncedMessagesCarryDetails(t *testing.T) {
+	db := setupChatDB(t)
+	service := websocket.NewChatService(db)
+
+	var messageID string
+	if err := db.QueryRow("SELECT id FROM messages").Scan(&messageID); err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO message_media (message_id, file_path, media_type, size) VALUES (?, 'uploads/a.png', 'image/png', 10)", messageID); err != nil {
+		t.Fatalf("Failed to attach media: %v", err)
+	}
+	if _, err := service.EditMessage(messageID, "alice", "hello again"); err != nil {
+		t.Fatalf("EditMessage failed: %v", err)
+	}
+	_, _, err := service.SaveMessageAndGetIDs(&websocket.ChatMessage{
+		SenderID:    "bob",
+		RecipientID: "alice",
+		Content:     "hi",
+		MessageType: "text",
+		Timestamp:   time.Now(),
+		ReplyToID:   &messageID,
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to save reply: %v", err)
+	}
+
+	messages, err := service.GetUserMessagesSince("bob", time.Now().Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("GetUserMessagesSince failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 missed messages, got %d", len(messages))
+	}
+	original, reply := messages[0], messages[1]
+	if !original.Edited || len(original.Attachments) != 1 {
+		t.Errorf("expected the edited message with its attachment, got %+v", original)
+	}
+	if reply.ReplyTo == nil || reply.ReplyTo.ID != messageID {
+		t.Errorf("expected the reply preview of %s, got %+v", messageID, reply.ReplyTo)
+	}
+}
@@ -2,8 +2,9 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"social-network/pkg/logging"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -23,20 +24,26 @@ type Client struct {
 	send        chan []byte
 	userID      string
 	chatService *ChatService
+
+	// Close frame writePump sends once send is closed, set before closing it; empty by default
+	closeMessage []byte
+
+	// Logs with the user and the ID of the request that opened the connection
+	logger *slog.Logger
 }
 
 // client to server
 func (c *Client) readPump() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[WS] Panic in readPump for user %s: %v", c.userID, r)
+			c.logger.Error("Panic in readPump", "panic", r)
 		}
 
 		// Ensure unregistration happens
 		select {
 		case c.hub.unregister <- c:
 		default:
-			log.Printf("[WS] Failed to unregister client %s - channel full", c.userID)
+			c.logger.Warn("Failed to unregister client, channel full")
 		}
 
 		c.conn.Close()
@@ -53,20 +60,20 @@ func (c *Client) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[WS] Unexpected close error for user %s: %v", c.userID, err)
+				c.logger.Warn("Unexpected close error", "error", err)
 			} else {
-				log.Printf("[WS] Connection closed for user %s: %v", c.userID, err)
+				c.logger.Info("Connection closed", "error", err)
 			}
 			break
 		}
 
-		log.Printf("[WS] Message received from user %s: %s", c.userID, string(message))
+		c.logger.Debug("Message received", "bytes", len(message))
 
 		// Handle message in a goroutine to prevent blocking
 		go func(msg []byte) {
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("[WS] Panic in handleMessage for user %s: %v", c.userID, r)
+					c.logger.Error("Panic in handleMessage", "panic", r)
 				}
 			}()
 			c.handleMessage(msg)
@@ -79,7 +86,7 @@ func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[WS] Panic in writePump for user %s: %v", c.userID, r)
+			c.logger.Error("Panic in writePump", "panic", r)
 		}
 		ticker.Stop()
 		c.conn.Close()
@@ -90,18 +97,22 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := c.closeMessage
+				if closeMessage == nil {
+					closeMessage = []byte{}
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
-				log.Printf("[WS] Error getting writer for user %s: %v", c.userID, err)
+				c.logger.Error("Failed to get writer", "error", err)
 				return
 			}
 
 			if _, err := w.Write(message); err != nil {
-				log.Printf("[WS] Error writing message for user %s: %v", c.userID, err)
+				c.logger.Error("Failed to write message", "error", err)
 				w.Close()
 				return
 			}
@@ -119,14 +130,14 @@ func (c *Client) writePump() {
 			}
 
 			if err := w.Close(); err != nil {
-				log.Printf("[WS] Error closing writer for user %s: %v", c.userID, err)
+				c.logger.Error("Failed to close writer", "error", err)
 				return
 			}
 
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Printf("[WS] Error sending ping to user %s: %v", c.userID, err)
+				c.logger.Warn("Failed to send ping", "error", err)
 				return
 			}
 		}
@@ -136,7 +147,7 @@ func (c *Client) writePump() {
 func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, userID string) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("[WS] Upgrade error: %v", err)
+		logging.FromContext(r.Context(), "websocket").Warn("Upgrade failed", logging.KeyUserID, userID, "error", err)
 		return
 	}
 
@@ -146,27 +157,44 @@ func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, userID string) {
 		send:        make(chan []byte, 512), // Increased buffer size
 		userID:      userID,
 		chatService: hub.chatService,
+		logger: logging.Handler("websocket").With(
+			logging.KeyRequestID, logging.RequestID(r.Context()),
+			logging.KeyUserID, userID,
+		),
+	}
+
+	// A stopped hub no longer registers clients
+	select {
+	case <-hub.stop:
+		conn.WriteControl(websocket.CloseMessage, shutdownCloseMessage(), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	default:
 	}
 
 	// Register client with timeout
 	select {
 	case client.hub.register <- client:
-		log.Printf("[WS] Client registered successfully: %s", userID)
+		client.logger.Info("Client registered")
 	case <-time.After(5 * time.Second):
-		log.Printf("[WS] Failed to register client - timeout for user: %s", userID)
+		client.logger.Warn("Failed to register client, timed out")
 		conn.Close()
 		return
 	}
 
 	// Start the pumps
-	go client.writePump()
+	hub.writers.Add(1)
+	go func() {
+		defer hub.writers.Done()
+		client.writePump()
+	}()
 	client.readPump() // This blocks until connection closes
 }
 
 func (c *Client) handleUserStatusUpdate(data interface{}) {
 	statusMsg, err := unmarshalData[UserStatusMessage](data)
 	if err != nil {
-		log.Printf("[WS] Error unmarshaling user status update: %v", err)
+		c.logger.Warn("Failed to unmarshal user status update", "error", err)
 		return
 	}
 
@@ -182,7 +210,7 @@ func (c *Client) handleOnlineUsersRequest(data interface{}) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WS] Panic in handleOnlineUsersRequest for user %s: %v", c.userID, r)
+				c.logger.Error("Panic in handleOnlineUsersRequest", "panic", r)
 			}
 		}()
 		c.hub.SendOnlineUsersToUser(c.userID)
@@ -194,18 +222,28 @@ func (c *Client) handleChatListRequest(data interface{}) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WS] Panic in handleChatListRequest for user %s: %v", c.userID, r)
+				c.logger.Error("Panic in handleChatListRequest", "panic", r)
 			}
 		}()
 
-		chats, err := c.chatService.GetUserChats(c.userID)
+		// Paging is optional, a request without data gets the first page
+		req := &ChatListRequest{}
+		if data != nil {
+			var err error
+			if req, err = unmarshalData[ChatListRequest](data); err != nil {
+				c.logger.Warn("Failed to unmarshal chat list request", "error", err)
+				return
+			}
+		}
+
+		chats, err := c.chatService.userChats(c.userID, req.Limit, req.Offset)
 		if err != nil {
-			log.Printf("[WS] Error getting user chats for %s: %v", c.userID, err)
+			c.logger.Error("Failed to get user chats", "error", err)
 			return
 		}
 
 		// Update online status for each chat
-		c.hub.updateChatsWithOnlineStatus(chats, c.userID)
+		c.hub.updateChatsWithOnlineStatus(chats.Chats, c.userID)
 
 		c.hub.SendChatListToUser(c.userID, chats)
 	}()
@@ -216,20 +254,20 @@ func (c *Client) handleChatMessagesRequest(data interface{}) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WS] Panic in handleChatMessagesRequest for user %s: %v", c.userID, r)
+				c.logger.Error("Panic in handleChatMessagesRequest", "panic", r)
 			}
 		}()
 
 		req, err := unmarshalData[ChatMessagesRequest](data)
 		if err != nil {
-			log.Printf("[WS] Error unmarshaling chat messages request for user %s: %v", c.userID, err)
+			c.logger.Warn("Failed to unmarshal chat messages request", "error", err)
 			c.sendChatMessagesError("Invalid request format")
 			return
 		}
 
 		// Validate required fields
 		if req.ChatID == "" {
-			log.Printf("[WS] Chat ID missing in request from user %s", c.userID)
+			c.logger.Warn("Chat ID missing in chat messages request")
 			c.sendChatMessagesError("Chat ID is required")
 			return
 		}
@@ -245,13 +283,13 @@ func (c *Client) handleChatMessagesRequest(data interface{}) {
 		// Check if user is a participant of the chat
 		isParticipant, err := c.chatService.IsUserChatParticipant(c.userID, req.ChatID)
 		if err != nil {
-			log.Printf("[WS] Error checking chat participation for user %s, chat %s: %v", c.userID, req.ChatID, err)
+			c.logger.Error("Failed to check chat participation", "chat_id", req.ChatID, "error", err)
 			c.sendChatMessagesError("Error checking chat access")
 			return
 		}
 
 		if !isParticipant {
-			log.Printf("[WS] User %s is not a participant of chat %s", c.userID, req.ChatID)
+			c.logger.Warn("User is not a participant of the chat", "chat_id", req.ChatID)
 			c.sendChatMessagesError("Access denied: You are not a participant of this chat")
 			return
 		}
@@ -259,7 +297,7 @@ func (c *Client) handleChatMessagesRequest(data interface{}) {
 		// Get chat messages
 		messages, err := c.chatService.GetChatMessages(req.ChatID, req.Limit, req.Offset)
 		if err != nil {
-			log.Printf("[WS] Error getting chat messages for user %s, chat %s: %v", c.userID, req.ChatID, err)
+			c.logger.Error("Failed to get chat messages", "chat_id", req.ChatID, "error", err)
 			c.sendChatMessagesError("Error retrieving chat messages")
 			return
 		}
@@ -267,7 +305,7 @@ func (c *Client) handleChatMessagesRequest(data interface{}) {
 		// Get total message count for pagination
 		total, err := c.chatService.GetChatMessageCount(req.ChatID)
 		if err != nil {
-			log.Printf("[WS] Error getting message count for chat %s: %v", req.ChatID, err)
+			c.logger.Error("Failed to get message count", "chat_id", req.ChatID, "error", err)
 			total = len(messages) // Fallback to current message count
 		}
 
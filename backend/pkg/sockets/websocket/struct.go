@@ -18,6 +18,10 @@ const (
 	TypeGroupInvitation   MessageType = "group_invitation"
 	TypeGroupEventCreated MessageType = "group_event_created"
 	TypeChatMessages      MessageType = "chat_messages" // New message type
+	TypeResync            MessageType = "resync"        // Client reconnect state sync
+	TypePresenceQuery     MessageType = "presence_query"
+	TypeEditMessage       MessageType = "edit_message"
+	TypeDeleteMessage     MessageType = "delete_message"
 )
 
 type WSMessage struct {
@@ -27,17 +31,35 @@ type WSMessage struct {
 }
 
 type ChatMessage struct {
-	ID           string    `json:"id"`
-	ChatID       string    `json:"chat_id"`
-	SenderID     string    `json:"sender_id"`
-	SenderName   string    `json:"sender_name"`
-	SenderAvatar string    `json:"sender_avatar"`
-	Content      string    `json:"content"`
-	MessageType  string    `json:"message_type"` // text, image, Emoji
-	Timestamp    time.Time `json:"timestamp"`
-	IsRead       bool      `json:"is_read"`
-	RecipientID  string    `json:"recipient_id,omitempty"`
-	GroupID      string    `json:"group_id,omitempty"`
+	ID           string              `json:"id"`
+	ChatID       string              `json:"chat_id"`
+	SenderID     string              `json:"sender_id"`
+	SenderName   string              `json:"sender_name"`
+	SenderAvatar string              `json:"sender_avatar"`
+	Content      string              `json:"content"`
+	MessageType  string              `json:"message_type"` // text, image, Emoji
+	Timestamp    time.Time           `json:"timestamp"`
+	IsRead       bool                `json:"is_read"`
+	RecipientID  string              `json:"recipient_id,omitempty"`
+	GroupID      string              `json:"group_id,omitempty"`
+	Edited       bool                `json:"edited"`
+	EditedAt     *time.Time          `json:"edited_at,omitempty"`
+	Deleted      bool                `json:"deleted"` // Content is replaced by a placeholder once deleted
+	Attachments  []MessageAttachment `json:"attachments,omitempty"`
+	ReplyToID    *string             `json:"reply_to_id,omitempty"`
+	ReplyTo      *MessageReply       `json:"reply_to,omitempty"` // Preview of the quoted message, set by the server
+	// Group chats only: how many of the other participants have read the message
+	ReadByCount    int `json:"read_by_count,omitempty"`
+	RecipientCount int `json:"recipient_count,omitempty"`
+}
+
+// MessageAttachment is a file shared in a chat message, stored in message_media
+type MessageAttachment struct {
+	ID        int64  `json:"id"`
+	MessageID string `json:"message_id"`
+	FilePath  string `json:"file_path"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
 }
 
 type TypingMessage struct {
@@ -54,7 +76,16 @@ type UserStatusMessage struct {
 }
 
 type ChatListMessage struct {
-	Chats []ChatRoom `json:"chats"`
+	Chats   []ChatRoom `json:"chats"`
+	Total   int        `json:"total"`
+	Offset  int        `json:"offset"`
+	Limit   int        `json:"limit"`
+	HasMore bool       `json:"has_more"`
+}
+
+type ChatListRequest struct {
+	Limit  int `json:"limit,omitempty"`  // Optional, default 50
+	Offset int `json:"offset,omitempty"` // Optional, default 0
 }
 
 type ChatRoom struct {
@@ -62,21 +93,24 @@ type ChatRoom struct {
 	Type         string       `json:"type"` // private, group
 	Name         string       `json:"name"`
 	Avatar       string       `json:"avatar"`
+	AvatarThumb  string       `json:"avatar_thumb"`
 	Participants []string     `json:"participants"` // User IDs
 	LastMessage  *ChatMessage `json:"last_message,omitempty"`
 	UnreadCount  int          `json:"unread_count"`
 	IsOnline     bool         `json:"is_online"`
 	MemberCount  int          `json:"member_count,omitempty"`
 	GroupID      string       `json:"group_id,omitempty"`
+	LastSeen     *time.Time   `json:"last_seen,omitempty"` // Private chats whose other participant is offline
 }
 
 type MessagesReadMessage struct {
-	ChatID     string   `json:"chat_id"`
-	MessageIDs []string `json:"message_ids"`
-	UserID     string   `json:"user_id"`
+	ChatID     string    `json:"chat_id"`
+	MessageIDs []string  `json:"message_ids"`
+	UserID     string    `json:"user_id"`
+	ReadAt     time.Time `json:"read_at"` // Set by the server, broadcast as the read receipt time
 }
 
-//! Not used?
+// ! Not used?
 type FollowMessage struct {
 	FollowerID   string `json:"follower_id"`
 	FollowingID  string `json:"following_id"`
@@ -135,3 +169,37 @@ type ChatMessagesResponse struct {
 	HasMore  bool          `json:"has_more"`
 	Total    int           `json:"total"`
 }
+
+// EditMessageRequest asks to replace the content of one of the sender's text messages
+type EditMessageRequest struct {
+	MessageID string `json:"message_id"`
+	Content   string `json:"content"`
+}
+
+// DeleteMessageRequest asks to delete a message, by its sender or an admin of the group chat
+type DeleteMessageRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// Presence query for an explicit set of users (e.g. a group members screen)
+type PresenceQueryRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+type PresenceQueryResponse struct {
+	Users []UserStatusMessage `json:"users"`
+}
+
+// Resync is sent by the client after a reconnect with the time it last saw server data
+type ResyncRequest struct {
+	LastSeen time.Time `json:"last_seen"` // Optional, zero means full state
+}
+
+type ResyncResponse struct {
+	Chats         []ChatRoom            `json:"chats"`
+	OnlineUsers   []string              `json:"online_users"`
+	Messages      []ChatMessage         `json:"messages"`
+	Notifications []NotificationMessage `json:"notifications"`
+	HasMore       bool                  `json:"has_more"` // More missed messages than were returned
+	SyncedAt      time.Time             `json:"synced_at"`
+}
@@ -8,12 +8,10 @@ import (
 )
 
 // send chat list to user
-func (h *Hub) SendChatListToUser(userID string, chatList []ChatRoom) {
+func (h *Hub) SendChatListToUser(userID string, chatList *ChatListMessage) {
 	message := WSMessage{
-		Type: TypeChatList,
-		Data: ChatListMessage{
-			Chats: chatList,
-		},
+		Type:      TypeChatList,
+		Data:      chatList,
 		Timestamp: time.Now(),
 	}
 
@@ -27,6 +25,11 @@ func (h *Hub) SendChatListToUser(userID string, chatList []ChatRoom) {
 }
 
 func (h *Hub) SendNotificationToUser(userID string, notification NotificationMessage) {
+	// Types the user turned off are not pushed either
+	if !notificationEnabled(h.chatService.DB, userID, notification.Type) {
+		return
+	}
+
 	notification.SenderAvatar = GetSenderAvatar(h.chatService.DB, notification.SenderID, notification.Type) // <-- Add this
 
 	message := WSMessage{
@@ -41,7 +44,7 @@ func (h *Hub) SendNotificationToUser(userID string, notification NotificationMes
 		return
 	}
 
-	h.SendToUser(userID, data)
+	h.SendToUserOrQueue(userID, data)
 }
 
 func (h *Hub) SendOnlineUsersToUser(userID string) {
@@ -85,8 +88,9 @@ func (h *Hub) SendChatMessagesToUser(userID string, chatMessagesResponse ChatMes
 }
 
 func GetSenderAvatar(db *sql.DB, senderID, notifType string) string {
-	// Special cases for group_kick and group_event_created
-	if notifType == "group_kick" || notifType == "group_event_created" {
+	// Special cases for group_kick and the group event notifications
+	if notifType == "group_kick" || notifType == "group_event_created" ||
+		notifType == "group_event_updated" || notifType == "group_event_cancelled" {
 		return "/images/default-group.png"
 	}
 	var avatar string
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -12,7 +14,7 @@ type ErrorResponse struct {
 }
 
 type SuccessResponse struct {
-	Data   interface{} `json:"data`
+	Data   interface{} `json:"Data"`
 	Status int         `json:"status"`
 }
 
@@ -29,6 +31,15 @@ func WriteErrorJSON(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(errorResp)
 }
 
+// QueryErrorStatus is the status code for a failed database call: 503 when the query ran
+// out of time, so clients know to retry, 500 otherwise
+func QueryErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
 func WriteSuccessJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const avatarMediaPrefix = "/uploads/media/"
+
+// AvatarThumb derives the thumbnail URL for a stored avatar path.
+// Thumbnails live in /uploads/media/thumbs/ under the same file name. The file
+// system isn't checked, list responses are built for many rows: the media file
+// server answers for a missing thumbnail with the full avatar instead.
+func AvatarThumb(avatarPath string) string {
+	if !strings.HasPrefix(avatarPath, avatarMediaPrefix) {
+		return avatarPath
+	}
+
+	return avatarMediaPrefix + "thumbs/" + filepath.Base(avatarPath)
+}
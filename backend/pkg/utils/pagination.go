@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// TotalUnknown can be passed as total by endpoints that do not count their results
+const TotalUnknown = -1
+
+// MaxOffset caps offset pagination; SQLite has to scan and discard every skipped row.
+// Only the home feed has a cursor to go further; other lists stop at this depth.
+const MaxOffset = 1000
+
+type Pagination struct {
+	HasMore    bool   `json:"hasMore"`
+	Total      *int   `json:"total,omitempty"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"` // only set by cursor paginated endpoints
+	Unread     *int   `json:"unread,omitempty"`     // only set by lists that track read state
+}
+
+type PaginatedResponse struct {
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+// WritePaginatedJSON writes a list response in the shared
+// { success, data, pagination: { hasMore, total, offset, limit } } envelope
+func WritePaginatedJSON(w http.ResponseWriter, items interface{}, hasMore bool, total, offset, limit int) {
+	WriteCursorPaginatedJSON(w, items, hasMore, total, offset, limit, "")
+}
+
+// WriteCursorPaginatedJSON is WritePaginatedJSON with the cursor of the next page in pagination.nextCursor
+func WriteCursorPaginatedJSON(w http.ResponseWriter, items interface{}, hasMore bool, total, offset, limit int, nextCursor string) {
+	writePaginated(w, items, newPagination(hasMore, total, offset, limit, nextCursor))
+}
+
+// WriteUnreadPaginatedJSON is WritePaginatedJSON with the number of unread items in pagination.unread
+func WriteUnreadPaginatedJSON(w http.ResponseWriter, items interface{}, hasMore bool, total, unread, offset, limit int) {
+	pagination := newPagination(hasMore, total, offset, limit, "")
+	pagination.Unread = &unread
+	writePaginated(w, items, pagination)
+}
+
+func newPagination(hasMore bool, total, offset, limit int, nextCursor string) Pagination {
+	pagination := Pagination{
+		HasMore:    hasMore,
+		Offset:     offset,
+		Limit:      limit,
+		NextCursor: nextCursor,
+	}
+	if total != TotalUnknown {
+		pagination.Total = &total
+	}
+	return pagination
+}
+
+func writePaginated(w http.ResponseWriter, items interface{}, pagination Pagination) {
+	// Empty lists are sent as [] rather than null
+	if v := reflect.ValueOf(items); !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		items = []interface{}{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PaginatedResponse{
+		Success:    true,
+		Data:       items,
+		Pagination: pagination,
+	})
+}
+
+// RejectDeepOffset writes a 400 and returns true when offset is beyond MaxOffset. hint tells
+// the client how to reach further results from this endpoint, if it can at all.
+func RejectDeepOffset(w http.ResponseWriter, offset int, hint string) bool {
+	if offset <= MaxOffset {
+		return false
+	}
+	if hint == "" {
+		hint = fmt.Sprintf("only the first %d results can be paged through", MaxOffset)
+	}
+	WriteErrorJSON(w, fmt.Sprintf("Offset exceeds the maximum of %d: %s", MaxOffset, hint), http.StatusBadRequest)
+	return true
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"html"
+	"strings"
+)
+
+// SanitizeText prepares user supplied text for storage. Stored text is always HTML-escaped
+// as a whole (&, <, >, ' and "), so it can never be rendered as markup and reads back as
+// exactly what the user typed once the entities are decoded; clients either render it as
+// HTML or decode it. Nothing is stripped. Callers validate the text as typed and sanitize
+// it right before storing it, and searches over sanitized columns escape their terms with
+// SanitizeSearchTerm.
+func SanitizeText(s string) string {
+	return html.EscapeString(strings.TrimSpace(s))
+}
+
+// SanitizeSearchTerm escapes a search term like SanitizeText so it matches the stored text
+func SanitizeSearchTerm(s string) string {
+	return html.EscapeString(s)
+}
@@ -0,0 +1,26 @@
+package utils_test
+
+import (
+	"social-network/pkg/utils"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a < b && c > d", "a &lt; b &amp;&amp; c &gt; d"},
+		{"if a<b and c>d", "if a&lt;b and c&gt;d"},
+		{"<b>bold</b> text", "&lt;b&gt;bold&lt;/b&gt; text"},
+		{"<<b>img src=x onerror=alert(1)>", "&lt;&lt;b&gt;img src=x onerror=alert(1)&gt;"},
+		{"<img src=x onerror=alert(1) //", "&lt;img src=x onerror=alert(1) //"},
+		{`it's "quoted"`, "it&#39;s &#34;quoted&#34;"},
+		{"already &lt;escaped&gt;", "already &amp;lt;escaped&amp;gt;"},
+		{"  trimmed  ", "trimmed"},
+	}
+	for _, tt := range tests {
+		if got := utils.SanitizeText(tt.in); got != tt.want {
+			t.Errorf("SanitizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
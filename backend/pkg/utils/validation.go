@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Codes describing why a field failed validation
+const (
+	CodeRequired = "required"
+	CodeTooShort = "too_short"
+	CodeTooLong  = "too_long"
+	CodeInvalid  = "invalid"
+	CodeTaken    = "taken"
+	CodeMismatch = "mismatch"
+)
+
+// ValidationError is a validation failure tied to a single request field
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Err     error  `json:"-"` // underlying sentinel error, if any
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects every field that failed validation so they can be reported together
+type ValidationErrors []ValidationError
+
+// Add records a failure for field
+func (v *ValidationErrors) Add(field, code, message string) {
+	*v = append(*v, ValidationError{Field: field, Code: code, Message: message})
+}
+
+// AddErr records err as the failure for field, keeping it available to errors.Is
+func (v *ValidationErrors) AddErr(field, code string, err error) {
+	*v = append(*v, ValidationError{Field: field, Code: code, Message: err.Error(), Err: err})
+}
+
+// Has reports whether field already has a failure recorded
+func (v ValidationErrors) Has(field string) bool {
+	for _, e := range v {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the collected errors, or nil when there are none
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, e := range v {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Fields maps each failing field to its first message
+func (v ValidationErrors) Fields() map[string]string {
+	fields := make(map[string]string, len(v))
+	for _, e := range v {
+		if _, ok := fields[e.Field]; !ok {
+			fields[e.Field] = e.Message
+		}
+	}
+	return fields
+}
+
+type ValidationErrorResponse struct {
+	Error   string            `json:"error"`
+	Message string            `json:"message"`
+	Status  int               `json:"status"`
+	Errors  map[string]string `json:"errors"`
+	Details ValidationErrors  `json:"details"`
+}
+
+// WriteValidationErrorsJSON writes the failing fields as { "errors": { field: message } } so
+// clients can show each message next to its form field
+func WriteValidationErrorsJSON(w http.ResponseWriter, errs ValidationErrors, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Error:   http.StatusText(statusCode),
+		Message: "Validation failed",
+		Status:  statusCode,
+		Errors:  errs.Fields(),
+		Details: errs,
+	})
+}
@@ -6,12 +6,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"social-network/pkg/db"
 	"social-network/pkg/db/sqlite"
 	"social-network/pkg/handlers"
+	"social-network/pkg/logging"
 	"social-network/pkg/middleware"
 	"social-network/pkg/models/follow"
 	"social-network/pkg/models/post"
@@ -19,6 +22,9 @@ import (
 )
 
 func main() {
+	// JSON log lines, or readable text with debug records when LOG_FORMAT=text
+	logging.Init(os.Stderr, os.Getenv("LOG_FORMAT") == "text")
+
 	// Initialize database with WAL mode
 	dbPath := "./social-network.db"
 	migrationsDir := "./pkg/db/migrations/sqlite"
@@ -29,14 +35,21 @@ func main() {
 	}
 	defer db.Close()
 
+	// Behind a proxy, e.g. TRUSTED_PROXIES=10.0.0.0/8,127.0.0.1, its X-Forwarded-For gives the client IP
+	if s := os.Getenv("TRUSTED_PROXIES"); s != "" {
+		if err := handlers.SetTrustedProxies(strings.Split(s, ",")); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
+
 	// create a new router
 	mux := http.NewServeMux()
 
 	// Setup routes
-	setupRoutes(mux)
+	hub := setupRoutes(mux)
 
-	// Apply CORS middleware
-	corsHandler := middleware.CorsMiddleware(mux)
+	// Apply CORS middleware, and tag every request with an ID for the logs
+	corsHandler := middleware.CorsMiddleware(middleware.RequestIDMiddleware(mux))
 
 	server := &http.Server{
 		Addr:    ":4000",
@@ -68,6 +81,9 @@ func main() {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	// Shutdown doesn't track websocket connections, close them while the DB is still open
+	hub.Stop()
+
 	// WAL checkpoint before closing DB
 	if err := sqlite.WALCheckpoint(db.DB); err != nil {
 		log.Printf("Warning: failed to checkpoint WAL before closing: %v", err)
@@ -81,14 +97,50 @@ func main() {
 	log.Println("Server exited gracefully")
 }
 
-func setupRoutes(mux *http.ServeMux) {
+func setupRoutes(mux *http.ServeMux) *websocket.Hub {
 	// Services initialization
-	// POST SERVICE
-	postService := post.NewPostService(db.DB)
-	postHandler := handlers.NewPostHandler(postService)
-	// WebSocket Hub (create first, since FollowService depends on it)
+	// WebSocket Hub (create first, since PostHandler and FollowService depend on it)
 	hub := websocket.NewHub(db.DB)
+	// With several backend instances, WebSocket messages are fanned out through Redis
+	if redisAddr := os.Getenv("REDIS_ADDR"); redisAddr != "" {
+		pubsub, err := websocket.NewRedisPubSub(redisAddr, os.Getenv("REDIS_PASSWORD"), "social-network:")
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		if err := hub.SetPubSub(pubsub); err != nil {
+			log.Fatalf("Failed to start Redis pub/sub: %v", err)
+		}
+		log.Printf("WebSocket messages fan out through Redis at %s", redisAddr)
+	}
+	// Chat messages a user may send, e.g. CHAT_RATE_LIMIT_MESSAGES=20 per CHAT_RATE_LIMIT_WINDOW=10s
+	chatLimit := websocket.DefaultChatRateLimit
+	if s := os.Getenv("CHAT_RATE_LIMIT_MESSAGES"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Invalid CHAT_RATE_LIMIT_MESSAGES %q: %v", s, err)
+		}
+		chatLimit.Messages = n
+	}
+	if s := os.Getenv("CHAT_RATE_LIMIT_WINDOW"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Invalid CHAT_RATE_LIMIT_WINDOW %q: %v", s, err)
+		}
+		chatLimit.Per = d
+	}
+	hub.SetChatRateLimit(chatLimit)
+	// Clients apply status changes to their chat list; WS_STATUS_CHAT_LIST_REFRESH=true resends whole lists instead
+	if s := os.Getenv("WS_STATUS_CHAT_LIST_REFRESH"); s != "" {
+		enabled, err := strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("Invalid WS_STATUS_CHAT_LIST_REFRESH %q: %v", s, err)
+		}
+		hub.SetStatusChatListRefresh(enabled)
+	}
 	go hub.Run()
+	// POST SERVICE
+	postService := post.NewPostService(db.DB)
+	postHandler := handlers.NewPostHandler(postService, hub)
 	// Follow Service (now with hub as second argument)
 	followService := follow.NewFollowService(db.DB, hub)
 	followHandler := handlers.NewFollowHandler(followService)
@@ -100,7 +152,7 @@ func setupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/upload/media", mediaHandler.UploadMediaHandler)
 
 	// Serve media files (to display the media)
-	mux.Handle("/uploads/media/", http.StripPrefix("/uploads/media/", http.FileServer(http.Dir("./uploads/media/"))))
+	mux.Handle("/uploads/media/", http.StripPrefix("/uploads/media/", handlers.ServeMediaFiles()))
 
 	// Public routes (no auth required)
 	mux.HandleFunc("/api/register", handlers.RegisterHandler)
@@ -114,50 +166,95 @@ func setupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/dev/update-notification-message", handlers.UpdateNotificationMessageHandler)
 	mux.Handle("/api/dev/checkAuth", middleware.AuthMiddleware(http.HandlerFunc(handlers.AuthTestHandler)))
 
+	// Data repair endpoints delete rows, so they only exist when asked for and need a session
+	if os.Getenv("DEV_REPAIR_ENDPOINTS") == "true" {
+		mux.Handle("/api/dev/orphaned-chats", middleware.AuthMiddleware(http.HandlerFunc(handlers.DevOrphanedChatsHandler)))
+		mux.Handle("/api/dev/inconsistent-posts", middleware.AuthMiddleware(http.HandlerFunc(handlers.DevInconsistentPostsHandler)))
+	}
+
 	// WAL management endpoints (development only)
 	http.HandleFunc("/api/dev/wal-status", handlers.WALStatusHandler)
 	http.HandleFunc("/api/dev/wal-checkpoint", handlers.WALCheckpointHandler)
 
 	// Protected routes (auth required)
 	mux.Handle("/api/logout", middleware.AuthMiddleware(http.HandlerFunc(handlers.LogoutHandler)))
+	mux.Handle("/api/change-password", middleware.AuthMiddleware(http.HandlerFunc(handlers.ChangePasswordHandler)))
+	mux.Handle("/api/delete-account", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteAccountHandler)))
+	mux.Handle("/api/sessions", middleware.AuthMiddleware(http.HandlerFunc(handlers.SessionsHandler)))
+	mux.Handle("/api/sessions/revoke", middleware.AuthMiddleware(http.HandlerFunc(handlers.RevokeSessionHandler)))
 	mux.Handle("/api/getUser", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetUserByIDHandler)))
 	mux.Handle("/api/getUser/batch", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetBatchUsersHandler)))
+	mux.Handle("/api/getUser/nickname", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetUserByNicknameHandler)))
 	mux.Handle("/api/dashboard", middleware.AuthMiddleware(http.HandlerFunc(handlers.DashboardHandler)))
 	mux.Handle("/api/edit-profile", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handlers.EditProfileHandler(w, r, *followService)
 	})))
+	mux.Handle("/api/profile", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.PatchProfileHandler(w, r, *followService)
+	})))
+	mux.Handle("/api/profile/visibility", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.ProfileVisibilityHandler(w, r, *followService)
+	})))
 	// -------------------notifications----------------------
 	mux.Handle("/api/notifications", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetNotificationsHandler)))
 	mux.Handle("/api/notifications/create", middleware.AuthMiddleware(handlers.CreateNotificationHandler(hub)))
 	mux.Handle("/api/notifications/read", middleware.AuthMiddleware(http.HandlerFunc(handlers.MarkNotificationAsReadHandler)))
+	mux.Handle("/api/notifications/read-all", middleware.AuthMiddleware(http.HandlerFunc(handlers.MarkAllNotificationsReadHandler)))
+	mux.Handle("/api/notifications/delete", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteNotificationHandler)))
+	mux.Handle("/api/notifications/clear-read", middleware.AuthMiddleware(http.HandlerFunc(handlers.ClearReadNotificationsHandler)))
+	mux.Handle("/api/notifications/preferences", middleware.AuthMiddleware(http.HandlerFunc(handlers.NotificationPreferencesHandler)))
 	// -------------------posts----------------------
 	mux.Handle("/api/posts", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetPosts)))
 	mux.Handle("/api/posts/user", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetUserPosts)))
+	mux.Handle("/api/posts/hashtag", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetPostsByHashtag)))
 	mux.Handle("/api/post/", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetPostByID)))
 	mux.Handle("/api/create-post", middleware.AuthMiddleware(http.HandlerFunc(postHandler.CreatePost)))
 	mux.Handle("/api/edit-post", middleware.AuthMiddleware(http.HandlerFunc(postHandler.EditPost)))
 	mux.Handle("/api/delete-post", middleware.AuthMiddleware(http.HandlerFunc(postHandler.DeletePost)))
+	mux.Handle("/api/restore-post", middleware.AuthMiddleware(http.HandlerFunc(postHandler.RestorePost)))
+	mux.Handle("/api/repost", middleware.AuthMiddleware(http.HandlerFunc(postHandler.RepostPost)))
 	mux.Handle("/api/like/post/", middleware.AuthMiddleware(http.HandlerFunc(postHandler.LikePost)))
+	mux.Handle("/api/react/post", middleware.AuthMiddleware(http.HandlerFunc(postHandler.ReactToPost)))
 	mux.Handle("/api/posts/group", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetGroupPosts)))
+	mux.Handle("/api/feed/prefs", middleware.AuthMiddleware(http.HandlerFunc(postHandler.FeedPrefsHandler)))
+	mux.Handle("/api/feed/mute", middleware.AuthMiddleware(http.HandlerFunc(postHandler.MuteUserPostsHandler)))
+	mux.Handle("/api/feed/unmute", middleware.AuthMiddleware(http.HandlerFunc(postHandler.UnmuteUserPostsHandler)))
 	// -------------------follow----------------------
 	mux.Handle("/api/unfollow", middleware.AuthMiddleware(http.HandlerFunc(followHandler.UnfollowHandler)))
 	mux.Handle("/api/follow/request", middleware.AuthMiddleware(http.HandlerFunc(followHandler.SendFollowRequestHandler)))
 	mux.Handle("/api/follow/accept", middleware.AuthMiddleware(http.HandlerFunc(followHandler.AcceptFollowRequestHandler)))
 	mux.Handle("/api/follow/reject", middleware.AuthMiddleware(http.HandlerFunc(followHandler.RejectFollowRequestHandler)))
+	mux.Handle("/api/follow/cancel", middleware.AuthMiddleware(http.HandlerFunc(followHandler.CancelFollowRequestHandler)))
 	mux.Handle("/api/follow/pending", middleware.AuthMiddleware(http.HandlerFunc(followHandler.GetPendingRequestsHandler)))
+	mux.Handle("/api/follow/incoming", middleware.AuthMiddleware(http.HandlerFunc(followHandler.GetIncomingRequestsHandler)))
+	mux.Handle("/api/follow/remove-follower", middleware.AuthMiddleware(http.HandlerFunc(followHandler.RemoveFollowerHandler)))
+	mux.Handle("/api/follow/status-batch", middleware.AuthMiddleware(http.HandlerFunc(followHandler.FollowStatusBatchHandler)))
 	mux.Handle("/api/user/followers", middleware.AuthMiddleware(http.HandlerFunc(followHandler.GetUserFollowersHandler)))
 	mux.Handle("/api/user/following", middleware.AuthMiddleware(http.HandlerFunc(followHandler.GetUserFollowingHandler)))
+	mux.Handle("/api/block", middleware.AuthMiddleware(http.HandlerFunc(followHandler.BlockUserHandler)))
+	mux.Handle("/api/unblock", middleware.AuthMiddleware(http.HandlerFunc(followHandler.UnblockUserHandler)))
 	// -------------------comment----------------------
 	mux.Handle("/api/comment", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetCommentsByPostIDHandler)))
-	mux.Handle("/api/comment/create", middleware.AuthMiddleware(http.HandlerFunc(handlers.CommentHandler)))
+	mux.Handle("/api/comment/create", middleware.AuthMiddleware(handlers.CommentHandler(hub)))
 	mux.Handle("/api/comment/edit", middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdateCommentHandler)))
 	mux.Handle("/api/comment/delete", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteCommentHandler)))
 	mux.Handle("/api/comment/like", middleware.AuthMiddleware(http.HandlerFunc(handlers.LikeCommentHandler)))
+	// -------------------report----------------------
+	mux.Handle("/api/report/post", middleware.AuthMiddleware(handlers.ReportPostHandler(hub)))
+	mux.Handle("/api/report/comment", middleware.AuthMiddleware(handlers.ReportCommentHandler(hub)))
 	// -------------------group----------------------
-	mux.Handle("/api/group", middleware.AuthMiddleware(http.HandlerFunc(handlers.GroupHandler)))
+	mux.Handle("/api/group", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// POST creates a group, DELETE removes one
+		if r.Method == http.MethodDelete {
+			handlers.DeleteGroupHandler(hub)(w, r)
+			return
+		}
+		handlers.GroupHandler(w, r)
+	})))
 	mux.Handle("/api/group/user", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetUserGroupsHandler)))
 	mux.Handle("/api/group/invitation", middleware.AuthMiddleware(handlers.GroupInvitationHandler(hub)))
 	mux.Handle("/api/group/request", middleware.AuthMiddleware(handlers.GroupRequestHandler(hub)))
+	mux.Handle("/api/group/cancel-request", middleware.AuthMiddleware(handlers.CancelGroupRequestHandler(hub)))
 	mux.Handle("/api/group/pending-requests", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetPendingGroupRequestsHandler)))
 	mux.Handle("/api/group/accept-invitation", middleware.AuthMiddleware(http.HandlerFunc(handlers.AcceptGroupInvitationHandler(hub))))
 	mux.Handle("/api/group/decline-invitation", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeclineGroupInvitationHandler(hub))))
@@ -167,18 +264,34 @@ func setupRoutes(mux *http.ServeMux) {
 	mux.Handle("/api/group/members", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetGroupMembersHandler)))
 	mux.Handle("/api/group/grant-admin", middleware.AuthMiddleware(http.HandlerFunc(handlers.GrantAdminHandler)))
 	mux.Handle("/api/group/revoke-admin", middleware.AuthMiddleware(http.HandlerFunc(handlers.RevokeAdminHandler)))
+	mux.Handle("/api/group/grant-moderator", middleware.AuthMiddleware(http.HandlerFunc(handlers.GrantModeratorHandler)))
+	mux.Handle("/api/group/revoke-moderator", middleware.AuthMiddleware(http.HandlerFunc(handlers.RevokeModeratorHandler)))
 	mux.Handle("/api/group/grant-creator", middleware.AuthMiddleware(http.HandlerFunc(handlers.GrantCreatorHandler)))
 	mux.Handle("/api/group/kick-member", middleware.AuthMiddleware(handlers.KickMemberHandler(hub)))
 	mux.Handle("/api/group/edit", middleware.AuthMiddleware(http.HandlerFunc(handlers.EditGroupHandler)))
-	mux.Handle("/api/group/join", middleware.AuthMiddleware(http.HandlerFunc(handlers.JoinPublicGroupHandler)))
+	mux.Handle("/api/group/join", middleware.AuthMiddleware(handlers.JoinPublicGroupHandler(hub)))
 	mux.Handle("/api/group/leave", middleware.AuthMiddleware(http.HandlerFunc(handlers.LeaveGroupHandler)))
+	mux.Handle("/api/group/mute", middleware.AuthMiddleware(http.HandlerFunc(handlers.MuteGroupHandler)))
+	mux.Handle("/api/group/unmute", middleware.AuthMiddleware(http.HandlerFunc(handlers.UnmuteGroupHandler)))
+	mux.Handle("/api/group/pin-post", middleware.AuthMiddleware(http.HandlerFunc(handlers.PinPostHandler)))
+	mux.Handle("/api/group/reports", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetPendingReportsHandler)))
+	mux.Handle("/api/group/suggested", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetSuggestedGroupsHandler)))
+	mux.Handle("/api/group/discover", middleware.AuthMiddleware(http.HandlerFunc(handlers.DiscoverGroupsHandler)))
 	// -------------------event----------------------
 	mux.Handle("/api/event", middleware.AuthMiddleware(handlers.CreateEventHandler(hub)))
 	mux.Handle("/api/event/response", middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateEventResponseHandler)))
 	mux.Handle("/api/event/group", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetGroupEventsHandler)))
+	mux.Handle("/api/event/edit", middleware.AuthMiddleware(handlers.EditEventHandler(hub)))
+	mux.Handle("/api/event/delete", middleware.AuthMiddleware(handlers.DeleteEventHandler(hub)))
+	mux.Handle("/api/event/attendees", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetEventAttendeesHandler)))
+	mux.Handle("/api/events/me", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetMyUpcomingEventsHandler)))
 	// -------------------chat----------------------
 	mux.Handle("/api/chats", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetUserChatsHandler(hub))))
 	mux.Handle("/api/chats/private", middleware.AuthMiddleware(http.HandlerFunc(handlers.CreatePrivateChatHandler)))
+	mux.Handle("/api/chats/leave", middleware.AuthMiddleware(http.HandlerFunc(handlers.LeaveChatHandler(hub))))
+	mux.Handle("/api/chats/stats", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetChatStatsHandler)))
+	mux.Handle("/api/chats/search", middleware.AuthMiddleware(http.HandlerFunc(handlers.SearchChatMessagesHandler)))
+	mux.Handle("/api/chats/receipts", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetMessageReadReceiptsHandler)))
 	// -------------------search----------------------
 	mux.Handle("/api/search/users", middleware.AuthMiddleware(http.HandlerFunc(handlers.SearchUsersHandler)))
 	mux.Handle("/api/search/groups", middleware.AuthMiddleware(http.HandlerFunc(handlers.SearchGroupsHandler)))
@@ -187,4 +300,6 @@ func setupRoutes(mux *http.ServeMux) {
 
 	// Health check route (pinging the server)
 	mux.HandleFunc("/health", handlers.HealthCheckHandler)
+
+	return hub
 }